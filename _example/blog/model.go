@@ -1,6 +1,11 @@
 package main
 
 import (
+	"context"
+	"flag"
+
+	"github.com/MuhmdHsn313/origin/logging"
+	"github.com/MuhmdHsn313/origin/migrate"
 	"github.com/MuhmdHsn313/origin/orm"
 	"github.com/MuhmdHsn313/origin/repository"
 	"github.com/MuhmdHsn313/origin/service"
@@ -17,8 +22,8 @@ type Blog struct {
 	// Contents holds multilingual content for the blog.
 	Contents []BlogContent `json:"contents" gorm:"foreignKey:BlogID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE"`
 
-	// Owner of the blog.
-	Owner string `json:"owner"`
+	// Owner of the blog, set server-side and never accepted from clients.
+	Owner string `json:"owner" origin:"readonly"`
 
 	// IsPublished indicates if the blog is published.
 	IsPublished bool `json:"is_published" gorm:"default:false;index"`
@@ -31,25 +36,41 @@ type BlogContent struct {
 	// Content holds the text of the blog content.
 	Content string `json:"content"`
 
-	// BlogID is the foreign key linking to the Blog.
-	BlogID uint `json:"blog_id"`
+	// BlogID is the foreign key linking to the Blog, part of the primary key with the language.
+	BlogID uint `json:"blog_id" gorm:"primaryKey"`
 }
 
+// tsOutput makes the example write its TypeScript client instead of serving, see `go generate`.
+var tsOutput = flag.String("ts", "", "write the TypeScript client to this file and exit")
+
+//go:generate go run . -ts ./api.ts
+
 func main() {
+	flag.Parse()
+
 	// Open a GORM SQLite database connection.
 	db, err := gorm.Open(sqlite.Open("test.db"), &gorm.Config{})
 	if err != nil {
 		panic("failed to connect database")
 	}
 
-	// Migrate the schema for Blog and BlogContent models.
-	db.AutoMigrate(&Blog{}, &BlogContent{})
+	// Initialize a logger, any logging.Logger such as logging.Slog(slog.Default()) can be used instead.
+	logger := logging.Logrus(logrus.New())
 
-	// Initialize a logger.
-	logger := logrus.New()
+	// Apply the pending migrations, the first one creates the Blog and BlogContent tables.
+	migrator, err := migrate.New(db, logger, []migrate.Migration{
+		migrate.Models(1, "create_blogs", &Blog{}, &BlogContent{}),
+	})
+	if err != nil {
+		panic(err)
+	}
+	if _, err := migrator.Up(context.Background()); err != nil {
+		panic(err)
+	}
 
-	// Create an Iris server.
+	// Create an Iris server, identifying each request in the logs of the repositories.
 	irisServer := iris.Default()
+	irisServer.UseRouter(logging.Middleware())
 
 	// Create the service engine and repository for Blog.
 	eng := service.CreateEngine[Blog]()
@@ -58,7 +79,24 @@ func main() {
 	// Register API routes under the /api path.
 	api := irisServer.Party("/api")
 	blogService := service.NewModelService[Blog](eng, repo)
-	service.RegisterHandler[Blog](api, blogService)
+	blogRouter := service.RegisterHandler[Blog](api, blogService)
+
+	// Serve the contents of a blog at /api/blog/{blog_id}/contents/{lang}.
+	contentRepo := repository.NewGenericRepository[BlogContent](db, logger)
+	service.RegisterChildHandler[Blog, BlogContent](blogRouter, repo, service.CreateEngine[BlogContent](), contentRepo, service.ChildOptions{
+		RegisterOptions: service.RegisterOptions{IDParam: "lang"},
+	})
+
+	// Serve the OpenAPI document at /openapi.json and a Swagger UI at /docs.
+	service.RegisterOpenAPI(irisServer, service.OpenAPIOptions{Title: "Blog API", UI: service.OpenAPIUISwagger})
+
+	// Generate the TypeScript client from the registered models when requested.
+	if *tsOutput != "" {
+		if err := service.WriteTypeScriptFile(*tsOutput, service.TypeScriptOptions{}); err != nil {
+			panic(err)
+		}
+		return
+	}
 
 	// Start the Iris server.
 	irisServer.Listen(":8080")
@@ -0,0 +1,152 @@
+// Package audit keeps a log of the creations, updates and deletions of the models, recording who changed
+// what: each Entry holds the model and the ID of the record, the operation, the principal and the request
+// that made it, and the changes of the fields, including those of the contents of each language.
+//
+// A Log records the mutations of the repositories it is given to, within their transaction, and serves
+// the entries of a record to the services it is given to, at GET /api/{model}/{id}/_audit:
+//
+//	auditLog := audit.New(db)
+//	repo := repository.NewGenericRepository[Blog](db, logger, repository.WithAuditor(auditLog))
+//	blogService := service.NewModelService[Blog](eng, repo, service.WithAuditLog(auditLog))
+//
+// The entries are stored in the audit_entries table, created by a migration of the application:
+//
+//	migrate.Models(2, "create_audit_entries", &audit.Entry{})
+//
+// The principal of a request is set by the middleware authenticating it, with SetPrincipal.
+package audit
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/MuhmdHsn313/origin/logging"
+	"github.com/MuhmdHsn313/origin/repository"
+	"github.com/kataras/iris/v12"
+	"gorm.io/gorm"
+)
+
+// Operations of the entries.
+const (
+	OperationCreate = "create"
+	OperationUpdate = "update"
+	OperationDelete = "delete"
+	OperationRevert = "revert"
+)
+
+// Entry is the record of a mutation of a model.
+//
+// Fields:
+//   - ID: Unique identifier of the entry, increasing with the order of the entries.
+//   - Model: The snake_case name of the model, such as "blog_post" or "api_key", see repository.ModelName.
+//   - RecordID: The primary key of the record, its values joined by commas when composite (e.g. "42,en").
+//   - Operation: OperationCreate, OperationUpdate, OperationDelete or OperationRevert.
+//   - Principal: The principal that made the mutation, see SetPrincipal, empty when unknown.
+//   - RequestID: The ID of the request that made the mutation, see logging.Middleware, empty when unknown.
+//   - Timestamp: When the mutation was recorded, in UTC.
+//   - Changes: The changes of the fields of the record, see Diff.
+type Entry struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	Model     string    `json:"model" gorm:"type:varchar(100);not null;index:idx_audit_entries_record"`
+	RecordID  string    `json:"record_id" gorm:"type:varchar(255);not null;index:idx_audit_entries_record"`
+	Operation string    `json:"operation" gorm:"type:varchar(10);not null"`
+	Principal string    `json:"principal" gorm:"type:varchar(255)"`
+	RequestID string    `json:"request_id" gorm:"type:varchar(128)"`
+	Timestamp time.Time `json:"timestamp" gorm:"not null;index"`
+	Changes   Changes   `json:"changes" gorm:"type:text"`
+}
+
+// TableName returns the table of the entries, "audit_entries".
+func (Entry) TableName() string {
+	return "audit_entries"
+}
+
+// Log records the mutations of the repositories in the audit_entries table and returns the entries of a record.
+// It implements repository.Auditor and service.AuditLog.
+type Log struct {
+	db *gorm.DB
+	// now returns the time of the entries.
+	now func() time.Time
+}
+
+// New returns a Log whose entries are read from db. They are written with the transaction of each mutation.
+func New(db *gorm.DB) *Log {
+	return &Log{db: db, now: time.Now}
+}
+
+// Record writes the entry of a mutation with tx, the transaction of the mutation, see repository.Auditor.
+// Its principal and request ID are read from the context of tx, which is the context of the request.
+func (log *Log) Record(tx *gorm.DB, mutation repository.Mutation) error {
+	changes, err := Diff(tx, mutation.Before, mutation.After)
+	if err != nil {
+		return err
+	}
+
+	ctx := tx.Statement.Context
+	requestID, _ := logging.FieldsFromContext(ctx)[logging.RequestIDField].(string)
+	entry := Entry{
+		Model:     mutation.Model,
+		RecordID:  mutation.RecordID,
+		Operation: strings.ToLower(mutation.Operation),
+		Principal: PrincipalFromContext(ctx),
+		RequestID: requestID,
+		Timestamp: log.now().UTC(),
+		Changes:   changes,
+	}
+	return tx.Create(&entry).Error
+}
+
+// Entries returns the entries of a record, the most recent first.
+//
+// Parameters:
+//   - ctx: The context of the query, such as the context of the request.
+//   - model: The snake_case name of the model, see repository.ModelName.
+//   - recordID: The ID of the record, see repository.RecordID.
+//   - limit: The number of entries returned, every entry when 0.
+//   - offset: The number of entries skipped.
+//
+// Returns:
+//   - The entries, or an error if they cannot be read.
+func (log *Log) Entries(ctx context.Context, model, recordID string, limit, offset int) ([]Entry, error) {
+	query := log.db.WithContext(ctx).
+		Where("model = ? AND record_id = ?", model, recordID).
+		Order("timestamp DESC, id DESC")
+	if limit > 0 {
+		query = query.Limit(limit)
+	}
+	if offset > 0 {
+		query = query.Offset(offset)
+	}
+
+	entries := make([]Entry, 0)
+	if err := query.Find(&entries).Error; err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// principalContextKey is the context value holding the principal of a request.
+type principalContextKey struct{}
+
+// ContextWithPrincipal returns a copy of ctx holding principal, the user or client making the mutations.
+func ContextWithPrincipal(ctx context.Context, principal string) context.Context {
+	return context.WithValue(ctx, principalContextKey{}, principal)
+}
+
+// PrincipalFromContext returns the principal held by ctx, empty when it holds none.
+func PrincipalFromContext(ctx context.Context) string {
+	principal, _ := ctx.Value(principalContextKey{}).(string)
+	return principal
+}
+
+// SetPrincipal sets the principal of the request served by ctx, recorded by the entries of its mutations.
+// It is meant to be called by the middleware authenticating the request:
+//
+//	app.UseRouter(func(ctx iris.Context) {
+//		audit.SetPrincipal(ctx, userFromToken(ctx))
+//		ctx.Next()
+//	})
+func SetPrincipal(ctx iris.Context, principal string) {
+	ctx.ResetRequest(ctx.Request().WithContext(ContextWithPrincipal(ctx.Request().Context(), principal)))
+}
@@ -0,0 +1,138 @@
+package audit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/MuhmdHsn313/origin/internal/testdb"
+	"github.com/MuhmdHsn313/origin/logging"
+	"github.com/MuhmdHsn313/origin/orm"
+	"github.com/MuhmdHsn313/origin/repository"
+)
+
+// newAuditedRepository returns a repository of APIKey recording its mutations in log, whose clock ticks a second
+// for each entry.
+func newAuditedRepository(t *testing.T) (*repository.GenericRepository[APIKey], *Log) {
+	t.Helper()
+	db := testdb.Open(t, &Entry{}, &APIKey{}, &APIKeyContent{})
+	log := New(db)
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.FixedZone("UTC+2", 2*60*60))
+	log.now = func() time.Time {
+		now = now.Add(time.Second)
+		return now
+	}
+	return repository.NewGenericRepository[APIKey](db, logging.Nop(), repository.WithAuditor(log)), log
+}
+
+func TestLogRecordsTheMutationsOfARepository(t *testing.T) {
+	repo, log := newAuditedRepository(t)
+
+	ctx := ContextWithPrincipal(logging.ContextWithFields(context.Background(),
+		logging.Fields{logging.RequestIDField: "req-1"}), "alice")
+	keys := repo.WithContext(ctx)
+	key := &APIKey{Name: "deploy", Contents: []APIKeyContent{{ContentModel: orm.ContentModel{LanguageID: "en"}, Description: "deploys"}}}
+	if err := keys.Create(key); err != nil {
+		t.Fatal(err)
+	}
+	key.Name = "release"
+	if err := keys.Update(key); err != nil {
+		t.Fatal(err)
+	}
+	// A mutation out of a request has no principal nor request ID.
+	if err := repo.Delete(key.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	// The entries are looked up under the name of the model in its repository.
+	entries, err := log.Entries(context.Background(), repository.ModelName[APIKey](), "1", 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []struct {
+		operation, principal, requestID string
+		changes                         int
+	}{
+		{OperationDelete, "", "", 5},
+		{OperationUpdate, "alice", "req-1", 1},
+		{OperationCreate, "alice", "req-1", 5},
+	}
+	if len(entries) != len(want) {
+		t.Fatalf("recorded %d entries %+v, want %d", len(entries), entries, len(want))
+	}
+	for i, entry := range entries {
+		if entry.Model != "api_key" || entry.RecordID != "1" || entry.Operation != want[i].operation ||
+			entry.Principal != want[i].principal || entry.RequestID != want[i].requestID || len(entry.Changes) != want[i].changes {
+			t.Errorf("entry %d is %+v, want %+v", i, entry, want[i])
+		}
+		if entry.Timestamp.Location() != time.UTC {
+			t.Errorf("entry %d is timestamped in %s, want UTC", i, entry.Timestamp.Location())
+		}
+	}
+	if change := entries[1].Changes[0]; change.Field != "name" || change.Before != "deploy" || change.After != "release" {
+		t.Errorf("the update changed %+v, want the name from deploy to release", change)
+	}
+}
+
+func TestLogEntries(t *testing.T) {
+	repo, log := newAuditedRepository(t)
+	for _, name := range []string{"first", "second"} {
+		if err := repo.Create(&APIKey{Name: name}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, name := range []string{"renamed", "renamed again"} {
+		if err := repo.Update(&APIKey{Model: orm.Model{ID: 1}, Name: name}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name          string
+		model, record string
+		limit, offset int
+		// want are the operations of the entries, the most recent first.
+		want []string
+	}{
+		{"every entry", "api_key", "1", 0, 0, []string{OperationUpdate, OperationUpdate, OperationCreate}},
+		{"limit", "api_key", "1", 1, 0, []string{OperationUpdate}},
+		{"offset", "api_key", "1", 0, 2, []string{OperationCreate}},
+		{"page", "api_key", "1", 1, 1, []string{OperationUpdate}},
+		{"other record", "api_key", "2", 0, 0, []string{OperationCreate}},
+		{"missing record", "api_key", "3", 0, 0, []string{}},
+		// The names splitting the initialisms letter by letter hold no entry.
+		{"other model", "a_p_i_key", "1", 0, 0, []string{}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			entries, err := log.Entries(context.Background(), test.model, test.record, test.limit, test.offset)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if entries == nil {
+				t.Error("Entries returned nil, want an empty list to serve as []")
+			}
+			operations := make([]string, len(entries))
+			for i, entry := range entries {
+				operations[i] = entry.Operation
+			}
+			if len(operations) != len(test.want) {
+				t.Fatalf("Entries returned %q, want %q", operations, test.want)
+			}
+			for i := range operations {
+				if operations[i] != test.want[i] {
+					t.Errorf("Entries returned %q, want %q", operations, test.want)
+				}
+			}
+		})
+	}
+
+	// The most recent entry comes first.
+	entries, err := log.Entries(context.Background(), "api_key", "1", 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if change := entries[0].Changes[0]; change.After != "renamed again" {
+		t.Errorf("the most recent entry changed %+v, want the name to renamed again", change)
+	}
+}
@@ -0,0 +1,228 @@
+package audit
+
+import (
+	"context"
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/MuhmdHsn313/origin/orm"
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+// Change is the change of a field of a record.
+//
+// Fields:
+//   - Field: The JSON name of the field, prefixed by the name of its collection for contents (e.g. "contents.title").
+//   - Language: The language of the content holding the field, empty for the fields of the record itself.
+//   - Before: The value of the field before the mutation, nil when the record or the content did not exist.
+//   - After: The value of the field after the mutation, nil when the record or the content was deleted.
+//   - Redacted: True for writeonly and hidden fields, whose values are not recorded.
+type Change struct {
+	Field    string      `json:"field"`
+	Language string      `json:"language,omitempty"`
+	Before   interface{} `json:"before,omitempty"`
+	After    interface{} `json:"after,omitempty"`
+	Redacted bool        `json:"redacted,omitempty"`
+}
+
+// Changes are the changes of a record, stored as a JSON array.
+type Changes []Change
+
+// Value encodes the changes as JSON, see driver.Valuer.
+func (changes Changes) Value() (driver.Value, error) {
+	if changes == nil {
+		changes = Changes{}
+	}
+	data, err := json.Marshal(changes)
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
+
+// Scan decodes the changes from JSON, see sql.Scanner.
+func (changes *Changes) Scan(value interface{}) error {
+	switch data := value.(type) {
+	case nil:
+		*changes = nil
+		return nil
+	case []byte:
+		return json.Unmarshal(data, changes)
+	case string:
+		return json.Unmarshal([]byte(data), changes)
+	default:
+		return fmt.Errorf("cannot scan %T into audit changes", value)
+	}
+}
+
+// Diff returns the changes of the columns of a record between two versions of it, followed by the changes
+// of its content collections (see orm.IsContentRelation), compared language by language. The timestamps
+// maintained by GORM (such as UpdatedAt) and the keys linking the contents to the record are left out,
+// and the values of the writeonly and hidden fields are redacted (see orm.FieldOptions).
+//
+// Parameters:
+//   - db: The database whose schema of the model is used.
+//   - before: The record before the mutation, nil for a creation.
+//   - after: The record after the mutation, nil for a deletion.
+//
+// Returns:
+//   - The changes, in the order of the fields of the model, or an error if the model cannot be parsed.
+func Diff(db *gorm.DB, before, after interface{}) (Changes, error) {
+	model := after
+	if model == nil {
+		model = before
+	}
+	if model == nil {
+		return nil, nil
+	}
+
+	stmt := &gorm.Statement{DB: db}
+	if err := stmt.Parse(model); err != nil {
+		return nil, err
+	}
+
+	ctx := db.Statement.Context
+	beforeValue, afterValue := recordValue(before), recordValue(after)
+	changes := diffFields(ctx, stmt.Schema.Fields, nil, beforeValue, afterValue, "", "")
+
+	for _, relation := range stmt.Schema.Relationships.HasMany {
+		if !orm.IsContentRelation(relation) {
+			continue
+		}
+		changes = append(changes, diffContents(ctx, relation, beforeValue, afterValue)...)
+	}
+	return changes, nil
+}
+
+// recordValue returns the struct value of a record, an invalid value when it is nil.
+func recordValue(record interface{}) reflect.Value {
+	value := reflect.ValueOf(record)
+	for value.Kind() == reflect.Ptr {
+		if value.IsNil() {
+			return reflect.Value{}
+		}
+		value = value.Elem()
+	}
+	return value
+}
+
+// diffFields returns the changes of the columns of two versions of a struct, either being invalid when missing.
+// The skipped fields, such as the foreign keys of contents, are not compared.
+func diffFields(ctx context.Context, fields []*schema.Field, skipped map[*schema.Field]bool, before, after reflect.Value, prefix, language string) []Change {
+	var changes []Change
+	for _, field := range fields {
+		if field.DBName == "" || skipped[field] || field.AutoCreateTime > 0 || field.AutoUpdateTime > 0 {
+			continue
+		}
+
+		options := orm.ParseFieldOptions(field.StructField)
+		name := prefix + jsonName(field.StructField)
+		var beforeField, afterField interface{}
+		if before.IsValid() {
+			beforeField, _ = field.ValueOf(ctx, before)
+		}
+		if after.IsValid() {
+			afterField, _ = field.ValueOf(ctx, after)
+		}
+		if before.IsValid() && after.IsValid() && equal(beforeField, afterField) {
+			continue
+		}
+
+		change := Change{Field: name, Language: language, Before: beforeField, After: afterField}
+		if !options.IsPublic() {
+			change = Change{Field: name, Language: language, Redacted: true}
+		}
+		changes = append(changes, change)
+	}
+	return changes
+}
+
+// diffContents returns the changes of a content collection of two versions of a record, by language.
+// The languages added or removed have their fields recorded with a nil Before or After.
+func diffContents(ctx context.Context, relation *schema.Relationship, before, after reflect.Value) []Change {
+	languageField := relation.FieldSchema.LookUpField(orm.LanguageField)
+	if languageField == nil {
+		return nil
+	}
+
+	// The keys of the contents hold the same parent and the language, which tells them apart already.
+	skipped := map[*schema.Field]bool{languageField: true}
+	for _, reference := range relation.References {
+		skipped[reference.ForeignKey] = true
+	}
+
+	beforeContents := contentsByLanguage(ctx, relation, languageField, before)
+	afterContents := contentsByLanguage(ctx, relation, languageField, after)
+	languages := make([]string, 0, len(beforeContents)+len(afterContents))
+	for language := range beforeContents {
+		languages = append(languages, language)
+	}
+	for language := range afterContents {
+		if _, ok := beforeContents[language]; !ok {
+			languages = append(languages, language)
+		}
+	}
+	sort.Strings(languages)
+
+	prefix := jsonName(relation.Field.StructField) + "."
+	var changes []Change
+	for _, language := range languages {
+		changes = append(changes, diffFields(ctx, relation.FieldSchema.Fields, skipped,
+			beforeContents[language], afterContents[language], prefix, language)...)
+	}
+	return changes
+}
+
+// contentsByLanguage returns the contents of a collection of a record by language, none when the record is missing.
+func contentsByLanguage(ctx context.Context, relation *schema.Relationship, languageField *schema.Field, record reflect.Value) map[string]reflect.Value {
+	contents := map[string]reflect.Value{}
+	if !record.IsValid() {
+		return contents
+	}
+
+	collection := reflect.Indirect(relation.Field.ReflectValueOf(ctx, record))
+	if collection.Kind() != reflect.Slice {
+		return contents
+	}
+	for i := 0; i < collection.Len(); i++ {
+		content := reflect.Indirect(collection.Index(i))
+		if !content.IsValid() {
+			continue
+		}
+		language, _ := languageField.ValueOf(ctx, content)
+		contents[fmt.Sprint(language)] = content
+	}
+	return contents
+}
+
+// equal reports whether two values of a field are the same, comparing times by instant
+// since a time read back from the database may have another location than the one written.
+func equal(before, after interface{}) bool {
+	if beforeTime, ok := before.(*time.Time); ok && beforeTime != nil {
+		before = *beforeTime
+	}
+	if afterTime, ok := after.(*time.Time); ok && afterTime != nil {
+		after = *afterTime
+	}
+
+	beforeTime, beforeIsTime := before.(time.Time)
+	afterTime, afterIsTime := after.(time.Time)
+	if beforeIsTime && afterIsTime {
+		return beforeTime.Equal(afterTime)
+	}
+	return reflect.DeepEqual(before, after)
+}
+
+// jsonName returns the JSON key of a struct field, its Go name when it has no json tag.
+func jsonName(field reflect.StructField) string {
+	if name := strings.Split(field.Tag.Get("json"), ",")[0]; name != "" && name != "-" {
+		return name
+	}
+	return field.Name
+}
@@ -0,0 +1,160 @@
+package audit
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/MuhmdHsn313/origin/internal/testdb"
+	"github.com/MuhmdHsn313/origin/orm"
+)
+
+// APIKey is named with an initialism, recorded as "api_key" like in its repositories.
+type APIKey struct {
+	orm.Model
+
+	Contents  []APIKeyContent `json:"contents" gorm:"foreignKey:APIKeyID"`
+	Name      string          `json:"name"`
+	Secret    string          `json:"secret" origin:"writeonly"`
+	ExpiresAt *time.Time      `json:"expires_at"`
+}
+
+type APIKeyContent struct {
+	orm.ContentModel
+
+	Description string `json:"description"`
+	APIKeyID    uint   `json:"api_key_id" gorm:"primaryKey"`
+}
+
+func TestDiff(t *testing.T) {
+	db := testdb.Open(t)
+	expiry := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	// The same instant read back from the database in another location.
+	sameExpiry := expiry.In(time.FixedZone("UTC+2", 2*60*60))
+	laterExpiry := expiry.Add(time.Hour)
+
+	content := func(language, description string) APIKeyContent {
+		return APIKeyContent{ContentModel: orm.ContentModel{LanguageID: language}, Description: description, APIKeyID: 1}
+	}
+	key := &APIKey{
+		Model:     orm.Model{ID: 1, UpdatedAt: expiry},
+		Contents:  []APIKeyContent{content("en", "deploys"), content("de", "verteilt")},
+		Name:      "deploy",
+		Secret:    "s3cr3t",
+		ExpiresAt: &expiry,
+	}
+
+	tests := []struct {
+		name          string
+		before, after *APIKey
+		want          Changes
+	}{
+		{
+			name:  "creation",
+			after: key,
+			want: Changes{
+				{Field: "id", After: uint(1)},
+				{Field: "name", After: "deploy"},
+				{Field: "secret", Redacted: true},
+				{Field: "expires_at", After: &expiry},
+				{Field: "contents.description", Language: "de", After: "verteilt"},
+				{Field: "contents.description", Language: "en", After: "deploys"},
+			},
+		},
+		{
+			name:   "deletion",
+			before: key,
+			want: Changes{
+				{Field: "id", Before: uint(1)},
+				{Field: "name", Before: "deploy"},
+				{Field: "secret", Redacted: true},
+				{Field: "expires_at", Before: &expiry},
+				{Field: "contents.description", Language: "de", Before: "verteilt"},
+				{Field: "contents.description", Language: "en", Before: "deploys"},
+			},
+		},
+		{
+			// The timestamps maintained by GORM and the times read back in another location are not changes.
+			name:   "unchanged",
+			before: key,
+			after: &APIKey{
+				Model:     orm.Model{ID: 1, UpdatedAt: laterExpiry},
+				Contents:  []APIKeyContent{content("de", "verteilt"), content("en", "deploys")},
+				Name:      "deploy",
+				Secret:    "s3cr3t",
+				ExpiresAt: &sameExpiry,
+			},
+			want: nil,
+		},
+		{
+			name:   "update",
+			before: key,
+			after: &APIKey{
+				Model:     orm.Model{ID: 1},
+				Contents:  []APIKeyContent{content("en", "releases"), content("fr", "déploie")},
+				Name:      "release",
+				Secret:    "rotated",
+				ExpiresAt: &laterExpiry,
+			},
+			want: Changes{
+				{Field: "name", Before: "deploy", After: "release"},
+				{Field: "secret", Redacted: true},
+				{Field: "expires_at", Before: &expiry, After: &laterExpiry},
+				{Field: "contents.description", Language: "de", Before: "verteilt"},
+				{Field: "contents.description", Language: "en", Before: "deploys", After: "releases"},
+				{Field: "contents.description", Language: "fr", After: "déploie"},
+			},
+		},
+		{
+			name: "no record",
+			want: nil,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// The missing records are given as nil interfaces, as the repositories do, see repository.Mutation.
+			var before, after interface{}
+			if test.before != nil {
+				before = test.before
+			}
+			if test.after != nil {
+				after = test.after
+			}
+			changes, err := Diff(db, before, after)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(changes, test.want) {
+				t.Errorf("Diff =\n%+v\nwant\n%+v", changes, test.want)
+			}
+		})
+	}
+}
+
+func TestChangesValueAndScan(t *testing.T) {
+	changes := Changes{{Field: "name", Before: "deploy", After: "release"}, {Field: "secret", Redacted: true}}
+	value, err := changes.Value()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `[{"field":"name","before":"deploy","after":"release"},{"field":"secret","redacted":true}]`; value != want {
+		t.Errorf("Value = %v, want %s", value, want)
+	}
+
+	for _, scanned := range []interface{}{value, []byte(value.(string))} {
+		var got Changes
+		if err := got.Scan(scanned); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, changes) {
+			t.Errorf("Scan(%T) = %+v, want %+v", scanned, got, changes)
+		}
+	}
+
+	if value, err := Changes(nil).Value(); err != nil || value != "[]" {
+		t.Errorf("the value of no changes is %v, %v, want []", value, err)
+	}
+	if err := new(Changes).Scan(42); err == nil {
+		t.Error("Scan of an integer succeeded")
+	}
+}
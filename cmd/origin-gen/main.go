@@ -0,0 +1,55 @@
+// Command origin-gen generates non-reflective engines for Origin models.
+//
+// It reads the models of the current package and writes named create, update and filter
+// parameter types along with a service.Engine implementation for each of them:
+//
+//	//go:generate go run github.com/MuhmdHsn313/origin/cmd/origin-gen -type Blog
+//
+// The generated NewBlogEngine can then replace service.CreateEngine[Blog]().
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/MuhmdHsn313/origin/codegen"
+)
+
+func main() {
+	typeNames := flag.String("type", "", "comma-separated list of model type names (required)")
+	output := flag.String("output", "", "output file name, defaults to <type>_engine_gen.go")
+	dir := flag.String("dir", ".", "directory of the package declaring the models")
+	flag.Parse()
+
+	if *typeNames == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	types := strings.Split(*typeNames, ",")
+	if *output == "" {
+		*output = strings.ToLower(types[0]) + "_engine_gen.go"
+	}
+
+	source, err := codegen.GenerateEngines(codegen.EngineOptions{
+		Dir:    *dir,
+		Types:  types,
+		Output: *output,
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "origin-gen: %v\n", err)
+		os.Exit(1)
+	}
+
+	path := *output
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(*dir, path)
+	}
+	if err := os.WriteFile(path, source, 0o644); err != nil {
+		fmt.Fprintf(os.Stderr, "origin-gen: %v\n", err)
+		os.Exit(1)
+	}
+}
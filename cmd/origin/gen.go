@@ -0,0 +1,289 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
+
+	"github.com/iancoleman/strcase"
+)
+
+// contentsPrefix marks the fields of the content model in the field arguments, such as "contents.title:string".
+const contentsPrefix = "contents."
+
+// fieldTypes maps the types accepted in field arguments to their Go type, gorm tag and import.
+var fieldTypes = map[string]struct {
+	goType, gormTag, importPath string
+}{
+	"string":  {goType: "string"},
+	"text":    {goType: "string", gormTag: "type:text"},
+	"int":     {goType: "int"},
+	"int64":   {goType: "int64"},
+	"uint":    {goType: "uint"},
+	"bool":    {goType: "bool"},
+	"float":   {goType: "float64"},
+	"float64": {goType: "float64"},
+	"time":    {goType: "time.Time", importPath: "time"},
+	"uuid":    {goType: "uuid.UUID", gormTag: "type:uuid", importPath: "github.com/google/uuid"},
+}
+
+// modelField is a field of a generated model.
+type modelField struct {
+	Name string
+	Type string
+	Tag  string
+}
+
+// modelSource holds the values rendered in the file of a generated model.
+type modelSource struct {
+	Package string
+	Name    string
+	// Key is the JSON name of the foreign key of the content model, such as "blog_id".
+	Key string
+	// StdImports and Imports are the imports required by the field types, besides those of every model.
+	StdImports []string
+	Imports    []string
+	UUID       bool
+	Fields     []modelField
+	Contents   []modelField
+	// HasContents is true when a content model is generated along with the model.
+	HasContents bool
+	// Register is true when the package declares the registrations of a project created by `origin new`.
+	Register bool
+}
+
+// runGen dispatches the generators, only models are generated for now.
+func runGen(args []string) error {
+	if len(args) == 0 || args[0] != "model" {
+		fmt.Fprintln(os.Stderr, "Usage: origin gen model <Name> [name:type ...] [-contents] [-uuid] [-dir .]")
+		return errUsage
+	}
+	return runGenModel(args[1:])
+}
+
+// runGenModel writes a model, its optional content model and the registration of its routes.
+func runGenModel(args []string) error {
+	flags := newFlagSet("gen model", "gen model <Name> [name:type ...] [-contents] [-uuid] [-dir .]\n\n"+
+		"Field types: "+strings.Join(fieldTypeNames(), ", ")+". Fields prefixed with \""+contentsPrefix+
+		"\" belong to the content model, such as "+contentsPrefix+"title:string.\n")
+	contents := flags.Bool("contents", false, "generate a multilingual content model, implied by content fields")
+	useUUID := flags.Bool("uuid", false, "identify the model by a UUID (orm.UUIDModel) instead of an integer")
+	dir := flags.String("dir", ".", "directory of the package receiving the model")
+	force := flags.Bool("force", false, "overwrite the file of the model if it exists")
+	positional, err := parseArgs(flags, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) == 0 {
+		flags.Usage()
+		return errUsage
+	}
+
+	name := strcase.ToCamel(positional[0])
+	if !token.IsIdentifier(name) {
+		return fmt.Errorf("invalid model name %q", positional[0])
+	}
+
+	source := modelSource{Name: name, Key: strcase.ToSnake(name) + "_id", UUID: *useUUID, HasContents: *contents}
+	imports := map[string]bool{}
+	if *useUUID {
+		imports["github.com/google/uuid"] = true
+	}
+	for _, arg := range positional[1:] {
+		field, importPath, isContent, err := parseField(arg)
+		if err != nil {
+			return err
+		}
+		if importPath != "" {
+			imports[importPath] = true
+		}
+		if isContent {
+			source.Contents = append(source.Contents, field)
+			source.HasContents = true
+		} else {
+			source.Fields = append(source.Fields, field)
+		}
+	}
+	// A field belongs to either model, the content fields are served in the contents of the model only.
+	fieldNames := map[string]bool{}
+	for _, field := range source.Fields {
+		fieldNames[field.Name] = true
+	}
+	for _, field := range source.Contents {
+		if fieldNames[field.Name] {
+			return fmt.Errorf("field %s is declared by both %s and %sContent, keep it in one of them", field.Name, name, name)
+		}
+	}
+	if source.HasContents && len(source.Contents) == 0 && !fieldNames["Title"] {
+		source.Contents = []modelField{{Name: "Title", Type: "string", Tag: "`json:\"title\"`"}}
+	}
+	for importPath := range imports {
+		if strings.Contains(importPath, ".") {
+			source.Imports = append(source.Imports, importPath)
+		} else {
+			source.StdImports = append(source.StdImports, importPath)
+		}
+	}
+	sort.Strings(source.StdImports)
+
+	source.Package, source.Register, err = inspectPackage(*dir)
+	if err != nil {
+		return err
+	}
+
+	var content bytes.Buffer
+	if err := modelTemplate.Execute(&content, source); err != nil {
+		return err
+	}
+	formatted, err := format.Source(content.Bytes())
+	if err != nil {
+		return fmt.Errorf("format %s: %w", name, err)
+	}
+
+	target := filepath.Join(*dir, strcase.ToSnake(name)+".go")
+	if _, err := os.Stat(target); err == nil && !*force {
+		return fmt.Errorf("%s already exists, use -force to overwrite it", target)
+	}
+	if err := os.WriteFile(target, formatted, 0o644); err != nil {
+		return err
+	}
+
+	fmt.Println(target)
+	if !source.Register {
+		fmt.Printf("Call register%s(app) to serve the routes of %s.\n", name, name)
+	}
+	return nil
+}
+
+// parseField parses a field argument such as "title:string" or "contents.body:text".
+// It returns the field, the import its type requires and whether it belongs to the content model.
+func parseField(arg string) (field modelField, importPath string, isContent bool, err error) {
+	spec := arg
+	if strings.HasPrefix(spec, contentsPrefix) {
+		spec, isContent = strings.TrimPrefix(spec, contentsPrefix), true
+	}
+
+	fieldName, typeName, ok := strings.Cut(spec, ":")
+	if !ok {
+		typeName = "string"
+	}
+	fieldType, ok := fieldTypes[typeName]
+	if !ok {
+		return modelField{}, "", false, fmt.Errorf("field %q: unknown type %q, expected one of %s", arg, typeName, strings.Join(fieldTypeNames(), ", "))
+	}
+
+	field.Name = strcase.ToCamel(fieldName)
+	if !token.IsIdentifier(field.Name) {
+		return modelField{}, "", false, fmt.Errorf("field %q: invalid name %q", arg, fieldName)
+	}
+	field.Type = fieldType.goType
+	field.Tag = fmt.Sprintf("json:%q", strcase.ToSnake(fieldName))
+	if fieldType.gormTag != "" {
+		field.Tag += fmt.Sprintf(" gorm:%q", fieldType.gormTag)
+	}
+	field.Tag = "`" + field.Tag + "`"
+	return field, fieldType.importPath, isContent, nil
+}
+
+// fieldTypeNames returns the types accepted in field arguments, sorted.
+func fieldTypeNames() []string {
+	names := make([]string, 0, len(fieldTypes))
+	for name := range fieldTypes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// inspectPackage returns the name of the package in dir, "main" when it has no Go files yet,
+// and whether it declares the registrations variable of a project created by `origin new`.
+func inspectPackage(dir string) (string, bool, error) {
+	packages, err := parser.ParseDir(token.NewFileSet(), dir, func(info os.FileInfo) bool {
+		return !strings.HasSuffix(info.Name(), "_test.go")
+	}, parser.SkipObjectResolution)
+	if err != nil {
+		return "", false, err
+	}
+
+	for name, pkg := range packages {
+		for _, file := range pkg.Files {
+			for _, decl := range file.Decls {
+				gen, ok := decl.(*ast.GenDecl)
+				if !ok || gen.Tok != token.VAR {
+					continue
+				}
+				for _, spec := range gen.Specs {
+					for _, ident := range spec.(*ast.ValueSpec).Names {
+						if ident.Name == "registrations" {
+							return name, true, nil
+						}
+					}
+				}
+			}
+		}
+		return name, false, nil
+	}
+	return "main", false, nil
+}
+
+var modelTemplate = template.Must(template.New("model").Parse(`package {{.Package}}
+
+import (
+{{- range .StdImports}}
+	"{{.}}"
+{{- end}}
+{{if .StdImports}}
+{{end}}
+{{- range .Imports}}
+	"{{.}}"
+{{- end}}
+	"github.com/MuhmdHsn313/origin/orm"
+	"github.com/MuhmdHsn313/origin/server"
+	"github.com/kataras/iris/v12/core/router"
+)
+
+// {{.Name}} is the {{.Name}} model.
+type {{.Name}} struct {
+	{{if .UUID}}orm.UUIDModel{{else}}orm.Model{{end}}
+{{range .Fields}}
+	{{.Name}} {{.Type}} {{.Tag}}
+{{- end}}
+{{- if .HasContents}}
+
+	// Contents holds the content of the {{.Name}} in each language.
+	Contents []{{.Name}}Content ` + "`" + `json:"contents" gorm:"foreignKey:{{.Name}}ID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE"` + "`" + `
+{{- end}}
+}
+{{- if .HasContents}}
+
+// {{.Name}}Content is the content of a {{.Name}} in one language.
+type {{.Name}}Content struct {
+	orm.ContentModel
+
+	// {{.Name}}ID is the foreign key linking to the {{.Name}}, part of the primary key with the language.
+	{{.Name}}ID {{if .UUID}}uuid.UUID{{else}}uint{{end}} ` + "`" + `json:"{{.Key}}" gorm:"primaryKey{{if .UUID}};type:uuid{{end}}"` + "`" + `
+{{range .Contents}}
+	{{.Name}} {{.Type}} {{.Tag}}
+{{- end}}
+}
+{{- end}}
+{{- if .Register}}
+
+func init() {
+	registrations = append(registrations, register{{.Name}})
+}
+{{- end}}
+
+// register{{.Name}} registers the routes of the {{.Name}} model on app.
+func register{{.Name}}(app *server.App) router.Party {
+	return server.Register(app, server.ModelOptions[{{.Name}}]{})
+}
+`))
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// scaffoldTest exercises the model generated by a test of TestGenModelScaffold: it writes the migration of the
+// model with migrate.WriteDiff, as `origin migrate diff` does, applies it and serves the model, creating two
+// blogs with an English content each.
+const scaffoldTest = `package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/MuhmdHsn313/origin/logging"
+	"github.com/MuhmdHsn313/origin/migrate"
+	"github.com/MuhmdHsn313/origin/server"
+	"github.com/MuhmdHsn313/origin/server/config"
+)
+
+func TestScaffold(t *testing.T) {
+	cfg := config.Default()
+	cfg.Database.DSN = "sqlite:" + filepath.Join(t.TempDir(), "scaffold.db")
+	app, err := server.New(cfg, server.WithLogger(logging.Nop()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer app.Close()
+	for _, register := range registrations {
+		register(app)
+	}
+
+	dir := t.TempDir()
+	files, err := migrate.WriteDiff(app.DB, dir, "create_blogs")
+	if err != nil || len(files) == 0 {
+		t.Fatalf("WriteDiff = %v, %v, want the migration of the blogs", files, err)
+	}
+	migrations, err := migrate.LoadSQL(os.DirFS(dir), ".")
+	if err != nil {
+		t.Fatal(err)
+	}
+	migrator, err := migrate.New(app.DB, logging.Nop(), migrations)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := migrator.Up(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if err := app.Iris.Build(); err != nil {
+		t.Fatal(err)
+	}
+
+	serve := func(method, path, body string, status int) map[string]interface{} {
+		t.Helper()
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		rec := httptest.NewRecorder()
+		app.Iris.ServeHTTP(rec, req)
+		if rec.Code != status {
+			t.Fatalf("%s %s returned %d %s, want %d", method, path, rec.Code, rec.Body, status)
+		}
+		var decoded map[string]interface{}
+		if err := json.Unmarshal(rec.Body.Bytes(), &decoded); err != nil {
+			t.Fatal(err)
+		}
+		return decoded
+	}
+
+	// Both blogs have an English content, keyed by their blog and their language.
+	var ids []string
+	for _, title := range []string{"first", "second"} {
+		created := serve(http.MethodPost, "/api/blog", ` + "`" + `{"slug":"` + "`" + `+title+` + "`" + `","contents":[{"language_id":"en","title":"` + "`" + `+title+` + "`" + `"}]}` + "`" + `, http.StatusCreated)
+		if _, ok := created["title"]; ok {
+			t.Errorf("the content field title is served on the blog: %v", created)
+		}
+		ids = append(ids, fmt.Sprint(created["id"]))
+	}
+	for i, title := range []string{"first", "second"} {
+		blog := serve(http.MethodGet, "/api/blog/"+ids[i], "", http.StatusOK)
+		contents, _ := blog["contents"].([]interface{})
+		if len(contents) != 1 || contents[0].(map[string]interface{})["title"] != title {
+			t.Errorf("blog %s has contents %v, want its English content %q", ids[i], contents, title)
+		}
+	}
+}
+`
+
+// newTestProject creates a project with `origin new` in a directory of the module, ignored by ./... as its name
+// starts with an underscore, and removes its go.mod so that it builds against the packages of the module.
+func newTestProject(t *testing.T) string {
+	t.Helper()
+	dir, err := os.MkdirTemp(".", "_project")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	if err := runNew([]string{dir, "-module", "example.com/project"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(filepath.Join(dir, "go.mod")); err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+// TestGenModelScaffold builds the projects holding the models generated with and without -uuid, and runs
+// scaffoldTest against them.
+func TestGenModelScaffold(t *testing.T) {
+	if testing.Short() {
+		t.Skip("builds the generated projects")
+	}
+
+	tests := []struct {
+		name string
+		args []string
+	}{
+		{"contents", []string{"Blog", "slug:string", "contents.title:string", "-contents"}},
+		{"uuid", []string{"Blog", "slug:string", "contents.title:string", "-uuid"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dir := newTestProject(t)
+			if err := runGenModel(append(test.args, "-dir", dir)); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(filepath.Join(dir, "scaffold_test.go"), []byte(scaffoldTest), 0o644); err != nil {
+				t.Fatal(err)
+			}
+
+			for _, args := range [][]string{{"vet", "./" + dir}, {"test", "-count=1", "./" + dir}} {
+				if output, err := exec.Command("go", args...).CombinedOutput(); err != nil {
+					t.Fatalf("go %s: %v\n%s", strings.Join(args, " "), err, output)
+				}
+			}
+		})
+	}
+}
+
+func TestGenModelRejectsFieldsOfBothModels(t *testing.T) {
+	dir := t.TempDir()
+	err := runGenModel([]string{"Blog", "title:string", "contents.title:string", "-dir", dir})
+	if err == nil || !strings.Contains(err.Error(), "Title") {
+		t.Errorf("runGenModel = %v, want the error of the field Title declared by both models", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "blog.go")); !os.IsNotExist(err) {
+		t.Errorf("blog.go was written: %v", err)
+	}
+}
@@ -0,0 +1,103 @@
+// Command origin scaffolds and manages Origin applications.
+//
+// Usage:
+//
+//	origin new <dir> [-module path]              create a project skeleton
+//	origin gen model <Name> [fields] [-contents] write a model and its registration
+//	origin migrate <up|down|status|create|diff>  manage the SQL migrations of a project
+//	origin routes [-dir .]                       print the routes of a project
+//
+// Projects created by `origin new` register the models written by `origin gen model` on their
+// own, apply the migrations of their migrations directory on start, and accept the -routes and
+// -diff flags used by `origin routes` and `origin migrate diff`.
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+)
+
+// errUsage is returned by a subcommand called with wrong arguments, after printing its usage.
+var errUsage = errors.New("invalid arguments")
+
+// commands maps the name of each subcommand to its implementation, which receives the remaining arguments.
+var commands = map[string]func(args []string) error{
+	"new":     runNew,
+	"gen":     runGen,
+	"migrate": runMigrate,
+	"routes":  runRoutes,
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+
+	command, ok := commands[os.Args[1]]
+	if !ok {
+		if os.Args[1] != "help" && os.Args[1] != "-h" && os.Args[1] != "--help" {
+			fmt.Fprintf(os.Stderr, "origin: unknown command %q\n", os.Args[1])
+		}
+		usage()
+		os.Exit(2)
+	}
+
+	if err := command(os.Args[2:]); err != nil {
+		if errors.Is(err, flag.ErrHelp) || errors.Is(err, errUsage) {
+			os.Exit(2)
+		}
+		fmt.Fprintf(os.Stderr, "origin: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func usage() {
+	fmt.Fprint(os.Stderr, `Usage: origin <command> [arguments]
+
+Commands:
+  new <dir> [-module path]              create a project skeleton
+  gen model <Name> [fields] [-contents] write a model and its registration
+  migrate <up|down|status|create|diff>  manage the SQL migrations of a project
+  routes [-dir .]                       print the routes of a project
+
+Run "origin <command> -h" for the flags of a command.
+`)
+}
+
+// parseArgs parses the flags of a subcommand wherever they appear among its positional arguments,
+// so that "gen model Blog -contents" and "gen model -contents Blog" are equivalent.
+// It returns the positional arguments in order.
+func parseArgs(flags *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for len(args) > 0 {
+		if err := flags.Parse(args); err != nil {
+			return nil, err
+		}
+		args = flags.Args()
+		if len(args) == 0 {
+			break
+		}
+
+		// A "--" ends the flags, the remaining arguments are all positional.
+		if args[0] == "--" {
+			positional = append(positional, args[1:]...)
+			break
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+	return positional, nil
+}
+
+// newFlagSet creates the flag set of a subcommand, printing the given usage line before its flags.
+func newFlagSet(name, usageLine string) *flag.FlagSet {
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: origin %s\n", usageLine)
+		flags.PrintDefaults()
+	}
+	return flags
+}
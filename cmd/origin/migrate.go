@@ -0,0 +1,135 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/MuhmdHsn313/origin/logging"
+	"github.com/MuhmdHsn313/origin/migrate"
+	"github.com/MuhmdHsn313/origin/server"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+const migrateUsage = `migrate <command> [-db dsn] [-dir migrations]
+
+Commands:
+  up [-to version]              apply the pending migrations
+  down [-steps 1] [-to version] revert the last applied migrations, or those newer than version (0 for all)
+  status                        list the migrations and whether they are applied
+  create <name>                 write an empty migration to fill by hand
+  diff <name>                   write the migration of the model changes, runs the project with -diff
+`
+
+// runMigrate manages the SQL migrations of a project.
+func runMigrate(args []string) error {
+	flags := newFlagSet("migrate", migrateUsage)
+	dsn := flags.String("db", os.Getenv("ORIGIN_DATABASE_DSN"), "database DSN, defaults to $ORIGIN_DATABASE_DSN")
+	dir := flags.String("dir", "migrations", "directory of the SQL migrations")
+	to := flags.Int64("to", 0, "version to migrate up or down to")
+	steps := flags.Int("steps", 1, "number of migrations reverted by down")
+	project := flags.String("project", ".", "directory of the project run by diff")
+	positional, err := parseArgs(flags, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) == 0 {
+		flags.Usage()
+		return errUsage
+	}
+
+	command, positional := positional[0], positional[1:]
+	switch command {
+	case "create":
+		if len(positional) != 1 {
+			flags.Usage()
+			return errUsage
+		}
+		files, err := migrate.WriteSQL(*dir, positional[0], nil, nil)
+		if err != nil {
+			return err
+		}
+		fmt.Println(strings.Join(files, "\n"))
+		return nil
+	case "diff":
+		if len(positional) != 1 {
+			flags.Usage()
+			return errUsage
+		}
+		projectArgs := []string{"-diff", positional[0]}
+		if *dsn != "" {
+			projectArgs = append(projectArgs, "-database.dsn", *dsn)
+		}
+		return runProject(*project, projectArgs...)
+	case "up", "down", "status":
+	default:
+		flags.Usage()
+		return errUsage
+	}
+
+	if *dsn == "" {
+		return fmt.Errorf("no database, set -db or $ORIGIN_DATABASE_DSN")
+	}
+	db, err := server.OpenDatabase(*dsn, &gorm.Config{Logger: logger.Default.LogMode(logger.Warn)})
+	if err != nil {
+		return err
+	}
+	migrations, err := migrate.LoadSQL(os.DirFS(*dir), ".")
+	if err != nil {
+		return err
+	}
+	migrator, err := migrate.New(db, logging.Logrus(logrus.New()), migrations)
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	switch command {
+	case "up":
+		applied, err := migrator.UpTo(ctx, *to)
+		if err == nil && len(applied) == 0 {
+			fmt.Println("No pending migration.")
+		}
+		return err
+	case "down":
+		// -to 0 reverts every migration, only a missing -to falls back to -steps.
+		toSet := false
+		flags.Visit(func(f *flag.Flag) {
+			toSet = toSet || f.Name == "to"
+		})
+		if toSet {
+			_, err = migrator.DownTo(ctx, *to)
+		} else {
+			_, err = migrator.Down(ctx, *steps)
+		}
+		return err
+	default:
+		statuses, err := migrator.Status(ctx)
+		if err != nil {
+			return err
+		}
+		return writeStatus(statuses)
+	}
+}
+
+// writeStatus prints the state of the migrations as a table.
+func writeStatus(statuses []migrate.Status) error {
+	table := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(table, "VERSION\tNAME\tSTATUS")
+	for _, status := range statuses {
+		state := "pending"
+		switch {
+		case status.Unknown:
+			state = "applied " + status.AppliedAt.Format("2006-01-02 15:04:05") + " (unknown)"
+		case status.Applied:
+			state = "applied " + status.AppliedAt.Format("2006-01-02 15:04:05")
+		}
+		fmt.Fprintf(table, "%d\t%s\t%s\n", status.Version, status.Name, state)
+	}
+	return table.Flush()
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/MuhmdHsn313/origin/logging"
+	"github.com/MuhmdHsn313/origin/migrate"
+	"github.com/MuhmdHsn313/origin/server"
+)
+
+func TestMigrateDown(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"1_create_blogs", "2_create_tags", "3_create_users"} {
+		table := name[len("1_create_"):]
+		files := map[string]string{
+			name + ".up.sql":   "CREATE TABLE " + table + " (id integer primary key);\n",
+			name + ".down.sql": "DROP TABLE " + table + ";\n",
+		}
+		for file, script := range files {
+			if err := os.WriteFile(filepath.Join(dir, file), []byte(script), 0o644); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	dsn := "sqlite:" + filepath.Join(t.TempDir(), "app.db")
+
+	// applied returns the versions of the applied migrations.
+	applied := func() []int64 {
+		t.Helper()
+		db, err := server.OpenDatabase(dsn, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		migrations, err := migrate.LoadSQL(os.DirFS(dir), ".")
+		if err != nil {
+			t.Fatal(err)
+		}
+		migrator, err := migrate.New(db, logging.Nop(), migrations)
+		if err != nil {
+			t.Fatal(err)
+		}
+		statuses, err := migrator.Status(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		var versions []int64
+		for _, status := range statuses {
+			if status.Applied {
+				versions = append(versions, status.Version)
+			}
+		}
+		return versions
+	}
+
+	tests := []struct {
+		name string
+		args []string
+		want int
+	}{
+		{"up", []string{"up"}, 3},
+		{"down one step by default", []string{"down"}, 2},
+		{"down to a version", []string{"down", "-to", "1"}, 1},
+		{"up again", []string{"up"}, 3},
+		{"down to version 0 reverts every migration", []string{"down", "-to", "0"}, 0},
+	}
+	for _, test := range tests {
+		args := append(append([]string(nil), test.args...), "-db", dsn, "-dir", dir)
+		if err := runMigrate(args); err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		if versions := applied(); len(versions) != test.want {
+			t.Errorf("%s left the migrations %v applied, want %d of them", test.name, versions, test.want)
+		}
+	}
+}
@@ -0,0 +1,242 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"os"
+	"path"
+	"path/filepath"
+	"text/template"
+)
+
+// projectFile is a file of the project skeleton, rendered with a project.
+type projectFile struct {
+	name     string
+	template *template.Template
+	gofmt    bool
+}
+
+// project holds the values rendered in the project skeleton.
+type project struct {
+	// Module is the module path of the project, such as "github.com/acme/blog".
+	Module string
+	// Name is the name of the project, the last element of its module path.
+	Name string
+}
+
+// projectFiles are the files written by `origin new`.
+var projectFiles = []projectFile{
+	{name: "go.mod", template: template.Must(template.New("go.mod").Parse(goModTemplate))},
+	{name: "main.go", template: template.Must(template.New("main.go").Parse(mainTemplate)), gofmt: true},
+	{name: filepath.Join("migrations", "README.md"), template: template.Must(template.New("migrations").Parse(migrationsReadmeTemplate))},
+	{name: "config.toml", template: template.Must(template.New("config.toml").Parse(configTemplate))},
+	{name: ".gitignore", template: template.Must(template.New(".gitignore").Parse("*.db\n"))},
+}
+
+// runNew creates a project skeleton in a new directory.
+func runNew(args []string) error {
+	flags := newFlagSet("new", "new <dir> [-module path]")
+	module := flags.String("module", "", "module path of the project, defaults to the name of the directory")
+	positional, err := parseArgs(flags, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 1 {
+		flags.Usage()
+		return errUsage
+	}
+
+	dir := positional[0]
+	if entries, err := os.ReadDir(dir); err == nil && len(entries) > 0 {
+		return fmt.Errorf("%s already exists and is not empty", dir)
+	}
+
+	p := project{Module: *module}
+	if p.Module == "" {
+		abs, err := filepath.Abs(dir)
+		if err != nil {
+			return err
+		}
+		p.Module = filepath.Base(abs)
+	}
+	p.Name = path.Base(p.Module)
+
+	for _, file := range projectFiles {
+		var content bytes.Buffer
+		if err := file.template.Execute(&content, p); err != nil {
+			return fmt.Errorf("render %s: %w", file.name, err)
+		}
+
+		source := content.Bytes()
+		if file.gofmt {
+			if source, err = format.Source(source); err != nil {
+				return fmt.Errorf("format %s: %w", file.name, err)
+			}
+		}
+
+		target := filepath.Join(dir, file.name)
+		if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+			return err
+		}
+		if err := os.WriteFile(target, source, 0o644); err != nil {
+			return err
+		}
+	}
+
+	fmt.Printf("Created %s in %s. Next steps:\n\n", p.Module, dir)
+	fmt.Printf("  cd %s\n", dir)
+	fmt.Println("  origin gen model Blog title:string -contents")
+	fmt.Println("  origin migrate diff create_blogs")
+	fmt.Println("  go mod tidy && go run .")
+	return nil
+}
+
+const goModTemplate = `module {{.Module}}
+
+go 1.23
+`
+
+const mainTemplate = `// Command {{.Name}} serves the API of the {{.Name}} project.
+package main
+
+import (
+	"context"
+	"embed"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/MuhmdHsn313/origin/migrate"
+	"github.com/MuhmdHsn313/origin/server"
+	"github.com/MuhmdHsn313/origin/server/config"
+	"github.com/MuhmdHsn313/origin/service"
+	"github.com/kataras/iris/v12/core/router"
+)
+
+// migrationFiles holds the SQL migrations applied on start, written by "origin migrate create" and "origin migrate diff".
+//
+//go:embed migrations
+var migrationFiles embed.FS
+
+// registrations register the routes of the models, the files written by "origin gen model" add theirs.
+var registrations []func(app *server.App) router.Party
+
+func main() {
+	routes := flag.Bool("routes", false, "print the routes and exit")
+	diff := flag.String("diff", "", "write the migration of the model changes under this name and exit")
+	config.BindFlags(flag.CommandLine)
+	flag.Parse()
+
+	// Settings are read from config.toml, then from ORIGIN_* environment variables, then from flags such as -server.addr.
+	cfg, err := config.Load(config.WithFile("config.toml"), config.WithFlags(flag.CommandLine))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	migrations, err := migrate.LoadSQL(migrationFiles, "migrations")
+	if err != nil {
+		log.Fatalf("Failed to load the migrations: %v", err)
+	}
+	app, err := server.New(cfg, server.WithMigrations(migrations))
+	if err != nil {
+		log.Fatal(err)
+	}
+	for _, register := range registrations {
+		register(app)
+	}
+	service.RegisterOpenAPI(app.Iris, service.OpenAPIOptions{Title: "{{.Name}}", UI: service.OpenAPIUISwagger})
+
+	if *routes {
+		if err := service.WriteRoutes(os.Stdout, app.Iris); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	// The changes are compared to the schema once the existing migrations are applied.
+	if *diff != "" {
+		if err := app.Migrate(context.Background()); err != nil {
+			log.Fatalf("Failed to apply the migrations: %v", err)
+		}
+		files, err := migrate.WriteDiff(app.DB, "migrations", *diff)
+		if err != nil {
+			log.Fatalf("Failed to write the migration: %v", err)
+		}
+		if len(files) == 0 {
+			fmt.Println("The models match the database, no migration written.")
+		}
+		for _, file := range files {
+			fmt.Println(file)
+		}
+		return
+	}
+
+	if err := app.Run(context.Background()); err != nil {
+		log.Fatal(err)
+	}
+}
+`
+
+const configTemplate = `# Configuration of {{.Name}}. Every setting can be overridden by an environment variable
+# (ORIGIN_DATABASE_DSN) or a flag (-database.dsn).
+
+[server]
+addr = ":8080"
+api_prefix = "/api"
+shutdown_timeout = "30s"
+
+[database]
+dsn = "sqlite:{{.Name}}.db"
+max_open_conns = 0
+max_idle_conns = 2
+
+[log]
+level = "info"
+format = "text"
+success_level = "info"
+success_sampling = 0
+slow_query_threshold = "200ms"
+
+[cors]
+allowed_origins = []
+
+[pagination]
+default_limit = 0
+max_limit = 1000
+
+[metrics]
+enabled = false
+path = "/metrics"
+
+[tracing]
+enabled = false
+service_name = "{{.Name}}"
+sample_ratio = 1.0
+
+[audit]
+enabled = false
+
+[events]
+enabled = false
+outbox = false
+relay_interval = "1s"
+batch_size = 100
+
+# Options of a single model, by snake_case name.
+# [models.blog]
+# pluralize = true
+# operations = ["list", "get", "schema"]
+# max_limit = 100
+# versioned = true
+`
+
+const migrationsReadmeTemplate = `# Migrations
+
+SQL migrations of {{.Name}}, applied in version order when the server starts.
+
+- "origin migrate diff <name>" writes the migration of the model changes.
+- "origin migrate create <name>" writes an empty migration to fill by hand.
+- "origin migrate up", "down" and "status" manage the database without starting the server.
+`
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+)
+
+// runRoutes prints the routes of a project created by `origin new`, by running it with -routes.
+func runRoutes(args []string) error {
+	flags := newFlagSet("routes", "routes [-dir .]")
+	dir := flags.String("dir", ".", "directory of the project")
+	positional, err := parseArgs(flags, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 0 {
+		flags.Usage()
+		return errUsage
+	}
+	return runProject(*dir, "-routes")
+}
+
+// runProject runs the main package in dir with `go run`, passing args to it.
+// The models of a project are only known to its own binary, which therefore lists its routes and diffs its models.
+func runProject(dir string, args ...string) error {
+	cmd := exec.Command("go", append([]string{"run", "."}, args...)...)
+	cmd.Dir = dir
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
@@ -0,0 +1,602 @@
+package codegen
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"sort"
+	"strings"
+
+	"github.com/MuhmdHsn313/origin/orm"
+)
+
+// serviceImportPath is the import path of the service package implemented by generated engines.
+const serviceImportPath = "github.com/MuhmdHsn313/origin/service"
+
+// associationIDTag is the tag of the "id" of association parameters, the same as in the reflective engine
+// so that both serve the same schemas.
+const associationIDTag = `json:"id" description:"ID of an existing record to reference, as in {\"id\": 1}; bare IDs are not accepted. Left out, the other fields create a new record."`
+
+// EngineOptions configures GenerateEngines.
+type EngineOptions struct {
+	// Dir is the directory of the package declaring the models.
+	Dir string
+	// Types lists the model type names to generate engines for (e.g. "Blog").
+	Types []string
+	// Output is the file name the result will be written to. It is ignored while parsing Dir,
+	// so that a previously generated file never influences the next run.
+	Output string
+}
+
+// paramField is a field of a generated parameters struct.
+type paramField struct {
+	name        string
+	typ         string
+	tag         string
+	inner       *innerParams
+	association *associationParams
+	// contents is set on content collections, merged by language on update.
+	contents bool
+}
+
+// associationParams is the generated type of an associated model (e.g. the items of Tags), referenced
+// by id or created from its fields. Collections also get an update type with replace/append/remove.
+type associationParams struct {
+	typeName   string
+	updateName string
+	elem       *sourceStruct
+	fields     []paramField
+	keyType    string
+	many       bool
+	pointer    bool
+}
+
+// innerParams is the generated element type of a collection (e.g. the items of Contents).
+type innerParams struct {
+	typeName string
+	elem     *sourceStruct
+	fields   []paramField
+}
+
+// modelPlan holds the generated shape of one model, mirroring what the reflective engine builds at runtime.
+type modelPlan struct {
+	model  *sourceStruct
+	create []paramField
+	update []paramField
+	filter []paramField
+	inners []*innerParams
+
+	associations []*associationParams
+}
+
+// GenerateEngines returns the formatted source of a file declaring, for each requested model,
+// named create/update/filter parameter types and a service.Engine implementation without reflection.
+// The generated types follow the same rules as the reflective engine returned by service.CreateEngine.
+func GenerateEngines(options EngineOptions) ([]byte, error) {
+	if len(options.Types) == 0 {
+		return nil, fmt.Errorf("no model types given")
+	}
+
+	source, err := loadPackage(options.Dir, map[string]bool{baseName(options.Output): options.Output != ""})
+	if err != nil {
+		return nil, err
+	}
+
+	imports := map[string]string{"fmt": "fmt", "service": serviceImportPath}
+	var body bytes.Buffer
+	for _, typeName := range options.Types {
+		model, ok := source.structs[typeName]
+		if !ok {
+			return nil, fmt.Errorf("struct type %s not found in package %s", typeName, source.name)
+		}
+
+		plan := source.planModel(model)
+		if err := source.collectImports(plan, imports); err != nil {
+			return nil, err
+		}
+		writeModel(&body, plan, source)
+	}
+
+	var out bytes.Buffer
+	fmt.Fprintf(&out, "// Code generated by origin-gen. DO NOT EDIT.\n\npackage %s\n\nimport (\n", source.name)
+	// Standard library imports come first, separated from the other imports like goimports does.
+	var standard, others []string
+	for name, path := range imports {
+		spec := fmt.Sprintf("%q", path)
+		if path[strings.LastIndex(path, "/")+1:] != name {
+			spec = name + " " + spec
+		}
+		if strings.Contains(strings.Split(path, "/")[0], ".") {
+			others = append(others, spec)
+		} else {
+			standard = append(standard, spec)
+		}
+	}
+	sort.Strings(standard)
+	sort.Strings(others)
+	for _, spec := range standard {
+		fmt.Fprintf(&out, "\t%s\n", spec)
+	}
+	if len(standard) > 0 && len(others) > 0 {
+		out.WriteString("\n")
+	}
+	for _, spec := range others {
+		fmt.Fprintf(&out, "\t%s\n", spec)
+	}
+	out.WriteString(")\n")
+	out.Write(body.Bytes())
+
+	formatted, err := format.Source(out.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("formatting generated code: %w\n%s", err, out.String())
+	}
+	return formatted, nil
+}
+
+// planModel derives the parameter structs of a model with the same rules as the reflective engine.
+func (source *sourcePackage) planModel(model *sourceStruct) *modelPlan {
+	plan := &modelPlan{model: model}
+	plan.create = source.planWritable(plan, false)
+	plan.update = source.planWritable(plan, true)
+	plan.filter = source.planFilter(model)
+	return plan
+}
+
+// planWritable mirrors buildCreateParameters and buildUpdateParameters of the reflective engine.
+// Update parameters wrap every field in a pointer so that nil means "unchanged".
+func (source *sourcePackage) planWritable(plan *modelPlan, update bool) []paramField {
+	var fields []paramField
+	added := map[string]bool{}
+	pointer := ""
+	if update {
+		pointer = "*"
+	}
+
+	for _, field := range plan.model.fields {
+		if field.Embedded || isBaseField(field) {
+			if field.Embedded && plan.model.baseModel(field) == "ContentModel" && !added["LanguageID"] {
+				// Like the reflective engine, an update without language keeps the current one.
+				fields = append(fields, paramField{name: "LanguageID", typ: pointer + "string", tag: `json:"language_id"`})
+				added["LanguageID"] = true
+			}
+			continue
+		}
+
+		if !orm.ParseFieldOptions(field.structField()).IsWritable() {
+			continue
+		}
+
+		if association := plan.associationFor(source, field); association != nil {
+			typ := "*" + association.typeName
+			if association.many {
+				typ = "[]" + association.typeName
+				if update {
+					typ = "*" + association.updateName
+				}
+			}
+			fields = append(fields, paramField{name: field.Name, typ: typ, tag: paramTag(field), association: association})
+			continue
+		}
+
+		// Only collections of structs declared in the package are supported, like the reflective
+		// engine which ignores collections of non-struct values.
+		if isSlice(field.Type) {
+			elem := source.localSliceElem(field.Type)
+			if elem == nil {
+				continue
+			}
+			inner := plan.innerFor(field.Name, elem)
+			fields = append(fields, paramField{name: field.Name, typ: pointer + "[]" + inner.typeName, tag: paramTag(field), inner: inner, contents: source.isContentField(field)})
+			continue
+		}
+
+		fields = append(fields, paramField{name: field.Name, typ: pointer + exprString(field.Type), tag: paramTag(field)})
+	}
+	return fields
+}
+
+// innerFor returns the generated element type of a collection field, building it on first use.
+// It mirrors generateInnerStruct of the reflective engine, foreign keys other than LanguageID are left out.
+func (plan *modelPlan) innerFor(fieldName string, elem *sourceStruct) *innerParams {
+	typeName := plan.model.name + fieldName + "Params"
+	for _, inner := range plan.inners {
+		if inner.typeName == typeName {
+			return inner
+		}
+	}
+
+	inner := &innerParams{typeName: typeName, elem: elem}
+	added := map[string]bool{}
+	for _, field := range elem.fields {
+		if field.Embedded && isBaseField(field) {
+			if elem.baseModel(field) == "ContentModel" && !added["LanguageID"] {
+				inner.fields = append(inner.fields, paramField{name: "LanguageID", typ: "string", tag: `json:"language_id"`})
+				added["LanguageID"] = true
+			}
+			continue
+		}
+		if added[field.Name] || !orm.ParseFieldOptions(field.structField()).IsWritable() || (strings.HasSuffix(field.Name, "ID") && field.Name != "LanguageID") {
+			continue
+		}
+		inner.fields = append(inner.fields, paramField{name: field.Name, typ: exprString(field.Type), tag: paramTag(field)})
+		added[field.Name] = true
+	}
+
+	plan.inners = append(plan.inners, inner)
+	return inner
+}
+
+// associationFor returns the generated type of an association field, building it on first use.
+// It mirrors buildAssociationParameters of the reflective engine.
+func (plan *modelPlan) associationFor(source *sourcePackage, field sourceField) *associationParams {
+	elem, many, pointer := source.associationElem(field)
+	if elem == nil {
+		return nil
+	}
+
+	typeName := plan.model.name + field.Name + "Params"
+	for _, association := range plan.associations {
+		if association.typeName == typeName {
+			return association
+		}
+	}
+
+	association := &associationParams{
+		typeName:   typeName,
+		updateName: plan.model.name + field.Name + "Update",
+		elem:       elem,
+		keyType:    elem.keyType(),
+		many:       many,
+		pointer:    pointer,
+	}
+	association.fields = append(association.fields, paramField{name: "ID", typ: "*" + association.keyType, tag: associationIDTag})
+	for _, elemField := range elem.fields {
+		if elemField.Embedded || strings.HasSuffix(elemField.Name, "ID") || !orm.ParseFieldOptions(elemField.structField()).IsWritable() {
+			continue
+		}
+		if nested, _, _ := source.associationElem(elemField); nested != nil || source.localSliceElem(elemField.Type) != nil {
+			continue
+		}
+		jsonTag, ok := elemField.Tag.Lookup("json")
+		if !ok {
+			jsonTag = elemField.Name
+		}
+		association.fields = append(association.fields, paramField{name: elemField.Name, typ: exprString(elemField.Type), tag: fmt.Sprintf(`json:"%s"`, jsonTag)})
+	}
+
+	plan.associations = append(plan.associations, association)
+	return association
+}
+
+// planFilter mirrors buildFilterParameters of the reflective engine: content collections are
+// flattened into top-level filters and every filter is an optional pointer with a url tag.
+func (source *sourcePackage) planFilter(model *sourceStruct) []paramField {
+	var fields []paramField
+	added := map[string]bool{}
+
+	for _, field := range model.fields {
+		if field.Embedded || isBaseField(field) || !orm.ParseFieldOptions(field.structField()).IsPublic() {
+			continue
+		}
+		if elem, _, _ := source.associationElem(field); elem != nil {
+			continue
+		}
+
+		if source.isContentField(field) {
+			elem := source.localSliceElem(field.Type)
+			for _, innerField := range elem.fields {
+				if innerField.Embedded && elem.baseModel(innerField) == "ContentModel" && !added["LanguageID"] {
+					fields = append(fields, paramField{name: "LanguageID", typ: "*string", tag: `url:"language_id"`})
+					added["LanguageID"] = true
+					continue
+				}
+				if (strings.HasSuffix(innerField.Name, "ID") && innerField.Name != "LanguageID") || innerField.Embedded || isBaseField(innerField) {
+					continue
+				}
+				if !added[innerField.Name] && orm.ParseFieldOptions(innerField.structField()).IsPublic() {
+					fields = append(fields, paramField{name: innerField.Name, typ: "*" + exprString(innerField.Type), tag: fmt.Sprintf(`url:"%s"`, orm.FilterName(innerField.Name))})
+					added[innerField.Name] = true
+				}
+			}
+			continue
+		}
+
+		if !added[field.Name] {
+			fields = append(fields, paramField{name: field.Name, typ: "*" + exprString(field.Type), tag: fmt.Sprintf(`url:"%s"`, orm.FilterName(field.Name))})
+			added[field.Name] = true
+		}
+	}
+	return fields
+}
+
+// collectImports adds the packages referenced by the copied field types to imports.
+func (source *sourcePackage) collectImports(plan *modelPlan, imports map[string]string) error {
+	add := func(s *sourceStruct, field sourceField) error {
+		for _, name := range packageRefs(field.Type) {
+			path, ok := s.imports[name]
+			if !ok {
+				return fmt.Errorf("%s.%s: unknown package %s", s.name, field.Name, name)
+			}
+			if existing, ok := imports[name]; ok && existing != path {
+				return fmt.Errorf("%s.%s: package name %s refers to both %s and %s", s.name, field.Name, name, existing, path)
+			}
+			imports[name] = path
+		}
+		return nil
+	}
+
+	for _, field := range plan.model.fields {
+		if field.Embedded || isBaseField(field) {
+			continue
+		}
+		if err := add(plan.model, field); err != nil {
+			return err
+		}
+	}
+	for _, inner := range plan.inners {
+		for _, field := range inner.elem.fields {
+			if field.Embedded {
+				continue
+			}
+			if err := add(inner.elem, field); err != nil {
+				return err
+			}
+		}
+	}
+	for _, association := range plan.associations {
+		// The "id" of the parameters has the key type of the associated model.
+		for _, elemField := range association.elem.fields {
+			if association.elem.baseModel(elemField) == "UUIDModel" {
+				if existing, ok := imports["uuid"]; ok && existing != uuidImportPath {
+					return fmt.Errorf("%s: package name uuid refers to both %s and %s", association.elem.name, existing, uuidImportPath)
+				}
+				imports["uuid"] = uuidImportPath
+			}
+			if elemField.Name == "ID" && !elemField.Embedded {
+				if err := add(association.elem, elemField); err != nil {
+					return err
+				}
+			}
+		}
+		for _, field := range association.fields[1:] {
+			for _, elemField := range association.elem.fields {
+				if elemField.Name == field.name {
+					if err := add(association.elem, elemField); err != nil {
+						return err
+					}
+				}
+			}
+		}
+	}
+	if source.mergesContents(plan) {
+		imports["orm"] = ormImportPath
+	}
+	return nil
+}
+
+// mergesContents reports whether the update function merges a content collection by language.
+func (source *sourcePackage) mergesContents(plan *modelPlan) bool {
+	for _, field := range plan.update {
+		if field.contents {
+			return true
+		}
+	}
+	return false
+}
+
+func writeModel(out *bytes.Buffer, plan *modelPlan, source *sourcePackage) {
+	name := plan.model.name
+	engineName := name + "Engine"
+
+	for _, inner := range plan.inners {
+		fmt.Fprintf(out, "\n// %s holds a single %s item of the %s create and update parameters.\n", inner.typeName, inner.elem.name, name)
+		writeStruct(out, inner.typeName, inner.fields)
+	}
+
+	for _, association := range plan.associations {
+		writeAssociation(out, name, association)
+	}
+
+	fmt.Fprintf(out, "\n// %sCreateParams holds the parameters accepted when creating a %s.\n", name, name)
+	writeStruct(out, name+"CreateParams", plan.create)
+	fmt.Fprintf(out, "\n// %sUpdateParams holds the parameters accepted when updating a %s, nil fields are left unchanged.\n", name, name)
+	writeStruct(out, name+"UpdateParams", plan.update)
+	fmt.Fprintf(out, "\n// %sFilterParams holds the query parameters accepted when listing %s records.\n", name, name)
+	writeStruct(out, name+"FilterParams", plan.filter)
+
+	fmt.Fprintf(out, `
+// %[2]s implements service.Engine[%[1]s] with the generated parameter types, without reflection.
+type %[2]s struct{}
+
+var _ service.Engine[%[1]s] = %[2]s{}
+
+// New%[2]s returns the generated engine of %[1]s.
+func New%[2]s() service.Engine[%[1]s] {
+	return %[2]s{}
+}
+
+func (%[2]s) GenerateCreateParameters() (interface{}, error) {
+	return &%[1]sCreateParams{}, nil
+}
+
+func (%[2]s) GenerateUpdateParameters() (interface{}, error) {
+	return &%[1]sUpdateParams{}, nil
+}
+
+func (%[2]s) GenerateFilterParameters() (interface{}, error) {
+	return &%[1]sFilterParams{}, nil
+}
+
+func (%[2]s) CreateSchema() (map[string]interface{}, error) {
+	return service.ParametersSchema(&%[1]sCreateParams{}, "%[1]sCreateParameters"), nil
+}
+
+func (%[2]s) UpdateSchema() (map[string]interface{}, error) {
+	return service.ParametersSchema(&%[1]sUpdateParams{}, "%[1]sUpdateParameters"), nil
+}
+`, name, engineName)
+
+	fmt.Fprintf(out, `
+func (%[2]s) FillModelFromCreateParameters(createParams interface{}) (*%[1]s, error) {
+	var params *%[1]sCreateParams
+	switch value := createParams.(type) {
+	case *%[1]sCreateParams:
+		params = value
+	case %[1]sCreateParams:
+		params = &value
+	default:
+		return nil, fmt.Errorf("unexpected create parameters %%T for %[1]s", createParams)
+	}
+
+	model := new(%[1]s)
+`, name, engineName)
+	for _, field := range plan.create {
+		if association := field.association; association != nil {
+			if association.many {
+				fmt.Fprintf(out, "\tmodel.%[1]s = make([]%[2]s, len(params.%[1]s))\n\tfor i, item := range params.%[1]s {\n", field.name, association.elemType())
+				fmt.Fprintf(out, "\t\t%s\n\t}\n", association.assign(fmt.Sprintf("model.%s[i]", field.name), "item"))
+			} else {
+				fmt.Fprintf(out, "\tif params.%[1]s != nil {\n\t\t%[2]s\n\t}\n", field.name, association.assign("model."+field.name, "params."+field.name))
+			}
+			continue
+		}
+		if field.inner == nil {
+			fmt.Fprintf(out, "\tmodel.%[1]s = params.%[1]s\n", field.name)
+			continue
+		}
+		fmt.Fprintf(out, "\tmodel.%[1]s = make([]%[2]s, len(params.%[1]s))\n\tfor i, item := range params.%[1]s {\n", field.name, field.inner.elem.name)
+		for _, innerField := range field.inner.fields {
+			fmt.Fprintf(out, "\t\tmodel.%[1]s[i].%[2]s = item.%[2]s\n", field.name, innerField.name)
+		}
+		out.WriteString("\t}\n")
+	}
+	out.WriteString("\treturn model, nil\n}\n")
+
+	fmt.Fprintf(out, `
+func (%[2]s) UpdateModelFromUpdateParameters(model *%[1]s, updateParams interface{}) (*%[1]s, error) {
+	var params *%[1]sUpdateParams
+	switch value := updateParams.(type) {
+	case *%[1]sUpdateParams:
+		params = value
+	case %[1]sUpdateParams:
+		params = &value
+	default:
+		return model, fmt.Errorf("unexpected update parameters %%T for %[1]s", updateParams)
+	}
+
+`, name, engineName)
+	for _, field := range plan.update {
+		if association := field.association; association != nil {
+			if association.many {
+				fmt.Fprintf(out, "\tif params.%[1]s != nil {\n\t\titems, err := params.%[1]s.applyTo(model.%[1]s)\n\t\tif err != nil {\n\t\t\treturn model, fmt.Errorf(\"field %[1]s: %%w\", err)\n\t\t}\n\t\tmodel.%[1]s = items\n\t}\n", field.name)
+			} else {
+				fmt.Fprintf(out, "\tif params.%[1]s != nil {\n\t\t%[2]s\n\t}\n", field.name, association.assign("model."+field.name, "params."+field.name))
+			}
+			continue
+		}
+		if field.inner == nil && !strings.HasPrefix(field.typ, "*") {
+			fmt.Fprintf(out, "\tmodel.%[1]s = params.%[1]s\n", field.name)
+			continue
+		}
+		if field.inner == nil {
+			fmt.Fprintf(out, "\tif params.%[1]s != nil {\n\t\tmodel.%[1]s = *params.%[1]s\n\t}\n", field.name)
+			continue
+		}
+		fmt.Fprintf(out, "\tif params.%[1]s != nil {\n\t\tupdates := make([]%[2]s, len(*params.%[1]s))\n\t\tfor i, item := range *params.%[1]s {\n", field.name, field.inner.elem.name)
+		for _, innerField := range field.inner.fields {
+			fmt.Fprintf(out, "\t\t\tupdates[i].%[1]s = item.%[1]s\n", innerField.name)
+		}
+		out.WriteString("\t\t}\n")
+		switch {
+		case !field.contents:
+			// Like the reflective engine, other collections are replaced as a whole.
+			fmt.Fprintf(out, "\t\tmodel.%[1]s = updates\n", field.name)
+		case source.isContentModel(field.inner.elem):
+			fmt.Fprintf(out, "\t\tmodel.%[1]s = orm.GetAllContentsWithUpdated(model.%[1]s, updates)\n", field.name)
+		default:
+			fmt.Fprintf(out, "\t\tmodel.%[1]s = orm.MergeContentsByLanguage(model.%[1]s, updates, func(item %[2]s) string {\n\t\t\treturn item.LanguageID\n\t\t})\n", field.name, field.inner.elem.name)
+		}
+		out.WriteString("\t}\n")
+	}
+	out.WriteString("\treturn model, nil\n}\n")
+}
+
+// writeAssociation writes the parameters type of an associated model and, for collections, its update type.
+func writeAssociation(out *bytes.Buffer, modelName string, association *associationParams) {
+	fmt.Fprintf(out, "\n// %s holds a %s of the %s parameters, referenced by id or created from its fields.\n", association.typeName, association.elem.name, modelName)
+	writeStruct(out, association.typeName, association.fields)
+
+	fmt.Fprintf(out, "\nfunc (params %s) toModel() %s {\n\tvar item %s\n\tif params.ID != nil {\n\t\titem.ID = *params.ID\n\t}\n", association.typeName, association.elem.name, association.elem.name)
+	for _, field := range association.fields[1:] {
+		fmt.Fprintf(out, "\titem.%[1]s = params.%[1]s\n", field.name)
+	}
+	out.WriteString("\treturn item\n}\n")
+
+	if !association.many {
+		return
+	}
+
+	elemType := association.elemType()
+	fmt.Fprintf(out, "\n// %s holds the changes to a %s collection: the items replacing it, or the items appended to and removed from it.\n", association.updateName, association.elem.name)
+	writeStruct(out, association.updateName, []paramField{
+		{name: "Replace", typ: "*[]" + association.typeName, tag: `json:"replace"`},
+		{name: "Append", typ: "[]" + association.typeName, tag: `json:"append"`},
+		{name: "Remove", typ: "[]" + association.typeName, tag: `json:"remove"`},
+	})
+	fmt.Fprintf(out, `
+func (update %[1]s) applyTo(current []%[2]s) ([]%[2]s, error) {
+	toModels := func(params []%[3]s) []%[2]s {
+		items := make([]%[2]s, len(params))
+		for i, item := range params {
+			%[4]s
+		}
+		return items
+	}
+
+	var replace *[]%[2]s
+	if update.Replace != nil {
+		items := toModels(*update.Replace)
+		replace = &items
+	}
+	return service.MergeAssociation(current, replace, toModels(update.Append), toModels(update.Remove), func(item %[2]s) %[5]s {
+		return item.ID
+	})
+}
+`, association.updateName, elemType, association.typeName, association.assign("items[i]", "item"), association.keyType)
+}
+
+// elemType returns the Go type of the model field elements, such as Tag or *Tag.
+func (association *associationParams) elemType() string {
+	if association.pointer {
+		return "*" + association.elem.name
+	}
+	return association.elem.name
+}
+
+// assign returns the statement setting target to the model converted from the parameters in source.
+func (association *associationParams) assign(target, source string) string {
+	if association.pointer {
+		return fmt.Sprintf("item := %s.toModel()\n%s = &item", source, target)
+	}
+	return fmt.Sprintf("%s = %s.toModel()", target, source)
+}
+
+func writeStruct(out *bytes.Buffer, name string, fields []paramField) {
+	fmt.Fprintf(out, "type %s struct {\n", name)
+	for _, field := range fields {
+		fmt.Fprintf(out, "\t%s %s `%s`\n", field.name, field.typ, field.tag)
+	}
+	out.WriteString("}\n")
+}
+
+// paramTag builds the tag of a parameter field like the reflective engine: the json tag and, if any, the validate tag.
+func paramTag(field sourceField) string {
+	jsonTag, ok := field.Tag.Lookup("json")
+	if !ok {
+		jsonTag = field.Name
+	}
+	if validationTag, ok := field.Tag.Lookup("validate"); ok {
+		return fmt.Sprintf(`json:"%s" validate:"%s"`, jsonTag, validationTag)
+	}
+	return fmt.Sprintf(`json:"%s"`, jsonTag)
+}
@@ -0,0 +1,174 @@
+package fixture
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/MuhmdHsn313/origin/codegen"
+	"github.com/MuhmdHsn313/origin/service"
+)
+
+// TestGeneratedEngineIsUpToDate checks that the committed engine is the one origin-gen generates now,
+// run go generate in this directory after changing the generator.
+func TestGeneratedEngineIsUpToDate(t *testing.T) {
+	generated, err := codegen.GenerateEngines(codegen.EngineOptions{
+		Dir:    ".",
+		Types:  []string{"Article"},
+		Output: "article_engine_gen.go",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	committed, err := os.ReadFile("article_engine_gen.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(generated, committed) {
+		t.Fatal("article_engine_gen.go is out of date, run go generate")
+	}
+}
+
+// decode generates the parameters with generate and decodes body into them.
+func decode(t *testing.T, generate func() (interface{}, error), body string) interface{} {
+	t.Helper()
+	params, err := generate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal([]byte(body), params); err != nil {
+		t.Fatalf("decoding %s: %v", body, err)
+	}
+	return params
+}
+
+// inline replaces the references to defs in schema by the definitions, and drops defs.
+func inline(schema interface{}, defs interface{}) map[string]interface{} {
+	var resolve func(value interface{}) interface{}
+	resolve = func(value interface{}) interface{} {
+		switch value := value.(type) {
+		case map[string]interface{}:
+			if ref, ok := value["$ref"].(string); ok {
+				name := ref[len("#/$defs/"):]
+				return resolve(defs.(map[string]interface{})[name])
+			}
+			resolved := map[string]interface{}{}
+			for key, item := range value {
+				if key != "$defs" {
+					resolved[key] = resolve(item)
+				}
+			}
+			return resolved
+		case []interface{}:
+			resolved := make([]interface{}, len(value))
+			for i, item := range value {
+				resolved[i] = resolve(item)
+			}
+			return resolved
+		default:
+			return value
+		}
+	}
+	return resolve(schema).(map[string]interface{})
+}
+
+// current returns the article updated by the update cases, as read from the repository.
+func current() *Article {
+	rating := 3.5
+	authorID := uint(7)
+	article := &Article{
+		Slug:     "hello",
+		Views:    3,
+		Rating:   &rating,
+		Owner:    "alice",
+		Secret:   "s3cret",
+		AuthorID: &authorID,
+		Author:   &Author{Name: "Alice"},
+		Tags:     []Tag{{Name: "go"}, {Name: "orm"}},
+	}
+	article.ID = 1
+	article.Author.ID = 7
+	article.Tags[0].ID = 1
+	article.Tags[1].ID = 2
+	article.Contents = []ArticleContent{{Title: "Hello", Body: "World", ArticleID: 1}}
+	article.Contents[0].LanguageID = "en"
+	return article
+}
+
+// TestGeneratedEngineMatchesReflective checks that the generated engine fills and updates the models
+// like the reflective engine of service.CreateEngine, for the same request bodies.
+func TestGeneratedEngineMatchesReflective(t *testing.T) {
+	generated := NewArticleEngine()
+	reflective := service.CreateEngine[Article]()
+
+	t.Run("schemas", func(t *testing.T) {
+		for name, schemas := range map[string][2]func() (map[string]interface{}, error){
+			"create": {generated.CreateSchema, reflective.CreateSchema},
+			"update": {generated.UpdateSchema, reflective.UpdateSchema},
+		} {
+			want, err := schemas[1]()
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := schemas[0]()
+			if err != nil {
+				t.Fatal(err)
+			}
+			// The generated engine names its parameter types, which are referenced from $defs.
+			if got := inline(got, got["$defs"]); !reflect.DeepEqual(got, want) {
+				t.Errorf("%s schema = %v, want %v", name, got, want)
+			}
+		}
+	})
+
+	creates := map[string]string{
+		"empty":    `{}`,
+		"plain":    `{"slug":"hello","views":3,"rating":4.5,"published_at":"2024-01-02T15:04:05Z","secret":"s3cret"}`,
+		"readonly": `{"slug":"hello","owner":"mallory"}`,
+		"contents": `{"slug":"hello","contents":[{"language_id":"en","title":"Hello","body":"World"},` +
+			`{"language_id":"ar","title":"Marhaba"}]}`,
+		"associations": `{"slug":"hello","author_id":7,"author":{"id":7},"tags":[{"id":1},{"name":"new"}]}`,
+	}
+	for name, body := range creates {
+		t.Run("create/"+name, func(t *testing.T) {
+			want, err := reflective.FillModelFromCreateParameters(decode(t, reflective.GenerateCreateParameters, body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := generated.FillModelFromCreateParameters(decode(t, generated.GenerateCreateParameters, body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("generated engine created %+v, reflective engine %+v", got, want)
+			}
+		})
+	}
+
+	updates := map[string]string{
+		"empty":    `{}`,
+		"plain":    `{"views":4,"rating":null,"published_at":"2024-01-02T15:04:05Z"}`,
+		"readonly": `{"owner":"mallory"}`,
+		"contents": `{"contents":[{"language_id":"en","title":"Hello again"},{"language_id":"fr","title":"Bonjour"}]}`,
+		"author":   `{"author":{"id":8}}`,
+		"replace":  `{"tags":{"replace":[{"id":3}]}}`,
+		"append":   `{"tags":{"append":[{"id":3},{"name":"new"}],"remove":[{"id":1}]}}`,
+	}
+	for name, body := range updates {
+		t.Run("update/"+name, func(t *testing.T) {
+			want, err := reflective.UpdateModelFromUpdateParameters(current(), decode(t, reflective.GenerateUpdateParameters, body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := generated.UpdateModelFromUpdateParameters(current(), decode(t, generated.GenerateUpdateParameters, body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("generated engine updated %+v, reflective engine %+v", got, want)
+			}
+		})
+	}
+}
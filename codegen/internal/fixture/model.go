@@ -0,0 +1,51 @@
+// Package fixture declares the models whose generated engines are compared with the reflective engine
+// of the service package by the tests of the code generator.
+package fixture
+
+import (
+	"time"
+
+	"github.com/MuhmdHsn313/origin/orm"
+)
+
+//go:generate go run github.com/MuhmdHsn313/origin/cmd/origin-gen -type Article
+
+// Article has the kinds of fields the engines handle: plain values, pointers, readonly and writeonly fields,
+// a content collection, a belongs-to and a many-to-many association.
+type Article struct {
+	orm.Model
+
+	Contents    []ArticleContent `json:"contents" gorm:"foreignKey:ArticleID"`
+	Slug        string           `json:"slug" validate:"required"`
+	Views       int              `json:"views"`
+	Rating      *float64         `json:"rating"`
+	PublishedAt *time.Time       `json:"published_at"`
+	Owner       string           `json:"owner" origin:"readonly"`
+	Secret      string           `json:"secret" origin:"writeonly"`
+	AuthorID    *uint            `json:"author_id"`
+	Author      *Author          `json:"author"`
+	Tags        []Tag            `json:"tags" gorm:"many2many:article_tags"`
+}
+
+// ArticleContent is the content of an Article in one language.
+type ArticleContent struct {
+	orm.ContentModel
+
+	Title     string `json:"title"`
+	Body      string `json:"body"`
+	ArticleID uint   `json:"article_id"`
+}
+
+// Author writes articles.
+type Author struct {
+	orm.Model
+
+	Name string `json:"name"`
+}
+
+// Tag labels articles.
+type Tag struct {
+	orm.Model
+
+	Name string `json:"name"`
+}
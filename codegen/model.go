@@ -0,0 +1,308 @@
+// Package codegen generates Go source for Origin models. Its engine generator reads model
+// declarations such as Blog/BlogContent from a package directory and emits named create, update
+// and filter parameter types along with a service.Engine implementation that works without reflection.
+package codegen
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/MuhmdHsn313/origin/orm"
+)
+
+// ormImportPath is the import path of the orm package providing the base models.
+const ormImportPath = "github.com/MuhmdHsn313/origin/orm"
+
+// uuidImportPath is the import path of the package declaring the ID type of orm.UUIDModel.
+const uuidImportPath = "github.com/google/uuid"
+
+// sourcePackage holds the struct declarations of a parsed package directory.
+type sourcePackage struct {
+	name    string
+	structs map[string]*sourceStruct
+	// methods holds the method names declared for each receiver type.
+	methods map[string]map[string]bool
+}
+
+// sourceStruct is a struct type declaration together with the imports of its file.
+type sourceStruct struct {
+	name    string
+	fields  []sourceField
+	imports map[string]string // local package name -> import path
+}
+
+// sourceField is a single field of a struct declaration.
+type sourceField struct {
+	Name     string
+	Type     ast.Expr
+	Tag      reflect.StructTag
+	Embedded bool
+}
+
+// loadPackage parses the Go files of dir, skipping tests and the files listed in exclude.
+func loadPackage(dir string, exclude map[string]bool) (*sourcePackage, error) {
+	fset := token.NewFileSet()
+	packages, err := parser.ParseDir(fset, dir, nil, parser.ParseComments)
+	if err != nil {
+		return nil, err
+	}
+
+	source := &sourcePackage{
+		structs: map[string]*sourceStruct{},
+		methods: map[string]map[string]bool{},
+	}
+
+	for name, pkg := range packages {
+		if strings.HasSuffix(name, "_test") {
+			continue
+		}
+		if source.name != "" && source.name != name {
+			return nil, fmt.Errorf("multiple packages in %s: %s and %s", dir, source.name, name)
+		}
+		source.name = name
+
+		for fileName, file := range pkg.Files {
+			if strings.HasSuffix(fileName, "_test.go") || exclude[baseName(fileName)] {
+				continue
+			}
+			source.addFile(file)
+		}
+	}
+
+	if source.name == "" {
+		return nil, fmt.Errorf("no Go package found in %s", dir)
+	}
+	return source, nil
+}
+
+func (source *sourcePackage) addFile(file *ast.File) {
+	imports := map[string]string{}
+	for _, spec := range file.Imports {
+		path, _ := strconv.Unquote(spec.Path.Value)
+		name := path[strings.LastIndex(path, "/")+1:]
+		if spec.Name != nil {
+			name = spec.Name.Name
+		}
+		imports[name] = path
+	}
+
+	for _, decl := range file.Decls {
+		switch decl := decl.(type) {
+		case *ast.GenDecl:
+			for _, spec := range decl.Specs {
+				typeSpec, ok := spec.(*ast.TypeSpec)
+				if !ok {
+					continue
+				}
+				structType, ok := typeSpec.Type.(*ast.StructType)
+				if !ok {
+					continue
+				}
+				source.structs[typeSpec.Name.Name] = &sourceStruct{
+					name:    typeSpec.Name.Name,
+					fields:  structFields(structType),
+					imports: imports,
+				}
+			}
+		case *ast.FuncDecl:
+			if decl.Recv == nil || len(decl.Recv.List) == 0 {
+				continue
+			}
+			receiver := decl.Recv.List[0].Type
+			if star, ok := receiver.(*ast.StarExpr); ok {
+				receiver = star.X
+			}
+			if ident, ok := receiver.(*ast.Ident); ok {
+				if source.methods[ident.Name] == nil {
+					source.methods[ident.Name] = map[string]bool{}
+				}
+				source.methods[ident.Name][decl.Name.Name] = true
+			}
+		}
+	}
+}
+
+func structFields(structType *ast.StructType) []sourceField {
+	var fields []sourceField
+	for _, field := range structType.Fields.List {
+		var tag reflect.StructTag
+		if field.Tag != nil {
+			value, _ := strconv.Unquote(field.Tag.Value)
+			tag = reflect.StructTag(value)
+		}
+
+		if len(field.Names) == 0 {
+			fields = append(fields, sourceField{Name: embeddedName(field.Type), Type: field.Type, Tag: tag, Embedded: true})
+			continue
+		}
+		for _, name := range field.Names {
+			fields = append(fields, sourceField{Name: name.Name, Type: field.Type, Tag: tag})
+		}
+	}
+	return fields
+}
+
+// embeddedName returns the field name of an embedded type (e.g. "Model" for orm.Model).
+func embeddedName(expr ast.Expr) string {
+	switch expr := expr.(type) {
+	case *ast.StarExpr:
+		return embeddedName(expr.X)
+	case *ast.SelectorExpr:
+		return expr.Sel.Name
+	case *ast.Ident:
+		return expr.Name
+	}
+	return ""
+}
+
+// baseModel returns "Model", "UUIDModel" or "ContentModel" when the field embeds one of the orm base models.
+func (s *sourceStruct) baseModel(field sourceField) string {
+	selector, ok := field.Type.(*ast.SelectorExpr)
+	if !ok || !field.Embedded {
+		return ""
+	}
+	pkg, ok := selector.X.(*ast.Ident)
+	if !ok || s.imports[pkg.Name] != ormImportPath {
+		return ""
+	}
+	switch selector.Sel.Name {
+	case "Model", "UUIDModel", "ContentModel":
+		return selector.Sel.Name
+	}
+	return ""
+}
+
+// isBaseField mirrors the reflective engine: fields of base structs are detected by name or type name.
+func isBaseField(field sourceField) bool {
+	for _, base := range []string{"Model", "ContentModel"} {
+		if strings.Contains(field.Name, base) || embeddedName(field.Type) == base {
+			return true
+		}
+	}
+	return false
+}
+
+// localSliceElem returns the declaration of the element type of a slice of a struct declared in the package.
+func (source *sourcePackage) localSliceElem(expr ast.Expr) *sourceStruct {
+	array, ok := expr.(*ast.ArrayType)
+	if !ok || array.Len != nil {
+		return nil
+	}
+	ident, ok := array.Elt.(*ast.Ident)
+	if !ok {
+		return nil
+	}
+	return source.structs[ident.Name]
+}
+
+// isContentModel reports whether a struct implements orm.IContentModel, either through an
+// embedded orm.ContentModel or its own GetLanguageID method.
+func (source *sourcePackage) isContentModel(s *sourceStruct) bool {
+	if source.methods[s.name]["GetLanguageID"] {
+		return true
+	}
+	for _, field := range s.fields {
+		if s.baseModel(field) == "ContentModel" {
+			return true
+		}
+	}
+	return false
+}
+
+// associationElem mirrors the association detection of the reflective engine for fields of local types:
+// it returns the associated model of a field holding (a slice of, or a pointer to) a struct declared in
+// the package with an ID primary key, that is not a content model.
+func (source *sourcePackage) associationElem(field sourceField) (elem *sourceStruct, many, pointer bool) {
+	expr := field.Type
+	if isSlice(expr) {
+		many = true
+		expr = expr.(*ast.ArrayType).Elt
+	}
+	if star, ok := expr.(*ast.StarExpr); ok {
+		pointer = true
+		expr = star.X
+	}
+
+	ident, ok := expr.(*ast.Ident)
+	if !ok || source.isContentField(field) {
+		return nil, false, false
+	}
+	elem = source.structs[ident.Name]
+	if elem == nil || source.isContentModel(elem) || elem.keyType() == "" {
+		return nil, false, false
+	}
+	return elem, many, pointer
+}
+
+// keyType returns the type of the ID primary key of a struct, or "" if it has none.
+func (s *sourceStruct) keyType() string {
+	for _, field := range s.fields {
+		switch s.baseModel(field) {
+		case "Model":
+			return "uint"
+		case "UUIDModel":
+			return "uuid.UUID"
+		}
+		if field.Name == "ID" && !field.Embedded {
+			return exprString(field.Type)
+		}
+	}
+	return ""
+}
+
+// isContentField mirrors orm.IsContentField for collections of local types: the elements implement
+// orm.IContentModel or the field is tagged with `origin:"contents"`.
+func (source *sourcePackage) isContentField(field sourceField) bool {
+	elem := source.localSliceElem(field.Type)
+	if elem == nil {
+		return false
+	}
+	return source.isContentModel(elem) || orm.ParseFieldOptions(field.structField()).Contents
+}
+
+// structField returns the reflect.StructField equivalent of the field, enough to read its tags.
+func (field sourceField) structField() reflect.StructField {
+	return reflect.StructField{Name: field.Name, Tag: field.Tag, Anonymous: field.Embedded}
+}
+
+// isSlice reports whether a type expression is a slice literal type such as []BlogContent.
+func isSlice(expr ast.Expr) bool {
+	array, ok := expr.(*ast.ArrayType)
+	return ok && array.Len == nil
+}
+
+// exprString prints a type expression as Go source.
+func exprString(expr ast.Expr) string {
+	return types.ExprString(expr)
+}
+
+// packageRefs returns the package names referenced by selector expressions in expr.
+func packageRefs(expr ast.Expr) []string {
+	seen := map[string]bool{}
+	ast.Inspect(expr, func(node ast.Node) bool {
+		if selector, ok := node.(*ast.SelectorExpr); ok {
+			if ident, ok := selector.X.(*ast.Ident); ok {
+				seen[ident.Name] = true
+			}
+		}
+		return true
+	})
+
+	var names []string
+	for name := range seen {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func baseName(path string) string {
+	return path[strings.LastIndexAny(path, `/\`)+1:]
+}
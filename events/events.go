@@ -0,0 +1,233 @@
+// Package events announces the creations, updates and deletions of the models to the rest of the application
+// and to other services.
+//
+// A Bus emits typed events, Created, Updated and Deleted, to the handlers subscribed in the process, once the
+// mutations made by the services it is given to are committed:
+//
+//	bus := events.NewBus(logger)
+//	events.Subscribe(bus, func(ctx context.Context, event events.Updated[Blog]) error {
+//		if event.HasChanged("published") && event.Model.Published {
+//			return notifySubscribers(ctx, event.Model)
+//		}
+//		return nil
+//	})
+//	blogService := service.NewModelService[Blog](eng, repo, service.WithEvents(bus))
+//
+// An Outbox writes the same events as Messages in the outbox_messages table, in the transaction of each
+// mutation, and a Relay publishes them to Sinks, such as a message broker, delivering each message at least
+// once: a message is only marked as published once every sink accepted it, and is retried until then.
+//
+//	outbox := events.NewOutbox()
+//	repo := repository.NewGenericRepository[Blog](db, logger, repository.WithAuditor(outbox))
+//	relay := events.NewRelay(db, logger, []events.Sink{brokerSink})
+//	go relay.Run(ctx)
+//
+// The messages are stored in the outbox_messages table, created by a migration of the application:
+//
+//	migrate.Models(4, "create_outbox_messages", &events.Message{})
+package events
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/MuhmdHsn313/origin/audit"
+	"github.com/MuhmdHsn313/origin/logging"
+	"github.com/MuhmdHsn313/origin/repository"
+	"gorm.io/gorm"
+)
+
+// Names of the events, which prefixed by the model name make the names of the messages, such as "blog.created".
+const (
+	NameCreated = "created"
+	NameUpdated = "updated"
+	NameDeleted = "deleted"
+)
+
+// Created is emitted once a record of model T is created, or recreated by reverting it to a previous version.
+//
+// Fields:
+//   - RecordID: The primary key of the record, see repository.RecordID.
+//   - Model: The record as created, with its associations.
+type Created[T any] struct {
+	RecordID string
+	Model    *T
+}
+
+// Updated is emitted once a record of model T is updated, or reverted to a previous version.
+//
+// Fields:
+//   - RecordID: The primary key of the record, see repository.RecordID.
+//   - Before: The record before the update, with its associations.
+//   - Model: The record after the update, with its associations.
+//   - Changed: The JSON names of the fields that changed, such as "title", prefixed by the name of their
+//     collection for contents (e.g. "contents.body"), in the order of the fields of the model.
+type Updated[T any] struct {
+	RecordID string
+	Before   *T
+	Model    *T
+	Changed  []string
+}
+
+// HasChanged reports whether the field, named as in Changed, is one of the fields that changed.
+func (event Updated[T]) HasChanged(field string) bool {
+	for _, changed := range event.Changed {
+		if changed == field {
+			return true
+		}
+	}
+	return false
+}
+
+// Deleted is emitted once a record of model T is deleted.
+//
+// Fields:
+//   - RecordID: The primary key of the record, see repository.RecordID.
+//   - Model: The record before its deletion, with its associations.
+type Deleted[T any] struct {
+	RecordID string
+	Model    *T
+}
+
+// Event is implemented by Created, Updated and Deleted, which are built from the mutations of their model.
+type Event interface {
+	// fromMutation returns the event of a mutation, false when the mutation is not of this kind of event or model.
+	fromMutation(db *gorm.DB, mutation repository.Mutation) (Event, bool, error)
+}
+
+func (Created[T]) fromMutation(db *gorm.DB, mutation repository.Mutation) (Event, bool, error) {
+	model, ok := mutation.After.(*T)
+	if !ok || eventName(mutation) != NameCreated {
+		return nil, false, nil
+	}
+	return Created[T]{RecordID: mutation.RecordID, Model: model}, true, nil
+}
+
+func (Updated[T]) fromMutation(db *gorm.DB, mutation repository.Mutation) (Event, bool, error) {
+	model, ok := mutation.After.(*T)
+	if !ok || eventName(mutation) != NameUpdated {
+		return nil, false, nil
+	}
+	before, _ := mutation.Before.(*T)
+
+	changed, err := changedFields(db, mutation)
+	if err != nil {
+		return nil, false, err
+	}
+	return Updated[T]{RecordID: mutation.RecordID, Before: before, Model: model, Changed: changed}, true, nil
+}
+
+func (Deleted[T]) fromMutation(db *gorm.DB, mutation repository.Mutation) (Event, bool, error) {
+	model, ok := mutation.Before.(*T)
+	if !ok || eventName(mutation) != NameDeleted {
+		return nil, false, nil
+	}
+	return Deleted[T]{RecordID: mutation.RecordID, Model: model}, true, nil
+}
+
+// eventName returns the name of the event of a mutation: a revert creates the record when it was deleted.
+func eventName(mutation repository.Mutation) string {
+	switch {
+	case mutation.After == nil:
+		return NameDeleted
+	case mutation.Before == nil:
+		return NameCreated
+	default:
+		return NameUpdated
+	}
+}
+
+// changedFields returns the JSON names of the fields changed by a mutation, once each, see audit.Diff.
+func changedFields(db *gorm.DB, mutation repository.Mutation) ([]string, error) {
+	changes, err := audit.Diff(db, mutation.Before, mutation.After)
+	if err != nil {
+		return nil, err
+	}
+
+	changed := make([]string, 0, len(changes))
+	seen := map[string]bool{}
+	for _, change := range changes {
+		if !seen[change.Field] {
+			seen[change.Field] = true
+			changed = append(changed, change.Field)
+		}
+	}
+	return changed, nil
+}
+
+// Handler handles the events of type E. An error is logged by the bus, the mutation being committed already.
+type Handler[E Event] func(ctx context.Context, event E) error
+
+// subscription is a handler subscribed to a Bus, called with the events of its type.
+type subscription struct {
+	// build returns the event of a mutation, false when the handler does not handle it.
+	build  func(db *gorm.DB, mutation repository.Mutation) (Event, bool, error)
+	handle func(ctx context.Context, event Event) error
+}
+
+// Bus emits the events of the mutations made by the services it is given to (see service.WithEvents)
+// to the handlers subscribed with Subscribe, once the mutations are committed. The handlers are called
+// in the order of their subscription, within the operation of the service: a long task should be
+// started in its own goroutine, with a context of its own since the one of the request ends with it.
+type Bus struct {
+	logger        logging.Logger
+	mu            sync.RWMutex
+	subscriptions []subscription
+}
+
+// NewBus returns a Bus that logs the errors of its handlers with logger.
+func NewBus(logger logging.Logger) *Bus {
+	if logger == nil {
+		logger = logging.Nop()
+	}
+	return &Bus{logger: logger}
+}
+
+// Subscribe calls handler with the events of type E emitted by bus, such as events.Created[Blog].
+func Subscribe[E Event](bus *Bus, handler Handler[E]) {
+	var zero E
+	bus.mu.Lock()
+	defer bus.mu.Unlock()
+	bus.subscriptions = append(bus.subscriptions, subscription{
+		build: zero.fromMutation,
+		handle: func(ctx context.Context, event Event) error {
+			return handler(ctx, event.(E))
+		},
+	})
+}
+
+// Committed emits the event of a committed mutation to the handlers subscribed to it, see
+// repository.CommitObserver. The errors and panics of the handlers are logged, and do not stop
+// the other handlers from being called.
+func (bus *Bus) Committed(db *gorm.DB, mutation repository.Mutation) {
+	bus.mu.RLock()
+	subscriptions := append([]subscription(nil), bus.subscriptions...)
+	bus.mu.RUnlock()
+
+	ctx := db.Statement.Context
+	logger := logging.FromContext(ctx, bus.logger).WithFields(logging.Fields{
+		"operation": "Emit",
+		"event":     mutation.Model + "." + eventName(mutation),
+		"record_id": mutation.RecordID,
+	})
+	for _, subscription := range subscriptions {
+		event, ok, err := subscription.build(db, mutation)
+		if err == nil && ok {
+			err = handle(ctx, subscription, event)
+		}
+		if err != nil {
+			logger.WithField("error", err.Error()).Error("Failed to handle event")
+		}
+	}
+}
+
+// handle calls the handler of subscription, turning its panic into an error.
+func handle(ctx context.Context, subscription subscription, event Event) (err error) {
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			err = fmt.Errorf("handler panicked: %v", recovered)
+		}
+	}()
+	return subscription.handle(ctx, event)
+}
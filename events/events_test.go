@@ -0,0 +1,204 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/MuhmdHsn313/origin/internal/testdb"
+	"github.com/MuhmdHsn313/origin/logging"
+	"github.com/MuhmdHsn313/origin/orm"
+	"github.com/MuhmdHsn313/origin/repository"
+	"github.com/MuhmdHsn313/origin/service"
+	"github.com/kataras/iris/v12"
+	"gorm.io/gorm"
+)
+
+type eventNote struct {
+	orm.Model
+
+	Title  string `json:"title"`
+	Secret string `json:"secret" origin:"writeonly"`
+}
+
+// newTestApp returns an iris application serving the routes of eventNote with repo, its service emitting to bus.
+func newTestApp(t *testing.T, repo repository.Repository[eventNote], bus *Bus) *iris.Application {
+	t.Helper()
+	app := iris.New()
+	eventService := service.NewModelService[eventNote](service.CreateEngine[eventNote](), repo, service.WithEvents(bus))
+	service.RegisterHandler[eventNote](app.Party("/api"), eventService)
+	if err := app.Build(); err != nil {
+		t.Fatal(err)
+	}
+	return app
+}
+
+// serve sends a request to app and returns its status code.
+func serve(app *iris.Application, method, path, body string) int {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	app.ServeHTTP(rec, req)
+	return rec.Code
+}
+
+// failingAuditor fails the mutations it records, rolling them back.
+type failingAuditor struct{}
+
+func (failingAuditor) Record(tx *gorm.DB, mutation repository.Mutation) error {
+	return errors.New("audit failed")
+}
+
+func TestBusEmitsTheMutationsOfTheService(t *testing.T) {
+	db := testdb.Open(t, &eventNote{}, &Message{})
+	bus := NewBus(logging.Nop())
+	var emitted []string
+	Subscribe(bus, func(ctx context.Context, event Created[eventNote]) error {
+		emitted = append(emitted, "created "+event.RecordID+" "+event.Model.Title)
+		return nil
+	})
+	Subscribe(bus, func(ctx context.Context, event Updated[eventNote]) error {
+		if !event.HasChanged("title") || event.HasChanged("secret") || event.Before.Title != "hello" {
+			t.Errorf("unexpected update %+v", event)
+		}
+		emitted = append(emitted, "updated "+event.RecordID+" "+event.Model.Title)
+		return nil
+	})
+	Subscribe(bus, func(ctx context.Context, event Deleted[eventNote]) error {
+		emitted = append(emitted, "deleted "+event.RecordID+" "+event.Model.Title)
+		return nil
+	})
+
+	repo := repository.NewGenericRepository[eventNote](db, logging.Nop())
+	app := newTestApp(t, repo, bus)
+
+	if code := serve(app, http.MethodPost, "/api/event_note", `{"title":"hello","secret":"s"}`); code != http.StatusCreated {
+		t.Fatalf("create returned %d", code)
+	}
+	if code := serve(app, http.MethodPatch, "/api/event_note/1", `{"title":"bye"}`); code != http.StatusOK {
+		t.Fatalf("update returned %d", code)
+	}
+	if code := serve(app, http.MethodDelete, "/api/event_note/1", ""); code != http.StatusOK && code != http.StatusNoContent {
+		t.Fatalf("delete returned %d", code)
+	}
+	// The failed deletion of a missing record emits nothing.
+	serve(app, http.MethodDelete, "/api/event_note/1", "")
+
+	want := []string{"created 1 hello", "updated 1 bye", "deleted 1 bye"}
+	if strings.Join(emitted, "|") != strings.Join(want, "|") {
+		t.Errorf("emitted %q, want %q", emitted, want)
+	}
+
+	// The mutations made through the repository outside of the service are not emitted.
+	if err := repo.Create(&eventNote{Title: "direct"}); err != nil {
+		t.Fatal(err)
+	}
+	if len(emitted) != len(want) {
+		t.Errorf("emitted %q for a mutation made outside of the service", emitted[len(want):])
+	}
+}
+
+func TestBusIgnoresRolledBackMutations(t *testing.T) {
+	db := testdb.Open(t, &eventNote{}, &Message{})
+	bus := NewBus(logging.Nop())
+	emitted := 0
+	Subscribe(bus, func(ctx context.Context, event Created[eventNote]) error {
+		emitted++
+		return nil
+	})
+
+	repo := repository.NewGenericRepository[eventNote](db, logging.Nop(), repository.WithAuditor(failingAuditor{}))
+	app := newTestApp(t, repo, bus)
+	if code := serve(app, http.MethodPost, "/api/event_note", `{"title":"hello"}`); code == http.StatusCreated {
+		t.Fatal("the creation was not rolled back")
+	}
+	if emitted != 0 {
+		t.Errorf("emitted %d events of a rolled back creation", emitted)
+	}
+}
+
+func TestBusHandlerFailures(t *testing.T) {
+	db := testdb.Open(t, &eventNote{}, &Message{})
+	bus := NewBus(logging.Nop())
+	called := 0
+	Subscribe(bus, func(ctx context.Context, event Created[eventNote]) error {
+		panic("boom")
+	})
+	Subscribe(bus, func(ctx context.Context, event Created[eventNote]) error {
+		called++
+		return errors.New("failed")
+	})
+	Subscribe(bus, func(ctx context.Context, event Created[eventNote]) error {
+		called++
+		return nil
+	})
+
+	repo := repository.NewGenericRepository[eventNote](db, logging.Nop())
+	app := newTestApp(t, repo, bus)
+	// The mutation is committed already, the failures of the handlers do not fail the request.
+	if code := serve(app, http.MethodPost, "/api/event_note", `{"title":"hello"}`); code != http.StatusCreated {
+		t.Fatalf("create returned %d", code)
+	}
+	if called != 2 {
+		t.Errorf("called %d handlers after the failing ones, want 2", called)
+	}
+}
+
+func TestOutboxWritesMessagesInTheTransaction(t *testing.T) {
+	db := testdb.Open(t, &eventNote{}, &Message{})
+	repo := repository.NewGenericRepository[eventNote](db, logging.Nop(), repository.WithAuditor(NewOutbox()))
+
+	note := eventNote{Title: "hello", Secret: "s"}
+	if err := repo.Create(&note); err != nil {
+		t.Fatal(err)
+	}
+	note.Title = "bye"
+	if err := repo.Update(&note); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.Delete(note.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	// A rolled back mutation writes no message.
+	failing := repository.NewGenericRepository[eventNote](db, logging.Nop(),
+		repository.WithAuditor(NewOutbox()), repository.WithAuditor(failingAuditor{}))
+	if err := failing.Create(&eventNote{Title: "rolled back"}); err == nil {
+		t.Fatal("the creation was not rolled back")
+	}
+
+	var messages []Message
+	if err := db.Order("id").Find(&messages).Error; err != nil {
+		t.Fatal(err)
+	}
+	names := make([]string, 0, len(messages))
+	for _, message := range messages {
+		names = append(names, message.Name)
+		if message.Model != "event_note" || message.RecordID != "1" || message.PublishedAt != nil {
+			t.Errorf("unexpected message %+v", message)
+		}
+	}
+	if strings.Join(names, ",") != "event_note.created,event_note.updated,event_note.deleted" {
+		t.Fatalf("wrote messages %v", names)
+	}
+
+	var payload struct {
+		Model   map[string]interface{} `json:"model"`
+		Before  map[string]interface{} `json:"before"`
+		Changed []string               `json:"changed"`
+	}
+	if err := json.Unmarshal(messages[1].Payload, &payload); err != nil {
+		t.Fatal(err)
+	}
+	if payload.Model["title"] != "bye" || payload.Before["title"] != "hello" || len(payload.Changed) == 0 {
+		t.Errorf("unexpected payload %s", messages[1].Payload)
+	}
+	// Payloads are encoded like the responses to anonymous callers.
+	if _, ok := payload.Model["secret"]; ok {
+		t.Errorf("the payload holds a writeonly field: %s", messages[1].Payload)
+	}
+}
@@ -0,0 +1,156 @@
+package events
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/MuhmdHsn313/origin/logging"
+	"github.com/MuhmdHsn313/origin/repository"
+	"github.com/MuhmdHsn313/origin/service"
+	"gorm.io/gorm"
+)
+
+// Message is an event written in the outbox, published to the sinks by a Relay.
+//
+// Fields:
+//   - ID: Unique identifier of the message, increasing with the order of the events. Consumers use it to
+//     ignore the messages delivered again, see Relay.
+//   - Name: The name of the event, the model name followed by NameCreated, NameUpdated or NameDeleted,
+//     such as "blog.created".
+//   - Model: The snake_case name of the model, such as "blog_post".
+//   - RecordID: The primary key of the record, its values joined by commas when composite (e.g. "42,en").
+//   - RequestID: The ID of the request that made the mutation, see logging.Middleware, empty when unknown.
+//   - OccurredAt: When the mutation was made, in UTC.
+//   - Payload: The event encoded as JSON, see Payload.
+//   - Attempts: The number of failed attempts to publish the message.
+//   - NextAttemptAt: When the message is published next, later after each failed attempt.
+//   - PublishedAt: When the message was accepted by every sink, nil until then.
+//   - LastError: The error of the last failed attempt.
+type Message struct {
+	ID            uint       `json:"id" gorm:"primaryKey"`
+	Name          string     `json:"name" gorm:"type:varchar(120);not null"`
+	Model         string     `json:"model" gorm:"type:varchar(100);not null;index:idx_outbox_messages_record"`
+	RecordID      string     `json:"record_id" gorm:"type:varchar(255);not null;index:idx_outbox_messages_record"`
+	RequestID     string     `json:"request_id" gorm:"type:varchar(128)"`
+	OccurredAt    time.Time  `json:"occurred_at" gorm:"not null"`
+	Payload       Payload    `json:"payload" gorm:"type:text"`
+	Attempts      int        `json:"attempts" gorm:"not null;default:0"`
+	NextAttemptAt time.Time  `json:"next_attempt_at" gorm:"not null;index:idx_outbox_messages_pending"`
+	PublishedAt   *time.Time `json:"published_at" gorm:"index:idx_outbox_messages_pending"`
+	LastError     string     `json:"last_error,omitempty" gorm:"type:text"`
+}
+
+// TableName returns the table of the messages, "outbox_messages".
+func (Message) TableName() string {
+	return "outbox_messages"
+}
+
+// Payload is the event of a message, encoded as JSON and stored as text:
+//
+//	{"model": {...}, "before": {...}, "changed": ["title", "contents.body"]}
+//
+// Model is the record after the mutation, before its deletion for deleted events. Before and changed are only
+// set for updated events. The records are encoded like the responses of the services to anonymous callers,
+// without their writeonly and hidden fields (see service.VisibleFields).
+type Payload json.RawMessage
+
+// Value stores the payload as text, see driver.Valuer.
+func (payload Payload) Value() (driver.Value, error) {
+	if len(payload) == 0 {
+		return nil, nil
+	}
+	return string(payload), nil
+}
+
+// Scan reads the payload from text, see sql.Scanner.
+func (payload *Payload) Scan(value interface{}) error {
+	switch data := value.(type) {
+	case nil:
+		*payload = nil
+	case []byte:
+		*payload = append(Payload(nil), data...)
+	case string:
+		*payload = Payload(data)
+	default:
+		return fmt.Errorf("cannot scan %T into a payload", value)
+	}
+	return nil
+}
+
+// MarshalJSON writes the payload as is, null when it is empty.
+func (payload Payload) MarshalJSON() ([]byte, error) {
+	if len(payload) == 0 {
+		return []byte("null"), nil
+	}
+	return payload, nil
+}
+
+// UnmarshalJSON keeps a copy of data as the payload.
+func (payload *Payload) UnmarshalJSON(data []byte) error {
+	*payload = append(Payload(nil), data...)
+	return nil
+}
+
+// payloadBody is the content of a Payload.
+type payloadBody struct {
+	Model   interface{} `json:"model"`
+	Before  interface{} `json:"before,omitempty"`
+	Changed []string    `json:"changed,omitempty"`
+}
+
+// Outbox writes the events of the mutations of the repositories it is given to (see repository.WithAuditor)
+// in the outbox_messages table, in the transaction of each mutation: an event is written if and only if
+// its mutation is committed. The messages are then published by a Relay.
+type Outbox struct {
+	// now returns the time of the messages.
+	now func() time.Time
+}
+
+// NewOutbox returns an Outbox, whose messages are written with the transaction of each mutation.
+func NewOutbox() *Outbox {
+	return &Outbox{now: time.Now}
+}
+
+// Record writes the message of a mutation with tx, the transaction of the mutation, see repository.Auditor.
+func (outbox *Outbox) Record(tx *gorm.DB, mutation repository.Mutation) error {
+	name := eventName(mutation)
+	body := payloadBody{}
+	var err error
+	switch name {
+	case NameDeleted:
+		body.Model, err = service.VisibleFields(mutation.Before, "")
+	case NameCreated:
+		body.Model, err = service.VisibleFields(mutation.After, "")
+	default:
+		body.Model, err = service.VisibleFields(mutation.After, "")
+		if err == nil {
+			body.Before, err = service.VisibleFields(mutation.Before, "")
+		}
+		if err == nil {
+			body.Changed, err = changedFields(tx, mutation)
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+
+	now := outbox.now().UTC()
+	requestID, _ := logging.FieldsFromContext(tx.Statement.Context)[logging.RequestIDField].(string)
+	message := Message{
+		Name:          mutation.Model + "." + name,
+		Model:         mutation.Model,
+		RecordID:      mutation.RecordID,
+		RequestID:     requestID,
+		OccurredAt:    now,
+		Payload:       payload,
+		NextAttemptAt: now,
+	}
+	return tx.Create(&message).Error
+}
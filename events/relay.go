@@ -0,0 +1,267 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/MuhmdHsn313/origin/logging"
+	"gorm.io/gorm"
+)
+
+// Sink receives the messages published by a Relay, such as a message broker or a webhook.
+// Publish returns once the message is accepted: an error makes the relay publish it again later.
+type Sink interface {
+	Publish(ctx context.Context, message Message) error
+}
+
+// SinkFunc is a function used as a Sink.
+type SinkFunc func(ctx context.Context, message Message) error
+
+// Publish calls the function.
+func (sink SinkFunc) Publish(ctx context.Context, message Message) error {
+	return sink(ctx, message)
+}
+
+// MemorySink keeps the messages published to it in memory, for tests.
+type MemorySink struct {
+	mu       sync.Mutex
+	messages []Message
+}
+
+// NewMemorySink returns an empty MemorySink.
+func NewMemorySink() *MemorySink {
+	return &MemorySink{}
+}
+
+// Publish keeps the message.
+func (sink *MemorySink) Publish(ctx context.Context, message Message) error {
+	sink.mu.Lock()
+	defer sink.mu.Unlock()
+	sink.messages = append(sink.messages, message)
+	return nil
+}
+
+// Messages returns the messages published so far, in the order of their publication.
+func (sink *MemorySink) Messages() []Message {
+	sink.mu.Lock()
+	defer sink.mu.Unlock()
+	return append([]Message(nil), sink.messages...)
+}
+
+// RelayOption configures optional behaviour of a Relay created by NewRelay.
+type RelayOption func(options *relayOptions)
+
+type relayOptions struct {
+	// interval is the time between two polls of the outbox.
+	interval time.Duration
+	// batchSize is the number of messages read from the outbox at once.
+	batchSize int
+	// minBackoff and maxBackoff bound the delay before a failed message is published again.
+	minBackoff time.Duration
+	maxBackoff time.Duration
+}
+
+// Defaults of the relay options.
+const (
+	DefaultRelayInterval = time.Second
+	DefaultBatchSize     = 100
+	DefaultMinBackoff    = time.Second
+	DefaultMaxBackoff    = 5 * time.Minute
+)
+
+func defaultRelayOptions() relayOptions {
+	return relayOptions{
+		interval:   DefaultRelayInterval,
+		batchSize:  DefaultBatchSize,
+		minBackoff: DefaultMinBackoff,
+		maxBackoff: DefaultMaxBackoff,
+	}
+}
+
+// WithRelayInterval sets the time between two polls of the outbox, DefaultRelayInterval by default.
+func WithRelayInterval(interval time.Duration) RelayOption {
+	return func(options *relayOptions) {
+		if interval > 0 {
+			options.interval = interval
+		}
+	}
+}
+
+// WithBatchSize sets the number of messages read from the outbox at once, DefaultBatchSize by default.
+func WithBatchSize(size int) RelayOption {
+	return func(options *relayOptions) {
+		if size > 0 {
+			options.batchSize = size
+		}
+	}
+}
+
+// WithBackoff bounds the delay before a message that failed to be published is published again.
+// The delay starts at min and doubles with each failed attempt, up to max.
+func WithBackoff(min, max time.Duration) RelayOption {
+	return func(options *relayOptions) {
+		if min > 0 {
+			options.minBackoff = min
+		}
+		if max >= options.minBackoff {
+			options.maxBackoff = max
+		}
+	}
+}
+
+// Relay publishes the messages of the outbox to its sinks, in the order of their IDs, and marks them as
+// published once every sink accepted them. A message whose publication fails is published again after a
+// delay, to every sink, until it succeeds: the messages are delivered at least once, and may be delivered
+// more than once when a sink fails after another accepted the message, when the relay stops between the
+// publication and the marking, or when several relays share the outbox. Consumers tell the messages
+// delivered again by their ID.
+//
+// The messages of a record (of the same model and record ID) are published in order: a message waits until
+// the earlier messages of its record are published, including those waiting to be published again, while
+// the messages of the other records are not held up.
+type Relay struct {
+	db      *gorm.DB
+	logger  logging.Logger
+	sinks   []Sink
+	options relayOptions
+	// now returns the current time, to tell the messages due.
+	now func() time.Time
+}
+
+// NewRelay returns a Relay publishing the messages of the outbox of db to sinks.
+//
+// Parameters:
+//   - db: The database holding the outbox_messages table.
+//   - logger: The logger of the failed publications.
+//   - sinks: The sinks every message is published to.
+//   - opts: Options such as WithRelayInterval and WithBackoff.
+//
+// Returns:
+//   - The Relay, started by Run.
+func NewRelay(db *gorm.DB, logger logging.Logger, sinks []Sink, opts ...RelayOption) *Relay {
+	options := defaultRelayOptions()
+	for _, opt := range opts {
+		opt(&options)
+	}
+	if logger == nil {
+		logger = logging.Nop()
+	}
+
+	return &Relay{db: db, logger: logger, sinks: sinks, options: options, now: time.Now}
+}
+
+// Run publishes the messages of the outbox every interval until ctx is done, see Flush.
+// The failures are logged and retried, Run only returns once ctx is done.
+func (relay *Relay) Run(ctx context.Context) {
+	ticker := time.NewTicker(relay.options.interval)
+	defer ticker.Stop()
+
+	for {
+		// Batches are followed by another one at once until none is published: the outbox may hold more
+		// messages, such as the following messages of the records published by the batch.
+		for {
+			published, err := relay.Flush(ctx)
+			if err != nil || published == 0 {
+				break
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Flush publishes a batch of the messages due, in the order of their IDs, leaving out the messages of the
+// records that have an earlier message not published yet: a batch holds the first pending message of each
+// record at most. It stops at the first message that fails to be published, which is published again after
+// a delay (see WithBackoff), so that the following messages are not sent to a failing sink. The following
+// messages of its record wait for it, those of the other records are published by the next batches.
+//
+// Returns:
+//   - The number of messages published, and the error of the failed message or of the outbox.
+func (relay *Relay) Flush(ctx context.Context) (int, error) {
+	// The messages of a record wait for its earlier messages, published or retried first.
+	earlier := relay.db.Table("outbox_messages AS earlier").Select("1").
+		Where("earlier.model = outbox_messages.model AND earlier.record_id = outbox_messages.record_id").
+		Where("earlier.published_at IS NULL AND earlier.id < outbox_messages.id")
+
+	var messages []Message
+	err := relay.db.WithContext(ctx).
+		Where("published_at IS NULL AND next_attempt_at <= ?", relay.now().UTC()).
+		Where("NOT EXISTS (?)", earlier).
+		Order("id").
+		Limit(relay.options.batchSize).
+		Find(&messages).Error
+	if err != nil {
+		relay.logger.WithFields(logging.Fields{
+			"operation": "Relay",
+			"error":     err.Error(),
+		}).Error("Failed to read outbox")
+		return 0, err
+	}
+
+	for i, message := range messages {
+		if err := relay.publish(ctx, message); err != nil {
+			return i, err
+		}
+	}
+	return len(messages), nil
+}
+
+// publish publishes a message to every sink and marks it as published, or records the failed attempt.
+func (relay *Relay) publish(ctx context.Context, message Message) error {
+	var errs []error
+	for _, sink := range relay.sinks {
+		if err := sink.Publish(ctx, message); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	publishErr := errors.Join(errs...)
+
+	now := relay.now().UTC()
+	updates := map[string]interface{}{"published_at": now}
+	if publishErr != nil {
+		updates = map[string]interface{}{
+			"attempts":        message.Attempts + 1,
+			"next_attempt_at": now.Add(relay.backoff(message.Attempts + 1)),
+			"last_error":      publishErr.Error(),
+		}
+	}
+	err := relay.db.WithContext(ctx).Model(&Message{}).Where("id = ?", message.ID).Updates(updates).Error
+
+	logger := relay.logger.WithFields(logging.Fields{
+		"operation":  "Relay",
+		"message_id": message.ID,
+		"event":      message.Name,
+	})
+	if publishErr != nil {
+		logger.WithFields(logging.Fields{
+			"attempts": message.Attempts + 1,
+			"error":    publishErr.Error(),
+		}).Warn("Failed to publish message, retrying later")
+		return publishErr
+	}
+	if err != nil {
+		// The message is published again, its consumers having to tell it apart by its ID.
+		logger.WithField("error", err.Error()).Error("Failed to mark message as published")
+		return err
+	}
+	return nil
+}
+
+// backoff returns the delay before the given attempt to publish a message.
+func (relay *Relay) backoff(attempts int) time.Duration {
+	delay := relay.options.minBackoff
+	for i := 1; i < attempts && delay < relay.options.maxBackoff; i++ {
+		delay *= 2
+	}
+	if delay > relay.options.maxBackoff {
+		delay = relay.options.maxBackoff
+	}
+	return delay
+}
@@ -0,0 +1,223 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/MuhmdHsn313/origin/internal/testdb"
+	"github.com/MuhmdHsn313/origin/logging"
+	"gorm.io/gorm"
+)
+
+// testClock is the time of a relay, advanced by the tests past the backoff of the failed messages.
+type testClock struct {
+	now time.Time
+}
+
+func (clock *testClock) Now() time.Time {
+	return clock.now
+}
+
+// newTestRelay returns a relay publishing to sinks with a second of backoff, on the time of the returned clock.
+func newTestRelay(db *gorm.DB, sinks ...Sink) (*Relay, *testClock) {
+	clock := &testClock{now: time.Now()}
+	relay := NewRelay(db, logging.Nop(), sinks, WithBackoff(time.Second, time.Minute))
+	relay.now = clock.Now
+	return relay, clock
+}
+
+// writeMessages writes a message per record ID to the outbox, due at once, and returns their IDs.
+func writeMessages(t *testing.T, db *gorm.DB, recordIDs ...string) []uint {
+	t.Helper()
+	ids := make([]uint, 0, len(recordIDs))
+	for _, recordID := range recordIDs {
+		message := Message{
+			Name:          "note.updated",
+			Model:         "note",
+			RecordID:      recordID,
+			OccurredAt:    time.Now().UTC(),
+			Payload:       Payload(`{"model":{}}`),
+			NextAttemptAt: time.Now().UTC().Add(-time.Second),
+		}
+		if err := db.Create(&message).Error; err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, message.ID)
+	}
+	return ids
+}
+
+// messageIDs returns the IDs of messages.
+func messageIDs(messages []Message) []uint {
+	ids := make([]uint, 0, len(messages))
+	for _, message := range messages {
+		ids = append(ids, message.ID)
+	}
+	return ids
+}
+
+func equalIDs(a, b []uint) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// failingSink fails the publication of the messages in fail, once each, and keeps the IDs of those it accepts.
+type failingSink struct {
+	mu       sync.Mutex
+	fail     map[uint]bool
+	accepted []uint
+}
+
+func (sink *failingSink) Publish(ctx context.Context, message Message) error {
+	sink.mu.Lock()
+	defer sink.mu.Unlock()
+	if sink.fail[message.ID] {
+		delete(sink.fail, message.ID)
+		return errors.New("broker unavailable")
+	}
+	sink.accepted = append(sink.accepted, message.ID)
+	return nil
+}
+
+func (sink *failingSink) Accepted() []uint {
+	sink.mu.Lock()
+	defer sink.mu.Unlock()
+	return append([]uint(nil), sink.accepted...)
+}
+
+func TestRelayPublishesInOrder(t *testing.T) {
+	db := testdb.Open(t, &Message{})
+	ids := writeMessages(t, db, "1", "2", "3")
+	sink := NewMemorySink()
+	relay, _ := newTestRelay(db, sink)
+
+	published, err := relay.Flush(context.Background())
+	if err != nil || published != 3 {
+		t.Fatalf("published %d messages (%v), want 3", published, err)
+	}
+	if got := messageIDs(sink.Messages()); !equalIDs(got, ids) {
+		t.Errorf("published %v, want %v", got, ids)
+	}
+
+	var pending int64
+	if err := db.Model(&Message{}).Where("published_at IS NULL").Count(&pending).Error; err != nil {
+		t.Fatal(err)
+	}
+	if pending != 0 {
+		t.Errorf("%d messages are not marked as published", pending)
+	}
+	if published, _ := relay.Flush(context.Background()); published != 0 {
+		t.Errorf("published %d messages again", published)
+	}
+}
+
+// TestRelayRedeliversFailedMessages checks the at-least-once delivery: a message that a sink fails to accept
+// is published again, to every sink, until they all accept it.
+func TestRelayRedeliversFailedMessages(t *testing.T) {
+	db := testdb.Open(t, &Message{})
+	ids := writeMessages(t, db, "1")
+	sink := NewMemorySink()
+	failing := &failingSink{fail: map[uint]bool{ids[0]: true}}
+	relay, clock := newTestRelay(db, sink, failing)
+
+	if published, err := relay.Flush(context.Background()); err == nil || published != 0 {
+		t.Fatalf("published %d messages (%v), want a failure", published, err)
+	}
+	var message Message
+	if err := db.First(&message, ids[0]).Error; err != nil {
+		t.Fatal(err)
+	}
+	if message.PublishedAt != nil || message.Attempts != 1 || !strings.Contains(message.LastError, "broker unavailable") {
+		t.Errorf("the failed attempt is not recorded: %+v", message)
+	}
+
+	// The message waits for its backoff.
+	if published, err := relay.Flush(context.Background()); err != nil || published != 0 {
+		t.Errorf("published %d messages (%v) before the backoff", published, err)
+	}
+
+	clock.now = clock.now.Add(2 * time.Second)
+	if published, err := relay.Flush(context.Background()); err != nil || published != 1 {
+		t.Fatalf("published %d messages (%v) after the backoff, want 1", published, err)
+	}
+	// The sink that accepted the message the first time receives it again, its consumers tell it by its ID.
+	if got := messageIDs(sink.Messages()); !equalIDs(got, []uint{ids[0], ids[0]}) {
+		t.Errorf("delivered %v, want the message twice", got)
+	}
+	if err := db.First(&message, ids[0]).Error; err != nil {
+		t.Fatal(err)
+	}
+	if message.PublishedAt == nil {
+		t.Error("the message is not marked as published")
+	}
+}
+
+// TestRelayKeepsTheOrderOfEachRecord checks that the messages of a record wait for its failed message,
+// while the messages of the other records are published.
+func TestRelayKeepsTheOrderOfEachRecord(t *testing.T) {
+	db := testdb.Open(t, &Message{})
+	ids := writeMessages(t, db, "1", "1", "2", "1")
+	failing := &failingSink{fail: map[uint]bool{ids[0]: true}}
+	relay, clock := newTestRelay(db, failing)
+
+	if _, err := relay.Flush(context.Background()); err == nil {
+		t.Fatal("the first message did not fail")
+	}
+	// The first message of record 1 waits for its backoff, the following ones for it.
+	for i := 0; i < 3; i++ {
+		if _, err := relay.Flush(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := failing.Accepted(); !equalIDs(got, []uint{ids[2]}) {
+		t.Fatalf("published %v before the backoff, want only the message of record 2 %d", got, ids[2])
+	}
+
+	clock.now = clock.now.Add(2 * time.Second)
+	for i := 0; i < 3; i++ {
+		if _, err := relay.Flush(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got, want := failing.Accepted(), []uint{ids[2], ids[0], ids[1], ids[3]}; !equalIDs(got, want) {
+		t.Errorf("published %v, want %v", got, want)
+	}
+}
+
+func TestRelayRun(t *testing.T) {
+	db := testdb.Open(t, &Message{})
+	// More messages of a record than a batch holds, published one per batch.
+	ids := writeMessages(t, db, "1", "1", "1", "2")
+	sink := NewMemorySink()
+	relay := NewRelay(db, logging.Nop(), []Sink{sink}, WithBatchSize(2), WithRelayInterval(time.Hour))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		relay.Run(ctx)
+		close(done)
+	}()
+
+	// The first poll drains the outbox, without waiting for the interval.
+	deadline := time.Now().Add(5 * time.Second)
+	for len(sink.Messages()) < len(ids) && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	cancel()
+	<-done
+
+	if got, want := messageIDs(sink.Messages()), []uint{ids[0], ids[3], ids[1], ids[2]}; !equalIDs(got, want) {
+		t.Errorf("published %v, want %v", got, want)
+	}
+}
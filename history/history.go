@@ -0,0 +1,230 @@
+// Package history keeps the versions of the models: each time a record is created, updated, reverted or
+// deleted, a Version holding a snapshot of the saved record, its contents and other associations included,
+// is added to its history. The record can then be read as it was at a point in time, and reverted to a version.
+//
+// A Store records the versions of the repositories it is given to, within their transaction, and serves
+// them to the services it is given to, at GET /api/{model}/{id}/_versions, GET /api/{model}/{id}?as_of=
+// and POST /api/{model}/{id}/_revert/{version}:
+//
+//	store := history.New(db)
+//	repo := repository.NewGenericRepository[Blog](db, logger, repository.WithAuditor(store))
+//	blogService := service.NewModelService[Blog](eng, repo, service.WithHistory(store))
+//
+// The snapshots are encoded as JSON and do not hold the fields hidden from it (json:"-"), such as password
+// hashes: a revert leaves them as stored, see repository.GenericRepository.Revert.
+//
+// The versions are stored in the record_versions table, created by a migration of the application:
+//
+//	migrate.Models(3, "create_record_versions", &history.Version{})
+package history
+
+import (
+	"context"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/MuhmdHsn313/origin/audit"
+	"github.com/MuhmdHsn313/origin/logging"
+	"github.com/MuhmdHsn313/origin/repository"
+	"gorm.io/gorm"
+)
+
+// ErrVersionNotFound is returned when a record has no such version, or did not exist at the requested time.
+var ErrVersionNotFound = errors.New("version not found")
+
+// Version is a saved state of a record.
+//
+// Fields:
+//   - ID: Unique identifier of the version, increasing with the order of the versions.
+//   - Model: The snake_case name of the model, such as "blog_post".
+//   - RecordID: The primary key of the record, its values joined by commas when composite (e.g. "42,en").
+//   - Number: The number of the version within the history of the record, starting at 1.
+//   - Operation: The operation that saved the version, "create", "update", "revert" or "delete".
+//   - Principal: The principal that saved the version, see audit.SetPrincipal, empty when unknown.
+//   - RequestID: The ID of the request that saved the version, see logging.Middleware, empty when unknown.
+//   - Timestamp: When the version was saved, in UTC.
+//   - Deleted: True for the version recording the deletion of the record, which has no snapshot.
+//   - Snapshot: The record as saved, encoded as JSON like the responses of the service.
+type Version struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	Model     string    `json:"model" gorm:"type:varchar(100);not null;uniqueIndex:idx_record_versions_number"`
+	RecordID  string    `json:"record_id" gorm:"type:varchar(255);not null;uniqueIndex:idx_record_versions_number"`
+	Number    int       `json:"version" gorm:"not null;uniqueIndex:idx_record_versions_number"`
+	Operation string    `json:"operation" gorm:"type:varchar(10);not null"`
+	Principal string    `json:"principal" gorm:"type:varchar(255)"`
+	RequestID string    `json:"request_id" gorm:"type:varchar(128)"`
+	Timestamp time.Time `json:"timestamp" gorm:"not null;index"`
+	Deleted   bool      `json:"deleted" gorm:"not null;default:false"`
+	Snapshot  Snapshot  `json:"snapshot" gorm:"type:text"`
+}
+
+// TableName returns the table of the versions, "record_versions".
+func (Version) TableName() string {
+	return "record_versions"
+}
+
+// Snapshot is a record encoded as JSON, stored as text and written as is in JSON documents.
+type Snapshot []byte
+
+// Value stores the snapshot as text, see driver.Valuer.
+func (snapshot Snapshot) Value() (driver.Value, error) {
+	if len(snapshot) == 0 {
+		return nil, nil
+	}
+	return string(snapshot), nil
+}
+
+// Scan reads the snapshot from text, see sql.Scanner.
+func (snapshot *Snapshot) Scan(value interface{}) error {
+	switch data := value.(type) {
+	case nil:
+		*snapshot = nil
+	case []byte:
+		*snapshot = append(Snapshot(nil), data...)
+	case string:
+		*snapshot = Snapshot(data)
+	default:
+		return fmt.Errorf("cannot scan %T into a snapshot", value)
+	}
+	return nil
+}
+
+// MarshalJSON writes the snapshot as is, null when the version has none.
+func (snapshot Snapshot) MarshalJSON() ([]byte, error) {
+	if len(snapshot) == 0 {
+		return []byte("null"), nil
+	}
+	return snapshot, nil
+}
+
+// UnmarshalJSON keeps a copy of data as the snapshot.
+func (snapshot *Snapshot) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		*snapshot = nil
+		return nil
+	}
+	*snapshot = append(Snapshot(nil), data...)
+	return nil
+}
+
+// Decode decodes the snapshot into model, a pointer to a model instance.
+func (version Version) Decode(model interface{}) error {
+	if version.Deleted || len(version.Snapshot) == 0 {
+		return fmt.Errorf("version %d of %s %s records its deletion and has no snapshot", version.Number, version.Model, version.RecordID)
+	}
+	return json.Unmarshal(version.Snapshot, model)
+}
+
+// Store records the versions of the records saved by the repositories in the record_versions table,
+// and returns them. It implements repository.Auditor and service.History.
+type Store struct {
+	db *gorm.DB
+	// now returns the time of the versions.
+	now func() time.Time
+}
+
+// New returns a Store whose versions are read from db. They are written with the transaction of each mutation.
+func New(db *gorm.DB) *Store {
+	return &Store{db: db, now: time.Now}
+}
+
+// Record adds the version saved by a mutation to the history of its record with tx, the transaction
+// of the mutation, see repository.Auditor. The numbers of the versions of a record are unique: of two
+// concurrent mutations of a record, the one committing last fails and is rolled back.
+func (store *Store) Record(tx *gorm.DB, mutation repository.Mutation) error {
+	ctx := tx.Statement.Context
+	requestID, _ := logging.FieldsFromContext(ctx)[logging.RequestIDField].(string)
+	version := Version{
+		Model:     mutation.Model,
+		RecordID:  mutation.RecordID,
+		Operation: strings.ToLower(mutation.Operation),
+		Principal: audit.PrincipalFromContext(ctx),
+		RequestID: requestID,
+		Timestamp: store.now().UTC(),
+		Deleted:   mutation.After == nil,
+	}
+	if mutation.After != nil {
+		snapshot, err := json.Marshal(mutation.After)
+		if err != nil {
+			return err
+		}
+		version.Snapshot = snapshot
+	}
+
+	var last int
+	err := tx.Model(&Version{}).
+		Where("model = ? AND record_id = ?", mutation.Model, mutation.RecordID).
+		Select("COALESCE(MAX(number), 0)").
+		Scan(&last).Error
+	if err != nil {
+		return err
+	}
+	version.Number = last + 1
+	return tx.Create(&version).Error
+}
+
+// Versions returns the versions of a record, the most recent first.
+//
+// Parameters:
+//   - ctx: The context of the query, such as the context of the request.
+//   - model: The snake_case name of the model.
+//   - recordID: The ID of the record, see repository.RecordID.
+//   - limit: The number of versions returned, every version when 0.
+//   - offset: The number of versions skipped.
+//
+// Returns:
+//   - The versions, or an error if they cannot be read.
+func (store *Store) Versions(ctx context.Context, model, recordID string, limit, offset int) ([]Version, error) {
+	query := store.db.WithContext(ctx).
+		Where("model = ? AND record_id = ?", model, recordID).
+		Order("number DESC")
+	if limit > 0 {
+		query = query.Limit(limit)
+	}
+	if offset > 0 {
+		query = query.Offset(offset)
+	}
+
+	versions := make([]Version, 0)
+	if err := query.Find(&versions).Error; err != nil {
+		return nil, err
+	}
+	return versions, nil
+}
+
+// Version returns the version number of a record, or ErrVersionNotFound if the record has no such version.
+func (store *Store) Version(ctx context.Context, model, recordID string, number int) (Version, error) {
+	return store.find(store.db.WithContext(ctx).
+		Where("model = ? AND record_id = ? AND number = ?", model, recordID, number))
+}
+
+// AsOf returns the version of a record current at the given time: the last version saved at or before it.
+// It returns ErrVersionNotFound if the record did not exist at that time, either not created yet or deleted.
+func (store *Store) AsOf(ctx context.Context, model, recordID string, at time.Time) (Version, error) {
+	version, err := store.find(store.db.WithContext(ctx).
+		Where("model = ? AND record_id = ? AND timestamp <= ?", model, recordID, at.UTC()).
+		Order("number DESC"))
+	if err != nil {
+		return Version{}, err
+	}
+	if version.Deleted {
+		return Version{}, ErrVersionNotFound
+	}
+	return version, nil
+}
+
+// find returns the first version matched by query, or ErrVersionNotFound.
+func (store *Store) find(query *gorm.DB) (Version, error) {
+	var versions []Version
+	if err := query.Limit(1).Find(&versions).Error; err != nil {
+		return Version{}, err
+	}
+	if len(versions) == 0 {
+		return Version{}, ErrVersionNotFound
+	}
+	return versions[0], nil
+}
@@ -0,0 +1,187 @@
+package history
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/MuhmdHsn313/origin/internal/testdb"
+	"github.com/MuhmdHsn313/origin/logging"
+	"github.com/MuhmdHsn313/origin/orm"
+	"github.com/MuhmdHsn313/origin/repository"
+)
+
+// account has fields hidden from JSON, which the snapshots do not hold.
+type account struct {
+	orm.Model
+
+	Contents     []accountContent `json:"contents" gorm:"foreignKey:AccountID;constraint:OnDelete:CASCADE"`
+	Email        string           `json:"email"`
+	PasswordHash string           `json:"-"`
+}
+
+type accountContent struct {
+	orm.ContentModel
+
+	Bio       string `json:"bio"`
+	Notes     string `json:"-"`
+	AccountID uint   `json:"account_id" gorm:"primaryKey"`
+}
+
+// newTestAccount creates an account with an English content, its versions recorded by store.
+func newTestAccount(t *testing.T, repo repository.Repository[account]) account {
+	t.Helper()
+	model := account{Email: "alice@example.com", PasswordHash: "hash-1"}
+	model.Contents = []accountContent{{Bio: "Hello", Notes: "note-1"}}
+	model.Contents[0].LanguageID = "en"
+	if err := repo.Create(&model); err != nil {
+		t.Fatal(err)
+	}
+	return model
+}
+
+func TestStoreRecordsVersions(t *testing.T) {
+	db := testdb.Open(t, &Version{}, &account{}, &accountContent{})
+	store := New(db)
+	repo := repository.NewGenericRepository[account](db, logging.Nop(), repository.WithAuditor(store))
+	ctx := context.Background()
+
+	model := newTestAccount(t, repo)
+	created := time.Now()
+	time.Sleep(5 * time.Millisecond)
+	model.Email = "bob@example.com"
+	if err := repo.Update(&model); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.Delete(model.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	versions, err := store.Versions(ctx, "account", "1", 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(versions) != 3 {
+		t.Fatalf("recorded %d versions, want 3", len(versions))
+	}
+	for i, operation := range []string{"delete", "update", "create"} {
+		if versions[i].Operation != operation || versions[i].Number != 3-i {
+			t.Errorf("version %d is %s %d, want %s %d", i, versions[i].Operation, versions[i].Number, operation, 3-i)
+		}
+	}
+	if !versions[0].Deleted || versions[0].Snapshot != nil {
+		t.Error("the deletion has a snapshot")
+	}
+
+	var snapshot account
+	if err := versions[1].Decode(&snapshot); err != nil {
+		t.Fatal(err)
+	}
+	if snapshot.Email != "bob@example.com" || len(snapshot.Contents) != 1 || snapshot.PasswordHash != "" {
+		t.Errorf("unexpected snapshot %+v", snapshot)
+	}
+
+	asOf, err := store.AsOf(ctx, "account", "1", created)
+	if err != nil || asOf.Number != 1 {
+		t.Errorf("the version as of the creation is %d (%v), want 1", asOf.Number, err)
+	}
+	if _, err := store.AsOf(ctx, "account", "1", time.Now()); !errors.Is(err, ErrVersionNotFound) {
+		t.Errorf("a deleted record is found with %v", err)
+	}
+	if _, err := store.Version(ctx, "account", "1", 4); !errors.Is(err, ErrVersionNotFound) {
+		t.Errorf("a missing version is found with %v", err)
+	}
+}
+
+// TestRevertKeepsUnencodedFields checks that reverting to a snapshot leaves the fields hidden from JSON as
+// stored, in the model and its contents, instead of clearing them.
+func TestRevertKeepsUnencodedFields(t *testing.T) {
+	db := testdb.Open(t, &Version{}, &account{}, &accountContent{})
+	store := New(db)
+	repo := repository.NewGenericRepository[account](db, logging.Nop(), repository.WithAuditor(store))
+
+	model := newTestAccount(t, repo)
+	model.Email = "bob@example.com"
+	model.PasswordHash = "hash-2"
+	model.Contents[0].Bio = "Hi"
+	model.Contents[0].Notes = "note-2"
+	if err := repo.Update(&model); err != nil {
+		t.Fatal(err)
+	}
+
+	version, err := store.Version(context.Background(), "account", "1", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reverted := new(account)
+	if err := version.Decode(reverted); err != nil {
+		t.Fatal(err)
+	}
+	if err := repository.Revert[account](repo, reverted); err != nil {
+		t.Fatal(err)
+	}
+
+	var stored account
+	if err := db.Preload("Contents").First(&stored, model.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if stored.Email != "alice@example.com" || len(stored.Contents) != 1 || stored.Contents[0].Bio != "Hello" {
+		t.Errorf("the account is not reverted: %+v", stored)
+	}
+	if stored.PasswordHash != "hash-2" {
+		t.Errorf("the password hash is %q after the revert, want it unchanged", stored.PasswordHash)
+	}
+	if stored.Contents[0].Notes != "note-2" {
+		t.Errorf("the notes of the content are %q after the revert, want them unchanged", stored.Contents[0].Notes)
+	}
+
+	// Updates still save every field.
+	stored.PasswordHash = ""
+	stored.Contents[0].Notes = ""
+	if err := repo.Update(&stored); err != nil {
+		t.Fatal(err)
+	}
+	var updated account
+	if err := db.Preload("Contents").First(&updated, model.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if updated.PasswordHash != "" || updated.Contents[0].Notes != "" {
+		t.Errorf("the update did not clear the hidden fields: %+v", updated)
+	}
+}
+
+// TestRevertRecreatesDeletedRecords checks that reverting a deleted record recreates it from its snapshot.
+func TestRevertRecreatesDeletedRecords(t *testing.T) {
+	db := testdb.Open(t, &Version{}, &account{}, &accountContent{})
+	store := New(db)
+	repo := repository.NewGenericRepository[account](db, logging.Nop(), repository.WithAuditor(store))
+
+	model := newTestAccount(t, repo)
+	if err := repo.Delete(model.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	version, err := store.Version(context.Background(), "account", "1", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reverted := new(account)
+	if err := version.Decode(reverted); err != nil {
+		t.Fatal(err)
+	}
+	if err := repository.Revert[account](repo, reverted); err != nil {
+		t.Fatal(err)
+	}
+
+	var stored account
+	if err := db.Preload("Contents").First(&stored, model.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if stored.Email != "alice@example.com" || len(stored.Contents) != 1 || stored.Contents[0].Bio != "Hello" {
+		t.Errorf("the account is not recreated: %+v", stored)
+	}
+	if versions, _ := store.Versions(context.Background(), "account", "1", 0, 0); len(versions) != 3 || versions[0].Operation != "revert" {
+		t.Errorf("the revert is not recorded: %+v", versions)
+	}
+}
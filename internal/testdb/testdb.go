@@ -0,0 +1,44 @@
+// Package testdb opens the SQLite databases of the tests of the other packages.
+package testdb
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/MuhmdHsn313/origin/orm"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// Open opens a SQLite database in a temporary directory of t, closed once the test ends, with the given
+// models migrated by orm.AutoMigrate. Its connections wait for each other's write locks, as the instances
+// of an application sharing the database do, and its statements are not logged.
+//
+// Parameters:
+//   - t: The test using the database.
+//   - models: The models to migrate, none leaving the database empty.
+//
+// Returns:
+//   - The database. The test fails if it cannot be opened or migrated.
+func Open(t testing.TB, models ...interface{}) *gorm.DB {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "test.db")
+	db, err := gorm.Open(sqlite.Open(path+"?_busy_timeout=5000"), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			_ = sqlDB.Close()
+		}
+	})
+	if len(models) > 0 {
+		if err := orm.AutoMigrate(db, models...); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return db
+}
@@ -0,0 +1,69 @@
+package logging
+
+import (
+	"context"
+	"log/slog"
+	"sort"
+
+	"github.com/sirupsen/logrus"
+)
+
+// logrusLogger is a Logger writing through logrus.
+type logrusLogger struct {
+	logger logrus.FieldLogger
+}
+
+// Logrus returns a Logger writing through logger, a *logrus.Logger or a *logrus.Entry.
+func Logrus(logger logrus.FieldLogger) Logger {
+	return logrusLogger{logger: logger}
+}
+
+func (l logrusLogger) WithField(key string, value interface{}) Logger {
+	return logrusLogger{logger: l.logger.WithField(key, value)}
+}
+
+func (l logrusLogger) WithFields(fields Fields) Logger {
+	return logrusLogger{logger: l.logger.WithFields(logrus.Fields(fields))}
+}
+
+func (l logrusLogger) Debug(msg string) { l.logger.Debug(msg) }
+func (l logrusLogger) Info(msg string)  { l.logger.Info(msg) }
+func (l logrusLogger) Warn(msg string)  { l.logger.Warn(msg) }
+func (l logrusLogger) Error(msg string) { l.logger.Error(msg) }
+
+// slogLogger is a Logger writing through log/slog.
+type slogLogger struct {
+	logger *slog.Logger
+}
+
+// Slog returns a Logger writing through logger, slog.Default() when nil.
+func Slog(logger *slog.Logger) Logger {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return slogLogger{logger: logger}
+}
+
+func (l slogLogger) WithField(key string, value interface{}) Logger {
+	return slogLogger{logger: l.logger.With(key, value)}
+}
+
+// WithFields adds the fields as attributes sorted by name, maps having no order.
+func (l slogLogger) WithFields(fields Fields) Logger {
+	keys := make([]string, 0, len(fields))
+	for key := range fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	args := make([]interface{}, 0, 2*len(keys))
+	for _, key := range keys {
+		args = append(args, key, fields[key])
+	}
+	return slogLogger{logger: l.logger.With(args...)}
+}
+
+func (l slogLogger) Debug(msg string) { l.logger.Log(context.Background(), slog.LevelDebug, msg) }
+func (l slogLogger) Info(msg string)  { l.logger.Log(context.Background(), slog.LevelInfo, msg) }
+func (l slogLogger) Warn(msg string)  { l.logger.Log(context.Background(), slog.LevelWarn, msg) }
+func (l slogLogger) Error(msg string) { l.logger.Log(context.Background(), slog.LevelError, msg) }
@@ -0,0 +1,105 @@
+package logging
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+)
+
+// decodeLines decodes the JSON lines written to buf, dropping the keys in drop such as the time.
+func decodeLines(t *testing.T, buf *bytes.Buffer, drop ...string) []map[string]interface{} {
+	t.Helper()
+	var lines []map[string]interface{}
+	scanner := bufio.NewScanner(buf)
+	for scanner.Scan() {
+		var line map[string]interface{}
+		if err := json.Unmarshal(scanner.Bytes(), &line); err != nil {
+			t.Fatalf("%q is not JSON: %v", scanner.Text(), err)
+		}
+		for _, key := range drop {
+			delete(line, key)
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// logEverything logs a line at each level with logger, the fields of each line adding to those of logger.
+func logEverything(logger Logger) {
+	logger = logger.WithField("model", "blog")
+	logger.Debug("debug")
+	logger.WithFields(Fields{"operation": "Create", "count": 2}).Info("info")
+	logger.WithField("operation", "Update").Warn("warn")
+	// A field replaces the one of the same name.
+	logger.WithField("model", "post").Error("error")
+}
+
+func TestLogrus(t *testing.T) {
+	var buf bytes.Buffer
+	logger := logrus.New()
+	logger.SetOutput(&buf)
+	logger.SetFormatter(&logrus.JSONFormatter{})
+	logger.SetLevel(logrus.InfoLevel)
+
+	// Entries are accepted as well as loggers.
+	logEverything(Logrus(logger.WithField("service", "api")))
+
+	want := []map[string]interface{}{
+		{"level": "info", "msg": "info", "service": "api", "model": "blog", "operation": "Create", "count": float64(2)},
+		{"level": "warning", "msg": "warn", "service": "api", "model": "blog", "operation": "Update"},
+		{"level": "error", "msg": "error", "service": "api", "model": "post"},
+	}
+	if got := decodeLines(t, &buf, "time"); !reflect.DeepEqual(got, want) {
+		t.Errorf("logged %v, want %v", got, want)
+	}
+}
+
+func TestSlog(t *testing.T) {
+	var buf bytes.Buffer
+	logEverything(Slog(slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))))
+
+	want := []map[string]interface{}{
+		{"level": "DEBUG", "msg": "debug", "model": "blog"},
+		{"level": "INFO", "msg": "info", "model": "blog", "operation": "Create", "count": float64(2)},
+		{"level": "WARN", "msg": "warn", "model": "blog", "operation": "Update"},
+		{"level": "ERROR", "msg": "error", "model": "post"},
+	}
+	if got := decodeLines(t, &buf, "time"); !reflect.DeepEqual(got, want) {
+		t.Errorf("logged %v, want %v", got, want)
+	}
+}
+
+func TestSlogSortsFields(t *testing.T) {
+	var buf bytes.Buffer
+	Slog(slog.New(slog.NewTextHandler(&buf, nil))).WithFields(Fields{"b": 2, "c": 3, "a": 1}).Info("sorted")
+
+	if line := buf.String(); !strings.Contains(line, `msg=sorted a=1 b=2 c=3`) {
+		t.Errorf("logged %q, want the fields sorted by name", line)
+	}
+}
+
+func TestSlogDefault(t *testing.T) {
+	var buf bytes.Buffer
+	previous := slog.Default()
+	slog.SetDefault(slog.New(slog.NewJSONHandler(&buf, nil)))
+	t.Cleanup(func() { slog.SetDefault(previous) })
+
+	Slog(nil).WithField("model", "blog").Info("default")
+	if got := decodeLines(t, &buf, "time"); len(got) != 1 || got[0]["model"] != "blog" || got[0]["msg"] != "default" {
+		t.Errorf("logged %v through the default logger", got)
+	}
+}
+
+func TestNop(t *testing.T) {
+	logger := Nop().WithField("model", "blog").WithFields(Fields{"operation": "Create"})
+	if logger != Nop() {
+		t.Errorf("Nop().WithField = %v, want the Nop logger", logger)
+	}
+	logEverything(logger)
+}
@@ -0,0 +1,134 @@
+// Package logging defines the Logger used by the repositories, the migrations and the server,
+// with adapters for logrus (Logrus), log/slog (Slog) and a logger discarding everything (Nop):
+//
+//	repo := repository.NewGenericRepository[Blog](db, logging.Slog(slog.Default()))
+//
+// Fields can also be attached to a request, such as its ID (see Middleware), its user or its model.
+// They are added to every line logged with FromContext while serving it:
+//
+//	logging.AddFields(ctx, logging.Fields{"user": userID})
+package logging
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// Fields are the structured fields of a log line, by name.
+type Fields map[string]interface{}
+
+// Logger writes structured logs. Implementations must be safe for concurrent use.
+type Logger interface {
+	// WithField returns a logger adding the field key to every line.
+	WithField(key string, value interface{}) Logger
+	// WithFields returns a logger adding fields to every line.
+	WithFields(fields Fields) Logger
+	// Debug logs a message at the debug level.
+	Debug(msg string)
+	// Info logs a message at the info level.
+	Info(msg string)
+	// Warn logs a message at the warning level.
+	Warn(msg string)
+	// Error logs a message at the error level.
+	Error(msg string)
+}
+
+// nopLogger is a Logger discarding every line.
+type nopLogger struct{}
+
+func (logger nopLogger) WithField(key string, value interface{}) Logger { return logger }
+func (logger nopLogger) WithFields(fields Fields) Logger                { return logger }
+func (logger nopLogger) Debug(msg string)                               {}
+func (logger nopLogger) Info(msg string)                                {}
+func (logger nopLogger) Warn(msg string)                                {}
+func (logger nopLogger) Error(msg string)                               {}
+
+// Nop returns a Logger discarding every line.
+func Nop() Logger {
+	return nopLogger{}
+}
+
+// fieldsContextKey is the context value holding the fields of a request.
+type fieldsContextKey struct{}
+
+// ContextWithFields returns a copy of ctx holding fields, in addition to the fields ctx already holds.
+// Fields with the same name replace the previous ones.
+func ContextWithFields(ctx context.Context, fields Fields) context.Context {
+	merged := make(Fields, len(fields))
+	for key, value := range FieldsFromContext(ctx) {
+		merged[key] = value
+	}
+	for key, value := range fields {
+		merged[key] = value
+	}
+	return context.WithValue(ctx, fieldsContextKey{}, merged)
+}
+
+// FieldsFromContext returns the fields held by ctx, nil when it holds none. They must not be modified.
+func FieldsFromContext(ctx context.Context) Fields {
+	fields, _ := ctx.Value(fieldsContextKey{}).(Fields)
+	return fields
+}
+
+// FromContext returns logger adding the fields held by ctx to every line, see ContextWithFields.
+func FromContext(ctx context.Context, logger Logger) Logger {
+	if fields := FieldsFromContext(ctx); len(fields) > 0 {
+		return logger.WithFields(fields)
+	}
+	return logger
+}
+
+// Level is the severity of a log line.
+type Level int
+
+// Levels of the log lines, from the least to the most severe.
+const (
+	LevelDebug Level = iota
+	LevelInfo
+	LevelWarn
+	LevelError
+)
+
+// ParseLevel returns the level named name: "debug", "info", "warn" (or "warning") or "error".
+func ParseLevel(name string) (Level, error) {
+	switch strings.ToLower(name) {
+	case "debug":
+		return LevelDebug, nil
+	case "info":
+		return LevelInfo, nil
+	case "warn", "warning":
+		return LevelWarn, nil
+	case "error":
+		return LevelError, nil
+	}
+	return LevelInfo, fmt.Errorf("unknown log level %q, expected debug, info, warn or error", name)
+}
+
+// String returns the name of the level, as accepted by ParseLevel.
+func (level Level) String() string {
+	switch level {
+	case LevelDebug:
+		return "debug"
+	case LevelWarn:
+		return "warn"
+	case LevelError:
+		return "error"
+	default:
+		return "info"
+	}
+}
+
+// Log logs msg with logger at the given level.
+func Log(logger Logger, level Level, msg string) {
+	switch level {
+	case LevelDebug:
+		logger.Debug(msg)
+	case LevelWarn:
+		logger.Warn(msg)
+	case LevelError:
+		logger.Error(msg)
+	default:
+		logger.Info(msg)
+	}
+}
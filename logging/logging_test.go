@@ -0,0 +1,86 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"reflect"
+	"testing"
+)
+
+func TestContextWithFields(t *testing.T) {
+	if fields := FieldsFromContext(context.Background()); fields != nil {
+		t.Errorf("a context without fields holds %v", fields)
+	}
+
+	parent := ContextWithFields(context.Background(), Fields{RequestIDField: "abc", "model": "blog"})
+	child := ContextWithFields(parent, Fields{"model": "post", "user": 42})
+
+	if want := (Fields{RequestIDField: "abc", "model": "post", "user": 42}); !reflect.DeepEqual(FieldsFromContext(child), want) {
+		t.Errorf("the child context holds %v, want %v", FieldsFromContext(child), want)
+	}
+	// The fields of the parent are left as they were.
+	if want := (Fields{RequestIDField: "abc", "model": "blog"}); !reflect.DeepEqual(FieldsFromContext(parent), want) {
+		t.Errorf("the parent context holds %v, want %v", FieldsFromContext(parent), want)
+	}
+}
+
+func TestFromContext(t *testing.T) {
+	var buf bytes.Buffer
+	logger := Slog(slog.New(slog.NewJSONHandler(&buf, nil)))
+
+	FromContext(context.Background(), logger).Info("without fields")
+	FromContext(ContextWithFields(context.Background(), Fields{RequestIDField: "abc"}), logger).Info("with fields")
+
+	want := []map[string]interface{}{
+		{"level": "INFO", "msg": "without fields"},
+		{"level": "INFO", "msg": "with fields", RequestIDField: "abc"},
+	}
+	if got := decodeLines(t, &buf, "time"); !reflect.DeepEqual(got, want) {
+		t.Errorf("logged %v, want %v", got, want)
+	}
+}
+
+func TestParseLevel(t *testing.T) {
+	tests := []struct {
+		name    string
+		want    Level
+		wantErr bool
+	}{
+		{"debug", LevelDebug, false},
+		{"info", LevelInfo, false},
+		{"warn", LevelWarn, false},
+		{"warning", LevelWarn, false},
+		{"ERROR", LevelError, false},
+		{"trace", LevelInfo, true},
+		{"", LevelInfo, true},
+	}
+	for _, test := range tests {
+		level, err := ParseLevel(test.name)
+		if level != test.want || (err != nil) != test.wantErr {
+			t.Errorf("ParseLevel(%q) = %v, %v, want %v with error %v", test.name, level, err, test.want, test.wantErr)
+		}
+		// The name of a level is parsed back to the level.
+		if err == nil {
+			if parsed, err := ParseLevel(level.String()); err != nil || parsed != level {
+				t.Errorf("ParseLevel(%q) = %v, %v, want %v", level.String(), parsed, err, level)
+			}
+		}
+	}
+}
+
+func TestLog(t *testing.T) {
+	var buf bytes.Buffer
+	logger := Slog(slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug})))
+	for _, level := range []Level{LevelDebug, LevelInfo, LevelWarn, LevelError} {
+		Log(logger, level, level.String())
+	}
+
+	var got []string
+	for _, line := range decodeLines(t, &buf) {
+		got = append(got, line["level"].(string)+" "+line["msg"].(string))
+	}
+	if want := []string{"DEBUG debug", "INFO info", "WARN warn", "ERROR error"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("logged %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,55 @@
+package logging
+
+import (
+	"github.com/google/uuid"
+	"github.com/kataras/iris/v12"
+)
+
+// RequestIDHeader is the header carrying the ID of a request, read from the request and set on the response.
+const RequestIDHeader = "X-Request-ID"
+
+// RequestIDField is the field holding the ID of a request, see Middleware.
+const RequestIDField = "request_id"
+
+// maxRequestIDLength bounds the request IDs accepted from the callers.
+const maxRequestIDLength = 128
+
+// Middleware identifies each request by the ID of its RequestIDHeader, or by a new UUID when it has
+// none or an invalid one, returns the ID in the same header and adds it to the fields of the request
+// as request_id (RequestIDField). It must be registered with UseRouter, before the handlers logging the request.
+func Middleware() iris.Handler {
+	return func(ctx iris.Context) {
+		requestID := ctx.GetHeader(RequestIDHeader)
+		if !validRequestID(requestID) {
+			requestID = uuid.NewString()
+		}
+		ctx.Header(RequestIDHeader, requestID)
+		AddFields(ctx, Fields{RequestIDField: requestID})
+		ctx.Next()
+	}
+}
+
+// validRequestID reports whether a request ID sent by a caller can be logged as is:
+// it is not empty, not too long and only holds printable ASCII characters.
+func validRequestID(requestID string) bool {
+	if requestID == "" || len(requestID) > maxRequestIDLength {
+		return false
+	}
+	for i := 0; i < len(requestID); i++ {
+		if requestID[i] < ' ' || requestID[i] > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+// AddFields adds fields to the request served by ctx, such as the user authenticated by a middleware.
+// They are logged by the repositories serving the request and by the loggers returned by RequestLogger.
+func AddFields(ctx iris.Context, fields Fields) {
+	ctx.ResetRequest(ctx.Request().WithContext(ContextWithFields(ctx.Request().Context(), fields)))
+}
+
+// RequestLogger returns logger adding the fields of the request served by ctx to every line.
+func RequestLogger(ctx iris.Context, logger Logger) Logger {
+	return FromContext(ctx.Request().Context(), logger)
+}
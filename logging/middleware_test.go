@@ -0,0 +1,94 @@
+package logging
+
+import (
+	"bytes"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/kataras/iris/v12"
+)
+
+// newMiddlewareApp returns an application identifying its requests with Middleware, whose route logs a line
+// with the fields of its request and of the user added by a middleware of the route.
+func newMiddlewareApp(t *testing.T, buf *bytes.Buffer) *iris.Application {
+	t.Helper()
+	logger := Slog(slog.New(slog.NewJSONHandler(buf, nil)))
+
+	app := iris.New()
+	app.UseRouter(Middleware())
+	authenticate := func(ctx iris.Context) {
+		AddFields(ctx, Fields{"user": "alice"})
+		ctx.Next()
+	}
+	app.Get("/blog", authenticate, func(ctx iris.Context) {
+		RequestLogger(ctx, logger).Info("served")
+	})
+	if err := app.Build(); err != nil {
+		t.Fatal(err)
+	}
+	return app
+}
+
+func TestMiddleware(t *testing.T) {
+	tests := []struct {
+		name string
+		// header is the request ID sent by the caller, none when empty.
+		header string
+		// kept is whether the request is identified by the header, or by a new UUID.
+		kept bool
+	}{
+		{"generated", "", false},
+		{"kept", "4f1c2b1e-trace", true},
+		{"too long", strings.Repeat("a", maxRequestIDLength+1), false},
+		{"longest", strings.Repeat("a", maxRequestIDLength), true},
+		{"control characters", "abc\x1b[31m", false},
+		{"non-ASCII", "idé", false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			app := newMiddlewareApp(t, &buf)
+
+			req := httptest.NewRequest(http.MethodGet, "/blog", nil)
+			if test.header != "" {
+				req.Header.Set(RequestIDHeader, test.header)
+			}
+			rec := httptest.NewRecorder()
+			app.ServeHTTP(rec, req)
+
+			requestID := rec.Header().Get(RequestIDHeader)
+			if test.kept && requestID != test.header {
+				t.Errorf("the request is identified by %q, want %q", requestID, test.header)
+			}
+			if !test.kept {
+				if _, err := uuid.Parse(requestID); err != nil {
+					t.Errorf("the request is identified by %q, want a new UUID", requestID)
+				}
+			}
+
+			lines := decodeLines(t, &buf, "time")
+			if len(lines) != 1 || lines[0][RequestIDField] != requestID || lines[0]["user"] != "alice" {
+				t.Errorf("logged %v, want the line of request %q and its user", lines, requestID)
+			}
+		})
+	}
+}
+
+func TestMiddlewareIdentifiesEachRequest(t *testing.T) {
+	var buf bytes.Buffer
+	app := newMiddlewareApp(t, &buf)
+
+	ids := map[string]bool{}
+	for i := 0; i < 3; i++ {
+		rec := httptest.NewRecorder()
+		app.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/blog", nil))
+		ids[rec.Header().Get(RequestIDHeader)] = true
+	}
+	if len(ids) != 3 {
+		t.Errorf("3 requests were identified by %d IDs", len(ids))
+	}
+}
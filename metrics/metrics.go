@@ -0,0 +1,210 @@
+// Package metrics exposes the metrics of an Origin application in the Prometheus text format.
+// A Metrics counts the requests of the routes registered by service.RegisterHandler and measures
+// their latency per model and operation, records the durations of the repository operations and
+// their rollbacks, and reports the statistics of the database connection pools:
+//
+//	m, err := metrics.New()
+//	app.UseRouter(m.Middleware())
+//	app.Get("/metrics", m.Handler())
+//	repo := repository.NewGenericRepository[Blog](db, logger, repository.WithObserver(m))
+//	err = m.RegisterDB(db, "main")
+//
+// server.New sets it up when the metrics are enabled in the configuration.
+package metrics
+
+import (
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/MuhmdHsn313/origin/service"
+	"github.com/kataras/iris/v12"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"gorm.io/gorm"
+)
+
+// Namespace prefixes the names of the metrics, as in origin_http_requests_total.
+const Namespace = "origin"
+
+// unmatchedRoute is the route label of the requests that matched no route, such as 404 responses.
+// Their paths are not used as labels, each of them would create a new series.
+const unmatchedRoute = "unmatched"
+
+// Metrics collects the metrics of an application into a Prometheus registry.
+// It implements repository.Observer.
+type Metrics struct {
+	registry *prometheus.Registry
+
+	requests          *prometheus.CounterVec
+	requestDuration   *prometheus.HistogramVec
+	operationDuration *prometheus.HistogramVec
+	rollbacks         *prometheus.CounterVec
+
+	// routes caches the model and operation of the routes by method and path template, see routeLabels.
+	routes sync.Map
+}
+
+// Option configures a Metrics created by New.
+type Option func(options *metricsOptions)
+
+type metricsOptions struct {
+	// registry receives the metrics, a new registry with the Go runtime and process collectors by default.
+	registry *prometheus.Registry
+	// buckets are the upper bounds of the duration histograms, in seconds.
+	buckets []float64
+}
+
+// WithRegistry registers the metrics on registry instead of a new registry. The Go runtime and process
+// collectors are only added to the default registry, a local registry holds the Origin metrics alone.
+func WithRegistry(registry *prometheus.Registry) Option {
+	return func(options *metricsOptions) {
+		options.registry = registry
+	}
+}
+
+// WithBuckets sets the upper bounds of the duration histograms, in seconds, prometheus.DefBuckets by default.
+func WithBuckets(buckets []float64) Option {
+	return func(options *metricsOptions) {
+		options.buckets = buckets
+	}
+}
+
+// New creates the metrics and registers them.
+//
+// Parameters:
+//   - opts: Options such as WithRegistry.
+//
+// Returns:
+//   - The Metrics, or an error if a metric is already registered on the registry.
+func New(opts ...Option) (*Metrics, error) {
+	options := metricsOptions{buckets: prometheus.DefBuckets}
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	registry := options.registry
+	if registry == nil {
+		registry = prometheus.NewRegistry()
+		if err := registerAll(registry,
+			collectors.NewGoCollector(),
+			collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		); err != nil {
+			return nil, err
+		}
+	}
+
+	m := &Metrics{
+		registry: registry,
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: Namespace,
+			Subsystem: "http",
+			Name:      "requests_total",
+			Help:      "Number of HTTP requests, by route, model, operation and status code.",
+		}, []string{"method", "route", "model", "operation", "code"}),
+		requestDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: Namespace,
+			Subsystem: "http",
+			Name:      "request_duration_seconds",
+			Help:      "Duration of the HTTP requests, by route, model and operation.",
+			Buckets:   options.buckets,
+		}, []string{"method", "route", "model", "operation"}),
+		operationDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: Namespace,
+			Subsystem: "repository",
+			Name:      "operation_duration_seconds",
+			Help:      "Duration of the repository operations, by model, operation and status (ok or error).",
+			Buckets:   options.buckets,
+		}, []string{"model", "operation", "status"}),
+		rollbacks: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: Namespace,
+			Subsystem: "repository",
+			Name:      "rollbacks_total",
+			Help:      "Number of transactions rolled back by the repositories, by model and operation.",
+		}, []string{"model", "operation"}),
+	}
+
+	if err := registerAll(registry, m.requests, m.requestDuration, m.operationDuration, m.rollbacks); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// registerAll registers the collectors on registry, stopping at the first error.
+func registerAll(registry *prometheus.Registry, cs ...prometheus.Collector) error {
+	for _, c := range cs {
+		if err := registry.Register(c); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Registry returns the registry of the metrics, for registering the collectors of the application.
+func (m *Metrics) Registry() *prometheus.Registry {
+	return m.registry
+}
+
+// RegisterDB reports the statistics of the connection pool of db (open, idle and in-use connections,
+// waits and closed connections), labeled with name as db_name.
+func (m *Metrics) RegisterDB(db *gorm.DB, name string) error {
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+	return m.registry.Register(collectors.NewDBStatsCollector(sqlDB, name))
+}
+
+// Handler serves the metrics of the registry in the Prometheus text format.
+func (m *Metrics) Handler() iris.Handler {
+	return iris.FromStd(promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{Registry: m.registry}))
+}
+
+// Middleware counts and times the requests. It must be registered with UseRouter so that requests
+// matching no route are counted too. The routes registered by service.RegisterHandler are labeled with
+// their model and operation, the other routes with their path template and empty model and operation.
+func (m *Metrics) Middleware() iris.Handler {
+	return func(ctx iris.Context) {
+		start := time.Now()
+		ctx.Next()
+
+		method, route, model, operation := ctx.Method(), unmatchedRoute, "", ""
+		if current := ctx.GetCurrentRoute(); current != nil {
+			route = current.Path()
+			model, operation = m.routeLabels(current.Method(), route)
+		}
+
+		m.requestDuration.WithLabelValues(method, route, model, operation).Observe(time.Since(start).Seconds())
+		m.requests.WithLabelValues(method, route, model, operation, strconv.Itoa(ctx.GetStatusCode())).Inc()
+	}
+}
+
+// routeLabels returns the model and operation of a route, see service.LookupRoute.
+func (m *Metrics) routeLabels(method, path string) (string, string) {
+	key := method + " " + path
+	if labels, ok := m.routes.Load(key); ok {
+		return labels.([2]string)[0], labels.([2]string)[1]
+	}
+
+	var labels [2]string
+	if model, operation, ok := service.LookupRoute(method, path); ok {
+		labels = [2]string{model, string(operation)}
+	}
+	m.routes.Store(key, labels)
+	return labels[0], labels[1]
+}
+
+// ObserveOperation records the duration of a repository operation.
+func (m *Metrics) ObserveOperation(model, operation string, duration time.Duration, err error) {
+	status := "ok"
+	if err != nil {
+		status = "error"
+	}
+	m.operationDuration.WithLabelValues(model, operation, status).Observe(duration.Seconds())
+}
+
+// ObserveRollback counts a transaction rolled back by a repository.
+func (m *Metrics) ObserveRollback(model, operation string) {
+	m.rollbacks.WithLabelValues(model, operation).Inc()
+}
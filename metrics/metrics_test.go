@@ -0,0 +1,194 @@
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/MuhmdHsn313/origin/internal/testdb"
+	"github.com/MuhmdHsn313/origin/logging"
+	"github.com/MuhmdHsn313/origin/orm"
+	"github.com/MuhmdHsn313/origin/repository"
+	"github.com/MuhmdHsn313/origin/service"
+	"github.com/kataras/iris/v12"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"gorm.io/gorm"
+)
+
+type metricNote struct {
+	orm.Model
+
+	Title string `json:"title"`
+}
+
+// newTestApp returns an iris application serving the routes of metricNote, with its repository observed
+// and its requests counted by m.
+func newTestApp(t *testing.T, m *Metrics) (*iris.Application, *gorm.DB) {
+	t.Helper()
+
+	db := testdb.Open(t, &metricNote{})
+
+	app := iris.New()
+	app.UseRouter(m.Middleware())
+	repo := repository.NewGenericRepository[metricNote](db, logging.Nop(), repository.WithObserver(m))
+	service.RegisterHandler[metricNote](app.Party("/api"), service.NewModelService[metricNote](service.CreateEngine[metricNote](), repo))
+	app.Get("/health", func(ctx iris.Context) { _, _ = ctx.WriteString("ok") })
+	if err := app.Build(); err != nil {
+		t.Fatal(err)
+	}
+	return app, db
+}
+
+// serve sends a request to app and returns its status code.
+func serve(app *iris.Application, method, path, body string) int {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	app.ServeHTTP(rec, req)
+	return rec.Code
+}
+
+// find returns the metric of the family name whose labels include labels, or nil.
+func find(t *testing.T, registry *prometheus.Registry, name string, labels map[string]string) *dto.Metric {
+	t.Helper()
+
+	families, err := registry.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, family := range families {
+		if family.GetName() != name {
+			continue
+		}
+	metrics:
+		for _, metric := range family.GetMetric() {
+			values := make(map[string]string, len(metric.GetLabel()))
+			for _, label := range metric.GetLabel() {
+				values[label.GetName()] = label.GetValue()
+			}
+			for key, value := range labels {
+				if values[key] != value {
+					continue metrics
+				}
+			}
+			return metric
+		}
+	}
+	return nil
+}
+
+func newTestMetrics(t *testing.T) (*Metrics, *prometheus.Registry) {
+	t.Helper()
+
+	registry := prometheus.NewRegistry()
+	m, err := New(WithRegistry(registry))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return m, registry
+}
+
+func TestMiddlewareLabelsRoutes(t *testing.T) {
+	m, registry := newTestMetrics(t)
+	app, _ := newTestApp(t, m)
+
+	if code := serve(app, http.MethodPost, "/api/metric_note", `{"title":"hello"}`); code != http.StatusCreated {
+		t.Fatalf("create returned %d", code)
+	}
+	for i := 0; i < 2; i++ {
+		if code := serve(app, http.MethodGet, "/api/metric_note/1", ""); code != http.StatusOK {
+			t.Fatalf("get returned %d", code)
+		}
+	}
+	serve(app, http.MethodGet, "/health", "")
+	serve(app, http.MethodGet, "/missing/42", "")
+
+	tests := []struct {
+		labels map[string]string
+		count  float64
+	}{
+		{map[string]string{"method": "POST", "route": "/api/metric_note", "model": "metric_note", "operation": "create", "code": "201"}, 1},
+		{map[string]string{"method": "GET", "route": "/api/metric_note/{id}", "model": "metric_note", "operation": "get", "code": "200"}, 2},
+		{map[string]string{"method": "GET", "route": "/health", "model": "", "operation": "", "code": "200"}, 1},
+		{map[string]string{"method": "GET", "route": unmatchedRoute, "model": "", "operation": "", "code": "404"}, 1},
+	}
+	for _, test := range tests {
+		metric := find(t, registry, "origin_http_requests_total", test.labels)
+		if metric == nil {
+			t.Errorf("no request counted with %v", test.labels)
+			continue
+		}
+		if got := metric.GetCounter().GetValue(); got != test.count {
+			t.Errorf("%v counted %v requests, want %v", test.labels, got, test.count)
+		}
+
+		delete(test.labels, "code")
+		duration := find(t, registry, "origin_http_request_duration_seconds", test.labels)
+		if duration == nil || float64(duration.GetHistogram().GetSampleCount()) != test.count {
+			t.Errorf("%v timed %v requests, want %v", test.labels, duration, test.count)
+		}
+	}
+}
+
+func TestObserveRepositoryOperations(t *testing.T) {
+	m, registry := newTestMetrics(t)
+	app, _ := newTestApp(t, m)
+
+	serve(app, http.MethodPost, "/api/metric_note", `{"title":"hello"}`)
+	serve(app, http.MethodDelete, "/api/metric_note/1", "")
+	// The record is gone, the lookup of the deletion fails and its transaction is rolled back.
+	serve(app, http.MethodDelete, "/api/metric_note/1", "")
+
+	tests := []struct {
+		operation, status string
+		count             uint64
+	}{
+		{"Create", "ok", 1},
+		{"Delete", "ok", 1},
+		{"Delete", "error", 1},
+	}
+	for _, test := range tests {
+		labels := map[string]string{"model": "metric_note", "operation": test.operation, "status": test.status}
+		metric := find(t, registry, "origin_repository_operation_duration_seconds", labels)
+		if metric == nil || metric.GetHistogram().GetSampleCount() != test.count {
+			t.Errorf("%v timed %v operations, want %d", labels, metric, test.count)
+		}
+	}
+
+	rollbacks := find(t, registry, "origin_repository_rollbacks_total", map[string]string{"model": "metric_note", "operation": "Delete"})
+	if rollbacks == nil || rollbacks.GetCounter().GetValue() != 1 {
+		t.Errorf("counted rollbacks %v, want 1", rollbacks)
+	}
+	if find(t, registry, "origin_repository_rollbacks_total", map[string]string{"operation": "Create"}) != nil {
+		t.Error("counted a rollback of a successful creation")
+	}
+}
+
+func TestRegisterDB(t *testing.T) {
+	m, registry := newTestMetrics(t)
+	_, db := newTestApp(t, m)
+
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDB.SetMaxOpenConns(3)
+	if err := m.RegisterDB(db, "main"); err != nil {
+		t.Fatal(err)
+	}
+
+	maxOpen := find(t, registry, "go_sql_max_open_connections", map[string]string{"db_name": "main"})
+	if maxOpen == nil || maxOpen.GetGauge().GetValue() != 3 {
+		t.Errorf("go_sql_max_open_connections is %v, want 3", maxOpen)
+	}
+	if find(t, registry, "go_sql_open_connections", map[string]string{"db_name": "main"}) == nil {
+		t.Error("go_sql_open_connections is not reported")
+	}
+
+	// The pool of a database is reported once.
+	if err := m.RegisterDB(db, "main"); err == nil {
+		t.Error("registered the pool of main twice")
+	}
+}
@@ -0,0 +1,443 @@
+package migrate
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/MuhmdHsn313/origin/orm"
+	"github.com/MuhmdHsn313/origin/service"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/logger"
+	"gorm.io/gorm/schema"
+)
+
+// SchemaDiff lists the differences between the models of the application and the live schema, see Diff.
+type SchemaDiff struct {
+	Tables []TableDiff
+}
+
+// TableDiff lists the differences of a single table.
+//
+// Fields:
+//   - Table: The name of the table.
+//   - Model: The Go type of the model stored in the table, empty for many-to-many join tables.
+//   - Missing: True when the table does not exist. Only the content key indexes created after it are then listed.
+//   - AddedColumns: Columns of the model missing from the table.
+//   - DroppedColumns: Columns of the table that no field of the model declares.
+//   - ChangedColumns: Columns whose type differs between the model and the table.
+//   - MissingIndexes: Indexes of the model missing from the table, including the unique index of content keys.
+type TableDiff struct {
+	Table          string
+	Model          string
+	Missing        bool
+	AddedColumns   []string
+	DroppedColumns []string
+	ChangedColumns []ColumnChange
+	MissingIndexes []string
+
+	target *diffTarget
+}
+
+// ColumnChange describes a column whose type differs between the model and the table.
+type ColumnChange struct {
+	Column       string
+	ModelType    string
+	DatabaseType string
+}
+
+// Empty reports whether the models match the live schema.
+func (diff SchemaDiff) Empty() bool {
+	for _, table := range diff.Tables {
+		if !table.empty() {
+			return false
+		}
+	}
+	return true
+}
+
+func (table TableDiff) empty() bool {
+	return !table.Missing && len(table.AddedColumns) == 0 && len(table.DroppedColumns) == 0 &&
+		len(table.ChangedColumns) == 0 && len(table.MissingIndexes) == 0
+}
+
+// String describes the differences, one line per change.
+func (diff SchemaDiff) String() string {
+	var builder strings.Builder
+	for _, table := range diff.Tables {
+		if table.Missing {
+			fmt.Fprintf(&builder, "+ table %s\n", table.Table)
+			continue
+		}
+		for _, column := range table.AddedColumns {
+			fmt.Fprintf(&builder, "+ column %s.%s\n", table.Table, column)
+		}
+		for _, column := range table.DroppedColumns {
+			fmt.Fprintf(&builder, "- column %s.%s\n", table.Table, column)
+		}
+		for _, change := range table.ChangedColumns {
+			fmt.Fprintf(&builder, "~ column %s.%s: %s -> %s\n", table.Table, change.Column, change.DatabaseType, change.ModelType)
+		}
+		for _, index := range table.MissingIndexes {
+			fmt.Fprintf(&builder, "+ index %s on %s\n", index, table.Table)
+		}
+	}
+	return builder.String()
+}
+
+// SQL returns the statements migrating the live schema to the models, and those reverting them,
+// written for the dialect of db. Missing tables, columns and indexes are created. Dropped and changed
+// columns may lose data and are only listed as comments of the up statements, to be migrated by hand
+// (e.g. renaming a column instead of dropping it).
+//
+// The statements are meant to be reviewed and saved as an SQL migration, see LoadSQL.
+func (diff SchemaDiff) SQL(db *gorm.DB) (up []string, down []string, err error) {
+	upRecorder := &sqlRecorder{}
+	downRecorder := &sqlRecorder{}
+	upDB := db.Session(&gorm.Session{DryRun: true, Logger: upRecorder})
+	downDB := db.Session(&gorm.Session{DryRun: true, Logger: downRecorder})
+
+	// Missing tables are created in the order of their foreign keys, and dropped in reverse order.
+	var missing []interface{}
+	missingTables := make(map[string]bool)
+	for _, table := range diff.Tables {
+		if table.Missing && !table.target.join {
+			missing = append(missing, table.target.value)
+			missingTables[table.Table] = true
+		}
+	}
+	if reorderer, ok := db.Migrator().(modelReorderer); ok {
+		// The dependencies are only followed when added, existing tables are filtered out again.
+		ordered := make([]interface{}, 0, len(missing))
+		for _, model := range reorderer.ReorderModels(missing, true) {
+			stmt := &gorm.Statement{DB: db}
+			if err := stmt.Parse(model); err == nil && missingTables[stmt.Schema.Table] {
+				ordered = append(ordered, model)
+				delete(missingTables, stmt.Schema.Table)
+			}
+		}
+		missing = ordered
+	}
+	for _, model := range missing {
+		if err := upDB.Migrator().CreateTable(model); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	for _, table := range diff.Tables {
+		migrator := upDB.Table(table.Table).Migrator()
+		if table.Missing && table.target.join {
+			if err := migrator.CreateTable(table.target.value); err != nil {
+				return nil, nil, err
+			}
+		}
+		for _, column := range table.AddedColumns {
+			if err := migrator.AddColumn(table.target.value, column); err != nil {
+				return nil, nil, err
+			}
+		}
+		for _, index := range table.MissingIndexes {
+			if relation, ok := table.target.contentKeys[index]; ok {
+				err = orm.CreateContentKeyIndex(upDB, relation)
+			} else if !table.Missing {
+				err = migrator.CreateIndex(table.target.value, index)
+			}
+			if err != nil {
+				return nil, nil, err
+			}
+		}
+		for _, column := range table.DroppedColumns {
+			upRecorder.comment(fmt.Sprintf("column %s.%s is not declared by the models", table.Table, column))
+		}
+		for _, change := range table.ChangedColumns {
+			upRecorder.comment(fmt.Sprintf("column %s.%s is %s in the database and %s in the models", table.Table, change.Column, change.DatabaseType, change.ModelType))
+		}
+	}
+
+	// Changes are reverted in reverse order, join tables before the tables they reference.
+	for i := len(diff.Tables) - 1; i >= 0; i-- {
+		table := diff.Tables[i]
+		if table.Missing {
+			if table.target.join {
+				if err := downDB.Exec("DROP TABLE ?", clause.Table{Name: table.Table}).Error; err != nil {
+					return nil, nil, err
+				}
+			}
+			continue
+		}
+		for _, index := range table.MissingIndexes {
+			if err := downDB.Exec("DROP INDEX ?", clause.Column{Name: index}).Error; err != nil {
+				return nil, nil, err
+			}
+		}
+		for _, column := range table.AddedColumns {
+			if err := downDB.Exec("ALTER TABLE ? DROP COLUMN ?", clause.Table{Name: table.Table}, clause.Column{Name: column}).Error; err != nil {
+				return nil, nil, err
+			}
+		}
+	}
+	for i := len(missing) - 1; i >= 0; i-- {
+		stmt := &gorm.Statement{DB: db}
+		if err := stmt.Parse(missing[i]); err != nil {
+			return nil, nil, err
+		}
+		if err := downDB.Exec("DROP TABLE ?", clause.Table{Name: stmt.Schema.Table}).Error; err != nil {
+			return nil, nil, err
+		}
+	}
+
+	return upRecorder.statements, downRecorder.statements, nil
+}
+
+// modelReorderer is implemented by the GORM migrators ordering models by their foreign keys.
+type modelReorderer interface {
+	ReorderModels(values []interface{}, autoAdd bool) []interface{}
+}
+
+// diffTarget is a table compared by Diff.
+type diffTarget struct {
+	table  string
+	schema *schema.Schema
+	// value is a model of the table, the join table model for many-to-many relations.
+	value interface{}
+	// join is true for the join tables of many-to-many relations.
+	join bool
+	// contentKeys holds the content relations keyed by this table, by the name of their unique index.
+	contentKeys map[string]*schema.Relationship
+}
+
+// Diff compares models with the live schema of db. The associations of the models are compared as
+// well, so that content collections and many-to-many join tables are included without listing them.
+// Content keys are set up as by orm.AutoMigrate.
+//
+// Parameters:
+//   - db: The database holding the live schema.
+//   - models: The models of the application, such as &Blog{}.
+//
+// Returns:
+//   - The differences, ordered like the models and their associations, or an error if a model cannot be
+//     parsed or the columns of a table cannot be read.
+func Diff(db *gorm.DB, models ...interface{}) (SchemaDiff, error) {
+	var targets []*diffTarget
+	byTable := make(map[string]*diffTarget)
+	var visit func(model interface{}) error
+	visit = func(model interface{}) error {
+		stmt := &gorm.Statement{DB: db}
+		if err := stmt.Parse(model); err != nil {
+			return err
+		}
+		if byTable[stmt.Schema.Table] != nil {
+			return nil
+		}
+
+		target := &diffTarget{table: stmt.Schema.Table, schema: stmt.Schema, value: model, contentKeys: map[string]*schema.Relationship{}}
+		byTable[target.table] = target
+		targets = append(targets, target)
+
+		// Relations are visited in the order of their fields, so that the diff is stable.
+		for _, field := range stmt.Schema.Fields {
+			relation, ok := stmt.Schema.Relationships.Relations[field.Name]
+			if !ok || relation.Schema != stmt.Schema {
+				continue
+			}
+
+			if err := visit(reflect.New(relation.FieldSchema.ModelType).Interface()); err != nil {
+				return err
+			}
+			if orm.IsContentRelation(relation) {
+				byTable[relation.FieldSchema.Table].contentKeys[orm.ContentKeyIndex(relation)] = relation
+			}
+
+			if joinTable := relation.JoinTable; joinTable != nil && byTable[joinTable.Table] == nil {
+				join := &diffTarget{
+					table:       joinTable.Table,
+					schema:      joinTable,
+					value:       reflect.New(joinTable.ModelType).Interface(),
+					join:        true,
+					contentKeys: map[string]*schema.Relationship{},
+				}
+				byTable[join.table] = join
+				targets = append(targets, join)
+			}
+		}
+		return nil
+	}
+	for _, model := range models {
+		if err := visit(model); err != nil {
+			return SchemaDiff{}, err
+		}
+	}
+
+	diff := SchemaDiff{Tables: make([]TableDiff, 0, len(targets))}
+	for _, target := range targets {
+		table, err := diffTable(db, target)
+		if err != nil {
+			return SchemaDiff{}, err
+		}
+		diff.Tables = append(diff.Tables, table)
+	}
+	return diff, nil
+}
+
+// DiffRegistered compares the models registered through service.RegisterHandler and
+// service.RegisterChildHandler with the live schema of db, see Diff.
+func DiffRegistered(db *gorm.DB) (SchemaDiff, error) {
+	var models []interface{}
+	seen := make(map[reflect.Type]bool)
+	for _, description := range service.RegisteredModels() {
+		if !seen[description.ModelType] {
+			seen[description.ModelType] = true
+			models = append(models, reflect.New(description.ModelType).Interface())
+		}
+	}
+	return Diff(db, models...)
+}
+
+// WriteDiff writes the migration of the difference between the registered models and db to dir,
+// see DiffRegistered, SchemaDiff.SQL and WriteSQL. Nothing is written when the models match the schema.
+//
+// Returns:
+//   - The paths of the written files, empty when there is no difference.
+func WriteDiff(db *gorm.DB, dir, name string) ([]string, error) {
+	diff, err := DiffRegistered(db)
+	if err != nil || diff.Empty() {
+		return nil, err
+	}
+
+	up, down, err := diff.SQL(db)
+	if err != nil {
+		return nil, err
+	}
+	return WriteSQL(dir, name, up, down)
+}
+
+// diffTable compares a single table with its model.
+func diffTable(db *gorm.DB, target *diffTarget) (TableDiff, error) {
+	table := TableDiff{Table: target.table, target: target}
+	if !target.join {
+		table.Model = target.schema.ModelType.String()
+	}
+
+	migrator := db.Table(target.table).Migrator()
+	if !migrator.HasTable(target.table) {
+		table.Missing = true
+		for name := range target.contentKeys {
+			table.MissingIndexes = append(table.MissingIndexes, name)
+		}
+		return table, nil
+	}
+
+	columnTypes, err := migrator.ColumnTypes(target.value)
+	if err != nil {
+		return TableDiff{}, err
+	}
+	columns := make(map[string]gorm.ColumnType, len(columnTypes))
+	for _, columnType := range columnTypes {
+		columns[strings.ToLower(columnType.Name())] = columnType
+	}
+
+	declared := make(map[string]bool, len(target.schema.DBNames))
+	for _, name := range target.schema.DBNames {
+		field := target.schema.FieldsByDBName[name]
+		if field.IgnoreMigration {
+			continue
+		}
+		declared[strings.ToLower(name)] = true
+
+		columnType, ok := columns[strings.ToLower(name)]
+		if !ok {
+			table.AddedColumns = append(table.AddedColumns, name)
+			continue
+		}
+
+		modelType := db.Dialector.DataTypeOf(field)
+		if !sameColumnType(migrator, modelType, columnType.DatabaseTypeName()) {
+			table.ChangedColumns = append(table.ChangedColumns, ColumnChange{
+				Column:       name,
+				ModelType:    modelType,
+				DatabaseType: columnType.DatabaseTypeName(),
+			})
+		}
+	}
+	for _, columnType := range columnTypes {
+		if !declared[strings.ToLower(columnType.Name())] {
+			table.DroppedColumns = append(table.DroppedColumns, columnType.Name())
+		}
+	}
+
+	for _, index := range target.schema.ParseIndexes() {
+		if !migrator.HasIndex(target.value, index.Name) {
+			table.MissingIndexes = append(table.MissingIndexes, index.Name)
+		}
+	}
+	for name := range target.contentKeys {
+		if !migrator.HasIndex(target.value, name) {
+			table.MissingIndexes = append(table.MissingIndexes, name)
+		}
+	}
+	return table, nil
+}
+
+// sameColumnType reports whether a column type of the models matches the type reported by the database.
+// Lengths and precisions are ignored, and aliases of the dialect (such as int8 and bigint) are equal.
+func sameColumnType(migrator gorm.Migrator, modelType, databaseType string) bool {
+	modelBase := baseColumnType(modelType)
+	databaseBase := baseColumnType(databaseType)
+	if modelBase == databaseBase {
+		return true
+	}
+	for _, alias := range migrator.GetTypeAliases(databaseBase) {
+		if baseColumnType(alias) == modelBase {
+			return true
+		}
+	}
+	return false
+}
+
+// serialTypes maps the auto-increment types of PostgreSQL to the integer type reported by the database.
+var serialTypes = map[string]string{
+	"smallserial": "int2",
+	"serial":      "int4",
+	"bigserial":   "int8",
+}
+
+// baseColumnType returns the lower case name of a column type without its length or options,
+// such as "varchar" for "VARCHAR(255)".
+func baseColumnType(columnType string) string {
+	columnType = strings.ToLower(strings.TrimSpace(columnType))
+	if i := strings.IndexAny(columnType, "( "); i >= 0 {
+		columnType = columnType[:i]
+	}
+	if integer, ok := serialTypes[columnType]; ok {
+		return integer
+	}
+	return columnType
+}
+
+// sqlRecorder is a GORM logger recording the statements of a dry run.
+type sqlRecorder struct {
+	statements []string
+}
+
+func (recorder *sqlRecorder) LogMode(logger.LogLevel) logger.Interface {
+	return recorder
+}
+
+func (recorder *sqlRecorder) Info(context.Context, string, ...interface{}) {}
+
+func (recorder *sqlRecorder) Warn(context.Context, string, ...interface{}) {}
+
+func (recorder *sqlRecorder) Error(context.Context, string, ...interface{}) {}
+
+func (recorder *sqlRecorder) Trace(_ context.Context, _ time.Time, fc func() (string, int64), _ error) {
+	statement, _ := fc()
+	recorder.statements = append(recorder.statements, statement)
+}
+
+// comment records a comment among the statements.
+func (recorder *sqlRecorder) comment(text string) {
+	recorder.statements = append(recorder.statements, "-- "+text)
+}
@@ -0,0 +1,158 @@
+package migrate
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/MuhmdHsn313/origin/internal/testdb"
+	"github.com/MuhmdHsn313/origin/orm"
+	"gorm.io/gorm"
+)
+
+// diffArticleV1 is the first version of diffArticle, whose table is migrated before the model changed.
+type diffArticleV1 struct {
+	orm.Model
+
+	Slug   string `json:"slug"`
+	Legacy string `json:"legacy"`
+}
+
+func (diffArticleV1) TableName() string { return "diff_articles" }
+
+// diffArticle adds a column, an index and contents to diffArticleV1, and no longer declares Legacy.
+type diffArticle struct {
+	orm.Model
+
+	Contents []diffArticleContent `json:"contents" gorm:"foreignKey:ArticleID"`
+	Slug     string               `json:"slug"`
+	Views    int                  `json:"views" gorm:"index"`
+}
+
+func (diffArticle) TableName() string { return "diff_articles" }
+
+type diffArticleContent struct {
+	orm.ContentModel
+
+	Title     string `json:"title"`
+	ArticleID uint   `json:"article_id" gorm:"primaryKey"`
+}
+
+// tableDiff returns the difference of a table, failing the test when the table is not part of diff.
+func tableDiff(t *testing.T, diff SchemaDiff, name string) TableDiff {
+	t.Helper()
+	for _, table := range diff.Tables {
+		if table.Table == name {
+			return table
+		}
+	}
+	t.Fatalf("no difference listed for %s in %+v", name, diff.Tables)
+	return TableDiff{}
+}
+
+// execute runs the statements on db, skipping the comments.
+func execute(t *testing.T, db *gorm.DB, statements []string) {
+	t.Helper()
+	for _, statement := range statements {
+		if strings.HasPrefix(statement, "--") {
+			continue
+		}
+		if err := db.Exec(statement).Error; err != nil {
+			t.Fatalf("%s: %v", statement, err)
+		}
+	}
+}
+
+func TestDiff(t *testing.T) {
+	db := testdb.Open(t)
+	if err := db.AutoMigrate(&diffArticleV1{}); err != nil {
+		t.Fatal(err)
+	}
+
+	diff, err := Diff(db, &diffArticle{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff.Empty() {
+		t.Fatal("Diff found no difference")
+	}
+
+	articles := tableDiff(t, diff, "diff_articles")
+	if articles.Missing || !reflect.DeepEqual(articles.AddedColumns, []string{"views"}) ||
+		!reflect.DeepEqual(articles.DroppedColumns, []string{"legacy"}) || len(articles.ChangedColumns) != 0 ||
+		!reflect.DeepEqual(articles.MissingIndexes, []string{"idx_diff_articles_views"}) {
+		t.Errorf("diff of diff_articles = %+v", articles)
+	}
+	contents := tableDiff(t, diff, "diff_article_contents")
+	if !contents.Missing || !reflect.DeepEqual(contents.MissingIndexes, []string{"idx_diff_article_contents_content_key"}) {
+		t.Errorf("diff of diff_article_contents = %+v, want the table and its content key", contents)
+	}
+	for _, line := range []string{
+		"+ column diff_articles.views",
+		"- column diff_articles.legacy",
+		"+ index idx_diff_articles_views on diff_articles",
+		"+ table diff_article_contents",
+	} {
+		if !strings.Contains(diff.String(), line) {
+			t.Errorf("String() = %q, want the line %q", diff.String(), line)
+		}
+	}
+
+	up, down, err := diff.SQL(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(strings.Join(up, "\n"), "-- column diff_articles.legacy is not declared by the models") {
+		t.Errorf("up statements %q do not mention the dropped column", up)
+	}
+
+	// The up statements migrate the schema to the models, only the dropped column is left to migrate by hand.
+	execute(t, db, up)
+	diff, err = Diff(db, &diffArticle{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, table := range diff.Tables {
+		dropped := append([]string(nil), table.DroppedColumns...)
+		sort.Strings(dropped)
+		table.DroppedColumns = nil
+		if table.Table == "diff_articles" && !reflect.DeepEqual(dropped, []string{"legacy"}) {
+			t.Errorf("dropped columns of diff_articles = %v, want [legacy]", dropped)
+		}
+		if !table.empty() {
+			t.Errorf("diff of %s after the up statements = %+v", table.Table, table)
+		}
+	}
+	if !db.Migrator().HasTable(&diffArticleContent{}) || !db.Migrator().HasIndex(&diffArticleContent{}, "idx_diff_article_contents_content_key") {
+		t.Error("the contents table and its content key were not created")
+	}
+
+	// The down statements revert them.
+	execute(t, db, down)
+	diff, err = Diff(db, &diffArticle{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if articles := tableDiff(t, diff, "diff_articles"); !reflect.DeepEqual(articles.AddedColumns, []string{"views"}) {
+		t.Errorf("diff of diff_articles after the down statements = %+v", articles)
+	}
+	if contents := tableDiff(t, diff, "diff_article_contents"); !contents.Missing {
+		t.Error("the contents table was not dropped by the down statements")
+	}
+}
+
+func TestDiffMatchingModels(t *testing.T) {
+	db := testdb.Open(t)
+	if err := orm.AutoMigrate(db, &diffArticle{}, &diffArticleContent{}); err != nil {
+		t.Fatal(err)
+	}
+
+	diff, err := Diff(db, &diffArticle{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !diff.Empty() || diff.String() != "" {
+		t.Errorf("Diff of migrated models = %q, want none", diff.String())
+	}
+}
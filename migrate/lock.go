@@ -0,0 +1,135 @@
+package migrate
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"os"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// lockPollInterval is the delay between two attempts to take the lock.
+const lockPollInterval = 200 * time.Millisecond
+
+// lockRow is the row of the lock table held by the instance running the migrations.
+type lockRow struct {
+	ID       int       `gorm:"primaryKey;autoIncrement:false"`
+	Owner    string    `gorm:"not null"`
+	LockedAt time.Time `gorm:"not null"`
+}
+
+// withLock runs fn while holding the migrations lock, on the connection holding it, after creating the migrations table.
+func (m *Migrator) withLock(ctx context.Context, fn func(db *gorm.DB) error) error {
+	db := m.db.WithContext(ctx)
+	key := m.options.table
+
+	switch db.Dialector.Name() {
+	case "postgres":
+		// Advisory locks belong to a session, every statement must run on the connection holding it.
+		id := lockID(key)
+		return db.Connection(func(conn *gorm.DB) error {
+			tryLock := func() (bool, error) {
+				var locked bool
+				err := conn.Raw("SELECT pg_try_advisory_lock(?)", id).Scan(&locked).Error
+				return locked, err
+			}
+			unlock := func() error {
+				return conn.Exec("SELECT pg_advisory_unlock(?)", id).Error
+			}
+			return m.holdLock(ctx, conn, tryLock, unlock, fn)
+		})
+	case "mysql":
+		return db.Connection(func(conn *gorm.DB) error {
+			tryLock := func() (bool, error) {
+				var locked *int
+				err := conn.Raw("SELECT GET_LOCK(?, 0)", key).Scan(&locked).Error
+				return locked != nil && *locked == 1, err
+			}
+			unlock := func() error {
+				return conn.Exec("SELECT RELEASE_LOCK(?)", key).Error
+			}
+			return m.holdLock(ctx, conn, tryLock, unlock, fn)
+		})
+	default:
+		return m.withTableLock(ctx, db, fn)
+	}
+}
+
+// withTableLock runs fn while holding the row of the lock table, for databases without advisory locks.
+func (m *Migrator) withTableLock(ctx context.Context, db *gorm.DB, fn func(db *gorm.DB) error) error {
+	lockTable := m.options.table + "_lock"
+	if err := db.Table(lockTable).AutoMigrate(&lockRow{}); err != nil && !db.Migrator().HasTable(lockTable) {
+		return err
+	}
+
+	hostname, _ := os.Hostname()
+	owner := fmt.Sprintf("%s:%d:%d", hostname, os.Getpid(), time.Now().UnixNano())
+
+	// Failing to insert the row is expected while another instance holds the lock, it is not logged.
+	quiet := db.Session(&gorm.Session{Logger: db.Logger.LogMode(logger.Silent)})
+	tryLock := func() (bool, error) {
+		err := quiet.Table(lockTable).Create(&lockRow{ID: 1, Owner: owner, LockedAt: time.Now()}).Error
+		if err == nil {
+			return true, nil
+		}
+
+		// The row of a crashed instance is taken over once stale, the next attempt inserts a new one.
+		stale := db.Table(lockTable).Where("id = ? AND locked_at < ?", 1, time.Now().Add(-m.options.staleLockAfter)).Delete(&lockRow{})
+		if stale.Error == nil && stale.RowsAffected > 0 {
+			m.logger.WithField("operation", "MigrateLock").Warn("Took over a stale migrations lock")
+		}
+		return false, nil
+	}
+	unlock := func() error {
+		return db.Table(lockTable).Where("id = ? AND owner = ?", 1, owner).Delete(&lockRow{}).Error
+	}
+	return m.holdLock(ctx, db, tryLock, unlock, fn)
+}
+
+// holdLock takes the lock with tryLock, waiting up to the lock timeout, then runs fn on db and releases the lock.
+func (m *Migrator) holdLock(ctx context.Context, db *gorm.DB, tryLock func() (bool, error), unlock func() error, fn func(db *gorm.DB) error) error {
+	deadline := time.Now().Add(m.options.lockTimeout)
+	for waiting := false; ; waiting = true {
+		locked, err := tryLock()
+		if err != nil {
+			return err
+		}
+		if locked {
+			break
+		}
+
+		if time.Now().After(deadline) {
+			return ErrLocked
+		}
+		if !waiting {
+			m.logger.WithField("operation", "MigrateLock").Info("Waiting for the migrations lock")
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(lockPollInterval):
+		}
+	}
+
+	defer func() {
+		if err := unlock(); err != nil {
+			m.logger.WithField("operation", "MigrateLock").Error("Failed to release the migrations lock: " + err.Error())
+		}
+	}()
+
+	if err := m.ensureTable(db); err != nil {
+		return err
+	}
+	return fn(db)
+}
+
+// lockID derives the key of a PostgreSQL advisory lock from the name of the migrations table.
+func lockID(name string) int64 {
+	hash := fnv.New64a()
+	_, _ = hash.Write([]byte("origin:" + name))
+	return int64(hash.Sum64())
+}
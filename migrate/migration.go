@@ -0,0 +1,195 @@
+// Package migrate applies versioned schema migrations to the database of an Origin application.
+// Migrations are written in Go or SQL, applied in version order and recorded in a schema_migrations
+// table, so that every instance of the application agrees on the state of the schema. A lock guards
+// each run, letting several instances start at once without applying the same migration twice.
+//
+// The package also compares the models of the application to the live schema (see Diff), to help
+// writing the migration of a model change instead of relying on gorm.DB.AutoMigrate in production.
+package migrate
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/MuhmdHsn313/origin/orm"
+	"gorm.io/gorm"
+)
+
+// Migration is a single versioned change of the schema.
+//
+// Fields:
+//   - Version: Orders the migrations and identifies them in the schema_migrations table.
+//     It must be positive and unique, timestamps such as 20250102150405 avoid conflicts between branches.
+//   - Name: Describes the change, such as "create_blogs".
+//   - Up: Applies the change.
+//   - Down: Reverts the change. A migration without Down cannot be reverted.
+//   - NoTransaction: Runs Up and Down outside of a transaction, for statements that cannot run in one
+//     (e.g. CREATE INDEX CONCURRENTLY on PostgreSQL). Migrations run in a transaction by default.
+type Migration struct {
+	Version       int64
+	Name          string
+	Up            func(tx *gorm.DB) error
+	Down          func(tx *gorm.DB) error
+	NoTransaction bool
+}
+
+// SQL returns a migration executing SQL statements, the down statements may be empty when the
+// migration cannot be reverted. Each script is executed at once, so MySQL connections must enable
+// multiStatements for scripts holding several statements.
+func SQL(version int64, name, up, down string) Migration {
+	migration := Migration{
+		Version: version,
+		Name:    name,
+		Up:      execSQL(up),
+	}
+	if down != "" {
+		migration.Down = execSQL(down)
+	}
+	return migration
+}
+
+// execSQL returns a migration step executing the script.
+func execSQL(script string) func(tx *gorm.DB) error {
+	return func(tx *gorm.DB) error {
+		return tx.Exec(script).Error
+	}
+}
+
+// Models returns a migration creating the tables of models with orm.AutoMigrate, and dropping them on Down.
+// It suits the first migration of an application, later changes of the models should be migrations
+// of their own (see Diff), since Models always migrates to the current definition of the models.
+func Models(version int64, name string, models ...interface{}) Migration {
+	return Migration{
+		Version: version,
+		Name:    name,
+		Up: func(tx *gorm.DB) error {
+			return orm.AutoMigrate(tx, models...)
+		},
+		Down: func(tx *gorm.DB) error {
+			// Tables are dropped in reverse order, so that tables referencing others go first.
+			reversed := make([]interface{}, 0, len(models))
+			for i := len(models) - 1; i >= 0; i-- {
+				reversed = append(reversed, models[i])
+			}
+			return tx.Migrator().DropTable(reversed...)
+		},
+	}
+}
+
+// sqlFileName matches the files read by LoadSQL, such as "20250102150405_create_blogs.up.sql".
+var sqlFileName = regexp.MustCompile(`^(\d+)_(.+)\.(up|down)\.sql$`)
+
+// LoadSQL reads the SQL migrations of a directory, typically embedded with go:embed.
+// Files are named "<version>_<name>.up.sql" and "<version>_<name>.down.sql", the down file being optional.
+// Other files are ignored.
+//
+// Parameters:
+//   - fsys: The file system holding the migrations, such as an embed.FS.
+//   - dir: The directory of the migrations in fsys, "." for its root.
+//
+// Returns:
+//   - The migrations sorted by version, or an error if a file cannot be read, a down file has no
+//     up file, or two files of the same version have different names.
+func LoadSQL(fsys fs.FS, dir string) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	type scripts struct {
+		name, up, down string
+		hasUp          bool
+	}
+	byVersion := make(map[int64]*scripts)
+	for _, entry := range entries {
+		match := sqlFileName.FindStringSubmatch(entry.Name())
+		if entry.IsDir() || match == nil {
+			continue
+		}
+
+		version, err := strconv.ParseInt(match[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("migration %s: %w", entry.Name(), err)
+		}
+		content, err := fs.ReadFile(fsys, path.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		script, ok := byVersion[version]
+		if !ok {
+			script = &scripts{name: match[2]}
+			byVersion[version] = script
+		} else if script.name != match[2] {
+			return nil, fmt.Errorf("migration %d is named both %q and %q", version, script.name, match[2])
+		}
+
+		if match[3] == "up" {
+			script.up, script.hasUp = string(content), true
+		} else {
+			script.down = string(content)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for version, script := range byVersion {
+		if !script.hasUp {
+			return nil, fmt.Errorf("migration %d_%s has no up file", version, script.name)
+		}
+		migrations = append(migrations, SQL(version, script.name, script.up, script.down))
+	}
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+	return migrations, nil
+}
+
+// VersionLayout formats the versions of the migrations written by WriteSQL, the time of their creation.
+const VersionLayout = "20060102150405"
+
+// WriteSQL writes an SQL migration to dir as read by LoadSQL, versioned by the current time
+// (e.g. "20250102150405_add_slug.up.sql" and "20250102150405_add_slug.down.sql").
+// Statements are terminated by semicolons, comments ("-- ...") are written as is.
+//
+// Parameters:
+//   - dir: The directory of the migrations, created if needed.
+//   - name: The name of the migration, such as "add_slug".
+//   - up: The statements applying the migration.
+//   - down: The statements reverting it. The down file is left empty when there are none,
+//     which makes the migration irreversible.
+//
+// Returns:
+//   - The paths of the up and down files, or an error if they cannot be written.
+func WriteSQL(dir, name string, up, down []string) ([]string, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+
+	base := time.Now().UTC().Format(VersionLayout) + "_" + name
+	files := []string{
+		filepath.Join(dir, base+".up.sql"),
+		filepath.Join(dir, base+".down.sql"),
+	}
+	for i, statements := range [][]string{up, down} {
+		var script strings.Builder
+		for _, statement := range statements {
+			script.WriteString(statement)
+			if !strings.HasPrefix(statement, "--") {
+				script.WriteString(";")
+			}
+			script.WriteString("\n")
+		}
+		if err := os.WriteFile(files[i], []byte(script.String()), 0o644); err != nil {
+			return nil, err
+		}
+	}
+	return files, nil
+}
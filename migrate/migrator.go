@@ -0,0 +1,385 @@
+package migrate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/MuhmdHsn313/origin/logging"
+	"gorm.io/gorm"
+)
+
+// DefaultTable is the table recording the applied migrations.
+const DefaultTable = "schema_migrations"
+
+var (
+	// ErrLocked is returned when the lock of the migrations is still held by another instance after the lock timeout.
+	ErrLocked = errors.New("migrations are locked by another instance")
+	// ErrIrreversible is returned when reverting a migration without Down.
+	ErrIrreversible = errors.New("migration cannot be reverted")
+	// ErrUnknownVersion is returned for a version that matches no migration.
+	ErrUnknownVersion = errors.New("unknown migration version")
+)
+
+// appliedMigration is a row of the schema_migrations table.
+type appliedMigration struct {
+	Version   int64     `gorm:"primaryKey;autoIncrement:false"`
+	Name      string    `gorm:"not null"`
+	AppliedAt time.Time `gorm:"not null"`
+}
+
+// Option configures a Migrator created by New.
+type Option func(options *options)
+
+type options struct {
+	// table records the applied migrations, its lock table is named after it.
+	table string
+	// lockTimeout is how long a run waits for another instance to release the lock.
+	lockTimeout time.Duration
+	// staleLockAfter is the age after which a lock row is considered left by a crashed instance.
+	staleLockAfter time.Duration
+}
+
+// DefaultLockTimeout is how long a run waits for the lock by default.
+const DefaultLockTimeout = time.Minute
+
+// DefaultStaleLockAfter is the age after which a lock row is taken over by default.
+const DefaultStaleLockAfter = 15 * time.Minute
+
+func defaultOptions() options {
+	return options{
+		table:          DefaultTable,
+		lockTimeout:    DefaultLockTimeout,
+		staleLockAfter: DefaultStaleLockAfter,
+	}
+}
+
+// WithTable sets the table recording the applied migrations, DefaultTable by default.
+func WithTable(table string) Option {
+	return func(options *options) {
+		if table != "" {
+			options.table = table
+		}
+	}
+}
+
+// WithLockTimeout sets how long a run waits for another instance to finish, DefaultLockTimeout by default.
+func WithLockTimeout(timeout time.Duration) Option {
+	return func(options *options) {
+		if timeout > 0 {
+			options.lockTimeout = timeout
+		}
+	}
+}
+
+// WithStaleLockAfter sets the age after which the lock row of a crashed instance is taken over,
+// DefaultStaleLockAfter by default. It only applies to databases locked through a lock table
+// (see Migrator), and must exceed the duration of the longest migration.
+func WithStaleLockAfter(age time.Duration) Option {
+	return func(options *options) {
+		if age > 0 {
+			options.staleLockAfter = age
+		}
+	}
+}
+
+// Migrator applies and reverts a set of migrations on a database.
+//
+// Runs are serialized across instances of the application: PostgreSQL and MySQL use an advisory lock
+// held by the connection running the migrations, other databases (such as SQLite) insert a row in a
+// lock table named after the migrations table, such as schema_migrations_lock.
+type Migrator struct {
+	db         *gorm.DB
+	logger     logging.Logger
+	migrations []Migration
+	options    options
+}
+
+// Status is the state of a migration in the database.
+//
+// Fields:
+//   - Migration: The migration. Only its Version and Name are set when Unknown.
+//   - Applied: True when the migration is recorded in the migrations table.
+//   - AppliedAt: When the migration was applied, zero when it is pending.
+//   - Unknown: True when the migration is applied but is not part of the migrations of the Migrator,
+//     for example when it was applied by a newer version of the application.
+type Status struct {
+	Migration
+	Applied   bool
+	AppliedAt time.Time
+	Unknown   bool
+}
+
+// New creates a Migrator for the given migrations.
+//
+// Parameters:
+//   - db: The database to migrate.
+//   - logger: Logs the migrations as they are applied or reverted, logging.Nop() when nil.
+//   - migrations: The migrations of the application, in any order.
+//   - opts: Options such as WithTable and WithLockTimeout.
+//
+// Returns:
+//   - The Migrator, or an error if a migration has no Up, a version that is not positive or the version of another migration.
+func New(db *gorm.DB, logger logging.Logger, migrations []Migration, opts ...Option) (*Migrator, error) {
+	options := defaultOptions()
+	for _, opt := range opts {
+		opt(&options)
+	}
+	if logger == nil {
+		logger = logging.Nop()
+	}
+
+	sorted := append([]Migration(nil), migrations...)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Version < sorted[j].Version
+	})
+	for i, migration := range sorted {
+		if migration.Version <= 0 {
+			return nil, fmt.Errorf("migration %q: version must be positive", migration.Name)
+		}
+		if migration.Up == nil {
+			return nil, fmt.Errorf("migration %d_%s has no Up", migration.Version, migration.Name)
+		}
+		if i > 0 && sorted[i-1].Version == migration.Version {
+			return nil, fmt.Errorf("migrations %q and %q have the same version %d", sorted[i-1].Name, migration.Name, migration.Version)
+		}
+	}
+
+	return &Migrator{db: db, logger: logger, migrations: sorted, options: options}, nil
+}
+
+// Status returns the state of every migration, known ones in version order followed by unknown applied ones.
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	db := m.db.WithContext(ctx)
+	if err := m.ensureTable(db); err != nil {
+		return nil, err
+	}
+
+	applied, err := m.applied(db)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]Status, 0, len(m.migrations))
+	known := make(map[int64]bool, len(m.migrations))
+	for _, migration := range m.migrations {
+		known[migration.Version] = true
+		status := Status{Migration: migration}
+		if record, ok := applied[migration.Version]; ok {
+			status.Applied = true
+			status.AppliedAt = record.AppliedAt
+		}
+		statuses = append(statuses, status)
+	}
+
+	for _, record := range sortedRecords(applied) {
+		if !known[record.Version] {
+			statuses = append(statuses, Status{
+				Migration: Migration{Version: record.Version, Name: record.Name},
+				Applied:   true,
+				AppliedAt: record.AppliedAt,
+				Unknown:   true,
+			})
+		}
+	}
+	return statuses, nil
+}
+
+// Up applies every pending migration in version order.
+// It returns the applied migrations, including those applied before a failing one.
+func (m *Migrator) Up(ctx context.Context) ([]Migration, error) {
+	return m.UpTo(ctx, 0)
+}
+
+// UpTo applies the pending migrations up to the given version included, or all of them for version 0.
+// It returns the applied migrations, or ErrUnknownVersion if no migration has the version.
+func (m *Migrator) UpTo(ctx context.Context, version int64) ([]Migration, error) {
+	if version != 0 && m.find(version) == nil {
+		return nil, fmt.Errorf("%w: %d", ErrUnknownVersion, version)
+	}
+
+	var done []Migration
+	err := m.withLock(ctx, func(db *gorm.DB) error {
+		applied, err := m.applied(db)
+		if err != nil {
+			return err
+		}
+
+		for _, migration := range m.migrations {
+			if version != 0 && migration.Version > version {
+				break
+			}
+			if _, ok := applied[migration.Version]; ok {
+				continue
+			}
+
+			if err := m.apply(db, migration); err != nil {
+				return err
+			}
+			done = append(done, migration)
+		}
+		return nil
+	})
+	return done, err
+}
+
+// Down reverts the last applied migrations, steps of them, latest first.
+// It returns the reverted migrations, including those reverted before a failing one.
+func (m *Migrator) Down(ctx context.Context, steps int) ([]Migration, error) {
+	if steps <= 0 {
+		return nil, nil
+	}
+
+	var done []Migration
+	err := m.withLock(ctx, func(db *gorm.DB) error {
+		applied, err := m.applied(db)
+		if err != nil {
+			return err
+		}
+
+		records := sortedRecords(applied)
+		for i := len(records) - 1; i >= 0 && len(done) < steps; i-- {
+			migration, err := m.revert(db, records[i])
+			if err != nil {
+				return err
+			}
+			done = append(done, migration)
+		}
+		return nil
+	})
+	return done, err
+}
+
+// DownTo reverts the applied migrations newer than the given version, latest first, version 0 reverting all of them.
+// It returns the reverted migrations, including those reverted before a failing one.
+func (m *Migrator) DownTo(ctx context.Context, version int64) ([]Migration, error) {
+	var done []Migration
+	err := m.withLock(ctx, func(db *gorm.DB) error {
+		applied, err := m.applied(db)
+		if err != nil {
+			return err
+		}
+
+		records := sortedRecords(applied)
+		for i := len(records) - 1; i >= 0 && records[i].Version > version; i-- {
+			migration, err := m.revert(db, records[i])
+			if err != nil {
+				return err
+			}
+			done = append(done, migration)
+		}
+		return nil
+	})
+	return done, err
+}
+
+// apply runs the Up of a migration and records it, in a single transaction unless NoTransaction is set.
+func (m *Migrator) apply(db *gorm.DB, migration Migration) error {
+	fields := logging.Fields{
+		"operation": "MigrateUp",
+		"version":   migration.Version,
+		"name":      migration.Name,
+	}
+	m.logger.WithFields(fields).Info("Applying migration")
+
+	err := m.run(db, migration.NoTransaction, func(tx *gorm.DB) error {
+		if err := migration.Up(tx); err != nil {
+			return err
+		}
+		return tx.Table(m.options.table).Create(&appliedMigration{
+			Version:   migration.Version,
+			Name:      migration.Name,
+			AppliedAt: time.Now(),
+		}).Error
+	})
+	if err != nil {
+		m.logger.WithFields(fields).WithField("error", err.Error()).Error("Failed to apply migration")
+		return fmt.Errorf("migration %d_%s: %w", migration.Version, migration.Name, err)
+	}
+
+	m.logger.WithFields(fields).Info("Migration applied successfully")
+	return nil
+}
+
+// revert runs the Down of an applied migration and removes its record.
+func (m *Migrator) revert(db *gorm.DB, record appliedMigration) (Migration, error) {
+	migration := m.find(record.Version)
+	if migration == nil {
+		return Migration{}, fmt.Errorf("%w: %d_%s is applied but not known", ErrUnknownVersion, record.Version, record.Name)
+	}
+	if migration.Down == nil {
+		return Migration{}, fmt.Errorf("migration %d_%s: %w", migration.Version, migration.Name, ErrIrreversible)
+	}
+
+	fields := logging.Fields{
+		"operation": "MigrateDown",
+		"version":   migration.Version,
+		"name":      migration.Name,
+	}
+	m.logger.WithFields(fields).Info("Reverting migration")
+
+	err := m.run(db, migration.NoTransaction, func(tx *gorm.DB) error {
+		if err := migration.Down(tx); err != nil {
+			return err
+		}
+		return tx.Table(m.options.table).Delete(&appliedMigration{}, "version = ?", migration.Version).Error
+	})
+	if err != nil {
+		m.logger.WithFields(fields).WithField("error", err.Error()).Error("Failed to revert migration")
+		return Migration{}, fmt.Errorf("migration %d_%s: %w", migration.Version, migration.Name, err)
+	}
+
+	m.logger.WithFields(fields).Info("Migration reverted successfully")
+	return *migration, nil
+}
+
+// run calls step in a transaction, or directly when noTransaction is set.
+func (m *Migrator) run(db *gorm.DB, noTransaction bool, step func(tx *gorm.DB) error) error {
+	if noTransaction {
+		return step(db)
+	}
+	return db.Transaction(step)
+}
+
+// find returns the migration of the given version, or nil.
+func (m *Migrator) find(version int64) *Migration {
+	for i := range m.migrations {
+		if m.migrations[i].Version == version {
+			return &m.migrations[i]
+		}
+	}
+	return nil
+}
+
+// ensureTable creates the migrations table when it does not exist.
+func (m *Migrator) ensureTable(db *gorm.DB) error {
+	return db.Table(m.options.table).AutoMigrate(&appliedMigration{})
+}
+
+// applied returns the applied migrations by version.
+func (m *Migrator) applied(db *gorm.DB) (map[int64]appliedMigration, error) {
+	var records []appliedMigration
+	if err := db.Table(m.options.table).Find(&records).Error; err != nil {
+		return nil, err
+	}
+
+	applied := make(map[int64]appliedMigration, len(records))
+	for _, record := range records {
+		applied[record.Version] = record
+	}
+	return applied, nil
+}
+
+// sortedRecords returns the applied migrations in version order.
+func sortedRecords(applied map[int64]appliedMigration) []appliedMigration {
+	records := make([]appliedMigration, 0, len(applied))
+	for _, record := range applied {
+		records = append(records, record)
+	}
+	sort.Slice(records, func(i, j int) bool {
+		return records[i].Version < records[j].Version
+	})
+	return records
+}
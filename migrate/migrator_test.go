@@ -0,0 +1,297 @@
+package migrate
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/MuhmdHsn313/origin/internal/testdb"
+	"github.com/MuhmdHsn313/origin/orm"
+	"gorm.io/gorm"
+)
+
+type migratePost struct {
+	orm.Model
+
+	Contents []migratePostContent `json:"contents" gorm:"foreignKey:PostID"`
+	Slug     string               `json:"slug"`
+}
+
+type migratePostContent struct {
+	orm.ContentModel
+
+	Title  string `json:"title"`
+	PostID uint   `json:"post_id" gorm:"primaryKey"`
+}
+
+// testMigrations creates the posts and their contents, indexes their slugs and adds a column.
+func testMigrations() []Migration {
+	return []Migration{
+		SQL(3, "add_posts_views", "ALTER TABLE migrate_posts ADD COLUMN views integer", "ALTER TABLE migrate_posts DROP COLUMN views"),
+		Models(1, "create_posts", &migratePost{}, &migratePostContent{}),
+		SQL(2, "index_posts_slug", "CREATE INDEX idx_migrate_posts_slug ON migrate_posts (slug)", "DROP INDEX idx_migrate_posts_slug"),
+	}
+}
+
+// versions returns the versions of migrations, in their order.
+func versions(migrations []Migration) []int64 {
+	result := make([]int64, 0, len(migrations))
+	for _, migration := range migrations {
+		result = append(result, migration.Version)
+	}
+	return result
+}
+
+func equalVersions(got []Migration, want ...int64) bool {
+	versions := versions(got)
+	if len(versions) != len(want) {
+		return false
+	}
+	for i := range want {
+		if versions[i] != want[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestMigratorUpAndDown(t *testing.T) {
+	ctx := context.Background()
+	db := testdb.Open(t)
+	migrator, err := New(db, nil, testMigrations())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	applied, err := migrator.UpTo(ctx, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !equalVersions(applied, 1, 2) {
+		t.Fatalf("UpTo(2) applied %v, want [1 2]", versions(applied))
+	}
+	if !db.Migrator().HasTable(&migratePost{}) || !db.Migrator().HasIndex(&migratePost{}, "idx_migrate_posts_slug") {
+		t.Fatal("the posts table and its index were not created")
+	}
+	if db.Migrator().HasColumn(&migratePost{}, "views") {
+		t.Fatal("migration 3 was applied by UpTo(2)")
+	}
+
+	applied, err = migrator.Up(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !equalVersions(applied, 3) || !db.Migrator().HasColumn(&migratePost{}, "views") {
+		t.Fatalf("Up applied %v, want [3]", versions(applied))
+	}
+	if applied, err = migrator.Up(ctx); err != nil || len(applied) != 0 {
+		t.Fatalf("Up applied %v, %v again", versions(applied), err)
+	}
+
+	statuses, err := migrator.Status(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, status := range statuses {
+		if !status.Applied || status.AppliedAt.IsZero() || status.Unknown {
+			t.Errorf("status of %d = %+v, want applied", status.Version, status)
+		}
+	}
+
+	reverted, err := migrator.Down(ctx, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !equalVersions(reverted, 3) || db.Migrator().HasColumn(&migratePost{}, "views") {
+		t.Fatalf("Down(1) reverted %v, want [3]", versions(reverted))
+	}
+
+	reverted, err = migrator.DownTo(ctx, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !equalVersions(reverted, 2, 1) {
+		t.Fatalf("DownTo(0) reverted %v, want [2 1]", versions(reverted))
+	}
+	if db.Migrator().HasTable(&migratePost{}) || db.Migrator().HasTable(&migratePostContent{}) {
+		t.Fatal("the tables of migration 1 were not dropped")
+	}
+
+	if _, err := migrator.UpTo(ctx, 4); !errors.Is(err, ErrUnknownVersion) {
+		t.Errorf("UpTo(4) = %v, want ErrUnknownVersion", err)
+	}
+}
+
+func TestMigratorRollsBackFailedMigrations(t *testing.T) {
+	ctx := context.Background()
+	db := testdb.Open(t)
+	failure := errors.New("failure")
+	migrator, err := New(db, nil, append(testMigrations(),
+		Migration{
+			Version: 4,
+			Name:    "fails",
+			Up: func(tx *gorm.DB) error {
+				if err := tx.Exec("CREATE TABLE migrate_partial (id integer)").Error; err != nil {
+					return err
+				}
+				return failure
+			},
+		},
+	))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	applied, err := migrator.Up(ctx)
+	if !errors.Is(err, failure) {
+		t.Fatalf("Up = %v, want the failure of migration 4", err)
+	}
+	if !equalVersions(applied, 1, 2, 3) {
+		t.Errorf("Up applied %v before failing, want [1 2 3]", versions(applied))
+	}
+	if db.Migrator().HasTable("migrate_partial") {
+		t.Error("the failed migration was not rolled back")
+	}
+
+	statuses, err := migrator.Status(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if last := statuses[len(statuses)-1]; last.Version != 4 || last.Applied {
+		t.Errorf("status of the failed migration = %+v, want pending", last)
+	}
+
+	// The failed migration is pending, Down reverts the last applied one.
+	if _, err := migrator.Down(ctx, 1); err != nil {
+		t.Fatal(err)
+	}
+	if db.Migrator().HasColumn(&migratePost{}, "views") {
+		t.Error("Down(1) did not revert migration 3")
+	}
+}
+
+func TestMigratorIrreversible(t *testing.T) {
+	ctx := context.Background()
+	db := testdb.Open(t)
+	migrator, err := New(db, nil, append(testMigrations(), SQL(4, "irreversible", "CREATE TABLE migrate_kept (id integer)", "")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := migrator.Up(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	reverted, err := migrator.Down(ctx, 2)
+	if !errors.Is(err, ErrIrreversible) || len(reverted) != 0 {
+		t.Errorf("Down(2) = %v, %v, want ErrIrreversible before reverting anything", versions(reverted), err)
+	}
+	if !db.Migrator().HasTable("migrate_kept") || !db.Migrator().HasColumn(&migratePost{}, "views") {
+		t.Error("a migration was reverted")
+	}
+}
+
+func TestNewRejectsInvalidMigrations(t *testing.T) {
+	db := testdb.Open(t)
+	up := func(tx *gorm.DB) error { return nil }
+	for name, migrations := range map[string][]Migration{
+		"version": {{Version: 0, Name: "zero", Up: up}},
+		"up":      {{Version: 1, Name: "no_up"}},
+		"twice":   {{Version: 1, Name: "first", Up: up}, {Version: 1, Name: "second", Up: up}},
+	} {
+		if _, err := New(db, nil, migrations); err == nil {
+			t.Errorf("New accepted the %s migrations", name)
+		}
+	}
+}
+
+func TestMigratorLock(t *testing.T) {
+	ctx := context.Background()
+	db := testdb.Open(t)
+	lockTable := DefaultTable + "_lock"
+	if err := db.Table(lockTable).AutoMigrate(&lockRow{}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Another instance holds the lock: the run waits for it, then gives up.
+	if err := db.Table(lockTable).Create(&lockRow{ID: 1, Owner: "other", LockedAt: time.Now()}).Error; err != nil {
+		t.Fatal(err)
+	}
+	migrator, err := New(db, nil, testMigrations(), WithLockTimeout(300*time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now()
+	if _, err := migrator.Up(ctx); !errors.Is(err, ErrLocked) {
+		t.Fatalf("Up = %v, want ErrLocked", err)
+	}
+	if waited := time.Since(start); waited < 300*time.Millisecond {
+		t.Errorf("Up gave up after %s, before the lock timeout", waited)
+	}
+	if db.Migrator().HasTable(&migratePost{}) {
+		t.Fatal("a migration was applied without the lock")
+	}
+
+	// The lock of a crashed instance is taken over once stale.
+	if err := db.Table(lockTable).Where("id = ?", 1).Update("locked_at", time.Now().Add(-time.Hour)).Error; err != nil {
+		t.Fatal(err)
+	}
+	migrator, err = New(db, nil, testMigrations(), WithLockTimeout(5*time.Second), WithStaleLockAfter(time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if applied, err := migrator.Up(ctx); err != nil || len(applied) != 3 {
+		t.Fatalf("Up = %v, %v, want the 3 migrations applied after taking over the lock", versions(applied), err)
+	}
+
+	var rows int64
+	if err := db.Table(lockTable).Count(&rows).Error; err != nil {
+		t.Fatal(err)
+	}
+	if rows != 0 {
+		t.Errorf("%d lock rows left, want the lock released", rows)
+	}
+}
+
+func TestMigratorLockSerializesInstances(t *testing.T) {
+	ctx := context.Background()
+	db := testdb.Open(t)
+
+	var runs atomic.Int32
+	migrations := []Migration{{
+		Version: 1,
+		Name:    "slow",
+		Up: func(tx *gorm.DB) error {
+			runs.Add(1)
+			time.Sleep(300 * time.Millisecond)
+			return tx.Exec("CREATE TABLE migrate_slow (id integer)").Error
+		},
+	}}
+
+	// Each instance has a migrator of its own, sharing the database.
+	var wg sync.WaitGroup
+	errs := make([]error, 3)
+	for i := range errs {
+		migrator, err := New(db, nil, migrations, WithLockTimeout(10*time.Second))
+		if err != nil {
+			t.Fatal(err)
+		}
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, errs[i] = migrator.Up(ctx)
+		}(i)
+	}
+	wg.Wait()
+
+	for i, err := range errs {
+		if err != nil {
+			t.Errorf("instance %d: %v", i, err)
+		}
+	}
+	if runs.Load() != 1 {
+		t.Errorf("the migration ran %d times, want once", runs.Load())
+	}
+}
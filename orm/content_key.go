@@ -0,0 +1,184 @@
+package orm
+
+import (
+	"fmt"
+	"reflect"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+// LanguageField is the name of the field holding the language of a content model, see ContentModel.
+const LanguageField = "LanguageID"
+
+// IsContentRelation reports whether an association is a collection of multilingual content, either
+// because its elements implement IContentModel or because the field is tagged with `origin:"contents"`.
+func IsContentRelation(relation *schema.Relationship) bool {
+	if relation.Type != schema.HasMany {
+		return false
+	}
+
+	iContentModelType := reflect.TypeOf((*IContentModel)(nil)).Elem()
+	modelType := relation.FieldSchema.ModelType
+	if modelType.Implements(iContentModelType) || reflect.PointerTo(modelType).Implements(iContentModelType) {
+		return true
+	}
+	return ParseFieldOptions(relation.Field.StructField).Contents
+}
+
+// ContentKey returns the fields identifying a row of a content collection: the foreign keys pointing
+// to the parent record (e.g. BlogID) followed by the language (LanguageID). A parent has at most one
+// content row per language, so that content is upserted by this key instead of being duplicated.
+//
+// A content whose primary key holds LanguageID without the foreign keys, such as ContentModel embedded without
+// tagging BlogID with `gorm:"primaryKey"`, is rejected: its table would hold a single content per language
+// for all the parents, the content of a parent overwriting that of the others.
+//
+// Parameters:
+//   - relation: A content relation, see IsContentRelation.
+//
+// Returns:
+//   - The key fields of the content schema, or an error if the content has no LanguageID field or is keyed by
+//     its language without its parent.
+func ContentKey(relation *schema.Relationship) ([]*schema.Field, error) {
+	languageField := relation.FieldSchema.LookUpField(LanguageField)
+	if languageField == nil {
+		return nil, fmt.Errorf("content %s of %s has no %s field", relation.FieldSchema.Name, relation.Schema.Name, LanguageField)
+	}
+
+	key := make([]*schema.Field, 0, len(relation.References)+1)
+	for _, reference := range relation.References {
+		if languageField.PrimaryKey && !reference.ForeignKey.PrimaryKey {
+			return nil, fmt.Errorf("content %s of %s is keyed by its %s without its parent, tag its %s field with `gorm:\"primaryKey\"`",
+				relation.FieldSchema.Name, relation.Schema.Name, LanguageField, reference.ForeignKey.Name)
+		}
+		key = append(key, reference.ForeignKey)
+	}
+	return append(key, languageField), nil
+}
+
+// ContentKeyColumns returns the columns of ContentKey, as the conflict target of content upserts.
+func ContentKeyColumns(relation *schema.Relationship) ([]clause.Column, error) {
+	key, err := ContentKey(relation)
+	if err != nil {
+		return nil, err
+	}
+
+	columns := make([]clause.Column, 0, len(key))
+	for _, field := range key {
+		columns = append(columns, clause.Column{Name: field.DBName})
+	}
+	return columns, nil
+}
+
+// ContentKeyIndex returns the name of the unique index created on the content key by AutoMigrate,
+// such as "idx_blog_contents_content_key".
+func ContentKeyIndex(relation *schema.Relationship) string {
+	return fmt.Sprintf("idx_%s_content_key", relation.FieldSchema.Table)
+}
+
+// HasUniqueContentKey reports whether the rows of a content relation are unique by their content key in db,
+// so that they can be upserted with the content key as conflict target: either the primary key of the content
+// schema is the content key, such as a content model tagging its BlogID field with `gorm:"primaryKey"`, or the
+// unique index created by AutoMigrate (see ContentKeyIndex) exists.
+//
+// Parameters:
+//   - db: The database holding the content table.
+//   - relation: A content relation, see IsContentRelation.
+//
+// Returns:
+//   - Whether the content key is unique, or an error if the content has no LanguageID field.
+func HasUniqueContentKey(db *gorm.DB, relation *schema.Relationship) (bool, error) {
+	key, err := ContentKey(relation)
+	if err != nil {
+		return false, err
+	}
+
+	primary := relation.FieldSchema.PrimaryFields
+	if len(primary) == len(key) {
+		unique := true
+		for _, field := range key {
+			unique = unique && field.PrimaryKey
+		}
+		if unique {
+			return true, nil
+		}
+	}
+
+	contentModel := reflect.New(relation.FieldSchema.ModelType).Interface()
+	return db.Migrator().HasIndex(contentModel, ContentKeyIndex(relation)), nil
+}
+
+// AutoMigrate migrates the given models like gorm.DB.AutoMigrate. It also creates a unique index on the
+// content key of every content collection (see ContentKeyIndex), so that content upserts have a conflict
+// target whatever the primary key of the content table. The primary key is the one declared by the content
+// model: ContentModel only declares LanguageID, the foreign key to the parent is added to it by tagging it
+// with `gorm:"primaryKey"`, so that a table holds the contents of several parents in the same language.
+// The models whose contents are keyed by their language without that foreign key are rejected before
+// any table is migrated, see ContentKey.
+//
+// Parameters:
+//   - db: The database to migrate.
+//   - models: The models to migrate, parents and contents alike, such as &Blog{}, &BlogContent{}.
+//
+// Returns:
+//   - An error if a model has an invalid content key, cannot be migrated or an index cannot be created.
+func AutoMigrate(db *gorm.DB, models ...interface{}) error {
+	var relations []*schema.Relationship
+	for _, model := range models {
+		stmt := &gorm.Statement{DB: db}
+		if err := stmt.Parse(model); err != nil {
+			return err
+		}
+
+		for _, relation := range stmt.Schema.Relationships.HasMany {
+			if !IsContentRelation(relation) {
+				continue
+			}
+			if _, err := ContentKey(relation); err != nil {
+				return err
+			}
+			relations = append(relations, relation)
+		}
+	}
+
+	if err := db.AutoMigrate(models...); err != nil {
+		return err
+	}
+
+	migrator := db.Migrator()
+	for _, relation := range relations {
+		// The content table may be migrated separately, its index is created once it exists.
+		contentModel := reflect.New(relation.FieldSchema.ModelType).Interface()
+		name := ContentKeyIndex(relation)
+		if !migrator.HasTable(contentModel) || migrator.HasIndex(contentModel, name) {
+			continue
+		}
+
+		if err := CreateContentKeyIndex(db, relation); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// CreateContentKeyIndex creates the unique index on the content key of a content relation, named by ContentKeyIndex.
+func CreateContentKeyIndex(db *gorm.DB, relation *schema.Relationship) error {
+	columns, err := ContentKeyColumns(relation)
+	if err != nil {
+		return err
+	}
+
+	indexColumns := make([]interface{}, 0, len(columns))
+	for _, column := range columns {
+		indexColumns = append(indexColumns, column)
+	}
+
+	name := ContentKeyIndex(relation)
+	err = db.Exec("CREATE UNIQUE INDEX ? ON ??", clause.Column{Name: name}, clause.Table{Name: relation.FieldSchema.Table}, indexColumns).Error
+	if err != nil {
+		return fmt.Errorf("create index %s: %w", name, err)
+	}
+	return nil
+}
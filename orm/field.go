@@ -0,0 +1,161 @@
+package orm
+
+import (
+	"reflect"
+	"strings"
+	"unicode"
+)
+
+// FieldTagName is the struct tag key used by Origin to declare per-field behaviour
+// on models, for example `origin:"readonly"` or `origin:"hidden,roles=admin|staff"`.
+const FieldTagName = "origin"
+
+// FieldOptions describes how a single model field may be written and read by API clients.
+// It is parsed from the `origin` struct tag of the field.
+//
+// Supported options:
+//   - readonly: The field is returned in responses but never accepted from clients.
+//     It is omitted from the generated create and update parameters (e.g. an Owner set server-side).
+//   - writeonly: The field is accepted from clients but never returned in responses
+//     (e.g. a password or a secret token).
+//   - hidden: The field is internal to the server. It is neither accepted nor returned.
+//   - roles=a|b: Roles that are still allowed to read a writeonly or hidden field.
+//   - contents: The field is a collection of multilingual content, merged by language on update,
+//     even when its elements do not implement IContentModel themselves (they must have a LanguageID field).
+//
+// Fields:
+//   - ReadOnly: True when the readonly option is present.
+//   - WriteOnly: True when the writeonly option is present.
+//   - Hidden: True when the hidden option is present.
+//   - Roles: The roles allowed to read the field despite WriteOnly or Hidden.
+//   - Contents: True when the contents option is present.
+type FieldOptions struct {
+	ReadOnly  bool
+	WriteOnly bool
+	Hidden    bool
+	Roles     []string
+	Contents  bool
+}
+
+// ParseFieldOptions reads the `origin` struct tag of a field and returns its options.
+// Unknown options are ignored so that later versions can extend the tag safely.
+//
+// Parameters:
+//   - field: The struct field to inspect.
+//
+// Returns:
+//   - The parsed FieldOptions. A field without the tag yields the zero value,
+//     which means the field is both writable and readable.
+func ParseFieldOptions(field reflect.StructField) FieldOptions {
+	var options FieldOptions
+
+	tag, ok := field.Tag.Lookup(FieldTagName)
+	if !ok {
+		return options
+	}
+
+	for _, part := range strings.Split(tag, ",") {
+		part = strings.TrimSpace(part)
+		switch {
+		case part == "readonly":
+			options.ReadOnly = true
+		case part == "writeonly":
+			options.WriteOnly = true
+		case part == "hidden":
+			options.Hidden = true
+		case part == "contents":
+			options.Contents = true
+		case strings.HasPrefix(part, "roles="):
+			for _, role := range strings.Split(strings.TrimPrefix(part, "roles="), "|") {
+				if role = strings.TrimSpace(role); role != "" {
+					options.Roles = append(options.Roles, role)
+				}
+			}
+		}
+	}
+
+	return options
+}
+
+// IsWritable reports whether clients are allowed to set the field on create or update.
+func (options FieldOptions) IsWritable() bool {
+	return !options.ReadOnly && !options.Hidden
+}
+
+// IsReadableBy reports whether a caller with the given role may see the field in responses.
+// Fields that are neither writeonly nor hidden are readable by everyone.
+func (options FieldOptions) IsReadableBy(role string) bool {
+	if !options.WriteOnly && !options.Hidden {
+		return true
+	}
+
+	for _, allowed := range options.Roles {
+		if allowed == role {
+			return true
+		}
+	}
+	return false
+}
+
+// IsPublic reports whether the field is readable by every caller regardless of role.
+func (options FieldOptions) IsPublic() bool {
+	return !options.WriteOnly && !options.Hidden
+}
+
+// IsContentField reports whether a model field is a collection of multilingual content,
+// such as `Contents []BlogContent` or `Translations []PostTranslation`.
+//
+// A field is a content collection when it is a slice whose elements (or pointers to them)
+// implement IContentModel, or when it is tagged with `origin:"contents"`. The field name
+// does not matter, so a model may declare several content collections.
+//
+// Parameters:
+//   - field: The struct field to inspect.
+//
+// Returns:
+//   - true if the field holds content models that are merged by language identifier.
+func IsContentField(field reflect.StructField) bool {
+	if field.Type.Kind() != reflect.Slice {
+		return false
+	}
+	if ParseFieldOptions(field).Contents {
+		return true
+	}
+
+	elemType := field.Type.Elem()
+	for elemType.Kind() == reflect.Ptr {
+		elemType = elemType.Elem()
+	}
+	contentType := reflect.TypeOf((*IContentModel)(nil)).Elem()
+	return elemType.Kind() == reflect.Struct &&
+		(elemType.Implements(contentType) || reflect.PointerTo(elemType).Implements(contentType))
+}
+
+// FilterName returns the name of the query parameter filtering on the model field named name, in snake_case.
+// Every upper-case letter starts a word, like the route and parameter names of the service package
+// ("IsPublished" becomes "is_published"), except the ID suffix of the foreign keys and the language of
+// the content models, which is kept as one word: "AuthorID" becomes "author_id" rather than "author_i_d".
+//
+// Parameters:
+//   - name: The Go name of the field, such as "AuthorID".
+//
+// Returns:
+//   - The name of the query parameter, such as "author_id".
+func FilterName(name string) string {
+	if prefix, ok := strings.CutSuffix(name, "ID"); ok && prefix != "" {
+		return splitWords(prefix) + "_id"
+	}
+	return splitWords(name)
+}
+
+// splitWords converts name to snake_case, starting a word at every upper-case letter.
+func splitWords(name string) string {
+	var result []rune
+	for i, r := range name {
+		if unicode.IsUpper(r) && i > 0 {
+			result = append(result, '_')
+		}
+		result = append(result, unicode.ToLower(r))
+	}
+	return string(result)
+}
@@ -0,0 +1,200 @@
+package orm_test
+
+import (
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/MuhmdHsn313/origin/orm"
+	"gorm.io/gorm/schema"
+)
+
+func TestFilterName(t *testing.T) {
+	tests := []struct {
+		name, want string
+	}{
+		{"Title", "title"},
+		{"IsPublished", "is_published"},
+		{"AuthorID", "author_id"},
+		{"LanguageID", "language_id"},
+		{"HTMLPageID", "h_t_m_l_page_id"},
+		{"Page2", "page2"},
+	}
+	for _, test := range tests {
+		if got := orm.FilterName(test.name); got != test.want {
+			t.Errorf("FilterName(%q) = %q, want %q", test.name, got, test.want)
+		}
+	}
+}
+
+func TestParseFieldOptions(t *testing.T) {
+	tests := []struct {
+		tag  reflect.StructTag
+		want orm.FieldOptions
+	}{
+		{``, orm.FieldOptions{}},
+		{`json:"title"`, orm.FieldOptions{}},
+		{`origin:"readonly"`, orm.FieldOptions{ReadOnly: true}},
+		{`origin:"writeonly"`, orm.FieldOptions{WriteOnly: true}},
+		{`origin:"hidden,roles=admin|staff"`, orm.FieldOptions{Hidden: true, Roles: []string{"admin", "staff"}}},
+		{`origin:" writeonly , roles= admin | "`, orm.FieldOptions{WriteOnly: true, Roles: []string{"admin"}}},
+		{`origin:"contents"`, orm.FieldOptions{Contents: true}},
+		// Unknown options are ignored.
+		{`origin:"readonly,sortable"`, orm.FieldOptions{ReadOnly: true}},
+	}
+	for _, test := range tests {
+		got := orm.ParseFieldOptions(reflect.StructField{Name: "Field", Tag: test.tag})
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("ParseFieldOptions(%q) = %+v, want %+v", test.tag, got, test.want)
+		}
+	}
+}
+
+func TestFieldOptionsAccess(t *testing.T) {
+	tests := []struct {
+		tag      reflect.StructTag
+		writable bool
+		public   bool
+		// readers are the roles allowed to read the field among "", "admin" and "staff".
+		readers []string
+	}{
+		{``, true, true, []string{"", "admin", "staff"}},
+		{`origin:"readonly"`, false, true, []string{"", "admin", "staff"}},
+		{`origin:"writeonly"`, true, false, nil},
+		{`origin:"writeonly,roles=admin"`, true, false, []string{"admin"}},
+		{`origin:"hidden"`, false, false, nil},
+		{`origin:"hidden,roles=admin|staff"`, false, false, []string{"admin", "staff"}},
+		// Roles only matter for the fields that are not readable by everyone.
+		{`origin:"readonly,roles=admin"`, false, true, []string{"", "admin", "staff"}},
+	}
+	for _, test := range tests {
+		options := orm.ParseFieldOptions(reflect.StructField{Name: "Field", Tag: test.tag})
+		if options.IsWritable() != test.writable {
+			t.Errorf("%q: IsWritable() = %v, want %v", test.tag, options.IsWritable(), test.writable)
+		}
+		if options.IsPublic() != test.public {
+			t.Errorf("%q: IsPublic() = %v, want %v", test.tag, options.IsPublic(), test.public)
+		}
+		var readers []string
+		for _, role := range []string{"", "admin", "staff"} {
+			if options.IsReadableBy(role) {
+				readers = append(readers, role)
+			}
+		}
+		if !reflect.DeepEqual(readers, test.readers) {
+			t.Errorf("%q is readable by %q, want %q", test.tag, readers, test.readers)
+		}
+	}
+}
+
+type fieldTestContent struct {
+	orm.ContentModel
+
+	Title string `json:"title"`
+}
+
+// fieldTestTranslation has a LanguageID without implementing IContentModel.
+type fieldTestTranslation struct {
+	LanguageID string `json:"language_id"`
+	Summary    string `json:"summary"`
+}
+
+type fieldTestTag struct {
+	Name string `json:"name"`
+}
+
+// fieldTestPost declares several content collections, by element type and by tag, next to other fields.
+type fieldTestPost struct {
+	orm.Model
+
+	Contents     []fieldTestContent     `json:"contents"`
+	Drafts       []*fieldTestContent    `json:"drafts"`
+	Translations []fieldTestTranslation `json:"translations" origin:"contents"`
+	Tags         []fieldTestTag         `json:"tags"`
+	Keywords     []string               `json:"keywords"`
+	Content      fieldTestContent       `json:"content"`
+	Featured     *fieldTestContent      `json:"featured"`
+	Summary      fieldTestTranslation   `json:"summary" origin:"contents"`
+}
+
+func TestIsContentField(t *testing.T) {
+	tests := []struct {
+		field string
+		want  bool
+	}{
+		{"Contents", true},
+		// Pointer elements are content too.
+		{"Drafts", true},
+		// The tag marks a collection whose elements do not implement IContentModel.
+		{"Translations", true},
+		{"Tags", false},
+		{"Keywords", false},
+		// A single content is not a collection, even when tagged.
+		{"Content", false},
+		{"Featured", false},
+		{"Summary", false},
+	}
+	postType := reflect.TypeOf(fieldTestPost{})
+	for _, test := range tests {
+		field, ok := postType.FieldByName(test.field)
+		if !ok {
+			t.Fatalf("no field %s", test.field)
+		}
+		if got := orm.IsContentField(field); got != test.want {
+			t.Errorf("IsContentField(%s) = %v, want %v", test.field, got, test.want)
+		}
+	}
+
+	// A model may declare several content collections, whatever their names.
+	var collections []string
+	for _, field := range reflect.VisibleFields(postType) {
+		if orm.IsContentField(field) {
+			collections = append(collections, field.Name)
+		}
+	}
+	if want := []string{"Contents", "Drafts", "Translations"}; !reflect.DeepEqual(collections, want) {
+		t.Errorf("the content collections are %q, want %q", collections, want)
+	}
+}
+
+type relationTestPost struct {
+	orm.Model
+
+	Contents     []relationTestContent     `json:"contents" gorm:"foreignKey:PostID"`
+	Translations []relationTestTranslation `json:"translations" gorm:"foreignKey:PostID" origin:"contents"`
+	Comments     []relationTestComment     `json:"comments" gorm:"foreignKey:PostID"`
+}
+
+type relationTestContent struct {
+	orm.ContentModel
+
+	PostID uint `json:"post_id" gorm:"primaryKey"`
+}
+
+type relationTestTranslation struct {
+	LanguageID string `json:"language_id" gorm:"primaryKey"`
+	PostID     uint   `json:"post_id" gorm:"primaryKey"`
+}
+
+type relationTestComment struct {
+	orm.Model
+
+	PostID uint `json:"post_id"`
+}
+
+// TestIsContentRelation checks that the content collections of a model are told apart from its other has-many
+// associations, as GORM parses them.
+func TestIsContentRelation(t *testing.T) {
+	s, err := schema.Parse(&relationTestPost{}, &sync.Map{}, schema.NamingStrategy{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := map[string]bool{}
+	for _, relation := range s.Relationships.HasMany {
+		got[relation.Name] = orm.IsContentRelation(relation)
+	}
+	if want := map[string]bool{"Contents": true, "Translations": true, "Comments": false}; !reflect.DeepEqual(got, want) {
+		t.Errorf("the content relations are %v, want %v", got, want)
+	}
+}
@@ -7,6 +7,9 @@ package orm
 import (
 	"reflect"
 	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
 )
 
 // Model is a base struct embedding common fields for all database entities.
@@ -22,6 +25,34 @@ type Model struct {
 	UpdatedAt time.Time `json:"updated_at" gorm:"not null;autoUpdateTime:milli"`
 }
 
+// UUIDModel is the counterpart of Model for entities identified by a UUID instead of an
+// auto-incremented integer, for example when IDs must not be guessable or are created offline.
+//
+// Fields:
+//   - ID: Unique identifier for the record, generated before the record is created unless already set.
+//   - CreatedAt: Timestamp when the record is first created.
+//   - UpdatedAt: Timestamp that updates automatically whenever the record is modified.
+//
+// The column uses the "uuid" type of PostgreSQL (SQLite stores it as text), use a char(36)
+// column on MySQL by redeclaring the ID with its own gorm tag.
+type UUIDModel struct {
+	ID        uuid.UUID `json:"id" gorm:"type:uuid;primaryKey"`
+	CreatedAt time.Time `json:"created_at" gorm:"not null;autoCreateTime"`
+	UpdatedAt time.Time `json:"updated_at" gorm:"not null;autoUpdateTime:milli"`
+}
+
+// BeforeCreate is the GORM hook generating the ID of a new record when it is not set.
+func (model *UUIDModel) BeforeCreate(tx *gorm.DB) error {
+	if model.ID == uuid.Nil {
+		id, err := uuid.NewRandom()
+		if err != nil {
+			return err
+		}
+		model.ID = id
+	}
+	return nil
+}
+
 // IContentModel is an interface that must be implemented by all content models that
 // support multilingual content. The sole responsibility of this interface is to return
 // a language identifier, which is used to uniquely identify content by language.
@@ -56,32 +87,39 @@ type Language struct {
 //   - inputContents: A new set of content models which can add new languages or override existing ones.
 //
 // Returns:
-//   - A merged slice of content models, with each unique language identified exactly once.
+//   - A merged slice of content models, with each unique language identified exactly once. The items keep
+//     the order of currentContents, followed by the new languages in the order of inputContents.
 //
 // This function is useful for performing idempotent updates on multilingual content,
 // ensuring that the latest content is present based on the language identifier.
 func GetAllContentsWithUpdated[CM IContentModel](currentContents, inputContents []CM) []CM {
-	// Initialize a map keyed by LanguageID to hold merged contents.
-	// The map capacity is preallocated based on the total length of both slices.
-	contentMap := make(map[string]CM, len(currentContents)+len(inputContents))
-
-	// Add all existing content models into the map using their language identifier as the key.
-	for _, content := range currentContents {
-		contentMap[content.GetLanguageID()] = content
-	}
-
-	// Iterate over the new input content models, overwriting any that share the same language ID.
-	// This ensures that any update in inputContents takes precedence over currentContents.
-	for _, content := range inputContents {
-		contentMap[content.GetLanguageID()] = content
-	}
+	return MergeContentsByLanguage(currentContents, inputContents, CM.GetLanguageID)
+}
 
-	// Prepare the final merged result slice by collecting all values from the map.
-	contents := make([]CM, 0, len(contentMap))
-	for _, content := range contentMap {
+// MergeContentsByLanguage merges two slices of content like GetAllContentsWithUpdated, for content
+// types that do not implement IContentModel (collections tagged with `origin:"contents"`).
+// The language identifier of each item is read with the given function.
+//
+// Parameters:
+//   - currentContents: The current set of content items.
+//   - inputContents: A new set of content items which can add new languages or override existing ones.
+//   - language: Returns the language identifier of an item, usually its LanguageID field.
+//
+// Returns:
+//   - A merged slice of content items, with each unique language identified exactly once, ordered like
+//     those of GetAllContentsWithUpdated.
+func MergeContentsByLanguage[C any](currentContents, inputContents []C, language func(C) string) []C {
+	// positions maps each language to the index of its item in contents.
+	positions := make(map[string]int, len(currentContents)+len(inputContents))
+	contents := make([]C, 0, len(currentContents)+len(inputContents))
+	for _, content := range append(append([]C(nil), currentContents...), inputContents...) {
+		if i, ok := positions[language(content)]; ok {
+			contents[i] = content
+			continue
+		}
+		positions[language(content)] = len(contents)
 		contents = append(contents, content)
 	}
-
 	return contents
 }
 
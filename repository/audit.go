@@ -0,0 +1,235 @@
+package repository
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Mutation is a change of a record made by a GenericRepository, recorded by its auditors.
+//
+// Fields:
+//   - Model: The snake_case name of the model, such as "blog_post".
+//   - Operation: The operation of the repository, "Create", "Update", "Revert" or "Delete".
+//   - RecordID: The primary key of the record, its values joined by commas when composite (see RecordID).
+//   - Before: The record as stored before the operation, with its associations, nil for a creation.
+//   - After: The record as stored after the operation, with its associations, nil for a deletion.
+type Mutation struct {
+	Model     string
+	Operation string
+	RecordID  string
+	Before    interface{}
+	After     interface{}
+}
+
+// Auditor records the mutations of a GenericRepository, for example in an audit log (see the audit package).
+// Record is called with the transaction of the mutation once the record is written, so that the mutation
+// and its record are committed together: an error rolls the mutation back.
+type Auditor interface {
+	Record(tx *gorm.DB, mutation Mutation) error
+}
+
+// CommitObserver is notified of the mutations of a repository once they are committed, such as an events.Bus,
+// which must not announce the mutations that are rolled back. It is given to the repositories of a service by
+// service.WithEvents (see WithCommitObserver), or is an auditor notified of the mutations it recorded.
+type CommitObserver interface {
+	// Committed is called after the commit of the transaction of the mutation, with the database of the
+	// repository, outside of the transaction, in the context of the operation.
+	Committed(db *gorm.DB, mutation Mutation)
+}
+
+// ObservableRepository is a Repository whose committed mutations can be observed by the user of a copy of it,
+// such as a service emitting the events of the mutations it makes.
+type ObservableRepository[T any] interface {
+	Repository[T]
+	// WithCommitObserver returns a repository notifying observer of the mutations it commits, as well as the
+	// observers of the receiver, which is left unchanged.
+	WithCommitObserver(observer CommitObserver) Repository[T]
+}
+
+// WithCommitObserver returns repo notifying observer of the mutations it commits when it is an
+// ObservableRepository, repo otherwise.
+func WithCommitObserver[T any](repo Repository[T], observer CommitObserver) Repository[T] {
+	if observable, ok := repo.(ObservableRepository[T]); ok {
+		return observable.WithCommitObserver(observer)
+	}
+	return repo
+}
+
+// WithCommitObserver returns a copy of the repository notifying observer of the mutations it commits.
+func (r *GenericRepository[T]) WithCommitObserver(observer CommitObserver) Repository[T] {
+	clone := *r
+	clone.options.commitObservers = append(append([]CommitObserver(nil), r.options.commitObservers...), observer)
+	return &clone
+}
+
+// WithCommitObserver returns a copy of the repository notifying observer of the mutations committed by the
+// wrapped repository.
+func (r *ScopedRepository[T]) WithCommitObserver(observer CommitObserver) Repository[T] {
+	clone := *r
+	clone.repo = WithCommitObserver(r.repo, observer)
+	return &clone
+}
+
+// WithAuditor records the creations, updates and deletions of the repository with auditor.
+// The records are loaded before updates and deletions, with their associations, to record their changes.
+// An auditor implementing CommitObserver is notified of the mutations once they are committed.
+func WithAuditor(auditor Auditor) RepositoryOption {
+	return func(options *repositoryOptions) {
+		if auditor != nil {
+			options.auditors = append(options.auditors, auditor)
+		}
+	}
+}
+
+// RecordIdentifier is implemented by the repositories telling the record ID of a model, see RecordID.
+type RecordIdentifier[T any] interface {
+	// RecordID returns the ID under which the mutations of model are recorded, from its key fields.
+	RecordID(model *T) (string, error)
+}
+
+// RecordID returns the ID under which the mutations of model are recorded by the auditors of repo,
+// such as "42", or "42,en" for the content of a parent. Only the key fields of model need to be set.
+//
+// Parameters:
+//   - repo: The repository of the model, a RecordIdentifier such as GenericRepository or ScopedRepository.
+//   - model: The model, its key fields set.
+//
+// Returns:
+//   - The record ID, or an error if repo cannot tell it and model has no ID field.
+func RecordID[T any](repo Repository[T], model *T) (string, error) {
+	if identifier, ok := repo.(RecordIdentifier[T]); ok {
+		return identifier.RecordID(model)
+	}
+	if id := primaryKey(model); id != nil {
+		return fmt.Sprint(id), nil
+	}
+	return "", fmt.Errorf("cannot identify the records of %s", ModelName[T]())
+}
+
+// RecordID returns the primary key of model, its values joined by commas in the order of the schema,
+// followed by the key fields of the repository missing from it (see WithKeyFields).
+func (r *GenericRepository[T]) RecordID(model *T) (string, error) {
+	return recordID(r.db, model, r.keyFields)
+}
+
+// RecordID attaches model to the parent and returns its record ID in the wrapped repository.
+func (r *ScopedRepository[T]) RecordID(model *T) (string, error) {
+	if err := setField(model, r.foreignKey, r.parentKey); err != nil {
+		return "", err
+	}
+	return RecordID(r.repo, model)
+}
+
+// recordID returns the key of model, its primary key followed by the keyFields missing from it, see recordKey.
+func recordID(db *gorm.DB, model interface{}, keyFields []string) (string, error) {
+	stmt := &gorm.Statement{DB: db}
+	if err := stmt.Parse(model); err != nil {
+		return "", err
+	}
+	key, err := recordKey(stmt.Schema, keyFields)
+	if err != nil {
+		return "", err
+	}
+
+	modelValue := reflect.Indirect(reflect.ValueOf(model))
+	values := make([]string, 0, len(key))
+	for _, field := range key {
+		value, _ := field.ValueOf(db.Statement.Context, modelValue)
+		values = append(values, fmt.Sprint(value))
+	}
+	return strings.Join(values, ","), nil
+}
+
+// audited reports whether the mutations of the repository are recorded by auditors or observed once committed.
+func (r *GenericRepository[T]) audited() bool {
+	return len(r.options.auditors) > 0 || len(r.options.commitObservers) > 0
+}
+
+// loadBefore loads the stored version of model, with its associations, for the auditors to record its changes.
+// It returns nil when the mutations of the repository are not audited or the record does not exist yet.
+func (r *GenericRepository[T]) loadBefore(tx *gorm.DB, model *T) (*T, error) {
+	if !r.audited() {
+		return nil, nil
+	}
+
+	stmt := &gorm.Statement{DB: tx}
+	if err := stmt.Parse(model); err != nil {
+		return nil, err
+	}
+
+	key, err := recordKey(stmt.Schema, r.keyFields)
+	if err != nil {
+		return nil, err
+	}
+
+	// Only the key is copied, Find looks the record up by the primary key of its destination and keyScope
+	// by the other key fields.
+	before := new(T)
+	modelValue := reflect.Indirect(reflect.ValueOf(model))
+	beforeValue := reflect.ValueOf(before).Elem()
+	for _, field := range key {
+		value, _ := field.ValueOf(tx.Statement.Context, modelValue)
+		if err := field.Set(tx.Statement.Context, beforeValue, value); err != nil {
+			return nil, err
+		}
+	}
+
+	// Find does not report missing records as errors, which GORM would log.
+	result := tx.Preload(clause.Associations).Scopes(r.keyScope(model)).Limit(1).Find(before)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, nil
+	}
+	return before, nil
+}
+
+// audit records the mutation of a record with the auditors of the repository, within tx, and returns it.
+// Before is nil for a creation and after is nil for a deletion.
+func (r *GenericRepository[T]) audit(tx *gorm.DB, operation string, before, after *T) (Mutation, error) {
+	if !r.audited() {
+		return Mutation{}, nil
+	}
+
+	mutation := Mutation{Model: r.model, Operation: operation}
+	record := after
+	if before != nil {
+		// A nil *T would make a non-nil interface, only the records are set.
+		mutation.Before = before
+		record = before
+	}
+	if after != nil {
+		mutation.After = after
+		record = after
+	}
+
+	id, err := recordID(tx, record, r.keyFields)
+	if err != nil {
+		return Mutation{}, err
+	}
+	mutation.RecordID = id
+
+	for _, auditor := range r.options.auditors {
+		if err := auditor.Record(tx, mutation); err != nil {
+			return Mutation{}, err
+		}
+	}
+	return mutation, nil
+}
+
+// committed notifies the commit observers and the auditors observing the commits of a mutation, once committed.
+func (r *GenericRepository[T]) committed(db *gorm.DB, mutation Mutation) {
+	for _, auditor := range r.options.auditors {
+		if observer, ok := auditor.(CommitObserver); ok {
+			observer.Committed(db, mutation)
+		}
+	}
+	for _, observer := range r.options.commitObservers {
+		observer.Committed(db, mutation)
+	}
+}
@@ -1,31 +1,486 @@
 package repository
 
 import (
-	"github.com/MuhmdHsn313/origin/orm"
+	"encoding"
+	"errors"
+	"fmt"
 	"reflect"
+	"slices"
+	"strconv"
+	"sync"
+
+	"github.com/MuhmdHsn313/origin/orm"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
 )
 
-// HasContents returns true if the model has a "Contents" field that is a slice and whose element type implements orm.IContentModel.
-func hasContents(model any) bool {
-	// Get the reflect.Type of the model.
-	typ := reflect.TypeOf(model)
-	// If the model is a pointer, get its element type.
-	if typ.Kind() == reflect.Ptr || typ.Kind() == reflect.Slice || typ.Kind() == reflect.Array {
-		typ = typ.Elem()
-	}
-
-	// Iterate over the fields of the struct.
-	for i := 0; i < typ.NumField(); i++ {
-		field := typ.Field(i)
-		// Check if the field's name is "Contents".
-		if field.Name == "Contents" && field.Type.Kind() == reflect.Slice {
-			// Get the interface type for IContentModel.
-			iContentModelType := reflect.TypeOf((*orm.IContentModel)(nil)).Elem()
-			// Check if the element type implements IContentModel.
-			if field.Type.Elem().Implements(iContentModelType) {
-				return true
+// ErrReferenceNotFound is returned (wrapped) by Create and Update when an association of the model references
+// a record, by its primary key, that does not exist. GORM would otherwise insert it as an empty record.
+var ErrReferenceNotFound = errors.New("referenced record not found")
+
+// checkReferences returns an error wrapping ErrReferenceNotFound if an associated record of model has a
+// primary key but does not exist. Associated records without a primary key are new and inserted with model,
+// content collections are saved by their content key and are not references.
+func checkReferences(tx *gorm.DB, model interface{}) error {
+	stmt := &gorm.Statement{DB: tx}
+	if err := stmt.Parse(model); err != nil {
+		return err
+	}
+
+	modelValue := reflect.ValueOf(model)
+	for _, relation := range stmt.Schema.Relationships.Relations {
+		if relation.Schema != stmt.Schema || isContentRelation(relation) {
+			continue
+		}
+		// Records with a composite primary key cannot be looked up by a single column.
+		primaryField := relation.FieldSchema.PrioritizedPrimaryField
+		if primaryField == nil {
+			continue
+		}
+
+		var items []reflect.Value
+		fieldValue := reflect.Indirect(modelValue).FieldByIndex(relation.Field.StructField.Index)
+		if fieldValue.Kind() == reflect.Slice {
+			for i := 0; i < fieldValue.Len(); i++ {
+				items = append(items, fieldValue.Index(i))
 			}
+		} else {
+			items = append(items, fieldValue)
+		}
+
+		seen := make(map[interface{}]bool)
+		var ids []interface{}
+		for _, item := range items {
+			item = reflect.Indirect(item)
+			if !item.IsValid() {
+				continue
+			}
+			id, zero := primaryField.ValueOf(tx.Statement.Context, item)
+			if zero || seen[id] {
+				continue
+			}
+			seen[id] = true
+			ids = append(ids, id)
+		}
+		if len(ids) == 0 {
+			continue
+		}
+
+		var count int64
+		err := tx.Model(reflect.New(relation.FieldSchema.ModelType).Interface()).
+			Where(clause.IN{Column: clause.Column{Name: primaryField.DBName}, Values: ids}).
+			Count(&count).Error
+		if err != nil {
+			return err
+		}
+		if count != int64(len(ids)) {
+			return fmt.Errorf("%w: %s references %d records of which %d exist",
+				ErrReferenceNotFound, relation.Field.Name, len(ids), count)
+		}
+	}
+	return nil
+}
+
+// replaceAssociations makes the has-many and many-to-many associations of model match its fields.
+// Content collections are left to Save, which merges them by language, and nil collections
+// (not loaded) are left untouched, an empty but non-nil collection clears the association.
+func replaceAssociations(tx *gorm.DB, model interface{}) error {
+	stmt := &gorm.Statement{DB: tx}
+	if err := stmt.Parse(model); err != nil {
+		return err
+	}
+
+	modelValue := reflect.ValueOf(model)
+	for _, relation := range stmt.Schema.Relationships.Relations {
+		// GORM also lists the has-one and has-many relations of other schemas pointing to this one.
+		if relation.Schema != stmt.Schema {
+			continue
+		}
+		if relation.Type != schema.HasMany && relation.Type != schema.Many2Many {
+			continue
+		}
+		if isContentRelation(relation) {
+			continue
+		}
+
+		fieldValue := reflect.Indirect(modelValue).FieldByIndex(relation.Field.StructField.Index)
+		if fieldValue.Kind() != reflect.Slice || fieldValue.IsNil() {
+			continue
 		}
+
+		items := reflect.New(fieldValue.Type())
+		items.Elem().Set(fieldValue)
+		if err := tx.Model(model).Association(relation.Name).Replace(items.Interface()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// contentAssociations returns the names of the content collections of model, or nil if it cannot be parsed.
+func contentAssociations(tx *gorm.DB, model interface{}) []string {
+	stmt := &gorm.Statement{DB: tx}
+	if err := stmt.Parse(model); err != nil {
+		return nil
+	}
+
+	var names []string
+	for _, relation := range stmt.Schema.Relationships.HasMany {
+		if isContentRelation(relation) {
+			names = append(names, relation.Name)
+		}
+	}
+	return names
+}
+
+// unencodedColumns returns the columns of the fields of s that are not encoded in JSON (json:"-"), except its
+// primary key and the skipped columns. The versions restored by Revert are JSON snapshots, which do not hold
+// these fields: their columns are left unchanged rather than cleared.
+func unencodedColumns(s *schema.Schema, skip ...string) []string {
+	var columns []string
+	for _, field := range s.Fields {
+		if field.DBName == "" || field.PrimaryKey || field.StructField.Tag.Get("json") != "-" {
+			continue
+		}
+		if slices.Contains(skip, field.DBName) {
+			continue
+		}
+		columns = append(columns, field.DBName)
+	}
+	return columns
+}
+
+// revertOmits returns the associations and columns left out of the Save of a reverted model: its content
+// collections, upserted by saveContents, and the columns of its unencoded fields, see unencodedColumns.
+func revertOmits(tx *gorm.DB, model interface{}) []string {
+	stmt := &gorm.Statement{DB: tx}
+	if err := stmt.Parse(model); err != nil {
+		return nil
+	}
+	return append(contentAssociations(tx, model), unencodedColumns(stmt.Schema)...)
+}
+
+// primaryKeyScope restricts a query to the record whose primary key is id, bound as a value whatever its type:
+// GORM reads the strings given to First as SQL conditions. A nil id leaves the query to the other scopes.
+func primaryKeyScope(id interface{}) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		if id == nil {
+			return db
+		}
+		return db.Where(clause.Eq{Column: clause.PrimaryColumn, Value: id})
+	}
+}
+
+// preloadContents preloads the content collections of model, the associations read by default.
+func preloadContents(db *gorm.DB, model interface{}) *gorm.DB {
+	for _, name := range contentAssociations(db, model) {
+		db = db.Preload(name)
+	}
+	return db
+}
+
+// recordKey returns the fields identifying the records of s: its primary key followed by the keyFields,
+// Go field names, that are not part of it.
+func recordKey(s *schema.Schema, keyFields []string) ([]*schema.Field, error) {
+	key := append([]*schema.Field(nil), s.PrimaryFields...)
+	for _, name := range keyFields {
+		field := s.LookUpField(name)
+		if field == nil {
+			return nil, fmt.Errorf("%s has no key field %s", s.Name, name)
+		}
+		if !slices.Contains(key, field) {
+			key = append(key, field)
+		}
+	}
+	if len(key) == 0 {
+		return nil, fmt.Errorf("%s has no primary key", s.Name)
+	}
+	return key, nil
+}
+
+// keyScope restricts a query to the record of model by the key fields of the repository that are not part of
+// its primary key, GORM looking records up by their primary key only.
+func (r *GenericRepository[T]) keyScope(model *T) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		if len(r.keyFields) == 0 {
+			return db
+		}
+
+		stmt := &gorm.Statement{DB: db}
+		if err := stmt.Parse(model); err != nil {
+			_ = db.AddError(err)
+			return db
+		}
+		key, err := recordKey(stmt.Schema, r.keyFields)
+		if err != nil {
+			_ = db.AddError(err)
+			return db
+		}
+
+		modelValue := reflect.Indirect(reflect.ValueOf(model))
+		for _, field := range key[len(stmt.Schema.PrimaryFields):] {
+			value, _ := field.ValueOf(db.Statement.Context, modelValue)
+			db = db.Where(clause.Eq{Column: clause.Column{Table: clause.CurrentTable, Name: field.DBName}, Value: value})
+		}
+		return db
+	}
+}
+
+// contentKeys caches, per content collection of a repository, whether its contents are unique by their content
+// key in the database (see orm.HasUniqueContentKey), so that the indexes are only looked up once.
+type contentKeys struct {
+	mu     sync.Mutex
+	unique map[string]bool
+}
+
+func newContentKeys() *contentKeys {
+	return &contentKeys{unique: make(map[string]bool)}
+}
+
+// isUnique reports whether the contents of relation are unique by their content key, looking it up with tx the
+// first time. A failed lookup is not cached.
+func (keys *contentKeys) isUnique(tx *gorm.DB, relation *schema.Relationship) (bool, error) {
+	keys.mu.Lock()
+	defer keys.mu.Unlock()
+
+	if unique, ok := keys.unique[relation.Name]; ok {
+		return unique, nil
+	}
+	unique, err := orm.HasUniqueContentKey(tx, relation)
+	if err != nil {
+		return false, err
+	}
+	keys.unique[relation.Name] = unique
+	return unique, nil
+}
+
+// saveContents upserts the content collections of model by their content key (see orm.ContentKey):
+// each content is attached to model, inserted when its language is new for model and updated otherwise.
+// Languages missing from a collection are kept, and nil or empty collections are left untouched.
+// When keepUnencoded is set, as for Revert, the columns of the unencoded content fields are left unchanged,
+// see unencodedColumns.
+//
+// The contents are upserted with the content key as conflict target when it is unique in the database, as in
+// the tables created by orm.AutoMigrate. Otherwise, such as in tables created by gorm.DB.AutoMigrate, each
+// content is updated by its content key and inserted when no row was updated, see updateOrCreateContent.
+func saveContents(tx *gorm.DB, model interface{}, keepUnencoded bool, keys *contentKeys) error {
+	stmt := &gorm.Statement{DB: tx}
+	if err := stmt.Parse(model); err != nil {
+		return err
+	}
+
+	modelValue := reflect.Indirect(reflect.ValueOf(model))
+	for _, relation := range stmt.Schema.Relationships.HasMany {
+		if !isContentRelation(relation) {
+			continue
+		}
+
+		fieldValue := modelValue.FieldByIndex(relation.Field.StructField.Index)
+		if fieldValue.Kind() != reflect.Slice || fieldValue.Len() == 0 {
+			continue
+		}
+
+		key, err := orm.ContentKey(relation)
+		if err != nil {
+			return err
+		}
+		unique, err := keys.isUnique(tx, relation)
+		if err != nil {
+			return err
+		}
+
+		for i := 0; i < fieldValue.Len(); i++ {
+			content := reflect.Indirect(fieldValue.Index(i))
+			for _, reference := range relation.References {
+				value := interface{}(reference.PrimaryValue)
+				if reference.OwnPrimaryKey {
+					value, _ = reference.PrimaryKey.ValueOf(tx.Statement.Context, modelValue)
+				}
+				if err := reference.ForeignKey.Set(tx.Statement.Context, content, value); err != nil {
+					return err
+				}
+			}
+		}
+
+		columns := make([]clause.Column, 0, len(key))
+		keyColumns := make([]string, 0, len(key))
+		for _, field := range key {
+			columns = append(columns, clause.Column{Name: field.DBName})
+			keyColumns = append(keyColumns, field.DBName)
+		}
+		omit := []string{clause.Associations}
+		if keepUnencoded {
+			omit = append(omit, unencodedColumns(relation.FieldSchema, keyColumns...)...)
+		}
+
+		if !unique {
+			for i := 0; i < fieldValue.Len(); i++ {
+				if err := updateOrCreateContent(tx, key, reflect.Indirect(fieldValue.Index(i)), omit); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+
+		contents := reflect.New(fieldValue.Type())
+		contents.Elem().Set(fieldValue)
+		err = tx.Clauses(clause.OnConflict{Columns: columns, UpdateAll: true}).Omit(omit...).Create(contents.Interface()).Error
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// updateOrCreateContent saves a content by its content key without a unique index to upsert it on: the row of
+// its parent and language is updated like an upsert would, leaving its primary key, creation time and the
+// omitted columns unchanged, and the content is inserted when there is no such row.
+func updateOrCreateContent(tx *gorm.DB, key []*schema.Field, content reflect.Value, omit []string) error {
+	contentSchema := key[0].Schema
+	query := tx.Session(&gorm.Session{NewDB: true}).Model(reflect.New(contentSchema.ModelType).Interface())
+	for _, field := range key {
+		value, _ := field.ValueOf(tx.Statement.Context, content)
+		query = query.Where(clause.Eq{Column: clause.Column{Name: field.DBName}, Value: value})
+	}
+
+	var columns []string
+	for _, field := range contentSchema.Fields {
+		if field.DBName == "" || field.PrimaryKey || field.AutoCreateTime != 0 || slices.Contains(key, field) || slices.Contains(omit, field.DBName) {
+			continue
+		}
+		columns = append(columns, field.DBName)
+	}
+
+	result := query.Select(columns).Updates(content.Addr().Interface())
+	if result.Error != nil || result.RowsAffected > 0 {
+		return result.Error
+	}
+	return tx.Session(&gorm.Session{NewDB: true}).Omit(omit...).Create(content.Addr().Interface()).Error
+}
+
+// deleteMissingContents deletes the contents of model whose language is missing from its content collections,
+// so that the collections saved by saveContents are the only contents of model. Nil collections are treated
+// as empty, deleting every content of model.
+func deleteMissingContents(tx *gorm.DB, model interface{}) error {
+	stmt := &gorm.Statement{DB: tx}
+	if err := stmt.Parse(model); err != nil {
+		return err
+	}
+
+	modelValue := reflect.Indirect(reflect.ValueOf(model))
+	for _, relation := range stmt.Schema.Relationships.HasMany {
+		if !isContentRelation(relation) {
+			continue
+		}
+
+		languageField := relation.FieldSchema.LookUpField(orm.LanguageField)
+		if languageField == nil {
+			return fmt.Errorf("content %s of %s has no %s field", relation.FieldSchema.Name, relation.Schema.Name, orm.LanguageField)
+		}
+
+		query := tx.Session(&gorm.Session{NewDB: true})
+		for _, reference := range relation.References {
+			value := interface{}(reference.PrimaryValue)
+			if reference.OwnPrimaryKey {
+				value, _ = reference.PrimaryKey.ValueOf(tx.Statement.Context, modelValue)
+			}
+			query = query.Where(clause.Eq{Column: clause.Column{Name: reference.ForeignKey.DBName}, Value: value})
+		}
+
+		fieldValue := modelValue.FieldByIndex(relation.Field.StructField.Index)
+		var languages []interface{}
+		for i := 0; fieldValue.Kind() == reflect.Slice && i < fieldValue.Len(); i++ {
+			language, _ := languageField.ValueOf(tx.Statement.Context, reflect.Indirect(fieldValue.Index(i)))
+			languages = append(languages, language)
+		}
+		if len(languages) > 0 {
+			query = query.Not(clause.IN{Column: clause.Column{Name: languageField.DBName}, Values: languages})
+		}
+
+		if err := query.Delete(reflect.New(relation.FieldSchema.ModelType).Interface()).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ParseKey converts a key read from a URL, such as the "id" path parameter, into the type of the
+// given field of T (e.g. "ID" or "LanguageID"), so that it can be compared with the column safely.
+// Strings, integers and types implementing encoding.TextUnmarshaler (such as uuid.UUID) are supported.
+//
+// Parameters:
+//   - field: The Go name of the field of T holding the key, promoted fields are looked up too.
+//   - raw: The key as text.
+//
+// Returns:
+//   - The key as a value of the field's type, or an error if the text is not a valid key.
+func ParseKey[T any](field, raw string) (interface{}, error) {
+	modelType := reflect.TypeOf((*T)(nil)).Elem()
+	for modelType.Kind() == reflect.Ptr {
+		modelType = modelType.Elem()
+	}
+	structField, ok := modelType.FieldByName(field)
+	if !ok {
+		return nil, fmt.Errorf("%s has no key field %s", modelType.Name(), field)
+	}
+
+	if unmarshaler, ok := reflect.New(structField.Type).Interface().(encoding.TextUnmarshaler); ok {
+		if err := unmarshaler.UnmarshalText([]byte(raw)); err != nil {
+			return nil, fmt.Errorf("invalid %s %q: %w", field, raw, err)
+		}
+		return reflect.ValueOf(unmarshaler).Elem().Interface(), nil
+	}
+
+	key := reflect.New(structField.Type).Elem()
+	switch key.Kind() {
+	case reflect.String:
+		if raw == "" {
+			return nil, fmt.Errorf("empty %s", field)
+		}
+		key.SetString(raw)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		value, err := strconv.ParseInt(raw, 10, key.Type().Bits())
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s %q: %w", field, raw, err)
+		}
+		key.SetInt(value)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		value, err := strconv.ParseUint(raw, 10, key.Type().Bits())
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s %q: %w", field, raw, err)
+		}
+		key.SetUint(value)
+	default:
+		return nil, fmt.Errorf("unsupported key type %s of %s", structField.Type, field)
+	}
+	return key.Interface(), nil
+}
+
+// primaryKey returns the ID field of a model for logging, or nil when the model has none
+// (content models are identified by their language and owner instead).
+func primaryKey(model interface{}) interface{} {
+	v := reflect.Indirect(reflect.ValueOf(model))
+	if v.Kind() != reflect.Struct {
+		return nil
+	}
+	if id := v.FieldByName("ID"); id.IsValid() {
+		return id.Interface()
+	}
+	return nil
+}
+
+// setField assigns value to the named field of model, converting it to the field's type.
+func setField(model interface{}, field string, value interface{}) error {
+	fieldValue := reflect.Indirect(reflect.ValueOf(model)).FieldByName(field)
+	if !fieldValue.IsValid() || !fieldValue.CanSet() {
+		return fmt.Errorf("unknown field %s", field)
+	}
+
+	v := reflect.ValueOf(value)
+	if !v.IsValid() || !v.Type().ConvertibleTo(fieldValue.Type()) {
+		return fmt.Errorf("cannot assign %T to field %s of type %s", value, field, fieldValue.Type())
 	}
-	return false
+	fieldValue.Set(v.Convert(fieldValue.Type()))
+	return nil
 }
@@ -0,0 +1,110 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/MuhmdHsn313/origin/logging"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+// WithSuccessLogLevel logs the lines of the successful operations ("Fetching model by ID",
+// "Model fetched successfully", ...) at level, logging.LevelInfo by default. Use logging.LevelDebug
+// to keep them out of production logs. Failures are always logged as errors.
+func WithSuccessLogLevel(level logging.Level) RepositoryOption {
+	return func(options *repositoryOptions) {
+		options.successLevel = level
+	}
+}
+
+// WithSuccessSampling logs the lines of one successful operation out of every, starting with the first.
+// Failures and slow queries are never sampled. Every operation is logged when every is 0 or 1.
+func WithSuccessSampling(every int) RepositoryOption {
+	return func(options *repositoryOptions) {
+		if every > 0 {
+			options.sampleEvery = uint64(every)
+		}
+	}
+}
+
+// WithSlowQueryThreshold logs the statements of the operations running for threshold or longer as warnings,
+// with their SQL, duration and row count. No statement is logged when threshold is 0, the default.
+func WithSlowQueryThreshold(threshold time.Duration) RepositoryOption {
+	return func(options *repositoryOptions) {
+		options.slowQueryThreshold = threshold
+	}
+}
+
+// successLogger returns the logger of the lines of an operation that do not report a failure: logger writing
+// its info lines at the success level, or a logger discarding them when the operation is not sampled.
+func (r *GenericRepository[T]) successLogger(logger logging.Logger) logging.Logger {
+	if r.options.sampleEvery > 1 && (r.operations.Add(1)-1)%r.options.sampleEvery != 0 {
+		return logging.Nop()
+	}
+	return levelLogger{Logger: logger, level: r.options.successLevel}
+}
+
+// levelLogger is a Logger writing its info lines at another level.
+type levelLogger struct {
+	logging.Logger
+	level logging.Level
+}
+
+func (logger levelLogger) WithField(key string, value interface{}) logging.Logger {
+	return levelLogger{Logger: logger.Logger.WithField(key, value), level: logger.level}
+}
+
+func (logger levelLogger) WithFields(fields logging.Fields) logging.Logger {
+	return levelLogger{Logger: logger.Logger.WithFields(fields), level: logger.level}
+}
+
+func (logger levelLogger) Info(msg string) {
+	logging.Log(logger.Logger, logger.level, msg)
+}
+
+// logSlowQueries returns db logging the statements of an operation slower than the threshold of the repository.
+func (r *GenericRepository[T]) logSlowQueries(db *gorm.DB, operation string) *gorm.DB {
+	if r.options.slowQueryThreshold <= 0 {
+		return db
+	}
+	return db.Session(&gorm.Session{Logger: slowQueryLogger{
+		Interface: db.Logger,
+		logger:    r.logger,
+		operation: operation,
+		threshold: r.options.slowQueryThreshold,
+	}})
+}
+
+// slowQueryLogger is a GORM logger logging the slow statements of an operation, along with the fields
+// of the request held by their context. The other lines are written by the logger it wraps.
+type slowQueryLogger struct {
+	gormlogger.Interface
+	logger    logging.Logger
+	operation string
+	threshold time.Duration
+}
+
+// LogMode sets the level of the wrapped logger, the slow statements are logged at any level.
+func (l slowQueryLogger) LogMode(level gormlogger.LogLevel) gormlogger.Interface {
+	l.Interface = l.Interface.LogMode(level)
+	return l
+}
+
+// Trace logs the statement as a warning when it ran for the threshold or longer, see gormlogger.Interface.
+func (l slowQueryLogger) Trace(ctx context.Context, begin time.Time, fc func() (sql string, rowsAffected int64), err error) {
+	l.Interface.Trace(ctx, begin, fc, err)
+
+	elapsed := time.Since(begin)
+	if elapsed < l.threshold {
+		return
+	}
+	sql, rows := fc()
+	logging.FromContext(ctx, l.logger).WithFields(logging.Fields{
+		"operation":   l.operation,
+		"duration_ms": float64(elapsed.Microseconds()) / 1000,
+		"threshold":   l.threshold.String(),
+		"sql":         sql,
+		"rows":        rows,
+	}).Warn("Slow query")
+}
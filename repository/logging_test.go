@@ -0,0 +1,200 @@
+package repository
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/MuhmdHsn313/origin/internal/testdb"
+	"github.com/MuhmdHsn313/origin/logging"
+)
+
+// logLine is a line logged by a repository, decoded from JSON.
+type logLine map[string]interface{}
+
+// newLoggedRepository returns a repository of testAuthor logging its lines as JSON to buf, from the debug level.
+func newLoggedRepository(t *testing.T, buf *bytes.Buffer, opts ...RepositoryOption) *GenericRepository[testAuthor] {
+	t.Helper()
+	db := testdb.Open(t, &testAuthor{})
+	logger := logging.Slog(slog.New(slog.NewJSONHandler(buf, &slog.HandlerOptions{Level: slog.LevelDebug})))
+	return NewGenericRepository[testAuthor](db, logger, opts...)
+}
+
+// loggedLines decodes the lines logged to buf and resets it.
+func loggedLines(t *testing.T, buf *bytes.Buffer) []logLine {
+	t.Helper()
+	defer buf.Reset()
+	var lines []logLine
+	for _, raw := range bytes.Split(bytes.TrimSpace(buf.Bytes()), []byte("\n")) {
+		if len(raw) == 0 {
+			continue
+		}
+		var line logLine
+		if err := json.Unmarshal(raw, &line); err != nil {
+			t.Fatalf("%q is not JSON: %v", raw, err)
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// levels returns the level and message of each line, as in "INFO Fetching model by ID".
+func levels(lines []logLine) []string {
+	described := make([]string, len(lines))
+	for i, line := range lines {
+		described[i] = line["level"].(string) + " " + line["msg"].(string)
+	}
+	return described
+}
+
+func equalLevels(got, want []string) bool {
+	if len(got) != len(want) {
+		return false
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestWithSuccessLogLevel(t *testing.T) {
+	tests := []struct {
+		name string
+		opts []RepositoryOption
+		want []string
+	}{
+		{"info by default", nil, []string{"INFO Fetching model by ID", "INFO Model fetched successfully"}},
+		{"debug", []RepositoryOption{WithSuccessLogLevel(logging.LevelDebug)},
+			[]string{"DEBUG Fetching model by ID", "DEBUG Model fetched successfully"}},
+		{"warn", []RepositoryOption{WithSuccessLogLevel(logging.LevelWarn)},
+			[]string{"WARN Fetching model by ID", "WARN Model fetched successfully"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			repo := newLoggedRepository(t, &buf, test.opts...)
+			if err := repo.Create(&testAuthor{Name: "Ada"}); err != nil {
+				t.Fatal(err)
+			}
+			buf.Reset()
+
+			if _, err := repo.GetByID(uint(1)); err != nil {
+				t.Fatal(err)
+			}
+			if got := levels(loggedLines(t, &buf)); !equalLevels(got, test.want) {
+				t.Errorf("GetByID logged %q, want %q", got, test.want)
+			}
+
+			// The failures are logged as errors whatever the level of the successes.
+			if _, err := repo.GetByID(uint(42)); err == nil {
+				t.Fatal("GetByID of a missing author succeeded")
+			}
+			want := []string{test.want[0], "ERROR Failed to fetch model by ID"}
+			if got := levels(loggedLines(t, &buf)); !equalLevels(got, want) {
+				t.Errorf("GetByID of a missing author logged %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestWithSuccessSampling(t *testing.T) {
+	tests := []struct {
+		every int
+		// logged are the operations out of 7 whose successes are logged.
+		logged []bool
+	}{
+		{0, []bool{true, true, true, true, true, true, true}},
+		{1, []bool{true, true, true, true, true, true, true}},
+		{-2, []bool{true, true, true, true, true, true, true}},
+		{3, []bool{true, false, false, true, false, false, true}},
+	}
+	for _, test := range tests {
+		var buf bytes.Buffer
+		repo := newLoggedRepository(t, &buf, WithSuccessSampling(test.every))
+		for i, logged := range test.logged {
+			if err := repo.Create(&testAuthor{Name: "Ada"}); err != nil {
+				t.Fatal(err)
+			}
+			if lines := loggedLines(t, &buf); (len(lines) > 0) != logged {
+				t.Errorf("WithSuccessSampling(%d): operation %d logged %q, want logged %v", test.every, i+1, levels(lines), logged)
+			}
+		}
+	}
+}
+
+func TestWithSuccessSamplingLogsEveryFailure(t *testing.T) {
+	var buf bytes.Buffer
+	repo := newLoggedRepository(t, &buf, WithSuccessSampling(100))
+
+	// The first operation is sampled, the failures of the next ones are logged all the same.
+	if _, err := repo.GetAll(); err != nil {
+		t.Fatal(err)
+	}
+	buf.Reset()
+	for i := 0; i < 3; i++ {
+		if _, err := repo.GetByID(uint(42)); err == nil {
+			t.Fatal("GetByID of a missing author succeeded")
+		}
+		want := []string{"ERROR Failed to fetch model by ID"}
+		if got := levels(loggedLines(t, &buf)); !equalLevels(got, want) {
+			t.Errorf("failure %d logged %q, want %q", i+1, got, want)
+		}
+	}
+}
+
+func TestWithSlowQueryThreshold(t *testing.T) {
+	tests := []struct {
+		name      string
+		threshold time.Duration
+		slow      bool
+	}{
+		{"disabled", 0, false},
+		{"fast statements", time.Hour, false},
+		{"slow statements", time.Nanosecond, true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			// The slow statements are logged even when the successes are sampled out.
+			repo := newLoggedRepository(t, &buf, WithSlowQueryThreshold(test.threshold), WithSuccessSampling(100))
+			if err := repo.Create(&testAuthor{Name: "Ada"}); err != nil {
+				t.Fatal(err)
+			}
+			buf.Reset()
+
+			ctx := logging.ContextWithFields(context.Background(), logging.Fields{logging.RequestIDField: "abc"})
+			if _, err := repo.WithContext(ctx).GetByID(uint(1)); err != nil {
+				t.Fatal(err)
+			}
+
+			var slow []logLine
+			for _, line := range loggedLines(t, &buf) {
+				if line["msg"] == "Slow query" {
+					slow = append(slow, line)
+				}
+			}
+			if (len(slow) > 0) != test.slow {
+				t.Fatalf("GetByID logged the slow queries %v, want slow queries %v", slow, test.slow)
+			}
+			for _, line := range slow {
+				sql, _ := line["sql"].(string)
+				if line["level"] != "WARN" || line["operation"] != "GetByID" || line["threshold"] != test.threshold.String() ||
+					line[logging.RequestIDField] != "abc" || !strings.Contains(sql, "test_authors") {
+					t.Errorf("logged the slow query %v", line)
+				}
+				if _, ok := line["duration_ms"].(float64); !ok {
+					t.Errorf("the slow query %v has no duration", line)
+				}
+				if rows, _ := line["rows"].(float64); rows != 1 {
+					t.Errorf("the slow query %v returned %v rows, want 1", line, line["rows"])
+				}
+			}
+		})
+	}
+}
@@ -1,46 +1,79 @@
 package repository
 
 import (
-	"github.com/sirupsen/logrus"
+	"sync/atomic"
+	"time"
+
+	"github.com/MuhmdHsn313/origin/logging"
+	"go.opentelemetry.io/otel/trace"
 	"gorm.io/gorm"
-	"reflect"
+	"gorm.io/gorm/clause"
 )
 
 // GenericRepository is a GORM-based implementation of the Repository interface.
 type GenericRepository[T any] struct {
-	db     *gorm.DB
-	logger *logrus.Logger
+	db      *gorm.DB
+	logger  logging.Logger
+	model   string
+	options repositoryOptions
+	tracer  trace.Tracer
+	// operations counts the operations, for sampling their success logs.
+	operations *atomic.Uint64
+	// keyFields identify the records along with their primary key, see WithKeyFields.
+	keyFields []string
+	// contentKeys caches how the content collections of T are saved, shared by the copies of the repository.
+	contentKeys *contentKeys
 }
 
 // NewGenericRepository creates a new GenericRepository instance using the provided GORM DB.
-func NewGenericRepository[T any](db *gorm.DB, logger *logrus.Logger) *GenericRepository[T] {
-	return &GenericRepository[T]{db: db, logger: logger}
+// The operations are logged with logger, logging.Nop() when nil, along with the fields of the request
+// the repository serves (see ContextRepository and logging.ContextWithFields). Their successes can be
+// demoted and sampled with WithSuccessLogLevel and WithSuccessSampling, their failures are always logged.
+// The content collections of T are saved by parent and language, see Update.
+// Options such as WithObserver are applied in order. With WithAuditor, the creations, updates and
+// deletions are recorded within their transaction. The operations are traced with the global
+// tracer provider unless WithTracerProvider is given, see ContextRepository.
+func NewGenericRepository[T any](db *gorm.DB, logger logging.Logger, opts ...RepositoryOption) *GenericRepository[T] {
+	if logger == nil {
+		logger = logging.Nop()
+	}
+	repo := &GenericRepository[T]{db: db, logger: logger, model: ModelName[T](), options: defaultRepositoryOptions(), operations: new(atomic.Uint64),
+		contentKeys: newContentKeys()}
+	for _, opt := range opts {
+		opt(&repo.options)
+	}
+	repo.tracer = repo.options.tracer()
+	return repo
 }
 
 // GetByID retrieves a model instance by its identifier.
-func (r *GenericRepository[T]) GetByID(id interface{}) (T, error) {
+func (r *GenericRepository[T]) GetByID(id interface{}, scopes ...ScopeWithLog) (_ T, err error) {
+	defer r.observe("GetByID", time.Now(), &err)
+	db, span := r.startSpan("GetByID")
+	defer endSpan(span, &err)
+	logger := logging.FromContext(db.Statement.Context, r.logger)
+	success := r.successLogger(logger)
+	db = r.logSlowQueries(db, "GetByID")
+
 	var model T
-	r.logger.WithFields(logrus.Fields{
+	success.WithFields(logging.Fields{
 		"operation": "GetByID",
 		"model_id":  id,
 	}).Info("Fetching model by ID")
 
-	tx := r.db.Begin()
-	if tx.Error != nil {
-		r.logger.WithFields(logrus.Fields{
-			"operation": "GetByID",
-			"model_id":  id,
-		}).Error(tx.Error.Error())
-		return model, tx.Error
-	}
+	// Define a scope function to apply the scopes.
+	queryScopes := make([]func(db *gorm.DB) *gorm.DB, 0)
 
-	if hasContents(model) {
-		tx = tx.Preload("Contents")
+	for _, scope := range scopes {
+		queryScopes = append(queryScopes, func(db *gorm.DB) *gorm.DB {
+			return scope(db, logger)
+		})
 	}
 
-	result := tx.First(&model, id)
+	// Reads run outside of a transaction with the contents preloaded, the scopes preload the other associations.
+	result := preloadContents(db, &model).Scopes(queryScopes...).Scopes(primaryKeyScope(id)).First(&model)
 	if result.Error != nil {
-		r.logger.WithFields(logrus.Fields{
+		logger.WithFields(logging.Fields{
 			"operation": "GetByID",
 			"model_id":  id,
 			"error":     result.Error.Error(),
@@ -48,7 +81,7 @@ func (r *GenericRepository[T]) GetByID(id interface{}) (T, error) {
 		return model, result.Error
 	}
 
-	r.logger.WithFields(logrus.Fields{
+	success.WithFields(logging.Fields{
 		"operation": "GetByID",
 		"model_id":  id,
 	}).Info("Model fetched successfully")
@@ -56,9 +89,16 @@ func (r *GenericRepository[T]) GetByID(id interface{}) (T, error) {
 }
 
 // GetAll returns all model instances.
-func (r *GenericRepository[T]) GetAll(scopes ...ScopeWithLog) ([]T, error) {
+func (r *GenericRepository[T]) GetAll(scopes ...ScopeWithLog) (_ []T, err error) {
+	defer r.observe("GetAll", time.Now(), &err)
+	db, span := r.startSpan("GetAll")
+	defer endSpan(span, &err)
+	logger := logging.FromContext(db.Statement.Context, r.logger)
+	success := r.successLogger(logger)
+	db = r.logSlowQueries(db, "GetAll")
+
 	var models []T
-	r.logger.WithFields(logrus.Fields{
+	success.WithFields(logging.Fields{
 		"operation": "GetAll",
 	}).Info("Fetching all models with filter")
 
@@ -67,33 +107,20 @@ func (r *GenericRepository[T]) GetAll(scopes ...ScopeWithLog) ([]T, error) {
 
 	for _, scope := range scopes {
 		filterScopes = append(filterScopes, func(db *gorm.DB) *gorm.DB {
-			scope(db, r.logger)
-			return db
+			return scope(db, logger)
 		})
 	}
 
-	tx := r.db.Begin()
-	if tx.Error != nil {
-		r.logger.WithFields(logrus.Fields{
-			"operation": "GetAll",
-		}).Error(tx.Error.Error())
-		return models, tx.Error
-	}
-
-	if hasContents(models) {
-		tx = tx.Preload("Contents")
-	}
-
-	result := tx.Scopes(filterScopes...).Find(&models)
+	result := preloadContents(db, new(T)).Scopes(filterScopes...).Find(&models)
 	if result.Error != nil {
-		r.logger.WithFields(logrus.Fields{
+		logger.WithFields(logging.Fields{
 			"operation": "GetAll",
 			"error":     result.Error.Error(),
 		}).Error("Failed to fetch models with filter")
 		return nil, result.Error
 	}
 
-	r.logger.WithFields(logrus.Fields{
+	success.WithFields(logging.Fields{
 		"operation": "GetAll",
 		"count":     len(models),
 	}).Info("Fetched models successfully")
@@ -101,133 +128,424 @@ func (r *GenericRepository[T]) GetAll(scopes ...ScopeWithLog) ([]T, error) {
 }
 
 // Create inserts a new model instance into the database within a transaction.
-// It automatically sets the CreatedAt and UpdatedAt fields.
-func (r *GenericRepository[T]) Create(model *T) error {
-	r.logger.WithField("operation", "Create").Info("Creating a new model")
-
-	tx := r.db.Begin()
+// It automatically sets the CreatedAt and UpdatedAt fields. Associated records given by their primary key
+// must exist, the error wraps ErrReferenceNotFound otherwise.
+func (r *GenericRepository[T]) Create(model *T) (err error) {
+	defer r.observe("Create", time.Now(), &err)
+	db, span := r.startSpan("Create")
+	defer endSpan(span, &err)
+	logger := logging.FromContext(db.Statement.Context, r.logger)
+	success := r.successLogger(logger)
+	db = r.logSlowQueries(db, "Create")
+
+	success.WithField("operation", "Create").Info("Creating a new model")
+
+	tx := db.Begin()
 	if tx.Error != nil {
-		r.logger.WithField("operation", "Create").Error(tx.Error.Error())
+		logger.WithFields(logging.Fields{
+			"operation": "Create",
+			"error":     tx.Error.Error(),
+		}).Error("Failed to begin transaction")
 		return tx.Error
 	}
 
-	result := tx.Create(model)
+	if err := checkReferences(tx, model); err != nil {
+		logger.WithFields(logging.Fields{
+			"operation": "Create",
+			"error":     err.Error(),
+		}).Error("Failed to check associated records, rolling back transaction")
+		if rbErr := r.rollback(tx, "Create"); rbErr != nil {
+			logger.WithFields(logging.Fields{
+				"operation": "Create",
+				"error":     rbErr.Error(),
+			}).Error("Failed to roll back transaction")
+			return rbErr
+		}
+		return err
+	}
+
+	// Content collections are saved by their content key below, as by Update.
+	result := tx.Omit(contentAssociations(tx, model)...).Create(model)
 	if result.Error != nil {
-		r.logger.WithFields(logrus.Fields{
+		logger.WithFields(logging.Fields{
 			"operation": "Create",
 			"error":     result.Error.Error(),
 		}).Error("Failed to create model, rolling back transaction")
-		if rbErr := tx.Rollback().Error; rbErr != nil {
-			r.logger.WithField("operation", "Create").Error("Rollback error: " + rbErr.Error())
+		if rbErr := r.rollback(tx, "Create"); rbErr != nil {
+			logger.WithFields(logging.Fields{
+				"operation": "Create",
+				"error":     rbErr.Error(),
+			}).Error("Failed to roll back transaction")
 			return rbErr
 		}
 		return result.Error
 	}
 
+	if err := saveContents(tx, model, false, r.contentKeys); err != nil {
+		logger.WithFields(logging.Fields{
+			"operation": "Create",
+			"error":     err.Error(),
+		}).Error("Failed to save contents, rolling back transaction")
+		if rbErr := r.rollback(tx, "Create"); rbErr != nil {
+			logger.WithFields(logging.Fields{
+				"operation": "Create",
+				"error":     rbErr.Error(),
+			}).Error("Failed to roll back transaction")
+			return rbErr
+		}
+		return err
+	}
+
+	// Reload the model so that associations referenced by ID are returned complete.
+	if err := tx.Preload(clause.Associations).Scopes(r.keyScope(model)).First(model).Error; err != nil {
+		logger.WithFields(logging.Fields{
+			"operation": "Create",
+			"error":     err.Error(),
+		}).Error("Failed to reload created model, rolling back transaction")
+		if rbErr := r.rollback(tx, "Create"); rbErr != nil {
+			logger.WithFields(logging.Fields{
+				"operation": "Create",
+				"error":     rbErr.Error(),
+			}).Error("Failed to roll back transaction")
+			return rbErr
+		}
+		return err
+	}
+
+	mutation, err := r.audit(tx, "Create", nil, model)
+	if err != nil {
+		logger.WithFields(logging.Fields{
+			"operation": "Create",
+			"error":     err.Error(),
+		}).Error("Failed to record audit entry, rolling back transaction")
+		if rbErr := r.rollback(tx, "Create"); rbErr != nil {
+			logger.WithFields(logging.Fields{
+				"operation": "Create",
+				"error":     rbErr.Error(),
+			}).Error("Failed to roll back transaction")
+			return rbErr
+		}
+		return err
+	}
+
 	if err := tx.Commit().Error; err != nil {
-		r.logger.WithField("operation", "Create").Error("Commit error: " + err.Error())
+		logger.WithFields(logging.Fields{
+			"operation": "Create",
+			"error":     err.Error(),
+		}).Error("Failed to commit transaction")
 		return err
 	}
 
-	r.logger.WithField("operation", "Create").Info("Model created successfully")
+	r.committed(db, mutation)
+
+	success.WithField("operation", "Create").Info("Model created successfully")
 	return nil
 }
 
 // Update modifies an existing model instance in the database within a transaction.
-// It automatically sets the UpdatedAt field.
+// It automatically sets the UpdatedAt field. The contents of its content collections are saved by parent and
+// language: new languages are inserted, existing ones are updated and the other languages are kept.
+// Associated records given by their primary key must exist, as for Create.
 func (r *GenericRepository[T]) Update(model *T) error {
-	// Use reflection to extract the model's ID from the embedded Model struct.
-	v := reflect.ValueOf(model).Elem()
-	idField := v.FieldByName("Model").FieldByName("ID").Uint()
+	return r.update("Update", model, false)
+}
 
-	r.logger.WithFields(logrus.Fields{
-		"operation": "Update",
+// Revert restores a model instance to a previous state within a transaction, such as a version recorded by
+// the history package, recreating it if it was deleted. Unlike Update, the contents of the languages missing
+// from its content collections are deleted, so that they hold exactly the contents of the model.
+// The fields of the model and of its contents that are not encoded in JSON (json:"-") are missing from the
+// snapshots of the versions: they are left unchanged, and take their default value when the record is recreated.
+func (r *GenericRepository[T]) Revert(model *T) error {
+	return r.update("Revert", model, true)
+}
+
+// update saves model as the given operation, Update or Revert. A revert replaces the contents of model and
+// leaves the columns of its unencoded fields unchanged.
+func (r *GenericRepository[T]) update(operation string, model *T, revert bool) (err error) {
+	defer r.observe(operation, time.Now(), &err)
+	db, span := r.startSpan(operation)
+	defer endSpan(span, &err)
+	logger := logging.FromContext(db.Statement.Context, r.logger)
+	success := r.successLogger(logger)
+	db = r.logSlowQueries(db, operation)
+
+	// The ID is only logged, models without one (such as content models) are logged without it.
+	idField := primaryKey(model)
+
+	success.WithFields(logging.Fields{
+		"operation": operation,
 		"model_id":  idField,
 	}).Info("Updating model")
 
-	tx := r.db.Begin()
+	tx := db.Begin()
 	if tx.Error != nil {
-		r.logger.WithField("operation", "Update").Error(tx.Error.Error())
+		logger.WithFields(logging.Fields{
+			"operation": operation,
+			"error":     tx.Error.Error(),
+		}).Error("Failed to begin transaction")
 		return tx.Error
 	}
 
-	result := tx.Save(model)
+	before, err := r.loadBefore(tx, model)
+	if err != nil {
+		logger.WithFields(logging.Fields{
+			"operation": operation,
+			"model_id":  idField,
+			"error":     err.Error(),
+		}).Error("Failed to load model for the audit, rolling back transaction")
+		if rbErr := r.rollback(tx, operation); rbErr != nil {
+			logger.WithFields(logging.Fields{
+				"operation": operation,
+				"error":     rbErr.Error(),
+			}).Error("Failed to roll back transaction")
+			return rbErr
+		}
+		return err
+	}
+
+	// A revert restores the associated records of its snapshot, deleted ones included.
+	if !revert {
+		if err := checkReferences(tx, model); err != nil {
+			logger.WithFields(logging.Fields{
+				"operation": operation,
+				"model_id":  idField,
+				"error":     err.Error(),
+			}).Error("Failed to check associated records, rolling back transaction")
+			if rbErr := r.rollback(tx, operation); rbErr != nil {
+				logger.WithFields(logging.Fields{
+					"operation": operation,
+					"error":     rbErr.Error(),
+				}).Error("Failed to roll back transaction")
+				return rbErr
+			}
+			return err
+		}
+	}
+
+	// Content collections are upserted by their content key below, Save would leave existing rows unchanged.
+	omit := contentAssociations(tx, model)
+	if revert {
+		omit = revertOmits(tx, model)
+	}
+	result := tx.Omit(omit...).Scopes(r.keyScope(model)).Save(model)
 	if result.Error != nil {
-		r.logger.WithFields(logrus.Fields{
-			"operation": "Update",
+		logger.WithFields(logging.Fields{
+			"operation": operation,
 			"model_id":  idField,
 			"error":     result.Error.Error(),
 		}).Error("Failed to update model, rolling back transaction")
-		if rbErr := tx.Rollback().Error; rbErr != nil {
-			r.logger.WithField("operation", "Update").Error("Rollback error: " + rbErr.Error())
+		if rbErr := r.rollback(tx, operation); rbErr != nil {
+			logger.WithFields(logging.Fields{
+				"operation": operation,
+				"error":     rbErr.Error(),
+			}).Error("Failed to roll back transaction")
 			return rbErr
 		}
 		return result.Error
 	}
 
+	if err := saveContents(tx, model, revert, r.contentKeys); err != nil {
+		logger.WithFields(logging.Fields{
+			"operation": operation,
+			"model_id":  idField,
+			"error":     err.Error(),
+		}).Error("Failed to save contents, rolling back transaction")
+		if rbErr := r.rollback(tx, operation); rbErr != nil {
+			logger.WithFields(logging.Fields{
+				"operation": operation,
+				"error":     rbErr.Error(),
+			}).Error("Failed to roll back transaction")
+			return rbErr
+		}
+		return err
+	}
+
+	if revert {
+		if err := deleteMissingContents(tx, model); err != nil {
+			logger.WithFields(logging.Fields{
+				"operation": operation,
+				"model_id":  idField,
+				"error":     err.Error(),
+			}).Error("Failed to delete contents, rolling back transaction")
+			if rbErr := r.rollback(tx, operation); rbErr != nil {
+				logger.WithFields(logging.Fields{
+					"operation": operation,
+					"error":     rbErr.Error(),
+				}).Error("Failed to roll back transaction")
+				return rbErr
+			}
+			return err
+		}
+	}
+
+	// Save only adds to associations, items dropped from has-many and many-to-many collections are unlinked here.
+	if err := replaceAssociations(tx, model); err != nil {
+		logger.WithFields(logging.Fields{
+			"operation": operation,
+			"model_id":  idField,
+			"error":     err.Error(),
+		}).Error("Failed to update associations, rolling back transaction")
+		if rbErr := r.rollback(tx, operation); rbErr != nil {
+			logger.WithFields(logging.Fields{
+				"operation": operation,
+				"error":     rbErr.Error(),
+			}).Error("Failed to roll back transaction")
+			return rbErr
+		}
+		return err
+	}
+
+	// Reload the model so that associations referenced by ID are returned complete.
+	if err := tx.Preload(clause.Associations).Scopes(r.keyScope(model)).First(model).Error; err != nil {
+		logger.WithFields(logging.Fields{
+			"operation": operation,
+			"model_id":  idField,
+			"error":     err.Error(),
+		}).Error("Failed to reload updated model, rolling back transaction")
+		if rbErr := r.rollback(tx, operation); rbErr != nil {
+			logger.WithFields(logging.Fields{
+				"operation": operation,
+				"error":     rbErr.Error(),
+			}).Error("Failed to roll back transaction")
+			return rbErr
+		}
+		return err
+	}
+
+	mutation, err := r.audit(tx, operation, before, model)
+	if err != nil {
+		logger.WithFields(logging.Fields{
+			"operation": operation,
+			"model_id":  idField,
+			"error":     err.Error(),
+		}).Error("Failed to record audit entry, rolling back transaction")
+		if rbErr := r.rollback(tx, operation); rbErr != nil {
+			logger.WithFields(logging.Fields{
+				"operation": operation,
+				"error":     rbErr.Error(),
+			}).Error("Failed to roll back transaction")
+			return rbErr
+		}
+		return err
+	}
+
 	if err := tx.Commit().Error; err != nil {
-		r.logger.WithFields(logrus.Fields{
-			"operation": "Update",
+		logger.WithFields(logging.Fields{
+			"operation": operation,
 			"model_id":  idField,
 			"error":     err.Error(),
 		}).Error("Commit error during update")
 		return err
 	}
 
-	r.logger.WithFields(logrus.Fields{
-		"operation": "Update",
+	r.committed(db, mutation)
+
+	success.WithFields(logging.Fields{
+		"operation": operation,
 		"model_id":  idField,
 	}).Info("Model updated successfully")
 	return nil
 }
 
 // Delete removes a model instance identified by id within a transaction.
-func (r *GenericRepository[T]) Delete(id interface{}) error {
+// The scopes restrict both the lookup and the deletion of the record.
+func (r *GenericRepository[T]) Delete(id interface{}, scopes ...ScopeWithLog) (err error) {
+	defer r.observe("Delete", time.Now(), &err)
+	db, span := r.startSpan("Delete")
+	defer endSpan(span, &err)
+	logger := logging.FromContext(db.Statement.Context, r.logger)
+	success := r.successLogger(logger)
+	db = r.logSlowQueries(db, "Delete")
+
 	var model T
-	r.logger.WithFields(logrus.Fields{
+	success.WithFields(logging.Fields{
 		"operation": "Delete",
 		"model_id":  id,
 	}).Info("Deleting model")
 
-	tx := r.db.Begin()
+	queryScopes := make([]func(db *gorm.DB) *gorm.DB, 0)
+
+	for _, scope := range scopes {
+		queryScopes = append(queryScopes, func(db *gorm.DB) *gorm.DB {
+			return scope(db, logger)
+		})
+	}
+
+	tx := db.Begin()
 	if tx.Error != nil {
-		r.logger.WithField("operation", "Delete").Error(tx.Error.Error())
+		logger.WithFields(logging.Fields{
+			"operation": "Delete",
+			"error":     tx.Error.Error(),
+		}).Error("Failed to begin transaction")
 		return tx.Error
 	}
 
-	if err := tx.First(&model, id).Error; err != nil {
-		r.logger.WithFields(logrus.Fields{
+	// The associations are only needed by the auditors, to record the deleted contents.
+	find := tx.Scopes(queryScopes...).Scopes(primaryKeyScope(id))
+	if r.audited() {
+		find = find.Preload(clause.Associations)
+	}
+	if err := find.First(&model).Error; err != nil {
+		logger.WithFields(logging.Fields{
 			"operation": "Delete",
 			"model_id":  id,
 			"error":     err.Error(),
 		}).Error("Failed to find model for deletion, rolling back transaction")
-		if rbErr := tx.Rollback().Error; rbErr != nil {
-			r.logger.WithField("operation", "Delete").Error("Rollback error: " + rbErr.Error())
+		if rbErr := r.rollback(tx, "Delete"); rbErr != nil {
+			logger.WithFields(logging.Fields{
+				"operation": "Delete",
+				"error":     rbErr.Error(),
+			}).Error("Failed to roll back transaction")
 			return rbErr
 		}
 		return err
 	}
 
-	result := tx.Delete(&model)
+	result := tx.Scopes(queryScopes...).Delete(&model)
 	if result.Error != nil {
-		r.logger.WithFields(logrus.Fields{
+		logger.WithFields(logging.Fields{
 			"operation": "Delete",
 			"model_id":  id,
 			"error":     result.Error.Error(),
 		}).Error("Failed to delete model, rolling back transaction")
-		if rbErr := tx.Rollback().Error; rbErr != nil {
-			r.logger.WithField("operation", "Delete").Error("Rollback error: " + rbErr.Error())
+		if rbErr := r.rollback(tx, "Delete"); rbErr != nil {
+			logger.WithFields(logging.Fields{
+				"operation": "Delete",
+				"error":     rbErr.Error(),
+			}).Error("Failed to roll back transaction")
 			return rbErr
 		}
 		return result.Error
 	}
 
+	mutation, err := r.audit(tx, "Delete", &model, nil)
+	if err != nil {
+		logger.WithFields(logging.Fields{
+			"operation": "Delete",
+			"model_id":  id,
+			"error":     err.Error(),
+		}).Error("Failed to record audit entry, rolling back transaction")
+		if rbErr := r.rollback(tx, "Delete"); rbErr != nil {
+			logger.WithFields(logging.Fields{
+				"operation": "Delete",
+				"error":     rbErr.Error(),
+			}).Error("Failed to roll back transaction")
+			return rbErr
+		}
+		return err
+	}
+
 	if err := tx.Commit().Error; err != nil {
-		r.logger.WithField("operation", "Delete").Error("Commit error: " + err.Error())
+		logger.WithFields(logging.Fields{
+			"operation": "Delete",
+			"error":     err.Error(),
+		}).Error("Failed to commit transaction")
 		return err
 	}
 
-	r.logger.WithFields(logrus.Fields{
+	r.committed(db, mutation)
+
+	success.WithFields(logging.Fields{
 		"operation": "Delete",
 		"model_id":  id,
 	}).Info("Model deleted successfully")
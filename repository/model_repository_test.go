@@ -0,0 +1,527 @@
+package repository
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/MuhmdHsn313/origin/internal/testdb"
+	"github.com/MuhmdHsn313/origin/orm"
+	"gorm.io/gorm"
+)
+
+type testAuthor struct {
+	orm.Model
+
+	Name string `json:"name"`
+}
+
+type testTag struct {
+	orm.Model
+
+	Name string `json:"name"`
+}
+
+type testPost struct {
+	orm.Model
+
+	Contents []testPostContent `json:"contents" gorm:"foreignKey:PostID;constraint:OnDelete:CASCADE"`
+	Slug     string            `json:"slug"`
+	AuthorID *uint             `json:"author_id"`
+	Author   *testAuthor       `json:"author"`
+	Tags     []testTag         `json:"tags" gorm:"many2many:test_post_tags"`
+}
+
+// testPostContent is keyed by its post and its language.
+type testPostContent struct {
+	orm.ContentModel
+
+	Title  string `json:"title"`
+	PostID uint   `json:"post_id" gorm:"primaryKey"`
+}
+
+// testPage has contents identified by their own ID, whose post and language are not unique in the tables
+// created by gorm.DB.AutoMigrate.
+type testPage struct {
+	orm.Model
+
+	Contents []testPageContent `json:"contents" gorm:"foreignKey:PageID;constraint:OnDelete:CASCADE" origin:"contents"`
+}
+
+type testPageContent struct {
+	ID         uint   `json:"id" gorm:"primaryKey"`
+	LanguageID string `json:"language_id" gorm:"type:varchar(2)"`
+	Title      string `json:"title"`
+	PageID     uint   `json:"page_id"`
+}
+
+// testNoteContent is keyed by its language alone, its note is only part of the primary key of its table,
+// see testKeyedNoteContent.
+type testNoteContent struct {
+	orm.ContentModel
+
+	Body   string `json:"body"`
+	NoteID uint   `json:"note_id"`
+}
+
+// testKeyedNoteContent creates the table of testNoteContent with the note in its primary key.
+type testKeyedNoteContent struct {
+	orm.ContentModel
+
+	Body   string `json:"body"`
+	NoteID uint   `json:"note_id" gorm:"primaryKey"`
+}
+
+func (testKeyedNoteContent) TableName() string {
+	return "test_note_contents"
+}
+
+// recordingAuditor keeps the mutations it records.
+type recordingAuditor struct {
+	mutations []Mutation
+}
+
+func (auditor *recordingAuditor) Record(tx *gorm.DB, mutation Mutation) error {
+	auditor.mutations = append(auditor.mutations, mutation)
+	return nil
+}
+
+// testCountry is identified by a string key.
+type testCountry struct {
+	Code string `json:"code" gorm:"primaryKey;type:varchar(10)"`
+	Name string `json:"name"`
+}
+
+// testDocument is identified by a UUID.
+type testDocument struct {
+	orm.UUIDModel
+
+	Title string `json:"title"`
+}
+
+// createTestPost creates a post with an author, a tag and an English content.
+func createTestPost(t *testing.T, repo *GenericRepository[testPost]) testPost {
+	t.Helper()
+	post := testPost{
+		Slug:   "hello",
+		Author: &testAuthor{Name: "Alice"},
+		Tags:   []testTag{{Name: "go"}},
+	}
+	post.Contents = []testPostContent{{Title: "Hello"}}
+	post.Contents[0].LanguageID = "en"
+	if err := repo.Create(&post); err != nil {
+		t.Fatal(err)
+	}
+	return post
+}
+
+func TestGenericRepositoryPreloadsContentsByDefault(t *testing.T) {
+	db := testdb.Open(t, &orm.Language{}, &testAuthor{}, &testTag{}, &testPost{}, &testPostContent{})
+	repo := NewGenericRepository[testPost](db, nil)
+	post := createTestPost(t, repo)
+
+	read, err := repo.GetByID(post.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(read.Contents) != 1 || read.Contents[0].Title != "Hello" {
+		t.Errorf("contents = %+v, want the English content", read.Contents)
+	}
+	if read.Author != nil || read.Tags != nil {
+		t.Errorf("author = %+v and tags = %+v, want them left out without include", read.Author, read.Tags)
+	}
+
+	read, err = repo.GetByID(post.ID, IncludeScope[testPost]("tags"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(read.Tags) != 1 || read.Author != nil {
+		t.Errorf("tags = %+v and author = %+v, want only the included tags", read.Tags, read.Author)
+	}
+
+	read, err = repo.GetByID(post.ID, AssociationsScope())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(read.Contents) != 1 || len(read.Tags) != 1 || read.Author == nil || read.Author.Name != "Alice" {
+		t.Errorf("read %+v, want every association", read)
+	}
+
+	posts, err := repo.GetAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(posts) != 1 || len(posts[0].Contents) != 1 || posts[0].Author != nil || posts[0].Tags != nil {
+		t.Errorf("listed %+v, want the posts with their contents only", posts)
+	}
+}
+
+func TestGenericRepositoryStringKeys(t *testing.T) {
+	db := testdb.Open(t, &testCountry{})
+	repo := NewGenericRepository[testCountry](db, nil)
+	for _, country := range []testCountry{{Code: "abc", Name: "First"}, {Code: "fr", Name: "France"}} {
+		if err := repo.Create(&country); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	country, err := repo.GetByID("fr")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if country.Name != "France" {
+		t.Errorf("got %+v, want France", country)
+	}
+	if country, err = repo.GetByID("abc"); err != nil || country.Name != "First" {
+		t.Errorf("got %+v, %v, want the record abc", country, err)
+	}
+
+	// Hostile keys are compared with the key, never read as SQL.
+	for _, key := range []string{"1=1", "1=1 OR code <> ''", "fr' OR '1'='1"} {
+		if country, err := repo.GetByID(key); !errors.Is(err, gorm.ErrRecordNotFound) {
+			t.Errorf("GetByID(%q) = %+v, %v, want gorm.ErrRecordNotFound", key, country, err)
+		}
+		if err := repo.Delete(key); !errors.Is(err, gorm.ErrRecordNotFound) {
+			t.Errorf("Delete(%q) = %v, want gorm.ErrRecordNotFound", key, err)
+		}
+	}
+
+	var count int64
+	if err := db.Model(&testCountry{}).Count(&count).Error; err != nil {
+		t.Fatal(err)
+	}
+	if count != 2 {
+		t.Fatalf("%d countries left, want 2", count)
+	}
+
+	if err := repo.Delete("fr"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.GetByID("fr"); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Errorf("GetByID of a deleted record = %v, want gorm.ErrRecordNotFound", err)
+	}
+	if _, err := repo.GetByID("abc"); err != nil {
+		t.Errorf("GetByID of the other record = %v", err)
+	}
+}
+
+func TestGenericRepositoryUUIDKeys(t *testing.T) {
+	db := testdb.Open(t, &testDocument{})
+	repo := NewGenericRepository[testDocument](db, nil)
+	first, second := testDocument{Title: "First"}, testDocument{Title: "Second"}
+	for _, document := range []*testDocument{&first, &second} {
+		if err := repo.Create(document); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	id, err := ParseKey[testDocument]("ID", second.ID.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	document, err := repo.GetByID(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if document.ID != second.ID || document.Title != "Second" {
+		t.Errorf("got %+v, want the second document", document)
+	}
+
+	if _, err := ParseKey[testDocument]("ID", "1=1"); err == nil {
+		t.Error("ParseKey accepted 1=1 as a UUID")
+	}
+	if _, err := repo.GetByID("1=1"); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Errorf("GetByID(1=1) = %v, want gorm.ErrRecordNotFound", err)
+	}
+
+	if err := repo.Delete(id); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.GetByID(id); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Errorf("GetByID of a deleted document = %v, want gorm.ErrRecordNotFound", err)
+	}
+	if document, err := repo.GetByID(first.ID); err != nil || document.Title != "First" {
+		t.Errorf("got %+v, %v, want the first document", document, err)
+	}
+}
+
+func TestGenericRepositoryUpsertsContentsByParentAndLanguage(t *testing.T) {
+	models := []interface{}{&orm.Language{}, &testAuthor{}, &testTag{}, &testPost{}, &testPostContent{}}
+	tests := []struct {
+		name    string
+		migrate func(db *gorm.DB) error
+	}{
+		{"orm.AutoMigrate", func(db *gorm.DB) error { return orm.AutoMigrate(db, models...) }},
+		// The content key is the primary key of the contents, without the index created by orm.AutoMigrate.
+		{"gorm.AutoMigrate", func(db *gorm.DB) error { return db.AutoMigrate(models...) }},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			db := testdb.Open(t)
+			if err := test.migrate(db); err != nil {
+				t.Fatal(err)
+			}
+			testUpsertContents(t, db)
+		})
+	}
+}
+
+func testUpsertContents(t *testing.T, db *gorm.DB) {
+	repo := NewGenericRepository[testPost](db, nil)
+	first, second := createTestPost(t, repo), createTestPost(t, repo)
+
+	// Both posts have an English content, the update of one leaves the other as is.
+	update, err := repo.GetByID(first.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	update.Contents = []testPostContent{{Title: "Hello again"}, {Title: "Marhaba"}}
+	update.Contents[0].LanguageID = "en"
+	update.Contents[1].LanguageID = "ar"
+	if err := repo.Update(&update); err != nil {
+		t.Fatal(err)
+	}
+
+	var contents []testPostContent
+	if err := db.Order("post_id, language_id").Find(&contents).Error; err != nil {
+		t.Fatal(err)
+	}
+	want := []struct {
+		postID   uint
+		language string
+		title    string
+	}{
+		{first.ID, "ar", "Marhaba"},
+		{first.ID, "en", "Hello again"},
+		{second.ID, "en", "Hello"},
+	}
+	if len(contents) != len(want) {
+		t.Fatalf("got %d contents %+v, want %d", len(contents), contents, len(want))
+	}
+	for i, content := range contents {
+		if content.PostID != want[i].postID || content.LanguageID != want[i].language || content.Title != want[i].title {
+			t.Errorf("content %d = (%d, %s, %q), want (%d, %s, %q)", i, content.PostID, content.LanguageID,
+				content.Title, want[i].postID, want[i].language, want[i].title)
+		}
+	}
+
+	// Languages missing from the update are kept.
+	update.Contents = []testPostContent{{Title: "Hello at last"}}
+	update.Contents[0].LanguageID = "en"
+	if err := repo.Update(&update); err != nil {
+		t.Fatal(err)
+	}
+	read, err := repo.GetByID(first.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(read.Contents) != 2 {
+		t.Errorf("got contents %+v, want the English and Arabic ones", read.Contents)
+	}
+}
+
+// TestGenericRepositoryCreatesContentsOfParentsSharingALanguage checks that Create saves the contents by their
+// content key, the content of a parent leaving those of the other parents in the same language as they are.
+func TestGenericRepositoryCreatesContentsOfParentsSharingALanguage(t *testing.T) {
+	db := testdb.Open(t, &orm.Language{}, &testAuthor{}, &testTag{}, &testPost{}, &testPostContent{})
+	repo := NewGenericRepository[testPost](db, nil)
+
+	posts := []testPost{{Slug: "first"}, {Slug: "second"}}
+	for i := range posts {
+		posts[i].Contents = []testPostContent{{Title: posts[i].Slug}}
+		posts[i].Contents[0].LanguageID = "en"
+		if err := repo.Create(&posts[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, post := range posts {
+		read, err := repo.GetByID(post.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(read.Contents) != 1 || read.Contents[0].PostID != post.ID || read.Contents[0].Title != post.Slug {
+			t.Errorf("post %s has contents %+v, want its own English content", post.Slug, read.Contents)
+		}
+	}
+}
+
+// testArticle has contents keyed by their language alone, as when ContentModel is embedded without tagging the
+// foreign key of the parent with `gorm:"primaryKey"`.
+type testArticle struct {
+	orm.Model
+
+	Contents []testArticleContent `json:"contents" gorm:"foreignKey:ArticleID"`
+}
+
+type testArticleContent struct {
+	orm.ContentModel
+
+	Title     string `json:"title"`
+	ArticleID uint   `json:"article_id"`
+}
+
+func TestGenericRepositoryRejectsContentsKeyedByLanguage(t *testing.T) {
+	db := testdb.Open(t)
+	if err := orm.AutoMigrate(db, &testArticle{}, &testArticleContent{}); err == nil {
+		t.Error("orm.AutoMigrate migrated contents keyed by their language alone")
+	}
+
+	if err := db.AutoMigrate(&testArticle{}, &testArticleContent{}); err != nil {
+		t.Fatal(err)
+	}
+	article := testArticle{Contents: []testArticleContent{{Title: "Hello"}}}
+	article.Contents[0].LanguageID = "en"
+	if err := NewGenericRepository[testArticle](db, nil).Create(&article); err == nil {
+		t.Error("Create saved contents keyed by their language alone")
+	}
+
+	// The rejected record is rolled back along with its contents.
+	var count int64
+	if err := db.Model(&testArticle{}).Count(&count).Error; err != nil || count != 0 {
+		t.Errorf("got %d articles, %v, want none", count, err)
+	}
+}
+
+// TestGenericRepositoryUpdatesContentsWithoutUniqueKey checks that contents are saved by parent and language in
+// tables created by gorm.DB.AutoMigrate, which have no unique index on them to upsert the contents on.
+func TestGenericRepositoryUpdatesContentsWithoutUniqueKey(t *testing.T) {
+	db := testdb.Open(t)
+	if err := db.AutoMigrate(&testPage{}, &testPageContent{}); err != nil {
+		t.Fatal(err)
+	}
+	repo := NewGenericRepository[testPage](db, nil)
+
+	pages := make([]testPage, 2)
+	for i := range pages {
+		pages[i].Contents = []testPageContent{{LanguageID: "en", Title: "Hello"}}
+		if err := repo.Create(&pages[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	contentID := pages[0].Contents[0].ID
+
+	update := pages[0]
+	update.Contents = []testPageContent{{LanguageID: "en", Title: "Hello again"}, {LanguageID: "ar", Title: "Marhaba"}}
+	if err := repo.Update(&update); err != nil {
+		t.Fatal(err)
+	}
+
+	var contents []testPageContent
+	if err := db.Order("page_id, language_id").Find(&contents).Error; err != nil {
+		t.Fatal(err)
+	}
+	want := []testPageContent{
+		{LanguageID: "ar", Title: "Marhaba", PageID: pages[0].ID},
+		{ID: contentID, LanguageID: "en", Title: "Hello again", PageID: pages[0].ID},
+		{LanguageID: "en", Title: "Hello", PageID: pages[1].ID},
+	}
+	if len(contents) != len(want) {
+		t.Fatalf("got %d contents %+v, want %d", len(contents), contents, len(want))
+	}
+	for i, content := range contents {
+		if content.LanguageID != want[i].LanguageID || content.Title != want[i].Title || content.PageID != want[i].PageID ||
+			(want[i].ID != 0 && content.ID != want[i].ID) {
+			t.Errorf("content %d = %+v, want %+v", i, content, want[i])
+		}
+	}
+}
+
+// TestScopedRepositoryIdentifiesRecordsByParent checks that the records of a scoped repository are identified by
+// their parent as well as their primary key, here the language of a content shared by the contents of other notes.
+func TestScopedRepositoryIdentifiesRecordsByParent(t *testing.T) {
+	db := testdb.Open(t, &testKeyedNoteContent{})
+	for _, content := range []testKeyedNoteContent{{Body: "first", NoteID: 1}, {Body: "second", NoteID: 2}} {
+		content.LanguageID = "en"
+		if err := db.Create(&content).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	auditor := &recordingAuditor{}
+	repo := NewScopedRepository[testNoteContent](NewGenericRepository[testNoteContent](db, nil, WithAuditor(auditor)), "NoteID", uint(2), "LanguageID")
+	content := testNoteContent{Body: "updated"}
+	content.LanguageID = "en"
+	if err := repo.Update(&content); err != nil {
+		t.Fatal(err)
+	}
+
+	var contents []testKeyedNoteContent
+	if err := db.Order("note_id").Find(&contents).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(contents) != 2 || contents[0].Body != "first" || contents[1].Body != "updated" {
+		t.Errorf("got contents %+v, want only the content of note 2 updated", contents)
+	}
+
+	if len(auditor.mutations) != 1 {
+		t.Fatalf("recorded %d mutations, want 1", len(auditor.mutations))
+	}
+	mutation := auditor.mutations[0]
+	if before, ok := mutation.Before.(*testNoteContent); !ok || before.Body != "second" {
+		t.Errorf("recorded the update of %+v, want the content of note 2", mutation.Before)
+	}
+	if mutation.RecordID != "en,2" {
+		t.Errorf("recorded the update of record %q, want \"en,2\"", mutation.RecordID)
+	}
+	if id, err := RecordID[testNoteContent](repo, &content); err != nil || id != "en,2" {
+		t.Errorf("RecordID = %q, %v, want \"en,2\"", id, err)
+	}
+}
+
+func TestGenericRepositoryRejectsMissingReferences(t *testing.T) {
+	db := testdb.Open(t, &orm.Language{}, &testAuthor{}, &testTag{}, &testPost{}, &testPostContent{})
+	repo := NewGenericRepository[testPost](db, nil)
+	post := createTestPost(t, repo)
+	missingTag := testTag{Model: orm.Model{ID: 99}}
+
+	tests := []struct {
+		name  string
+		write func() error
+	}{
+		{"create with a missing author", func() error {
+			return repo.Create(&testPost{Slug: "missing", Author: &testAuthor{Model: orm.Model{ID: 42}}})
+		}},
+		{"create with a missing tag", func() error {
+			return repo.Create(&testPost{Slug: "missing", Tags: []testTag{post.Tags[0], missingTag}})
+		}},
+		{"update with a missing tag", func() error {
+			updated := post
+			updated.Tags = []testTag{missingTag}
+			return repo.Update(&updated)
+		}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if err := test.write(); !errors.Is(err, ErrReferenceNotFound) {
+				t.Errorf("got error %v, want ErrReferenceNotFound", err)
+			}
+		})
+	}
+
+	// Nothing was written, the missing records were not inserted empty.
+	for _, table := range []interface{}{&testPost{}, &testAuthor{}, &testTag{}} {
+		var count int64
+		if err := db.Model(table).Count(&count).Error; err != nil {
+			t.Fatal(err)
+		}
+		if count != 1 {
+			t.Errorf("%T has %d records, want only those of the first post", table, count)
+		}
+	}
+	read, err := repo.GetByID(post.ID, IncludeScope[testPost]("tags"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(read.Tags) != 1 || read.Tags[0].ID != post.Tags[0].ID {
+		t.Errorf("tags = %+v, want the tag of the first post", read.Tags)
+	}
+
+	// Existing records are referenced by their primary key alone.
+	other := testPost{Slug: "other", Author: &testAuthor{Model: orm.Model{ID: post.Author.ID}}, Tags: []testTag{{Model: orm.Model{ID: post.Tags[0].ID}}}}
+	if err := repo.Create(&other); err != nil {
+		t.Fatal(err)
+	}
+	if other.Author == nil || other.Author.Name != "Alice" || len(other.Tags) != 1 || other.Tags[0].Name != "go" {
+		t.Errorf("created %+v, want the author and the tag of the first post", other)
+	}
+}
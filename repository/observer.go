@@ -0,0 +1,56 @@
+package repository
+
+import (
+	"reflect"
+	"time"
+
+	"github.com/iancoleman/strcase"
+	"gorm.io/gorm"
+)
+
+// Observer is notified of the operations of a GenericRepository, for example to record metrics.
+// Its methods are called synchronously by the repository and must not block.
+type Observer interface {
+	// ObserveOperation is called once an operation (GetByID, GetAll, Create, Update or Delete) of the
+	// model completes, with its duration and the error it returned, nil on success.
+	ObserveOperation(model, operation string, duration time.Duration, err error)
+	// ObserveRollback is called when an operation of the model rolls its transaction back.
+	ObserveRollback(model, operation string)
+}
+
+// WithObserver notifies observer of the operations of the repository, the model being named
+// after the snake_case name of its type (e.g. "blog_post").
+func WithObserver(observer Observer) RepositoryOption {
+	return func(options *repositoryOptions) {
+		if observer != nil {
+			options.observers = append(options.observers, observer)
+		}
+	}
+}
+
+// ModelName returns the snake_case name of model T, as in "blog_post" or "api_key" for APIKey, under which
+// its repositories are observed and record their mutations (see Mutation). The services look up the audit
+// entries and versions of their records under the same name.
+func ModelName[T any]() string {
+	return strcase.ToSnake(reflect.TypeOf((*T)(nil)).Elem().Name())
+}
+
+// observe notifies the observers that an operation started at start returned *err.
+// It is deferred by the operations, which name their error result.
+func (r *GenericRepository[T]) observe(operation string, start time.Time, err *error) {
+	if len(r.options.observers) == 0 {
+		return
+	}
+	duration := time.Since(start)
+	for _, observer := range r.options.observers {
+		observer.ObserveOperation(r.model, operation, duration, *err)
+	}
+}
+
+// rollback rolls tx back and notifies the observers, returning the error of the rollback.
+func (r *GenericRepository[T]) rollback(tx *gorm.DB, operation string) error {
+	for _, observer := range r.options.observers {
+		observer.ObserveRollback(r.model, operation)
+	}
+	return tx.Rollback().Error
+}
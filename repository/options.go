@@ -0,0 +1,32 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/MuhmdHsn313/origin/logging"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// RepositoryOption configures a GenericRepository created by NewGenericRepository.
+type RepositoryOption func(options *repositoryOptions)
+
+type repositoryOptions struct {
+	// observers are notified of the operations of the repository.
+	observers []Observer
+	// auditors record the mutations of the repository within their transaction.
+	auditors []Auditor
+	// commitObservers are notified of the mutations committed by the repository, see WithCommitObserver.
+	commitObservers []CommitObserver
+	// tracerProvider creates the spans of the operations, the global provider when nil.
+	tracerProvider trace.TracerProvider
+	// successLevel is the level of the lines logged by successful operations.
+	successLevel logging.Level
+	// sampleEvery logs the successes of one operation out of sampleEvery, every operation when 0 or 1.
+	sampleEvery uint64
+	// slowQueryThreshold logs the statements running longer, none when 0.
+	slowQueryThreshold time.Duration
+}
+
+func defaultRepositoryOptions() repositoryOptions {
+	return repositoryOptions{successLevel: logging.LevelInfo}
+}
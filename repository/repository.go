@@ -1,23 +1,43 @@
 package repository
 
 import (
-	"github.com/sirupsen/logrus"
+	"github.com/MuhmdHsn313/origin/logging"
 	"gorm.io/gorm"
 )
 
-type ScopeWithLog func(db *gorm.DB, logger *logrus.Logger) *gorm.DB
+// ScopeWithLog is a GORM scope given the logger of the repository running the query,
+// which holds the fields of the request served, see logging.FromContext.
+type ScopeWithLog func(db *gorm.DB, logger logging.Logger) *gorm.DB
 
 // Repository is a generic interface that abstracts data storage operations for a model of type T.
 type Repository[T any] interface {
 	// GetByID retrieves a model instance by its identifier.
-	GetByID(id interface{}) (T, error)
-	// GetAll returns all model instances that match the provided filter.
+	// Only the content collections are preloaded, the scopes can refine the query, for example to preload
+	// the other associations with IncludeScope or AssociationsScope.
+	// The id may be nil when the scopes alone identify the record, as done by ScopedRepository.
+	GetByID(id interface{}, scopes ...ScopeWithLog) (T, error)
+	// GetAll returns all model instances that match the provided filter, preloaded like with GetByID.
 	// The filter is a map of field names to their expected values.
 	GetAll(scopes ...ScopeWithLog) ([]T, error)
 	// Create inserts a new model instance into the database.
 	Create(model *T) error
 	// Update modifies an existing model instance in the database.
 	Update(model *T) error
-	// Delete removes a model instance identified by id.
-	Delete(id interface{}) error
+	// Delete removes a model instance identified by id, restricted by the optional scopes.
+	// As with GetByID, the id may be nil when the scopes alone identify the record.
+	Delete(id interface{}, scopes ...ScopeWithLog) error
+}
+
+// Reverter is a Repository able to restore a model instance to a previous state, see GenericRepository.Revert.
+type Reverter[T any] interface {
+	// Revert restores the model instance to the state of model, recreating it if it was deleted.
+	Revert(model *T) error
+}
+
+// Revert restores model with repo when it is a Reverter, and updates it otherwise.
+func Revert[T any](repo Repository[T], model *T) error {
+	if reverter, ok := repo.(Reverter[T]); ok {
+		return reverter.Revert(model)
+	}
+	return repo.Update(model)
 }
@@ -0,0 +1,108 @@
+package repository
+
+// ScopedRepository restricts a repository to the children of a single parent record, such as the
+// comments of one blog. Every read and delete is narrowed to the records whose foreign key holds
+// the parent's key, and created or updated records are attached to that parent.
+//
+// Records are identified by their key field, which is their ID by default and may be another
+// column unique within the parent, such as the LanguageID of content models.
+type ScopedRepository[T any] struct {
+	repo       Repository[T]
+	foreignKey string
+	parentKey  interface{}
+	keyField   string
+}
+
+// NewScopedRepository wraps repo so that it only sees the records of T whose foreignKey field
+// (e.g. "BlogID") equals parentKey, looking records up by keyField ("ID" when empty).
+// The parentKey must be convertible to the type of the foreign key field, see ParseKey.
+// The records written through repo are identified by their foreign key too, see WithKeyFields.
+func NewScopedRepository[T any](repo Repository[T], foreignKey string, parentKey interface{}, keyField string) *ScopedRepository[T] {
+	if keyField == "" {
+		keyField = "ID"
+	}
+	return &ScopedRepository[T]{
+		repo:       WithKeyFields(repo, foreignKey),
+		foreignKey: foreignKey,
+		parentKey:  parentKey,
+		keyField:   keyField,
+	}
+}
+
+// parentScope narrows a query to the records of the parent.
+func (r *ScopedRepository[T]) parentScope() ScopeWithLog {
+	return FieldScope[T](r.foreignKey, r.parentKey)
+}
+
+// GetByID retrieves the record of the parent whose key field equals id.
+func (r *ScopedRepository[T]) GetByID(id interface{}, scopes ...ScopeWithLog) (T, error) {
+	return r.repo.GetByID(nil, append(scopes, FieldScope[T](r.keyField, id), r.parentScope())...)
+}
+
+// GetAll returns the records of the parent matching the scopes.
+func (r *ScopedRepository[T]) GetAll(scopes ...ScopeWithLog) ([]T, error) {
+	return r.repo.GetAll(append(scopes, r.parentScope())...)
+}
+
+// Create attaches the model to the parent, whatever foreign key the client sent, and inserts it.
+func (r *ScopedRepository[T]) Create(model *T) error {
+	if err := setField(model, r.foreignKey, r.parentKey); err != nil {
+		return err
+	}
+	return r.repo.Create(model)
+}
+
+// Update saves the model, keeping it attached to the parent so that it cannot be moved to another one.
+func (r *ScopedRepository[T]) Update(model *T) error {
+	if err := setField(model, r.foreignKey, r.parentKey); err != nil {
+		return err
+	}
+	return r.repo.Update(model)
+}
+
+// Revert restores the model, keeping it attached to the parent like Update.
+func (r *ScopedRepository[T]) Revert(model *T) error {
+	if err := setField(model, r.foreignKey, r.parentKey); err != nil {
+		return err
+	}
+	return Revert(r.repo, model)
+}
+
+// Delete removes the record of the parent whose key field equals id.
+func (r *ScopedRepository[T]) Delete(id interface{}, scopes ...ScopeWithLog) error {
+	return r.repo.Delete(nil, append(scopes, FieldScope[T](r.keyField, id), r.parentScope())...)
+}
+
+// KeyedRepository is a Repository whose records can be identified by more fields than their primary key,
+// such as content models declaring only their LanguageID as primary key, which are unique per parent.
+type KeyedRepository[T any] interface {
+	Repository[T]
+	// WithKeyFields returns a repository identifying the records it writes by fields, the Go names of fields
+	// of T, along with their primary key and the key fields of the receiver, which is left unchanged.
+	WithKeyFields(fields ...string) Repository[T]
+}
+
+// WithKeyFields returns repo identifying the records it writes by fields along with their primary key when it
+// is a KeyedRepository, repo otherwise.
+func WithKeyFields[T any](repo Repository[T], fields ...string) Repository[T] {
+	if keyed, ok := repo.(KeyedRepository[T]); ok {
+		return keyed.WithKeyFields(fields...)
+	}
+	return repo
+}
+
+// WithKeyFields returns a copy of the repository identifying the records it writes by fields along with their
+// primary key: updates, reloads, the versions loaded for the auditors and the record IDs of the mutations
+// (see RecordID) use them. The fields that are already part of the primary key are ignored.
+func (r *GenericRepository[T]) WithKeyFields(fields ...string) Repository[T] {
+	clone := *r
+	clone.keyFields = append(append([]string(nil), r.keyFields...), fields...)
+	return &clone
+}
+
+// WithKeyFields returns a copy of the repository whose wrapped repository identifies its records by fields.
+func (r *ScopedRepository[T]) WithKeyFields(fields ...string) Repository[T] {
+	clone := *r
+	clone.repo = WithKeyFields(r.repo, fields...)
+	return &clone
+}
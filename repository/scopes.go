@@ -0,0 +1,244 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/MuhmdHsn313/origin/logging"
+	"github.com/MuhmdHsn313/origin/orm"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+// FilterScope returns a scope that narrows a query on T to the non-nil fields of filter.
+// The filter is expected to be a (pointer to a) struct such as the ones produced by
+// service.Engine.GenerateFilterParameters, where every field is a pointer and nil means "no filter".
+//
+// Fields matching a column of T are compared directly. Fields matching a column of a
+// content association (e.g. "Content" or "LanguageID" of BlogContent) select the
+// records that own at least one content row with that value, in any content collection.
+func FilterScope[T any](filter interface{}) ScopeWithLog {
+	return func(db *gorm.DB, logger logging.Logger) *gorm.DB {
+		var model T
+		stmt := &gorm.Statement{DB: db}
+		if err := stmt.Parse(&model); err != nil {
+			_ = db.AddError(err)
+			return db
+		}
+		modelSchema := stmt.Schema
+
+		filterValue := reflect.ValueOf(filter)
+		for filterValue.Kind() == reflect.Ptr {
+			if filterValue.IsNil() {
+				return db
+			}
+			filterValue = filterValue.Elem()
+		}
+		if filterValue.Kind() != reflect.Struct {
+			return db
+		}
+
+		for i := 0; i < filterValue.NumField(); i++ {
+			fieldName := filterValue.Type().Field(i).Name
+			fieldValue := filterValue.Field(i)
+			if fieldValue.Kind() == reflect.Ptr {
+				if fieldValue.IsNil() {
+					continue
+				}
+				fieldValue = fieldValue.Elem()
+			}
+			value := fieldValue.Interface()
+
+			// Filter on a column of the model itself.
+			if field := modelSchema.LookUpField(fieldName); field != nil && field.DBName != "" {
+				logger.WithFields(logging.Fields{
+					"operation": "Filter",
+					"field":     fieldName,
+				}).Debug("Applying model filter")
+				db = db.Where(clause.Eq{
+					Column: clause.Column{Table: modelSchema.Table, Name: field.DBName},
+					Value:  value,
+				})
+				continue
+			}
+
+			// Filter on a column of the content associations, through a sub-query on each content table.
+			// A field shared by several content collections matches the records having the value in any of them.
+			var conditions []clause.Expression
+			for _, relation := range modelSchema.Relationships.HasMany {
+				if !isContentRelation(relation) {
+					continue
+				}
+				field := relation.FieldSchema.LookUpField(fieldName)
+				if field == nil || field.DBName == "" {
+					continue
+				}
+
+				for _, reference := range relation.References {
+					if !reference.OwnPrimaryKey {
+						continue
+					}
+					logger.WithFields(logging.Fields{
+						"operation":   "Filter",
+						"field":       fieldName,
+						"association": relation.Name,
+					}).Debug("Applying content filter")
+
+					subQuery := db.Session(&gorm.Session{NewDB: true}).
+						Table(relation.FieldSchema.Table).
+						Select(reference.ForeignKey.DBName).
+						Where(clause.Eq{Column: clause.Column{Name: field.DBName}, Value: value})
+					conditions = append(conditions, clause.Expr{
+						SQL:  "? IN (?)",
+						Vars: []interface{}{clause.Column{Table: modelSchema.Table, Name: reference.PrimaryKey.DBName}, subQuery},
+					})
+				}
+			}
+
+			if len(conditions) == 1 {
+				db = db.Where(conditions[0])
+			} else if len(conditions) > 1 {
+				db = db.Where(clause.Or(conditions...))
+			}
+		}
+
+		return db
+	}
+}
+
+// FieldScope returns a scope that narrows a query on T to the records whose field equals value.
+// The field is the Go name of a column of T, such as "BlogID" or "LanguageID".
+func FieldScope[T any](field string, value interface{}) ScopeWithLog {
+	return func(db *gorm.DB, logger logging.Logger) *gorm.DB {
+		var model T
+		stmt := &gorm.Statement{DB: db}
+		if err := stmt.Parse(&model); err != nil {
+			_ = db.AddError(err)
+			return db
+		}
+
+		schemaField := stmt.Schema.LookUpField(field)
+		if schemaField == nil || schemaField.DBName == "" {
+			_ = db.AddError(fmt.Errorf("%s has no column %s", stmt.Schema.Name, field))
+			return db
+		}
+
+		logger.WithFields(logging.Fields{
+			"operation": "Scope",
+			"field":     field,
+		}).Debug("Applying field scope")
+		return db.Where(clause.Eq{
+			Column: clause.Column{Table: stmt.Schema.Table, Name: schemaField.DBName},
+			Value:  value,
+		})
+	}
+}
+
+// PaginateScope returns a scope that limits a query to at most limit rows, starting after offset rows.
+// A limit lower than or equal to zero disables the limit, and a negative offset is treated as zero.
+func PaginateScope(limit, offset int) ScopeWithLog {
+	return func(db *gorm.DB, logger logging.Logger) *gorm.DB {
+		if offset < 0 {
+			offset = 0
+		}
+
+		logger.WithFields(logging.Fields{
+			"operation": "Paginate",
+			"limit":     limit,
+			"offset":    offset,
+		}).Debug("Applying pagination")
+
+		if limit > 0 {
+			db = db.Limit(limit)
+		}
+		if offset > 0 {
+			db = db.Offset(offset)
+		}
+		return db
+	}
+}
+
+// isContentRelation reports whether an association is a content collection, see orm.IsContentRelation.
+func isContentRelation(relation *schema.Relationship) bool {
+	return orm.IsContentRelation(relation)
+}
+
+// ErrInvalidInclude is returned (wrapped) by reads using IncludeScope with a path that does not
+// name an association of the model.
+var ErrInvalidInclude = errors.New("invalid include")
+
+// IncludeScope returns a scope that preloads the associations named by include paths, such as
+// "author", "tags" or "comments.author". Path segments are the JSON names of association fields
+// (their Go names are accepted too), nested associations are separated by dots.
+//
+// Content collections of every included association are preloaded as well, so that included
+// records are returned with their multilingual content. An unknown path makes the query fail
+// with an error wrapping ErrInvalidInclude.
+func IncludeScope[T any](paths ...string) ScopeWithLog {
+	return func(db *gorm.DB, logger logging.Logger) *gorm.DB {
+		var model T
+		stmt := &gorm.Statement{DB: db}
+		if err := stmt.Parse(&model); err != nil {
+			_ = db.AddError(err)
+			return db
+		}
+
+		for _, path := range paths {
+			relationSchema := stmt.Schema
+			var names []string
+			for _, segment := range strings.Split(path, ".") {
+				relation := lookUpRelation(relationSchema, segment)
+				if relation == nil {
+					_ = db.AddError(fmt.Errorf("%w: %q has no association %q", ErrInvalidInclude, path, segment))
+					return db
+				}
+				names = append(names, relation.Name)
+				relationSchema = relation.FieldSchema
+
+				// Content of the included records is preloaded with them.
+				for _, contentRelation := range relationSchema.Relationships.HasMany {
+					if isContentRelation(contentRelation) {
+						db = db.Preload(strings.Join(append(names, contentRelation.Name), "."))
+					}
+				}
+			}
+
+			logger.WithFields(logging.Fields{
+				"operation": "Include",
+				"include":   path,
+			}).Debug("Applying include")
+			db = db.Preload(strings.Join(names, "."))
+		}
+		return db
+	}
+}
+
+// AssociationsScope returns a scope that preloads every direct association of the model, such as
+// the current items of a collection before they are merged with the changes of an update.
+func AssociationsScope() ScopeWithLog {
+	return func(db *gorm.DB, logger logging.Logger) *gorm.DB {
+		logger.WithFields(logging.Fields{
+			"operation": "Include",
+			"include":   clause.Associations,
+		}).Debug("Applying include")
+		return db.Preload(clause.Associations)
+	}
+}
+
+// lookUpRelation finds an association of a schema by the JSON name of its field or by its Go name.
+func lookUpRelation(modelSchema *schema.Schema, name string) *schema.Relationship {
+	for _, relation := range modelSchema.Relationships.Relations {
+		// GORM also lists the has-one and has-many relations of other schemas pointing to this one.
+		if relation.Schema != modelSchema {
+			continue
+		}
+		jsonName := strings.Split(relation.Field.Tag.Get("json"), ",")[0]
+		if jsonName == name || strings.EqualFold(relation.Name, name) {
+			return relation
+		}
+	}
+	return nil
+}
@@ -0,0 +1,83 @@
+package repository
+
+import (
+	"context"
+	"errors"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"gorm.io/gorm"
+)
+
+// TracerName is the instrumentation scope of the spans of the repository operations.
+const TracerName = "github.com/MuhmdHsn313/origin/repository"
+
+// ContextRepository is a Repository whose queries can run within a context, such as the context
+// of a request, which carries its deadline and its trace.
+type ContextRepository[T any] interface {
+	Repository[T]
+	// WithContext returns a repository running its queries within ctx. The receiver is left unchanged.
+	WithContext(ctx context.Context) Repository[T]
+}
+
+// WithContext returns repo running its queries within ctx when it is a ContextRepository, repo otherwise.
+func WithContext[T any](repo Repository[T], ctx context.Context) Repository[T] {
+	if contextual, ok := repo.(ContextRepository[T]); ok {
+		return contextual.WithContext(ctx)
+	}
+	return repo
+}
+
+// WithTracerProvider creates the spans of the repository operations with provider,
+// instead of the global provider of otel.GetTracerProvider.
+func WithTracerProvider(provider trace.TracerProvider) RepositoryOption {
+	return func(options *repositoryOptions) {
+		options.tracerProvider = provider
+	}
+}
+
+// tracer returns the tracer of the repository operations.
+func (options repositoryOptions) tracer() trace.Tracer {
+	if options.tracerProvider == nil {
+		return otel.GetTracerProvider().Tracer(TracerName)
+	}
+	return options.tracerProvider.Tracer(TracerName)
+}
+
+// WithContext returns a copy of the repository running its queries within ctx.
+func (r *GenericRepository[T]) WithContext(ctx context.Context) Repository[T] {
+	clone := *r
+	clone.db = r.db.WithContext(ctx)
+	return &clone
+}
+
+// startSpan starts the span of an operation, a child of the span of the context of the repository,
+// and returns the database bound to the context of the new span so that its queries are nested in it.
+func (r *GenericRepository[T]) startSpan(operation string) (*gorm.DB, trace.Span) {
+	ctx, span := r.tracer.Start(r.db.Statement.Context, "GenericRepository."+operation,
+		trace.WithAttributes(
+			attribute.String("origin.model", r.model),
+			attribute.String("origin.operation", operation),
+		),
+	)
+	return r.db.WithContext(ctx), span
+}
+
+// endSpan records *err on span and ends it. It is deferred by the operations, which name their error result.
+// Missing records are expected by the callers and are not reported as span errors.
+func endSpan(span trace.Span, err *error) {
+	if *err != nil && !errors.Is(*err, gorm.ErrRecordNotFound) {
+		span.RecordError(*err)
+		span.SetStatus(codes.Error, (*err).Error())
+	}
+	span.End()
+}
+
+// WithContext returns a copy of the repository running the queries of the wrapped repository within ctx.
+func (r *ScopedRepository[T]) WithContext(ctx context.Context) Repository[T] {
+	clone := *r
+	clone.repo = WithContext(r.repo, ctx)
+	return &clone
+}
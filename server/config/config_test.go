@@ -0,0 +1,125 @@
+package config
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(config *Config)
+		// want are the keys of the invalid settings, none for a valid configuration.
+		want []string
+	}{
+		{"defaults", func(config *Config) {}, nil},
+		{"valid settings", func(config *Config) {
+			config.Database.MaxOpenConns = 10
+			config.Database.MaxIdleConns = 10
+			config.Log.Level = "debug"
+			config.Log.Format = "json"
+			config.Log.SuccessLevel = "debug"
+			config.CORS.AllowedOrigins = []string{"*"}
+			config.Metrics.Enabled = true
+			config.Tracing.SampleRatio = 0
+			config.Models = map[string]Model{"blog": {Path: "posts", Operations: []string{"list", "get", "audit"}, DefaultLimit: 10}}
+		}, nil},
+		{"server", func(config *Config) {
+			config.Server.Addr = ""
+			config.Server.APIPrefix = "api"
+			config.Server.ShutdownTimeout = -time.Second
+		}, []string{"server.addr", "server.api_prefix", "server.shutdown_timeout"}},
+		{"database", func(config *Config) {
+			config.Database.DSN = ""
+			config.Database.MaxOpenConns = -1
+			config.Database.ConnMaxLifetime = -time.Second
+			config.Database.ConnMaxIdleTime = -time.Second
+		}, []string{"database.dsn", "database.max_open_conns", "database.conn_max_lifetime", "database.conn_max_idle_time"}},
+		{"idle connections", func(config *Config) {
+			config.Database.MaxOpenConns = 2
+			config.Database.MaxIdleConns = 3
+		}, []string{"database.max_idle_conns"}},
+		{"log", func(config *Config) {
+			config.Log.Level = "loud"
+			config.Log.Format = "xml"
+			config.Log.SuccessLevel = "trace"
+			config.Log.SuccessSampling = -1
+			config.Log.SlowQueryThreshold = -time.Second
+		}, []string{"log.level", "log.format", "log.success_level", "log.success_sampling", "log.slow_query_threshold"}},
+		{"cors", func(config *Config) {
+			config.CORS.AllowedOrigins = []string{"https://a.example", ""}
+			config.CORS.MaxAge = -time.Second
+		}, []string{"cors.allowed_origins", "cors.max_age"}},
+		{"pagination", func(config *Config) {
+			config.Pagination.DefaultLimit = 50
+			config.Pagination.MaxLimit = 20
+		}, []string{"pagination.default_limit"}},
+		{"metrics path", func(config *Config) {
+			config.Metrics.Enabled = true
+			config.Metrics.Path = "metrics"
+		}, []string{"metrics.path"}},
+		{"disabled metrics path", func(config *Config) {
+			config.Metrics.Path = "metrics"
+		}, nil},
+		{"tracing", func(config *Config) {
+			config.Tracing.SampleRatio = 1.5
+		}, []string{"tracing.sample_ratio"}},
+		{"events", func(config *Config) {
+			config.Events.RelayInterval = 0
+			config.Events.BatchSize = 0
+		}, []string{"events.relay_interval", "events.batch_size"}},
+		{"models", func(config *Config) {
+			config.Models = map[string]Model{
+				"blog": {Path: "/posts", Operations: []string{"list", "publish"}},
+				// The limits of a model are checked merged with the global ones.
+				"tag": {DefaultLimit: 2000},
+			}
+		}, []string{"models.blog.path", "models.blog.operations", "models.tag.default_limit"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			config := Default()
+			test.modify(&config)
+			err := config.Validate()
+			if len(test.want) == 0 {
+				if err != nil {
+					t.Errorf("Validate = %v, want nil", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatalf("Validate = nil, want errors for %q", test.want)
+			}
+
+			// Every invalid setting is reported at once, one per line.
+			lines := strings.Split(err.Error(), "\n")
+			if len(lines) != len(test.want) {
+				t.Errorf("Validate reported %d errors, want %d:\n%v", len(lines), len(test.want), err)
+			}
+			for i, key := range test.want {
+				if i < len(lines) && !strings.HasPrefix(lines[i], key+": ") {
+					t.Errorf("error %d is %q, want the error of %s", i, lines[i], key)
+				}
+			}
+		})
+	}
+}
+
+func TestModelPagination(t *testing.T) {
+	global := Pagination{DefaultLimit: 20, MaxLimit: 100}
+	tests := []struct {
+		model Model
+		want  Pagination
+	}{
+		{Model{}, global},
+		{Model{DefaultLimit: 10}, Pagination{DefaultLimit: 10, MaxLimit: 100}},
+		{Model{MaxLimit: 50}, Pagination{DefaultLimit: 20, MaxLimit: 50}},
+		{Model{DefaultLimit: -1, MaxLimit: -1}, global},
+	}
+	for _, test := range tests {
+		if got := test.model.Pagination(global); got != test.want {
+			t.Errorf("%+v.Pagination = %+v, want %+v", test.model, got, test.want)
+		}
+	}
+}
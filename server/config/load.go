@@ -0,0 +1,338 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultEnvPrefix prefixes the environment variables read by Load, as in ORIGIN_DATABASE_DSN.
+const DefaultEnvPrefix = "ORIGIN"
+
+// ConfigFlag is the flag, and with the prefix the environment variable (ORIGIN_CONFIG), naming the configuration file.
+const ConfigFlag = "config"
+
+// Option configures Load.
+type Option func(options *loadOptions)
+
+type loadOptions struct {
+	// defaults is the configuration overridden by the sources.
+	defaults Config
+	// file is the configuration file, overridden by the ConfigFlag variable and flag.
+	file string
+	// envPrefix prefixes the environment variables, none are read when empty.
+	envPrefix string
+	// flags holds the flags bound by BindFlags, once parsed.
+	flags *flag.FlagSet
+}
+
+// WithDefaults replaces the defaults of Load, Default by default.
+func WithDefaults(defaults Config) Option {
+	return func(options *loadOptions) {
+		options.defaults = defaults
+	}
+}
+
+// WithFile reads the configuration file at path, TOML (.toml) or YAML (.yaml or .yml).
+// The file named by the ORIGIN_CONFIG environment variable or the -config flag is read instead when set.
+func WithFile(path string) Option {
+	return func(options *loadOptions) {
+		options.file = path
+	}
+}
+
+// WithEnvPrefix sets the prefix of the environment variables, DefaultEnvPrefix by default.
+// An empty prefix ignores the environment.
+func WithEnvPrefix(prefix string) Option {
+	return func(options *loadOptions) {
+		options.envPrefix = prefix
+	}
+}
+
+// WithFlags applies the flags set on the command line, flags must have been bound by BindFlags and parsed.
+func WithFlags(flags *flag.FlagSet) Option {
+	return func(options *loadOptions) {
+		options.flags = flags
+	}
+}
+
+// Load merges the defaults, the configuration file, the environment variables and the flags,
+// in that order, and validates the result.
+//
+// Parameters:
+//   - opts: The sources, such as WithFile and WithFlags.
+//
+// Returns:
+//   - The configuration, or an error if a source cannot be read, holds an unknown key or an invalid value,
+//     or the merged configuration is invalid (see Config.Validate).
+func Load(opts ...Option) (Config, error) {
+	options := loadOptions{defaults: Default(), envPrefix: DefaultEnvPrefix}
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	config := options.defaults
+	file := options.file
+	if value, ok := lookupEnv(options.envPrefix, []string{ConfigFlag}); ok {
+		file = value
+	}
+	if options.flags != nil {
+		if configFlag := options.flags.Lookup(ConfigFlag); configFlag != nil && configFlag.Value.String() != "" {
+			file = configFlag.Value.String()
+		}
+	}
+
+	if file != "" {
+		if err := decodeFile(file, &config); err != nil {
+			return Config{}, err
+		}
+	}
+	if options.envPrefix != "" {
+		if err := applyEnv(options.envPrefix, &config); err != nil {
+			return Config{}, err
+		}
+	}
+	if options.flags != nil {
+		if err := applyFlags(options.flags, &config); err != nil {
+			return Config{}, err
+		}
+	}
+
+	if err := config.Validate(); err != nil {
+		return Config{}, fmt.Errorf("invalid configuration: %w", err)
+	}
+	return config, nil
+}
+
+// BindFlags defines the flags read by WithFlags on flags: -config and a flag per setting,
+// named after its key with dashes, such as -database.dsn and -database.max-open-conns.
+// Per-model options are not available as flags.
+func BindFlags(flags *flag.FlagSet) {
+	flags.String(ConfigFlag, "", "configuration file (TOML or YAML)")
+
+	defaults := Default()
+	walkSettings(reflect.ValueOf(&defaults).Elem(), nil, func(key []string, value reflect.Value) {
+		usage := "overrides " + strings.Join(key, ".")
+		if value.Kind() == reflect.Bool {
+			flags.Bool(flagName(key), value.Bool(), usage)
+		} else {
+			flags.String(flagName(key), formatValue(value), usage)
+		}
+	})
+}
+
+// decodeFile decodes the configuration file at path into config, rejecting unknown keys.
+func decodeFile(path string, config *Config) error {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".toml":
+		metadata, err := toml.Decode(string(content), config)
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		if undecoded := metadata.Undecoded(); len(undecoded) > 0 {
+			return fmt.Errorf("%s: unknown key %s", path, undecoded[0])
+		}
+	case ".yaml", ".yml":
+		decoder := yaml.NewDecoder(bytes.NewReader(content))
+		decoder.KnownFields(true)
+		if err := decoder.Decode(config); err != nil && !errors.Is(err, io.EOF) {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+	default:
+		return fmt.Errorf("%s: unknown configuration format, expected .toml, .yaml or .yml", path)
+	}
+	return nil
+}
+
+// applyEnv sets the settings of config from the environment variables named after their key,
+// such as ORIGIN_DATABASE_DSN, and the options of the models from variables such as ORIGIN_MODELS_BLOG_POST_MAX_LIMIT.
+func applyEnv(prefix string, config *Config) error {
+	var err error
+	walkSettings(reflect.ValueOf(config).Elem(), nil, func(key []string, value reflect.Value) {
+		if raw, ok := lookupEnv(prefix, key); ok && err == nil {
+			if setErr := setValue(value, raw); setErr != nil {
+				err = fmt.Errorf("%s: %w", envName(prefix, key), setErr)
+			}
+		}
+	})
+	if err != nil {
+		return err
+	}
+
+	// The name of a model may hold underscores, it is what precedes the key of a known model setting.
+	modelsPrefix := envName(prefix, []string{"models"}) + "_"
+	var modelKeys [][]string
+	walkSettings(reflect.ValueOf(&Model{}).Elem(), nil, func(key []string, value reflect.Value) {
+		modelKeys = append(modelKeys, key)
+	})
+	for _, variable := range os.Environ() {
+		name, raw, _ := strings.Cut(variable, "=")
+		if !strings.HasPrefix(name, modelsPrefix) {
+			continue
+		}
+
+		rest := strings.TrimPrefix(name, modelsPrefix)
+		for _, key := range modelKeys {
+			suffix := "_" + envName("", key)
+			if !strings.HasSuffix(rest, suffix) || len(rest) == len(suffix) {
+				continue
+			}
+
+			modelName := strings.ToLower(strings.TrimSuffix(rest, suffix))
+			if config.Models == nil {
+				config.Models = make(map[string]Model)
+			}
+			model := config.Models[modelName]
+			if err := setValue(settingValue(reflect.ValueOf(&model).Elem(), key), raw); err != nil {
+				return fmt.Errorf("%s: %w", name, err)
+			}
+			config.Models[modelName] = model
+			break
+		}
+	}
+	return nil
+}
+
+// applyFlags sets the settings of config from the flags set on the command line.
+func applyFlags(flags *flag.FlagSet, config *Config) error {
+	set := make(map[string]string)
+	flags.Visit(func(f *flag.Flag) {
+		set[f.Name] = f.Value.String()
+	})
+
+	var err error
+	walkSettings(reflect.ValueOf(config).Elem(), nil, func(key []string, value reflect.Value) {
+		if raw, ok := set[flagName(key)]; ok && err == nil {
+			if setErr := setValue(value, raw); setErr != nil {
+				err = fmt.Errorf("-%s: %w", flagName(key), setErr)
+			}
+		}
+	})
+	return err
+}
+
+// walkSettings calls fn with the key and value of every setting of a struct, skipping maps.
+func walkSettings(value reflect.Value, key []string, fn func(key []string, value reflect.Value)) {
+	for i := 0; i < value.NumField(); i++ {
+		field := value.Type().Field(i)
+		fieldKey := append(append([]string(nil), key...), strings.Split(field.Tag.Get("toml"), ",")[0])
+
+		switch {
+		case field.Type.Kind() == reflect.Map:
+			continue
+		case field.Type.Kind() == reflect.Struct:
+			walkSettings(value.Field(i), fieldKey, fn)
+		default:
+			fn(fieldKey, value.Field(i))
+		}
+	}
+}
+
+// settingValue returns the setting of a struct with the given key.
+func settingValue(value reflect.Value, key []string) reflect.Value {
+	var found reflect.Value
+	walkSettings(value, nil, func(settingKey []string, settingValue reflect.Value) {
+		if strings.Join(settingKey, ".") == strings.Join(key, ".") {
+			found = settingValue
+		}
+	})
+	return found
+}
+
+// durationType is the type of the duration settings, which are set from strings such as "30s".
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// setValue sets a setting from its textual value, lists being comma-separated.
+func setValue(value reflect.Value, raw string) error {
+	if value.Type() == durationType {
+		duration, err := time.ParseDuration(raw)
+		if err != nil {
+			return err
+		}
+		value.SetInt(int64(duration))
+		return nil
+	}
+
+	switch value.Kind() {
+	case reflect.String:
+		value.SetString(raw)
+	case reflect.Int:
+		number, err := strconv.Atoi(raw)
+		if err != nil {
+			return fmt.Errorf("invalid integer %q", raw)
+		}
+		value.SetInt(int64(number))
+	case reflect.Float64:
+		number, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return fmt.Errorf("invalid number %q", raw)
+		}
+		value.SetFloat(number)
+	case reflect.Bool:
+		boolean, err := strconv.ParseBool(raw)
+		if err != nil {
+			return fmt.Errorf("invalid boolean %q", raw)
+		}
+		value.SetBool(boolean)
+	case reflect.Slice:
+		var items []string
+		for _, item := range strings.Split(raw, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		value.Set(reflect.ValueOf(items))
+	default:
+		return fmt.Errorf("unsupported setting type %s", value.Type())
+	}
+	return nil
+}
+
+// formatValue returns the textual value of a setting, as accepted by setValue.
+func formatValue(value reflect.Value) string {
+	if value.Type() == durationType {
+		return time.Duration(value.Int()).String()
+	}
+	if value.Kind() == reflect.Slice {
+		return strings.Join(value.Interface().([]string), ",")
+	}
+	return fmt.Sprint(value.Interface())
+}
+
+// lookupEnv returns the environment variable of a key.
+func lookupEnv(prefix string, key []string) (string, bool) {
+	if prefix == "" {
+		return "", false
+	}
+	return os.LookupEnv(envName(prefix, key))
+}
+
+// envName returns the environment variable of a key, such as ORIGIN_DATABASE_MAX_OPEN_CONNS.
+func envName(prefix string, key []string) string {
+	name := strings.ToUpper(strings.Join(key, "_"))
+	if prefix == "" {
+		return name
+	}
+	return prefix + "_" + name
+}
+
+// flagName returns the flag of a key, such as database.max-open-conns.
+func flagName(key []string) string {
+	return strings.ReplaceAll(strings.Join(key, "."), "_", "-")
+}
@@ -0,0 +1,280 @@
+package config
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLoad(t *testing.T) {
+	tests := []struct {
+		name string
+		// files are written in a temporary directory, the one named app.* is given to WithFile.
+		files map[string]string
+		env   map[string]string
+		// flags are parsed by a flag set bound by BindFlags, nil giving no flags to Load.
+		flags []string
+		opts  []Option
+		// want modifies the defaults into the expected configuration.
+		want func(config *Config)
+		// wantErr is a part of the expected error.
+		wantErr string
+	}{
+		{
+			name: "defaults",
+			want: func(config *Config) {},
+		},
+		{
+			name:  "toml file over defaults",
+			files: map[string]string{"app.toml": "[server]\naddr = \":9000\"\n\n[log]\nformat = \"json\"\nslow_query_threshold = \"1s\"\n"},
+			want: func(config *Config) {
+				config.Server.Addr = ":9000"
+				config.Log.Format = "json"
+				config.Log.SlowQueryThreshold = time.Second
+			},
+		},
+		{
+			name:  "yaml file over defaults",
+			files: map[string]string{"app.yaml": "server:\n  addr: \":9000\"\ncors:\n  allowed_origins: [\"https://a.example\"]\n"},
+			want: func(config *Config) {
+				config.Server.Addr = ":9000"
+				config.CORS.AllowedOrigins = []string{"https://a.example"}
+			},
+		},
+		{
+			name:  "empty yaml file",
+			files: map[string]string{"app.yml": ""},
+			want:  func(config *Config) {},
+		},
+		{
+			name:  "environment over file",
+			files: map[string]string{"app.toml": "[server]\naddr = \":9000\"\napi_prefix = \"/v1\"\n"},
+			env:   map[string]string{"ORIGIN_SERVER_ADDR": ":9100", "ORIGIN_CORS_ALLOWED_ORIGINS": "https://a.example, https://b.example"},
+			want: func(config *Config) {
+				config.Server.Addr = ":9100"
+				config.Server.APIPrefix = "/v1"
+				config.CORS.AllowedOrigins = []string{"https://a.example", "https://b.example"}
+			},
+		},
+		{
+			name:  "set flags over environment",
+			files: map[string]string{"app.toml": "[database]\nmax_open_conns = 5\n"},
+			env:   map[string]string{"ORIGIN_SERVER_ADDR": ":9100", "ORIGIN_DATABASE_MAX_OPEN_CONNS": "10"},
+			flags: []string{"-server.addr", ":9200", "-metrics.enabled"},
+			want: func(config *Config) {
+				config.Server.Addr = ":9200"
+				config.Database.MaxOpenConns = 10
+				config.Metrics.Enabled = true
+			},
+		},
+		{
+			// The defaults of the flags that are not set do not override the other sources.
+			name:  "unset flags",
+			env:   map[string]string{"ORIGIN_SERVER_ADDR": ":9100"},
+			flags: []string{},
+			want: func(config *Config) {
+				config.Server.Addr = ":9100"
+			},
+		},
+		{
+			name:  "configuration file of the environment",
+			files: map[string]string{"app.toml": "[server]\naddr = \":9000\"\n", "other.toml": "[server]\naddr = \":9001\"\n"},
+			env:   map[string]string{"ORIGIN_CONFIG": "other.toml"},
+			want: func(config *Config) {
+				config.Server.Addr = ":9001"
+			},
+		},
+		{
+			name:  "configuration file of the flags",
+			files: map[string]string{"app.toml": "[server]\naddr = \":9000\"\n", "other.toml": "[server]\naddr = \":9001\"\n", "flag.toml": "[server]\naddr = \":9002\"\n"},
+			env:   map[string]string{"ORIGIN_CONFIG": "other.toml"},
+			flags: []string{"-config", "flag.toml"},
+			want: func(config *Config) {
+				config.Server.Addr = ":9002"
+			},
+		},
+		{
+			name:  "models",
+			files: map[string]string{"app.toml": "[models.blog_post]\npluralize = true\noperations = [\"list\", \"get\"]\nmax_limit = 100\n"},
+			env:   map[string]string{"ORIGIN_MODELS_BLOG_POST_MAX_LIMIT": "50", "ORIGIN_MODELS_TAG_VERSIONED": "true"},
+			want: func(config *Config) {
+				config.Models = map[string]Model{
+					"blog_post": {Pluralize: true, Operations: []string{"list", "get"}, MaxLimit: 50},
+					"tag":       {Versioned: true},
+				}
+			},
+		},
+		{
+			name: "custom environment prefix",
+			env:  map[string]string{"ORIGIN_SERVER_ADDR": ":9100", "APP_SERVER_ADDR": ":9300"},
+			opts: []Option{WithEnvPrefix("APP")},
+			want: func(config *Config) {
+				config.Server.Addr = ":9300"
+			},
+		},
+		{
+			name: "environment ignored",
+			env:  map[string]string{"ORIGIN_SERVER_ADDR": ":9100"},
+			opts: []Option{WithEnvPrefix("")},
+			want: func(config *Config) {},
+		},
+		{
+			name: "custom defaults",
+			opts: []Option{WithDefaults(func() Config {
+				config := Default()
+				config.Server.Addr = ":7000"
+				return config
+			}())},
+			want: func(config *Config) {
+				config.Server.Addr = ":7000"
+			},
+		},
+		{
+			name:    "unknown toml key",
+			files:   map[string]string{"app.toml": "[server]\nport = 9000\n"},
+			wantErr: "unknown key server.port",
+		},
+		{
+			name:    "unknown yaml key",
+			files:   map[string]string{"app.yaml": "server:\n  port: 9000\n"},
+			wantErr: "field port not found",
+		},
+		{
+			name:    "unknown section",
+			files:   map[string]string{"app.toml": "[cache]\nsize = 1\n"},
+			wantErr: "unknown key cache",
+		},
+		{
+			name:    "unknown format",
+			files:   map[string]string{"app.json": "{}"},
+			wantErr: "unknown configuration format",
+		},
+		{
+			name:    "missing file",
+			env:     map[string]string{"ORIGIN_CONFIG": "missing.toml"},
+			wantErr: "missing.toml",
+		},
+		{
+			name:    "bad file value",
+			files:   map[string]string{"app.toml": "[database]\nmax_open_conns = \"many\"\n"},
+			wantErr: "app.toml",
+		},
+		{
+			name:    "bad environment duration",
+			env:     map[string]string{"ORIGIN_SERVER_SHUTDOWN_TIMEOUT": "soon"},
+			wantErr: "ORIGIN_SERVER_SHUTDOWN_TIMEOUT",
+		},
+		{
+			name:    "bad environment boolean",
+			env:     map[string]string{"ORIGIN_AUDIT_ENABLED": "maybe"},
+			wantErr: `ORIGIN_AUDIT_ENABLED: invalid boolean "maybe"`,
+		},
+		{
+			name:    "bad model environment value",
+			env:     map[string]string{"ORIGIN_MODELS_BLOG_MAX_LIMIT": "lots"},
+			wantErr: `ORIGIN_MODELS_BLOG_MAX_LIMIT: invalid integer "lots"`,
+		},
+		{
+			name:    "bad flag value",
+			flags:   []string{"-database.max-open-conns", "many"},
+			wantErr: `-database.max-open-conns: invalid integer "many"`,
+		},
+		{
+			name:    "invalid merged configuration",
+			files:   map[string]string{"app.toml": "[log]\nformat = \"xml\"\n"},
+			env:     map[string]string{"ORIGIN_TRACING_SAMPLE_RATIO": "2"},
+			wantErr: "invalid configuration",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// The files, including those named by the environment and the flags, are in dir.
+			dir := t.TempDir()
+			opts := append([]Option(nil), test.opts...)
+			for name, content := range test.files {
+				if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+					t.Fatal(err)
+				}
+				if strings.HasPrefix(name, "app.") {
+					opts = append(opts, WithFile(filepath.Join(dir, name)))
+				}
+			}
+			for name, value := range test.env {
+				if name == "ORIGIN_CONFIG" {
+					value = filepath.Join(dir, value)
+				}
+				t.Setenv(name, value)
+			}
+			if test.flags != nil {
+				args := append([]string(nil), test.flags...)
+				for i := 1; i < len(args); i++ {
+					if args[i-1] == "-"+ConfigFlag {
+						args[i] = filepath.Join(dir, args[i])
+					}
+				}
+				flags := flag.NewFlagSet("test", flag.ContinueOnError)
+				BindFlags(flags)
+				if err := flags.Parse(args); err != nil {
+					t.Fatal(err)
+				}
+				opts = append(opts, WithFlags(flags))
+			}
+
+			config, err := Load(opts...)
+			if test.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+					t.Fatalf("Load = %v, want an error containing %q", err, test.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := Default()
+			test.want(&want)
+			if !reflect.DeepEqual(config, want) {
+				t.Errorf("Load = %+v, want %+v", config, want)
+			}
+		})
+	}
+}
+
+func TestBindFlags(t *testing.T) {
+	flags := flag.NewFlagSet("test", flag.ContinueOnError)
+	BindFlags(flags)
+
+	for name, want := range map[string]string{
+		ConfigFlag:                   "",
+		"server.addr":                ":8080",
+		"server.shutdown-timeout":    "30s",
+		"database.max-open-conns":    "0",
+		"log.slow-query-threshold":   "200ms",
+		"cors.allowed-origins":       "",
+		"metrics.enabled":            "false",
+		"tracing.sample-ratio":       "1",
+		"events.relay-interval":      "1s",
+		"pagination.max-limit":       "1000",
+		"log.success-sampling":       "0",
+		"cors.allow-credentials":     "false",
+		"database.conn-max-lifetime": "0s",
+	} {
+		f := flags.Lookup(name)
+		if f == nil {
+			t.Errorf("-%s is not bound", name)
+			continue
+		}
+		if f.DefValue != want {
+			t.Errorf("-%s defaults to %q, want %q", name, f.DefValue, want)
+		}
+	}
+	// Per-model options are not available as flags.
+	flags.VisitAll(func(f *flag.Flag) {
+		if strings.HasPrefix(f.Name, "models") {
+			t.Errorf("-%s is bound", f.Name)
+		}
+	})
+}
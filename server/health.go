@@ -0,0 +1,208 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/MuhmdHsn313/origin/logging"
+	"github.com/MuhmdHsn313/origin/migrate"
+	"github.com/kataras/iris/v12"
+	"gorm.io/gorm"
+)
+
+const (
+	// HealthPath serves the liveness of the process, it only fails when a liveness check does.
+	HealthPath = "/healthz"
+	// ReadyPath serves the readiness of the application to receive traffic.
+	ReadyPath = "/readyz"
+	// DefaultCheckTimeout bounds the duration of each check.
+	DefaultCheckTimeout = 5 * time.Second
+)
+
+// Checker is a health check of an application dependency, such as its database.
+type Checker interface {
+	// Name identifies the check in the responses, such as "database".
+	Name() string
+	// Check returns an error when the dependency is unhealthy. It must return once ctx is done.
+	Check(ctx context.Context) error
+}
+
+// checkFunc is a Checker calling a function.
+type checkFunc struct {
+	name  string
+	check func(ctx context.Context) error
+}
+
+func (c checkFunc) Name() string                    { return c.name }
+func (c checkFunc) Check(ctx context.Context) error { return c.check(ctx) }
+
+// CheckFunc returns a Checker named name calling check.
+func CheckFunc(name string, check func(ctx context.Context) error) Checker {
+	return checkFunc{name: name, check: check}
+}
+
+// DatabaseChecker returns a Checker pinging db, named "database".
+func DatabaseChecker(db *gorm.DB) Checker {
+	return CheckFunc("database", func(ctx context.Context) error {
+		sqlDB, err := db.DB()
+		if err != nil {
+			return err
+		}
+		return sqlDB.PingContext(ctx)
+	})
+}
+
+// MigrationsChecker returns a Checker named "migrations", failing while some of migrations are not applied to db.
+func MigrationsChecker(db *gorm.DB, logger logging.Logger, migrations []migrate.Migration, opts ...migrate.Option) Checker {
+	return CheckFunc("migrations", func(ctx context.Context) error {
+		migrator, err := migrate.New(db, logger, migrations, opts...)
+		if err != nil {
+			return err
+		}
+		statuses, err := migrator.Status(ctx)
+		if err != nil {
+			return err
+		}
+
+		pending := 0
+		for _, status := range statuses {
+			if !status.Applied {
+				pending++
+			}
+		}
+		if pending > 0 {
+			return fmt.Errorf("%d pending migrations", pending)
+		}
+		return nil
+	})
+}
+
+// errShuttingDown fails the readiness of an App that is draining its requests.
+var errShuttingDown = errors.New("server is shutting down")
+
+// CheckResult is the outcome of a check, as served by the health endpoints.
+//
+// Fields:
+//   - Name: The name of the check.
+//   - Status: "ok" or "error".
+//   - DurationMs: How long the check took, in milliseconds.
+//   - Error: Why the check failed, empty when it passed.
+type CheckResult struct {
+	Name       string  `json:"name"`
+	Status     string  `json:"status"`
+	DurationMs float64 `json:"duration_ms"`
+	Error      string  `json:"error,omitempty"`
+}
+
+// HealthReport is the response of the health endpoints.
+//
+// Fields:
+//   - Status: "ok" when every check passed, "error" otherwise.
+//   - DurationMs: How long the checks took, in milliseconds. Checks run concurrently.
+//   - Checks: The result of each check, in the order the checks were added.
+type HealthReport struct {
+	Status     string        `json:"status"`
+	DurationMs float64       `json:"duration_ms"`
+	Checks     []CheckResult `json:"checks"`
+}
+
+// runChecks runs the checks concurrently, each bounded by timeout, and reports their results.
+func runChecks(ctx context.Context, checks []Checker, timeout time.Duration) HealthReport {
+	start := time.Now()
+	report := HealthReport{Status: "ok", Checks: make([]CheckResult, len(checks))}
+
+	var wg sync.WaitGroup
+	for i, checker := range checks {
+		wg.Add(1)
+		go func(i int, checker Checker) {
+			defer wg.Done()
+
+			checkCtx, cancel := context.WithTimeout(ctx, timeout)
+			defer cancel()
+
+			checkStart := time.Now()
+			err := checker.Check(checkCtx)
+			result := CheckResult{Name: checker.Name(), Status: "ok", DurationMs: milliseconds(time.Since(checkStart))}
+			if err != nil {
+				result.Status = "error"
+				result.Error = err.Error()
+			}
+			report.Checks[i] = result
+		}(i, checker)
+	}
+	wg.Wait()
+
+	for _, result := range report.Checks {
+		if result.Status != "ok" {
+			report.Status = "error"
+		}
+	}
+	report.DurationMs = milliseconds(time.Since(start))
+	return report
+}
+
+// milliseconds returns a duration in milliseconds, with a microsecond precision.
+func milliseconds(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
+
+// AddLivenessCheck adds checks to HealthPath. Liveness checks should only fail when the process
+// must be restarted, such as a deadlocked worker, never because of a dependency.
+func (app *App) AddLivenessCheck(checks ...Checker) {
+	app.healthMu.Lock()
+	defer app.healthMu.Unlock()
+	app.livenessChecks = append(app.livenessChecks, checks...)
+}
+
+// AddReadinessCheck adds checks to ReadyPath, such as the availability of a cache or of another service.
+// The database and the migrations given by WithMigrations are always checked.
+func (app *App) AddReadinessCheck(checks ...Checker) {
+	app.healthMu.Lock()
+	defer app.healthMu.Unlock()
+	app.readinessChecks = append(app.readinessChecks, checks...)
+}
+
+// registerHealth serves HealthPath and ReadyPath.
+func (app *App) registerHealth() {
+	app.AddReadinessCheck(DatabaseChecker(app.DB))
+	if len(app.options.migrations) > 0 {
+		app.AddReadinessCheck(MigrationsChecker(app.DB, app.Logger, app.options.migrations, app.options.migrateOptions...))
+	}
+	app.AddReadinessCheck(CheckFunc("shutdown", func(ctx context.Context) error {
+		if app.shuttingDown.Load() {
+			return errShuttingDown
+		}
+		return nil
+	}))
+
+	app.Iris.Get(HealthPath, app.healthHandler("Liveness", func() []Checker { return app.livenessChecks }))
+	app.Iris.Get(ReadyPath, app.healthHandler("Readiness", func() []Checker { return app.readinessChecks }))
+}
+
+// healthHandler serves the report of the checks returned by checks, with a 503 status when one fails.
+func (app *App) healthHandler(operation string, checks func() []Checker) iris.Handler {
+	return func(ctx iris.Context) {
+		app.healthMu.RLock()
+		checkers := append([]Checker(nil), checks()...)
+		app.healthMu.RUnlock()
+
+		report := runChecks(ctx.Request().Context(), checkers, DefaultCheckTimeout)
+		if report.Status != "ok" {
+			for _, result := range report.Checks {
+				if result.Status != "ok" {
+					logging.RequestLogger(ctx, app.Logger).WithFields(logging.Fields{
+						"operation": operation,
+						"check":     result.Name,
+						"error":     result.Error,
+					}).Warn("Health check failed")
+				}
+			}
+			_ = ctx.StopWithJSON(iris.StatusServiceUnavailable, report)
+			return
+		}
+		_ = ctx.JSON(report)
+	}
+}
@@ -0,0 +1,210 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/MuhmdHsn313/origin/migrate"
+	"gorm.io/gorm"
+)
+
+// getHealth builds app and serves a GET request of path, returning its status code and report.
+func getHealth(t *testing.T, app *App, path string) (int, HealthReport) {
+	t.Helper()
+	if err := app.Iris.Build(); err != nil {
+		t.Fatal(err)
+	}
+	rec := httptest.NewRecorder()
+	app.Iris.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+
+	var report HealthReport
+	if err := json.Unmarshal(rec.Body.Bytes(), &report); err != nil {
+		t.Fatalf("%s returned %q: %v", path, rec.Body, err)
+	}
+	return rec.Code, report
+}
+
+// checkStatuses returns the status of each check of report, by name.
+func checkStatuses(report HealthReport) map[string]string {
+	statuses := make(map[string]string, len(report.Checks))
+	for _, check := range report.Checks {
+		statuses[check.Name] = check.Status
+	}
+	return statuses
+}
+
+func TestHealth(t *testing.T) {
+	tests := []struct {
+		name  string
+		setup func(t *testing.T, app *App)
+		// path is requested once setup ran, and answers code with the statuses of its checks.
+		path     string
+		code     int
+		statuses map[string]string
+	}{
+		{
+			name:     "live",
+			path:     HealthPath,
+			code:     http.StatusOK,
+			statuses: map[string]string{},
+		},
+		{
+			name:     "ready",
+			path:     ReadyPath,
+			code:     http.StatusOK,
+			statuses: map[string]string{"database": "ok", "shutdown": "ok"},
+		},
+		{
+			name: "not ready during shutdown",
+			setup: func(t *testing.T, app *App) {
+				app.shuttingDown.Store(true)
+			},
+			path:     ReadyPath,
+			code:     http.StatusServiceUnavailable,
+			statuses: map[string]string{"database": "ok", "shutdown": "error"},
+		},
+		{
+			// A process draining its requests is still alive.
+			name: "live during shutdown",
+			setup: func(t *testing.T, app *App) {
+				app.shuttingDown.Store(true)
+			},
+			path:     HealthPath,
+			code:     http.StatusOK,
+			statuses: map[string]string{},
+		},
+		{
+			name:     "database down",
+			setup:    closeDatabase,
+			path:     ReadyPath,
+			code:     http.StatusServiceUnavailable,
+			statuses: map[string]string{"database": "error", "shutdown": "ok"},
+		},
+		{
+			// The liveness does not depend on the database, restarting the process would not bring it back.
+			name:     "live with the database down",
+			setup:    closeDatabase,
+			path:     HealthPath,
+			code:     http.StatusOK,
+			statuses: map[string]string{},
+		},
+		{
+			name: "failing liveness check",
+			setup: func(t *testing.T, app *App) {
+				app.AddLivenessCheck(CheckFunc("worker", func(ctx context.Context) error { return errors.New("deadlocked") }))
+			},
+			path:     HealthPath,
+			code:     http.StatusServiceUnavailable,
+			statuses: map[string]string{"worker": "error"},
+		},
+		{
+			name: "additional readiness check",
+			setup: func(t *testing.T, app *App) {
+				app.AddReadinessCheck(CheckFunc("cache", func(ctx context.Context) error { return nil }))
+			},
+			path:     ReadyPath,
+			code:     http.StatusOK,
+			statuses: map[string]string{"database": "ok", "shutdown": "ok", "cache": "ok"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			app := newTestApp(t, testConfig(t))
+			if test.setup != nil {
+				test.setup(t, app)
+			}
+
+			code, report := getHealth(t, app, test.path)
+			if code != test.code {
+				t.Errorf("%s returned %d, want %d", test.path, code, test.code)
+			}
+			wantStatus := "ok"
+			if test.code != http.StatusOK {
+				wantStatus = "error"
+			}
+			if report.Status != wantStatus {
+				t.Errorf("%s reported %q, want %q", test.path, report.Status, wantStatus)
+			}
+			if statuses := checkStatuses(report); !equalStatuses(statuses, test.statuses) {
+				t.Errorf("%s reported the checks %v, want %v", test.path, statuses, test.statuses)
+			}
+			for _, check := range report.Checks {
+				if (check.Status == "error") != (check.Error != "") {
+					t.Errorf("check %s has status %q and error %q", check.Name, check.Status, check.Error)
+				}
+			}
+		})
+	}
+}
+
+// closeDatabase closes the database of app, as when the database server goes away.
+func closeDatabase(t *testing.T, app *App) {
+	sqlDB, err := app.DB.DB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := sqlDB.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func equalStatuses(got, want map[string]string) bool {
+	if len(got) != len(want) {
+		return false
+	}
+	for name, status := range want {
+		if got[name] != status {
+			return false
+		}
+	}
+	return true
+}
+
+func TestReadinessWaitsForTheMigrations(t *testing.T) {
+	app := newTestApp(t, testConfig(t), WithMigrations([]migrate.Migration{{
+		Version: 1,
+		Name:    "create_blogs",
+		Up:      func(tx *gorm.DB) error { return tx.Exec("CREATE TABLE blogs (id integer primary key)").Error },
+	}}))
+
+	code, report := getHealth(t, app, ReadyPath)
+	if code != http.StatusServiceUnavailable || checkStatuses(report)["migrations"] != "error" {
+		t.Errorf("%s returned %d %+v with a pending migration, want %d", ReadyPath, code, report, http.StatusServiceUnavailable)
+	}
+
+	if err := app.Migrate(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if code, report := getHealth(t, app, ReadyPath); code != http.StatusOK || checkStatuses(report)["migrations"] != "ok" {
+		t.Errorf("%s returned %d %+v once migrated, want %d", ReadyPath, code, report, http.StatusOK)
+	}
+}
+
+func TestRunChecksBoundsEachCheck(t *testing.T) {
+	checks := []Checker{
+		CheckFunc("slow", func(ctx context.Context) error {
+			<-ctx.Done()
+			return ctx.Err()
+		}),
+		CheckFunc("fast", func(ctx context.Context) error { return nil }),
+	}
+
+	start := time.Now()
+	report := runChecks(context.Background(), checks, 20*time.Millisecond)
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("the checks took %s, want them bounded by the timeout", elapsed)
+	}
+	if report.Status != "error" {
+		t.Errorf("the report has status %q, want error", report.Status)
+	}
+	// The results keep the order of the checks.
+	if report.Checks[0].Name != "slow" || report.Checks[0].Error != context.DeadlineExceeded.Error() ||
+		report.Checks[1].Name != "fast" || report.Checks[1].Status != "ok" {
+		t.Errorf("got the results %+v", report.Checks)
+	}
+}
@@ -0,0 +1,84 @@
+package server
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/MuhmdHsn313/origin/logging"
+	"github.com/sirupsen/logrus"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+// NewLogger creates the logrus logger of an App, writing to stderr.
+//
+// Parameters:
+//   - level: The minimum level of the logs, as parsed by logrus.ParseLevel ("info" when empty).
+//   - format: "text" (the default) or "json".
+//
+// Returns:
+//   - The logger, or an error for an unknown level or format.
+func NewLogger(level, format string) (*logrus.Logger, error) {
+	logger := logrus.New()
+	logger.SetOutput(os.Stderr)
+
+	if level != "" {
+		parsed, err := logrus.ParseLevel(level)
+		if err != nil {
+			return nil, err
+		}
+		logger.SetLevel(parsed)
+	}
+
+	switch format {
+	case "", "text":
+		logger.SetFormatter(&logrus.TextFormatter{FullTimestamp: true})
+	case "json":
+		logger.SetFormatter(&logrus.JSONFormatter{})
+	default:
+		return nil, fmt.Errorf("unknown log format %q, expected text or json", format)
+	}
+	return logger, nil
+}
+
+// gormLogger returns a GORM logger writing through logger: errors are logged, and every query when the level
+// of the configuration is debug. Slow queries are logged by the repositories, with the fields of their request.
+func gormLogger(logger logging.Logger, level string) gormlogger.Interface {
+	logLevel := gormlogger.Warn
+	if parsed, err := logrus.ParseLevel(level); err == nil && parsed >= logrus.DebugLevel {
+		logLevel = gormlogger.Info
+	}
+	return gormlogger.New(gormWriter{logger: logger}, gormlogger.Config{
+		LogLevel:                  logLevel,
+		IgnoreRecordNotFoundError: true,
+	})
+}
+
+// gormWriter writes the lines of the GORM logger through a Logger, at the info level.
+type gormWriter struct {
+	logger logging.Logger
+}
+
+func (writer gormWriter) Printf(format string, args ...interface{}) {
+	writer.logger.Info(fmt.Sprintf(format, args...))
+}
+
+// irisLogLevel maps a level of the configuration to the level of the iris logger.
+func irisLogLevel(level string) string {
+	parsed, err := logrus.ParseLevel(level)
+	if err != nil {
+		return "info"
+	}
+
+	switch parsed {
+	case logrus.PanicLevel, logrus.FatalLevel:
+		return "fatal"
+	case logrus.ErrorLevel:
+		return "error"
+	case logrus.WarnLevel:
+		return "warn"
+	case logrus.InfoLevel:
+		return "info"
+	default:
+		return "debug"
+	}
+}
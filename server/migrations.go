@@ -0,0 +1,58 @@
+package server
+
+import (
+	"github.com/MuhmdHsn313/origin/audit"
+	"github.com/MuhmdHsn313/origin/events"
+	"github.com/MuhmdHsn313/origin/history"
+	"github.com/MuhmdHsn313/origin/migrate"
+	"gorm.io/gorm"
+)
+
+// MigrationsTable records the migrations of the tables of Origin, such as audit_entries, apart from
+// the migrations of the application recorded in migrate.DefaultTable, so that their versions never collide.
+const MigrationsTable = "origin_migrations"
+
+// Versions of the migrations of the tables of Origin. They are never changed once released,
+// a change of these tables is a migration of its own.
+const (
+	auditEntriesVersion   = 1
+	recordVersionsVersion = 2
+	outboxMessagesVersion = 3
+)
+
+// Migrations returns the migrations creating the tables of the features enabled on app: audit_entries
+// for App.Audit, record_versions for App.History and outbox_messages for App.Outbox. Migrate applies them,
+// recorded in MigrationsTable, before the migrations of WithMigrations. Applications migrating their
+// database by other means apply them with migrate.New and migrate.WithTable(MigrationsTable).
+func (app *App) Migrations() []migrate.Migration {
+	var migrations []migrate.Migration
+	if app.Audit != nil {
+		migrations = append(migrations, createTable(auditEntriesVersion, "create_audit_entries", &audit.Entry{}))
+	}
+	if app.History != nil {
+		migrations = append(migrations, createTable(recordVersionsVersion, "create_record_versions", &history.Version{}))
+	}
+	if app.Outbox != nil {
+		migrations = append(migrations, createTable(outboxMessagesVersion, "create_outbox_messages", &events.Message{}))
+	}
+	return migrations
+}
+
+// createTable returns a migration creating the table of model with its indexes, and dropping it on Down.
+// A table that already exists, such as one created by gorm.DB.AutoMigrate before the migration was released,
+// is kept as is.
+func createTable(version int64, name string, model interface{}) migrate.Migration {
+	return migrate.Migration{
+		Version: version,
+		Name:    name,
+		Up: func(tx *gorm.DB) error {
+			if tx.Migrator().HasTable(model) {
+				return nil
+			}
+			return tx.Migrator().CreateTable(model)
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable(model)
+		},
+	}
+}
@@ -0,0 +1,115 @@
+package server
+
+import (
+	"github.com/MuhmdHsn313/origin/history"
+	"github.com/MuhmdHsn313/origin/logging"
+	"github.com/MuhmdHsn313/origin/repository"
+	"github.com/MuhmdHsn313/origin/service"
+	"github.com/kataras/iris/v12/core/router"
+)
+
+// ModelOptions configures the registration of a model by Register.
+//
+// Fields:
+//   - Engine: The engine of the model, service.CreateEngine by default. Set it to an engine generated by origin-gen.
+//   - Party: The party the routes are registered under, App.API by default.
+//   - Route: The options of the routes, see service.RegisterOptions.
+//   - Service: The options of the service, such as service.WithRoleResolver.
+//   - Versioned: Keeps the versions of the records in App.History, as does the versioned setting of the model.
+type ModelOptions[T any] struct {
+	Engine    service.Engine[T]
+	Party     router.Party
+	Route     service.RegisterOptions
+	Service   []service.ServiceOption
+	Versioned bool
+}
+
+// Register serves a model: it creates its engine and repository, a service on top of them,
+// and registers the routes of the service with service.RegisterHandler.
+//
+//	server.Register(app, server.ModelOptions[Blog]{})
+//	server.Register(app, server.ModelOptions[Blog]{Engine: NewBlogEngine(), Route: service.RegisterOptions{Path: "posts"}})
+//
+// The configuration of the model, under its snake_case name in Config.Models (e.g. "blog_post"),
+// overrides the path, pluralization and operations of opts.Route. The service paginates list requests
+// with Config.Pagination, overridden by the limits of the model and then by the options of opts.Service.
+// The repository logs its operations as configured by Config.Log, they are observed by App.Metrics
+// when the metrics are enabled, and the service and repository trace their operations with
+// App.TracerProvider when tracing is. When the audit log is enabled, the repository records the mutations
+// in App.Audit and the service serves them at GET /{model}/{id}/_audit. When the model is versioned,
+// the repository records its versions in App.History, created on the first versioned model, and the
+// service serves them at GET /{model}/{id}/_versions, GET /{model}/{id}?as_of= and POST /{model}/{id}/_revert/{version}.
+// When Config.Events is enabled, the service emits the events of its mutations to App.Events, and when
+// its outbox is, the repository writes them to App.Outbox.
+//
+// Parameters:
+//   - app: The application serving the model.
+//   - opts: The options of the model, the zero value registering it under App.API with the defaults.
+//
+// Returns:
+//   - The party of the routes of the model, for registering child models.
+func Register[T any](app *App, opts ModelOptions[T]) router.Party {
+	eng := opts.Engine
+	if eng == nil {
+		eng = service.CreateEngine[T]()
+	}
+	party := opts.Party
+	if party == nil {
+		party = app.API
+	}
+
+	modelConfig := app.Config.Models[repository.ModelName[T]()]
+	route := opts.Route
+	if modelConfig.Path != "" {
+		route.Path = modelConfig.Path
+	}
+	if modelConfig.Pluralize {
+		route.Pluralize = true
+	}
+	if len(modelConfig.Operations) > 0 {
+		route.Operations = make([]service.Operation, 0, len(modelConfig.Operations))
+		for _, operation := range modelConfig.Operations {
+			route.Operations = append(route.Operations, service.Operation(operation))
+		}
+	}
+
+	pagination := modelConfig.Pagination(app.Config.Pagination)
+	serviceOptions := []service.ServiceOption{
+		service.WithPagination(pagination.DefaultLimit, pagination.MaxLimit),
+	}
+
+	// An unknown success level, rejected by Config.Validate, falls back to the info level.
+	successLevel, _ := logging.ParseLevel(app.Config.Log.SuccessLevel)
+	repositoryOptions := []repository.RepositoryOption{
+		repository.WithSuccessLogLevel(successLevel),
+		repository.WithSuccessSampling(app.Config.Log.SuccessSampling),
+		repository.WithSlowQueryThreshold(app.Config.Log.SlowQueryThreshold),
+	}
+	if app.Metrics != nil {
+		repositoryOptions = append(repositoryOptions, repository.WithObserver(app.Metrics))
+	}
+	if app.TracerProvider != nil {
+		serviceOptions = append(serviceOptions, service.WithTracerProvider(app.TracerProvider))
+		repositoryOptions = append(repositoryOptions, repository.WithTracerProvider(app.TracerProvider))
+	}
+	if app.Audit != nil {
+		serviceOptions = append(serviceOptions, service.WithAuditLog(app.Audit))
+		repositoryOptions = append(repositoryOptions, repository.WithAuditor(app.Audit))
+	}
+	if opts.Versioned || modelConfig.Versioned {
+		if app.History == nil {
+			app.History = history.New(app.DB)
+		}
+		serviceOptions = append(serviceOptions, service.WithHistory(app.History))
+		repositoryOptions = append(repositoryOptions, repository.WithAuditor(app.History))
+	}
+	if app.Events != nil {
+		serviceOptions = append(serviceOptions, service.WithEvents(app.Events))
+	}
+	if app.Outbox != nil {
+		repositoryOptions = append(repositoryOptions, repository.WithAuditor(app.Outbox))
+	}
+	serviceOptions = append(serviceOptions, opts.Service...)
+	repo := repository.NewGenericRepository[T](app.DB, app.Logger, repositoryOptions...)
+	return service.RegisterHandler[T](party, service.NewModelService[T](eng, repo, serviceOptions...), route)
+}
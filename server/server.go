@@ -1 +1,456 @@
+// Package server bootstraps Origin applications. An App holds the configuration, the logger,
+// the database and the iris application of a service, models are registered on it with Register,
+// and Run serves them until the process is asked to stop:
+//
+//	cfg, err := config.Load(config.WithFile("app.toml"))
+//	app, err := server.New(cfg, server.WithMigrations(migrations))
+//	server.Register(app, server.ModelOptions[Blog]{})
+//	err = app.Run(context.Background())
 package server
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"sync/atomic"
+	"syscall"
+
+	"github.com/MuhmdHsn313/origin/audit"
+	"github.com/MuhmdHsn313/origin/events"
+	"github.com/MuhmdHsn313/origin/history"
+	"github.com/MuhmdHsn313/origin/logging"
+	"github.com/MuhmdHsn313/origin/metrics"
+	"github.com/MuhmdHsn313/origin/migrate"
+	"github.com/MuhmdHsn313/origin/server/config"
+	"github.com/MuhmdHsn313/origin/tracing"
+	"github.com/kataras/iris/v12"
+	"github.com/kataras/iris/v12/core/router"
+	"github.com/kataras/iris/v12/middleware/cors"
+	"github.com/kataras/iris/v12/middleware/recover"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+	"gorm.io/gorm"
+)
+
+// App is the container of an Origin application.
+//
+// Fields:
+//   - Config: The configuration the App was created with.
+//   - Logger: The logger shared by the repositories, the migrations and the server.
+//   - DB: The database of the application.
+//   - Iris: The iris application serving the routes.
+//   - API: The party the models are registered under, at Config.Server.APIPrefix.
+//   - Metrics: The metrics of the application, nil unless enabled by Config.Metrics or WithMetrics.
+//   - TracerProvider: The provider of the spans of the application, nil unless enabled by Config.Tracing or WithTraceExporter.
+//   - Audit: The audit log of the models registered by Register, nil unless enabled by Config.Audit.
+//   - History: The versions of the models registered by Register as versioned, nil unless a model is.
+//   - Events: The bus emitting the events of the models registered by Register, nil unless enabled by Config.Events.
+//   - Outbox: The outbox of the events of the models registered by Register, nil unless enabled by Config.Events.Outbox.
+//   - Relay: The relay publishing the outbox to the sinks of WithEventSinks, started by Run, nil without outbox or sinks.
+type App struct {
+	Config         config.Config
+	Logger         logging.Logger
+	DB             *gorm.DB
+	Iris           *iris.Application
+	API            router.Party
+	Metrics        *metrics.Metrics
+	TracerProvider trace.TracerProvider
+	Audit          *audit.Log
+	History        *history.Store
+	Events         *events.Bus
+	Outbox         *events.Outbox
+	Relay          *events.Relay
+
+	options appOptions
+	// sdkTracerProvider is the provider created for the exporter of WithTraceExporter, shut down by Close.
+	sdkTracerProvider *sdktrace.TracerProvider
+
+	// healthMu guards the checks of the health endpoints.
+	healthMu        sync.RWMutex
+	livenessChecks  []Checker
+	readinessChecks []Checker
+	// shuttingDown fails the readiness once Run starts draining the requests.
+	shuttingDown atomic.Bool
+}
+
+// Option configures an App created by New.
+type Option func(options *appOptions)
+
+type appOptions struct {
+	// logger replaces the logger created from the configuration.
+	logger logging.Logger
+	// db replaces the database opened from the configuration.
+	db *gorm.DB
+	// gormConfig configures the database opened from the configuration.
+	gormConfig *gorm.Config
+	// migrations are applied by Run before serving.
+	migrations []migrate.Migration
+	// migrateOptions configure the migrator of the migrations.
+	migrateOptions []migrate.Option
+	// metrics replaces the metrics created when Config.Metrics is enabled.
+	metrics *metrics.Metrics
+	// traceExporter exports the spans of the application.
+	traceExporter sdktrace.SpanExporter
+	// eventSinks receive the messages of the outbox, published by the relay.
+	eventSinks []events.Sink
+}
+
+// WithLogger uses logger, such as logging.Slog(slog.Default()), instead of the logrus logger created
+// from the log level and format of the configuration. The level still sets the verbosity of iris and GORM.
+func WithLogger(logger logging.Logger) Option {
+	return func(options *appOptions) {
+		options.logger = logger
+	}
+}
+
+// WithDB uses an opened database instead of opening the DSN of the configuration.
+// Close does not close a database given this way.
+func WithDB(db *gorm.DB) Option {
+	return func(options *appOptions) {
+		options.db = db
+	}
+}
+
+// WithGormConfig sets the GORM configuration of the database opened from the configuration.
+// Its logger defaults to one writing through the logger of the App.
+func WithGormConfig(config *gorm.Config) Option {
+	return func(options *appOptions) {
+		options.gormConfig = config
+	}
+}
+
+// WithMigrations sets the migrations applied by Run before the server starts, see migrate.New.
+func WithMigrations(migrations []migrate.Migration, opts ...migrate.Option) Option {
+	return func(options *appOptions) {
+		options.migrations = migrations
+		options.migrateOptions = opts
+	}
+}
+
+// WithMetrics collects the metrics of the App into m, such as one created on a local registry,
+// and serves them at Config.Metrics.Path whether or not Config.Metrics is enabled.
+func WithMetrics(m *metrics.Metrics) Option {
+	return func(options *appOptions) {
+		options.metrics = m
+	}
+}
+
+// WithTraceExporter traces the App whether or not Config.Tracing is enabled, exporting its spans with exporter,
+// such as an OTLP exporter or the in-memory exporter of go.opentelemetry.io/otel/sdk/trace/tracetest.
+// The service name and sample ratio of the spans are those of Config.Tracing.
+func WithTraceExporter(exporter sdktrace.SpanExporter) Option {
+	return func(options *appOptions) {
+		options.traceExporter = exporter
+	}
+}
+
+// WithEventSinks publishes the messages of the outbox to sinks, such as a message broker, when Config.Events.Outbox
+// is enabled: Run then starts App.Relay. Without sinks, the messages are kept in the outbox for another process to relay.
+func WithEventSinks(sinks ...events.Sink) Option {
+	return func(options *appOptions) {
+		options.eventSinks = append(options.eventSinks, sinks...)
+	}
+}
+
+// New creates an App: it sets up the logger, opens the database, configures its connection pool
+// and creates the iris application, accepting cross-origin requests when configured.
+// The application serves its liveness at HealthPath and its readiness at ReadyPath, the latter
+// checking the database and the migrations given by WithMigrations (see AddReadinessCheck).
+// Each request is identified by an ID (see logging.Middleware) logged along with the lines of the
+// repositories serving it. When Config.Metrics is enabled, it serves the metrics of the requests, of the repositories created
+// by Register and of the connection pool at Config.Metrics.Path. When Config.Tracing is enabled, it traces
+// the requests, the operations of the models registered by Register and their SQL statements with
+// the global tracer provider (see otel.SetTracerProvider), or with the exporter of WithTraceExporter.
+// When Config.Audit is enabled, the mutations of the models registered by Register are recorded in App.Audit.
+// When a model of Config.Models is versioned, the versions of its records are kept in App.History.
+// When Config.Events is enabled, the mutations are emitted to the handlers subscribed to App.Events once committed,
+// and when its outbox is, they are written to App.Outbox, published to the sinks of WithEventSinks by App.Relay.
+//
+// Parameters:
+//   - cfg: The configuration, such as the one returned by config.Load.
+//   - opts: Options such as WithMigrations and WithDB.
+//
+// Returns:
+//   - The App, or an error if the logger cannot be configured, the database cannot be opened
+//     or the metrics or the tracing cannot be registered. The database opened by New is closed on error.
+func New(cfg config.Config, opts ...Option) (_ *App, err error) {
+	app := &App{Config: cfg}
+	for _, opt := range opts {
+		opt(&app.options)
+	}
+	defer func() {
+		if err != nil {
+			_ = app.Close()
+		}
+	}()
+
+	app.Logger = app.options.logger
+	if app.Logger == nil {
+		logger, err := NewLogger(cfg.Log.Level, cfg.Log.Format)
+		if err != nil {
+			return nil, err
+		}
+		app.Logger = logging.Logrus(logger)
+	}
+
+	app.DB = app.options.db
+	if app.DB == nil {
+		gormConfig := app.options.gormConfig
+		if gormConfig == nil {
+			gormConfig = &gorm.Config{}
+		}
+		if gormConfig.Logger == nil {
+			gormConfig.Logger = gormLogger(app.Logger, cfg.Log.Level)
+		}
+
+		db, err := OpenDatabase(cfg.Database.DSN, gormConfig)
+		if err != nil {
+			return nil, err
+		}
+		app.DB = db
+		if err := configurePool(db, cfg.Database); err != nil {
+			return nil, err
+		}
+	}
+
+	app.Metrics = app.options.metrics
+	if app.Metrics == nil && cfg.Metrics.Enabled {
+		m, err := metrics.New()
+		if err != nil {
+			return nil, err
+		}
+		app.Metrics = m
+	}
+	if app.Metrics != nil {
+		if err := app.Metrics.RegisterDB(app.DB, "default"); err != nil {
+			return nil, err
+		}
+	}
+
+	if app.options.traceExporter != nil {
+		app.sdkTracerProvider = tracing.NewTracerProvider(app.options.traceExporter,
+			tracing.WithServiceName(cfg.Tracing.ServiceName),
+			tracing.WithSampleRatio(cfg.Tracing.SampleRatio),
+		)
+		app.TracerProvider = app.sdkTracerProvider
+	} else if cfg.Tracing.Enabled {
+		app.TracerProvider = otel.GetTracerProvider()
+	}
+	if app.TracerProvider != nil {
+		if err := app.DB.Use(tracing.NewGormPlugin(tracing.WithTracerProvider(app.TracerProvider))); err != nil {
+			return nil, err
+		}
+	}
+
+	if cfg.Audit.Enabled {
+		app.Audit = audit.New(app.DB)
+	}
+	for _, model := range cfg.Models {
+		if model.Versioned {
+			app.History = history.New(app.DB)
+			break
+		}
+	}
+	if cfg.Events.Enabled {
+		app.Events = events.NewBus(app.Logger)
+	}
+	if cfg.Events.Outbox {
+		app.Outbox = events.NewOutbox()
+		if len(app.options.eventSinks) > 0 {
+			app.Relay = events.NewRelay(app.DB, app.Logger, app.options.eventSinks,
+				events.WithRelayInterval(cfg.Events.RelayInterval),
+				events.WithBatchSize(cfg.Events.BatchSize),
+			)
+		}
+	}
+
+	app.Iris = iris.New()
+	app.Iris.Logger().SetLevel(irisLogLevel(cfg.Log.Level))
+	app.Iris.UseRouter(logging.Middleware())
+	if app.Metrics != nil {
+		// Registered before the recovery so that the requests which panicked are counted with their 500 status.
+		app.Iris.UseRouter(app.Metrics.Middleware())
+	}
+	if app.TracerProvider != nil {
+		app.Iris.UseRouter(tracing.Middleware(tracing.WithTracerProvider(app.TracerProvider)))
+	}
+	app.Iris.UseRouter(recover.New())
+	if cfg.CORS.Enabled() {
+		app.Iris.UseRouter(corsHandler(cfg.CORS))
+	}
+	app.registerHealth()
+	if app.Metrics != nil {
+		app.Iris.Get(metricsPath(cfg.Metrics), app.Metrics.Handler())
+	}
+	app.API = app.Iris.Party(cfg.Server.APIPrefix)
+	return app, nil
+}
+
+// metricsPath returns the path of the metrics endpoint, the default one when the configuration has none.
+func metricsPath(cfg config.Metrics) string {
+	if cfg.Path == "" {
+		return config.Default().Metrics.Path
+	}
+	return cfg.Path
+}
+
+// configurePool applies the connection pool settings of the configuration to db.
+func configurePool(db *gorm.DB, cfg config.Database) error {
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+	sqlDB.SetMaxOpenConns(cfg.MaxOpenConns)
+	sqlDB.SetMaxIdleConns(cfg.MaxIdleConns)
+	sqlDB.SetConnMaxLifetime(cfg.ConnMaxLifetime)
+	sqlDB.SetConnMaxIdleTime(cfg.ConnMaxIdleTime)
+	return nil
+}
+
+// corsHandler returns the middleware answering preflight requests and setting the CORS headers of the allowed origins.
+// Requests without an Origin header, such as those of other services, are not cross-origin and always pass.
+func corsHandler(cfg config.CORS) iris.Handler {
+	allowed := make(map[string]bool, len(cfg.AllowedOrigins))
+	for _, origin := range cfg.AllowedOrigins {
+		allowed[origin] = true
+	}
+
+	handler := cors.New().AllowOriginFunc(func(ctx iris.Context, origin string) bool {
+		return origin == "" || allowed["*"] || allowed[origin]
+	})
+	if len(cfg.AllowedHeaders) > 0 {
+		handler.AllowHeaders(cfg.AllowedHeaders...)
+	}
+	if len(cfg.ExposedHeaders) > 0 {
+		handler.ExposeHeaders(cfg.ExposedHeaders...)
+	}
+	if !cfg.AllowCredentials {
+		handler.DisallowCredentials()
+	}
+	if cfg.MaxAge > 0 {
+		handler.MaxAge(cfg.MaxAge)
+	}
+	return handler.Handler()
+}
+
+// Migrate applies the pending migrations of the tables of Origin (see App.Migrations), then those given by
+// WithMigrations. Run calls it before serving.
+func (app *App) Migrate(ctx context.Context) error {
+	if migrations := app.Migrations(); len(migrations) > 0 {
+		opts := append(append([]migrate.Option(nil), app.options.migrateOptions...), migrate.WithTable(MigrationsTable))
+		migrator, err := migrate.New(app.DB, app.Logger, migrations, opts...)
+		if err != nil {
+			return err
+		}
+		if _, err := migrator.Up(ctx); err != nil {
+			return err
+		}
+	}
+	if len(app.options.migrations) == 0 {
+		return nil
+	}
+
+	migrator, err := migrate.New(app.DB, app.Logger, app.options.migrations, app.options.migrateOptions...)
+	if err != nil {
+		return err
+	}
+	_, err = migrator.Up(ctx)
+	return err
+}
+
+// Run applies the pending migrations, then serves the application on Config.Server.Addr until ctx is done
+// or the process receives SIGINT or SIGTERM, relaying the outbox meanwhile when App.Relay is set.
+// It then stops accepting connections, waits up to Config.Server.ShutdownTimeout for the in-flight
+// requests to complete, stops the relay and closes the database.
+//
+// Returns:
+//   - nil once stopped, or the error that prevented the server from starting or stopping gracefully.
+func (app *App) Run(ctx context.Context) error {
+	defer app.Close()
+
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if err := app.Migrate(ctx); err != nil {
+		return err
+	}
+	if err := app.Iris.Build(); err != nil {
+		return err
+	}
+
+	// The relay stops after the server, the messages it did not publish by then are kept in the outbox.
+	if app.Relay != nil {
+		relayCtx, stopRelay := context.WithCancel(context.Background())
+		relayDone := make(chan struct{})
+		go func() {
+			defer close(relayDone)
+			app.Relay.Run(relayCtx)
+		}()
+		defer func() {
+			stopRelay()
+			<-relayDone
+		}()
+	}
+
+	listener, err := net.Listen("tcp", app.Config.Server.Addr)
+	if err != nil {
+		return err
+	}
+
+	srv := &http.Server{Handler: app.Iris}
+	served := make(chan error, 1)
+	go func() {
+		served <- srv.Serve(listener)
+	}()
+
+	fields := logging.Fields{
+		"operation": "Run",
+		"addr":      listener.Addr().String(),
+	}
+	app.Logger.WithFields(fields).Info("Server started")
+
+	select {
+	case err := <-served:
+		return err
+	case <-ctx.Done():
+	}
+
+	app.Logger.WithFields(fields).Info("Shutting down, draining in-flight requests")
+	app.shuttingDown.Store(true)
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), app.Config.Server.ShutdownTimeout)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		app.Logger.WithFields(fields).WithField("error", err.Error()).Error("Failed to drain in-flight requests")
+		return err
+	}
+	if err := <-served; err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+
+	app.Logger.WithFields(fields).Info("Server stopped")
+	return nil
+}
+
+// Close flushes the spans of the exporter given with WithTraceExporter and closes the database,
+// unless it was given with WithDB. Run closes it when it returns.
+func (app *App) Close() error {
+	var errs []error
+	if app.sdkTracerProvider != nil {
+		errs = append(errs, app.sdkTracerProvider.Shutdown(context.Background()))
+	}
+
+	if app.options.db == nil && app.DB != nil {
+		sqlDB, err := app.DB.DB()
+		if err != nil {
+			errs = append(errs, err)
+		} else {
+			errs = append(errs, sqlDB.Close())
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,332 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/MuhmdHsn313/origin/logging"
+	"github.com/MuhmdHsn313/origin/metrics"
+	"github.com/MuhmdHsn313/origin/migrate"
+	"github.com/MuhmdHsn313/origin/server/config"
+	"github.com/kataras/iris/v12"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"gorm.io/gorm"
+)
+
+// testConfig returns the default configuration with a SQLite database in a temporary directory of t
+// and a free local address.
+func testConfig(t *testing.T) config.Config {
+	t.Helper()
+	cfg := config.Default()
+	cfg.Database.DSN = "sqlite:" + filepath.Join(t.TempDir(), "app.db")
+	cfg.Server.Addr = freeAddr(t)
+	return cfg
+}
+
+// freeAddr returns a local address that was free when it was returned.
+func freeAddr(t *testing.T) string {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	return listener.Addr().String()
+}
+
+// newTestApp creates an App of cfg logging nowhere, closed once the test ends.
+func newTestApp(t *testing.T, cfg config.Config, opts ...Option) *App {
+	t.Helper()
+	app, err := New(cfg, append([]Option{WithLogger(logging.Nop())}, opts...)...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = app.Close() })
+	return app
+}
+
+// capturePlugin keeps the database it is registered on, under the name of the plugin it stands for.
+type capturePlugin struct {
+	name string
+	db   **gorm.DB
+}
+
+func (plugin capturePlugin) Name() string { return plugin.name }
+
+func (plugin capturePlugin) Initialize(db *gorm.DB) error {
+	*plugin.db = db
+	return nil
+}
+
+func TestNewClosesTheDatabaseOnError(t *testing.T) {
+	tests := []struct {
+		name string
+		// plugin is the name of the plugin capturing the database, that of the tracing plugin making it fail.
+		plugin string
+		opts   func(t *testing.T) []Option
+	}{
+		{
+			// The database statistics of "default" are already registered on the metrics.
+			name:   "metrics",
+			plugin: "capture",
+			opts: func(t *testing.T) []Option {
+				m, err := metrics.New(metrics.WithRegistry(prometheus.NewRegistry()))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if err := m.RegisterDB(newTestApp(t, testConfig(t)).DB, "default"); err != nil {
+					t.Fatal(err)
+				}
+				return []Option{WithMetrics(m)}
+			},
+		},
+		{
+			name:   "tracing",
+			plugin: "origin:tracing",
+			opts: func(t *testing.T) []Option {
+				return []Option{WithTraceExporter(tracetest.NewInMemoryExporter())}
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var db *gorm.DB
+			opts := append(test.opts(t), WithLogger(logging.Nop()), WithGormConfig(&gorm.Config{
+				Plugins: map[string]gorm.Plugin{test.plugin: capturePlugin{name: test.plugin, db: &db}},
+			}))
+			if app, err := New(testConfig(t), opts...); err == nil {
+				app.Close()
+				t.Fatal("New succeeded")
+			}
+
+			if db == nil {
+				t.Fatal("the database was not opened")
+			}
+			sqlDB, err := db.DB()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := sqlDB.Ping(); err == nil {
+				t.Error("the database opened by New is still open")
+			}
+		})
+	}
+}
+
+func TestNewKeepsTheGivenDatabaseOpenOnError(t *testing.T) {
+	db := newTestApp(t, testConfig(t)).DB
+	newTestApp(t, testConfig(t), WithDB(db), WithTraceExporter(tracetest.NewInMemoryExporter()))
+
+	// The tracing plugin is already registered on db, the database given with WithDB is left open.
+	if _, err := New(testConfig(t), WithLogger(logging.Nop()), WithDB(db), WithTraceExporter(tracetest.NewInMemoryExporter())); err == nil {
+		t.Fatal("New registered the tracing plugin twice")
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := sqlDB.Ping(); err != nil {
+		t.Errorf("the database given with WithDB was closed: %v", err)
+	}
+}
+
+func TestNewRejectsUnsupportedDatabases(t *testing.T) {
+	cfg := testConfig(t)
+	cfg.Database.DSN = "redis://localhost:6379"
+	if _, err := New(cfg, WithLogger(logging.Nop())); err == nil {
+		t.Error("New opened a redis DSN")
+	}
+}
+
+func TestNewEnablesTheConfiguredFeatures(t *testing.T) {
+	cfg := testConfig(t)
+	cfg.Audit.Enabled = true
+	cfg.Events.Enabled = true
+	cfg.Events.Outbox = true
+	cfg.Models = map[string]config.Model{"blog": {Versioned: true}}
+	app := newTestApp(t, cfg)
+
+	if app.Audit == nil || app.History == nil || app.Events == nil || app.Outbox == nil {
+		t.Fatalf("audit %v, history %v, events %v and outbox %v, want them all enabled", app.Audit, app.History, app.Events, app.Outbox)
+	}
+	// The outbox is kept for another process to relay without sinks.
+	if app.Relay != nil {
+		t.Error("the relay is started without sinks")
+	}
+
+	plain := newTestApp(t, testConfig(t))
+	if plain.Audit != nil || plain.History != nil || plain.Events != nil || plain.Outbox != nil || plain.Metrics != nil || plain.TracerProvider != nil {
+		t.Error("the default configuration enables optional features")
+	}
+}
+
+func TestMigrateCreatesTheTablesOfTheEnabledFeatures(t *testing.T) {
+	cfg := testConfig(t)
+	cfg.Audit.Enabled = true
+	cfg.Events.Outbox = true
+	created := false
+	app := newTestApp(t, cfg, WithMigrations([]migrate.Migration{{
+		Version: 1,
+		Name:    "create_blogs",
+		Up: func(tx *gorm.DB) error {
+			created = true
+			return nil
+		},
+	}}))
+
+	// A table created before its migration was released is kept.
+	if err := app.DB.Exec("CREATE TABLE outbox_messages (id integer primary key)").Error; err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		if err := app.Migrate(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if !created {
+		t.Error("the migration of the application was not applied")
+	}
+	migrator := app.DB.Migrator()
+	if !migrator.HasTable("audit_entries") || migrator.HasTable("record_versions") {
+		t.Error("got the tables of the disabled features, or not those of the enabled ones")
+	}
+
+	var versions []int64
+	if err := app.DB.Table(MigrationsTable).Order("version").Pluck("version", &versions).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(versions) != 2 || versions[0] != auditEntriesVersion || versions[1] != outboxMessagesVersion {
+		t.Errorf("%s holds the versions %v, want those of the audit entries and the outbox", MigrationsTable, versions)
+	}
+	// The versions of the application are recorded apart, they may collide with those of Origin.
+	var count int64
+	if err := app.DB.Table(migrate.DefaultTable).Count(&count).Error; err != nil || count != 1 {
+		t.Errorf("%s holds %d migrations, %v, want the migration of the application", migrate.DefaultTable, count, err)
+	}
+}
+
+// waitReady waits for the server of app to answer on its address.
+func waitReady(t *testing.T, app *App) {
+	t.Helper()
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		resp, err := http.Get("http://" + app.Config.Server.Addr + HealthPath)
+		if err == nil {
+			resp.Body.Close()
+			return
+		}
+	}
+	t.Fatal("the server did not start")
+}
+
+func TestRunDrainsInFlightRequests(t *testing.T) {
+	cfg := testConfig(t)
+	app, err := New(cfg, WithLogger(logging.Nop()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	started, release := make(chan struct{}), make(chan struct{})
+	app.Iris.Get("/slow", func(ctx iris.Context) {
+		close(started)
+		<-release
+		ctx.WriteString("done")
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ran := make(chan error, 1)
+	go func() { ran <- app.Run(ctx) }()
+	waitReady(t, app)
+
+	type response struct {
+		body string
+		err  error
+	}
+	responses := make(chan response, 1)
+	go func() {
+		resp, err := http.Get("http://" + cfg.Server.Addr + "/slow")
+		if err != nil {
+			responses <- response{err: err}
+			return
+		}
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		responses <- response{body: string(body), err: err}
+	}()
+	<-started
+	cancel()
+
+	// The App stops being ready while it drains the request.
+	for deadline := time.Now().Add(5 * time.Second); !app.shuttingDown.Load(); time.Sleep(10 * time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatal("the App is not shutting down")
+		}
+	}
+	select {
+	case err := <-ran:
+		t.Fatalf("Run returned %v before the in-flight request completed", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(release)
+	if resp := <-responses; resp.err != nil || resp.body != "done" {
+		t.Errorf("the in-flight request got %q, %v, want it completed", resp.body, resp.err)
+	}
+	if err := <-ran; err != nil {
+		t.Errorf("Run = %v, want nil", err)
+	}
+
+	// Run closes the database it opened.
+	sqlDB, err := app.DB.DB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := sqlDB.Ping(); err == nil {
+		t.Error("the database is still open after Run")
+	}
+}
+
+func TestRunFailsWhenTheRequestsOutlastTheShutdownTimeout(t *testing.T) {
+	cfg := testConfig(t)
+	cfg.Server.ShutdownTimeout = 50 * time.Millisecond
+	app, err := New(cfg, WithLogger(logging.Nop()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	started, release := make(chan struct{}), make(chan struct{})
+	defer close(release)
+	app.Iris.Get("/slow", func(ctx iris.Context) {
+		close(started)
+		<-release
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ran := make(chan error, 1)
+	go func() { ran <- app.Run(ctx) }()
+	waitReady(t, app)
+
+	go func() {
+		if resp, err := http.Get("http://" + cfg.Server.Addr + "/slow"); err == nil {
+			resp.Body.Close()
+		}
+	}()
+	<-started
+	cancel()
+
+	select {
+	case err := <-ran:
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("Run = %v, want the shutdown timeout exceeded", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run did not return after the shutdown timeout")
+	}
+}
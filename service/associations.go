@@ -0,0 +1,332 @@
+package service
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/MuhmdHsn313/origin/orm"
+)
+
+// associationKind tells how a model field relates to another model.
+type associationKind int
+
+const (
+	// noAssociation is a plain field, copied as is.
+	noAssociation associationKind = iota
+	// singleAssociation is a belongs-to or has-one field, such as `Author User`.
+	singleAssociation
+	// manyAssociation is a has-many or many-to-many field, such as `Tags []Tag`.
+	manyAssociation
+)
+
+var contentModelInterface = reflect.TypeOf((*orm.IContentModel)(nil)).Elem()
+
+// associationOf classifies a model field and returns the type of the associated model.
+// A field is an association when it holds (a slice of) structs with an ID primary key that
+// are not content models, content collections keep their own language-based handling.
+func associationOf(field reflect.StructField) (associationKind, reflect.Type) {
+	if orm.IsContentField(field) {
+		return noAssociation, nil
+	}
+
+	kind := singleAssociation
+	fieldType := field.Type
+	if fieldType.Kind() == reflect.Slice {
+		kind = manyAssociation
+		fieldType = fieldType.Elem()
+	}
+
+	associated := derefType(fieldType)
+	if associated.Kind() != reflect.Struct || associated == timeType || isContentType(associated) {
+		return noAssociation, nil
+	}
+	if _, ok := associated.FieldByName("ID"); !ok {
+		return noAssociation, nil
+	}
+	return kind, associated
+}
+
+// isContentType reports whether values of t, or pointers to them, implement orm.IContentModel.
+func isContentType(t reflect.Type) bool {
+	return t.Implements(contentModelInterface) || reflect.PointerTo(t).Implements(contentModelInterface)
+}
+
+// associationIDTag is the tag of the "id" of association parameters, its description documents in the
+// schemas that records are referenced by objects: a bare ID, such as "author": 1, fails to parse.
+const associationIDTag = `json:"id" description:"ID of an existing record to reference, as in {\"id\": 1}; bare IDs are not accepted. Left out, the other fields create a new record."`
+
+// buildAssociationParameters generates the struct accepted for one associated model: an optional "id"
+// referencing an existing record, and the writable fields of the model to create a new one.
+// Nested associations and foreign keys of the associated model are left out.
+func buildAssociationParameters(associated reflect.Type) reflect.Type {
+	idField, _ := associated.FieldByName("ID")
+	fields := []reflect.StructField{{
+		Name: "ID",
+		Type: reflect.PointerTo(idField.Type),
+		Tag:  associationIDTag,
+	}}
+
+	for i := 0; i < associated.NumField(); i++ {
+		field := associated.Field(i)
+		if field.Anonymous || !field.IsExported() || field.Name == "ID" || strings.HasSuffix(field.Name, "ID") {
+			continue
+		}
+		if !orm.ParseFieldOptions(field).IsWritable() {
+			continue
+		}
+		if kind, _ := associationOf(field); kind != noAssociation {
+			continue
+		}
+		if field.Type.Kind() == reflect.Slice && derefType(field.Type.Elem()).Kind() == reflect.Struct {
+			continue
+		}
+
+		// Validation rules are not carried over, a reference by ID is valid without the other fields.
+		jsonTag, ok := field.Tag.Lookup("json")
+		if !ok {
+			jsonTag = field.Name
+		}
+		fields = append(fields, reflect.StructField{
+			Name: field.Name,
+			Type: field.Type,
+			Tag:  reflect.StructTag(fmt.Sprintf(`json:"%s"`, jsonTag)),
+		})
+	}
+
+	return reflect.StructOf(fields)
+}
+
+// buildAssociationUpdate generates the struct accepted when updating a has-many or many-to-many
+// association: the items replacing the whole collection, or the items appended to and removed from it.
+func buildAssociationUpdate(itemType reflect.Type) reflect.Type {
+	return reflect.StructOf([]reflect.StructField{
+		{Name: "Replace", Type: reflect.PointerTo(reflect.SliceOf(itemType)), Tag: `json:"replace"`},
+		{Name: "Append", Type: reflect.SliceOf(itemType), Tag: `json:"append"`},
+		{Name: "Remove", Type: reflect.SliceOf(itemType), Tag: `json:"remove"`},
+	})
+}
+
+// associationField returns the parameters field of an association. Create parameters take the items
+// (or the single item) directly, update parameters take a replace/append/remove struct for collections.
+func associationField(field reflect.StructField, kind associationKind, associated reflect.Type, update bool) reflect.StructField {
+	itemType := buildAssociationParameters(associated)
+
+	jsonTag, ok := field.Tag.Lookup("json")
+	if !ok {
+		jsonTag = field.Name
+	}
+	tag := fmt.Sprintf(`json:"%s"`, jsonTag)
+	if validationTag, ok := field.Tag.Lookup("validate"); ok {
+		tag = fmt.Sprintf(`json:"%s" validate:"%s"`, jsonTag, validationTag)
+	}
+
+	paramType := reflect.PointerTo(itemType)
+	if kind == manyAssociation {
+		paramType = reflect.SliceOf(itemType)
+		if update {
+			paramType = reflect.PointerTo(buildAssociationUpdate(itemType))
+		}
+	}
+
+	return reflect.StructField{
+		Name: field.Name,
+		Type: paramType,
+		Tag:  reflect.StructTag(tag),
+	}
+}
+
+// associationItem converts association parameters into a value of the model field's element type
+// (a struct or a pointer to one). The "id" of the parameters, when given, becomes the primary key.
+func associationItem(elemType reflect.Type, params reflect.Value) (reflect.Value, error) {
+	for params.Kind() == reflect.Ptr {
+		params = params.Elem()
+	}
+
+	item := reflect.New(derefType(elemType))
+	paramsType := params.Type()
+	for i := 0; i < paramsType.NumField(); i++ {
+		paramValue := params.Field(i)
+		if paramValue.Kind() == reflect.Ptr && paramValue.IsNil() {
+			continue
+		}
+
+		field := item.Elem().FieldByName(paramsType.Field(i).Name)
+		if !field.IsValid() || !field.CanSet() {
+			continue
+		}
+		if err := copyField(field, paramValue); err != nil {
+			return reflect.Value{}, fmt.Errorf("field %s: %w", paramsType.Field(i).Name, err)
+		}
+	}
+
+	if elemType.Kind() == reflect.Ptr {
+		return item, nil
+	}
+	return item.Elem(), nil
+}
+
+// fillAssociation sets an association of a new model from its create parameters.
+func fillAssociation(modelField, paramValue reflect.Value, kind associationKind) error {
+	if kind == singleAssociation {
+		if paramValue.IsNil() {
+			return nil
+		}
+		item, err := associationItem(modelField.Type(), paramValue)
+		if err != nil {
+			return err
+		}
+		modelField.Set(item)
+		return nil
+	}
+
+	items := reflect.MakeSlice(modelField.Type(), 0, paramValue.Len())
+	for i := 0; i < paramValue.Len(); i++ {
+		item, err := associationItem(modelField.Type().Elem(), paramValue.Index(i))
+		if err != nil {
+			return fmt.Errorf("item %d: %w", i, err)
+		}
+		items = reflect.Append(items, item)
+	}
+	modelField.Set(items)
+	return nil
+}
+
+// updateAssociation applies the replace/append/remove parameters of a collection association to the
+// loaded model field. Appended items that are already associated are ignored, and removed items are
+// matched by their "id". The repository then synchronises the association with the resulting slice.
+func updateAssociation(modelField, update reflect.Value) error {
+	elemType := modelField.Type().Elem()
+	convert := func(params reflect.Value) ([]reflect.Value, error) {
+		items := make([]reflect.Value, 0, params.Len())
+		for i := 0; i < params.Len(); i++ {
+			item, err := associationItem(elemType, params.Index(i))
+			if err != nil {
+				return nil, fmt.Errorf("item %d: %w", i, err)
+			}
+			items = append(items, item)
+		}
+		return items, nil
+	}
+
+	current := make([]reflect.Value, 0, modelField.Len())
+	for i := 0; i < modelField.Len(); i++ {
+		current = append(current, modelField.Index(i))
+	}
+
+	if replace := update.FieldByName("Replace"); !replace.IsNil() {
+		items, err := convert(replace.Elem())
+		if err != nil {
+			return fmt.Errorf("replace: %w", err)
+		}
+		current = items
+	}
+
+	appended, err := convert(update.FieldByName("Append"))
+	if err != nil {
+		return fmt.Errorf("append: %w", err)
+	}
+	for _, item := range appended {
+		if key, ok := associationKey(item); ok && containsAssociation(current, key) {
+			continue
+		}
+		current = append(current, item)
+	}
+
+	removed := map[interface{}]bool{}
+	remove := update.FieldByName("Remove")
+	for i := 0; i < remove.Len(); i++ {
+		key, ok := associationKey(remove.Index(i))
+		if !ok {
+			return fmt.Errorf("remove: item %d has no id", i)
+		}
+		removed[key] = true
+	}
+
+	result := reflect.MakeSlice(modelField.Type(), 0, len(current))
+	for _, item := range current {
+		if key, ok := associationKey(item); ok && removed[key] {
+			continue
+		}
+		result = reflect.Append(result, item)
+	}
+	modelField.Set(result)
+	return nil
+}
+
+// associationKey returns the primary key of an associated model or association parameters,
+// and false when it is not set (a record that does not exist yet).
+func associationKey(v reflect.Value) (interface{}, bool) {
+	for v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return nil, false
+		}
+		v = v.Elem()
+	}
+
+	id := v.FieldByName("ID")
+	for id.IsValid() && id.Kind() == reflect.Ptr {
+		if id.IsNil() {
+			return nil, false
+		}
+		id = id.Elem()
+	}
+	if !id.IsValid() || id.IsZero() {
+		return nil, false
+	}
+	return id.Interface(), true
+}
+
+func containsAssociation(items []reflect.Value, key interface{}) bool {
+	for _, item := range items {
+		if itemKey, ok := associationKey(item); ok && itemKey == key {
+			return true
+		}
+	}
+	return false
+}
+
+// MergeAssociation applies the replace/append/remove semantics of collection association updates
+// to the loaded items of a model. It is used by generated engines, key returns the primary key of
+// an item and its zero value marks records that do not exist yet.
+func MergeAssociation[E any, K comparable](current []E, replace *[]E, appended, removed []E, key func(E) K) ([]E, error) {
+	var zero K
+	if replace != nil {
+		current = *replace
+	}
+
+	result := make([]E, 0, len(current)+len(appended))
+	result = append(result, current...)
+	for _, item := range appended {
+		if itemKey := key(item); itemKey != zero && containsKey(result, itemKey, key) {
+			continue
+		}
+		result = append(result, item)
+	}
+
+	removedKeys := make(map[K]bool, len(removed))
+	for i, item := range removed {
+		itemKey := key(item)
+		if itemKey == zero {
+			return nil, fmt.Errorf("remove: item %d has no id", i)
+		}
+		removedKeys[itemKey] = true
+	}
+
+	kept := result[:0]
+	for _, item := range result {
+		if itemKey := key(item); itemKey == zero || !removedKeys[itemKey] {
+			kept = append(kept, item)
+		}
+	}
+	return kept, nil
+}
+
+func containsKey[E any, K comparable](items []E, itemKey K, key func(E) K) bool {
+	for _, item := range items {
+		if key(item) == itemKey {
+			return true
+		}
+	}
+	return false
+}
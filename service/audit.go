@@ -0,0 +1,79 @@
+package service
+
+import (
+	"context"
+
+	"github.com/MuhmdHsn313/origin/audit"
+	"github.com/kataras/iris/v12"
+)
+
+// AuditLog returns the entries recorded for the mutations of a record, such as an audit.Log.
+type AuditLog interface {
+	// Entries returns the entries of the record recordID of model, the most recent first,
+	// skipping offset entries and returning at most limit of them (every entry when 0).
+	Entries(ctx context.Context, model, recordID string, limit, offset int) ([]audit.Entry, error)
+}
+
+// WithAuditLog serves the entries of each record from log at GET /{model}/{id}/_audit (see OperationAudit),
+// paginated like the list requests. The route is only registered for the services given an audit log.
+// The mutations are recorded by the repository, see repository.WithAuditor.
+func WithAuditLog(log AuditLog) ServiceOption {
+	return func(options *serviceOptions) {
+		options.auditLog = log
+	}
+}
+
+func (service modelService[T]) Audit(ctx iris.Context) {
+	span := service.startSpan(ctx, "Audit")
+	defer endSpan(ctx, span)
+
+	if service.options.auditLog == nil {
+		_ = ctx.StopWithJSON(
+			iris.StatusBadRequest,
+			iris.Map{
+				"error":      "the audit log is not enabled",
+				"error_code": ErrorCodeFetchAudit,
+			},
+		)
+		return
+	}
+
+	id, err := service.parseID(ctx)
+	if err != nil {
+		_ = ctx.StopWithJSON(
+			iris.StatusBadRequest,
+			iris.Map{
+				"error":      err.Error(),
+				"error_code": ErrorCodeCantReadID,
+			},
+		)
+		return
+	}
+
+	recordID, err := service.recordID(ctx, id)
+	if err != nil {
+		_ = ctx.StopWithJSON(
+			iris.StatusBadRequest,
+			iris.Map{
+				"error":      err.Error(),
+				"error_code": ErrorCodeCantReadID,
+			},
+		)
+		return
+	}
+
+	entries, err := service.options.auditLog.Entries(ctx.Request().Context(), service.modelName(), recordID,
+		service.limit(ctx), ctx.URLParamIntDefault("offset", 0))
+	if err != nil {
+		_ = ctx.StopWithJSON(
+			iris.StatusBadRequest,
+			iris.Map{
+				"error":      err.Error(),
+				"error_code": ErrorCodeFetchAudit,
+			},
+		)
+		return
+	}
+
+	_ = ctx.StopWithJSON(iris.StatusOK, entries)
+}
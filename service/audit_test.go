@@ -0,0 +1,108 @@
+package service
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/MuhmdHsn313/origin/audit"
+	"github.com/MuhmdHsn313/origin/internal/testdb"
+	"github.com/MuhmdHsn313/origin/logging"
+	"github.com/MuhmdHsn313/origin/orm"
+	"github.com/MuhmdHsn313/origin/repository"
+	"github.com/kataras/iris/v12"
+)
+
+// APIKey is named with an initialism, served at /api/a_p_i_key and recorded as "api_key" by its repository.
+type APIKey struct {
+	orm.Model
+
+	Contents []APIKeyContent `json:"contents" gorm:"foreignKey:APIKeyID"`
+	Name     string          `json:"name"`
+	Secret   string          `json:"secret" origin:"writeonly"`
+}
+
+type APIKeyContent struct {
+	orm.ContentModel
+
+	Description string `json:"description"`
+	APIKeyID    uint   `json:"api_key_id" gorm:"primaryKey"`
+}
+
+// newAuditedApp serves APIKey at /api/a_p_i_key, its mutations recorded in an audit log.
+func newAuditedApp(t *testing.T) *iris.Application {
+	t.Helper()
+	useRegistry(t)
+	db := testdb.Open(t, &audit.Entry{}, &APIKey{}, &APIKeyContent{})
+	log := audit.New(db)
+
+	app := iris.New()
+	app.UseRouter(logging.Middleware())
+	repo := repository.NewGenericRepository[APIKey](db, logging.Nop(), repository.WithAuditor(log))
+	RegisterHandler[APIKey](app.Party("/api"), NewModelService[APIKey](CreateEngine[APIKey](), repo, WithAuditLog(log)))
+	if err := app.Build(); err != nil {
+		t.Fatal(err)
+	}
+	return app
+}
+
+func TestAuditServesTheEntriesOfARecord(t *testing.T) {
+	app := newAuditedApp(t)
+	mustServe(t, app, http.MethodPost, "/api/a_p_i_key", `{"name":"deploy","secret":"s3cr3t"}`, http.StatusCreated)
+	mustServe(t, app, http.MethodPost, "/api/a_p_i_key", `{"name":"other"}`, http.StatusCreated)
+	mustServe(t, app, http.MethodPatch, "/api/a_p_i_key/1", `{"name":"release","secret":"rotated"}`, http.StatusOK)
+
+	tests := []struct {
+		path string
+		// want are the operations of the entries, the most recent first.
+		want []string
+	}{
+		{"/api/a_p_i_key/1/_audit", []string{audit.OperationUpdate, audit.OperationCreate}},
+		{"/api/a_p_i_key/1/_audit?limit=1", []string{audit.OperationUpdate}},
+		{"/api/a_p_i_key/1/_audit?offset=1", []string{audit.OperationCreate}},
+		{"/api/a_p_i_key/2/_audit", []string{audit.OperationCreate}},
+		{"/api/a_p_i_key/3/_audit", []string{}},
+	}
+	for _, test := range tests {
+		entries := mustServe(t, app, http.MethodGet, test.path, "", http.StatusOK).([]interface{})
+		if len(entries) != len(test.want) {
+			t.Errorf("%s returned %v, want the entries %q", test.path, entries, test.want)
+			continue
+		}
+		for i, entry := range entries {
+			entry := entry.(map[string]interface{})
+			if entry["operation"] != test.want[i] || entry["model"] != "api_key" || entry["request_id"] == "" {
+				t.Errorf("%s returned the entry %v, want the %s of api_key", test.path, entry, test.want[i])
+			}
+		}
+	}
+
+	// The values of the writeonly fields are not served.
+	entries := mustServe(t, app, http.MethodGet, "/api/a_p_i_key/1/_audit?limit=1", "", http.StatusOK).([]interface{})
+	if len(entries) != 1 {
+		t.Fatalf("the record has the entries %v, want its update", entries)
+	}
+	for _, change := range entries[0].(map[string]interface{})["changes"].([]interface{}) {
+		change := change.(map[string]interface{})
+		switch change["field"] {
+		case "name":
+			if change["before"] != "deploy" || change["after"] != "release" {
+				t.Errorf("the name changed %v, want from deploy to release", change)
+			}
+		case "secret":
+			if change["redacted"] != true || change["before"] != nil || change["after"] != nil {
+				t.Errorf("the secret changed %v, want it redacted", change)
+			}
+		default:
+			t.Errorf("the update changed %v", change)
+		}
+	}
+}
+
+func TestAuditRejectsInvalidIDs(t *testing.T) {
+	app := newAuditedApp(t)
+
+	code, body := serve(t, app, http.MethodGet, "/api/a_p_i_key/first/_audit", "")
+	if code != http.StatusBadRequest || body.(map[string]interface{})["error_code"] != ErrorCodeCantReadID {
+		t.Errorf("an invalid ID returned %d %v, want %d %s", code, body, http.StatusBadRequest, ErrorCodeCantReadID)
+	}
+}
@@ -5,25 +5,147 @@ import (
 	"github.com/MuhmdHsn313/origin/orm"
 	"reflect"
 	"strings"
+	"sync"
 )
 
 type Engine[T any] interface {
 	GenerateCreateParameters() (interface{}, error)
 	GenerateUpdateParameters() (interface{}, error)
 	GenerateFilterParameters() (interface{}, error)
+	CreateSchema() (map[string]interface{}, error)
+	UpdateSchema() (map[string]interface{}, error)
 	FillModelFromCreateParameters(createParams interface{}) (*T, error)
 	UpdateModelFromUpdateParameters(model *T, updateParams interface{}) (*T, error)
 }
 
 type engine[T any] struct {
+	once sync.Once
+	plan *enginePlan
+	err  error
+}
+
+// enginePlan holds everything the engine derives from the model by reflection.
+// It is built once per engine and is read-only afterwards, so it is safe for concurrent use.
+type enginePlan struct {
+	createType reflect.Type
+	updateType reflect.Type
+	filterType reflect.Type
+
+	// createFields and updateFields map the fields of the parameter structs to the model fields.
+	createFields []fieldMapping
+	updateFields []fieldMapping
+}
+
+// fieldMapping links a field of a generated parameters struct to the model field with the same name.
+type fieldMapping struct {
+	name       string
+	paramIndex int
+	modelIndex []int
+	// association tells whether the model field is an association with another model.
+	association associationKind
+	// contents tells whether the model field is a content collection, merged by language on update.
+	contents bool
 }
 
 func CreateEngine[M any]() Engine[M] {
-	return &engine[M]{}
+	eng := &engine[M]{}
+	// Build the plan eagerly, errors are reported by the first method that needs it.
+	_, _ = eng.compile()
+	return eng
+}
+
+// compile builds the engine plan on first use and returns the cached plan afterwards.
+func (e *engine[T]) compile() (*enginePlan, error) {
+	e.once.Do(func() {
+		e.plan, e.err = e.buildPlan()
+	})
+	return e.plan, e.err
+}
+
+func (e *engine[T]) buildPlan() (*enginePlan, error) {
+	plan := &enginePlan{}
+	var err error
+
+	if plan.createType, err = e.buildCreateParameters(); err != nil {
+		return nil, err
+	}
+	if plan.updateType, err = e.buildUpdateParameters(); err != nil {
+		return nil, err
+	}
+	if plan.filterType, err = e.buildFilterParameters(); err != nil {
+		return nil, err
+	}
+
+	modelType := reflect.TypeOf((*T)(nil)).Elem()
+	plan.createFields = mapFields(plan.createType, modelType)
+	plan.updateFields = mapFields(plan.updateType, modelType)
+
+	return plan, nil
+}
+
+// mapFields resolves, for each field of paramsType, the index of the model field with the same name.
+// Parameter fields without a counterpart in the model are left out.
+func mapFields(paramsType, modelType reflect.Type) []fieldMapping {
+	var mappings []fieldMapping
+	for i := 0; i < paramsType.NumField(); i++ {
+		paramField := paramsType.Field(i)
+		modelField, ok := modelType.FieldByName(paramField.Name)
+		if !ok {
+			continue
+		}
+		association, _ := associationOf(modelField)
+		mappings = append(mappings, fieldMapping{
+			name:        paramField.Name,
+			paramIndex:  i,
+			modelIndex:  modelField.Index,
+			association: association,
+			contents:    orm.IsContentField(modelField),
+		})
+	}
+	return mappings
+}
+
+// fieldMappings returns the cached mappings when the parameters are of the generated type,
+// and resolves them by name for any other parameters struct.
+func (e *engine[T]) fieldMappings(paramsType, generatedType reflect.Type, cached []fieldMapping) []fieldMapping {
+	if paramsType == generatedType {
+		return cached
+	}
+	return mapFields(paramsType, reflect.TypeOf((*T)(nil)).Elem())
+}
+
+// GenerateCreateParameters returns a new instance of the struct used to create a model,
+// excluding fields from base models. The struct type is built once per engine.
+func (e *engine[T]) GenerateCreateParameters() (interface{}, error) {
+	plan, err := e.compile()
+	if err != nil {
+		return nil, err
+	}
+	return reflect.New(plan.createType).Interface(), nil
+}
+
+// GenerateUpdateParameters returns a new instance of the struct used to update a model,
+// excluding fields from base models. The struct type is built once per engine.
+func (e *engine[T]) GenerateUpdateParameters() (interface{}, error) {
+	plan, err := e.compile()
+	if err != nil {
+		return nil, err
+	}
+	return reflect.New(plan.updateType).Interface(), nil
 }
 
-// GenerateCreateParameters generates a new struct type for creating a model, excluding fields from base models.
-func (e engine[T]) GenerateCreateParameters() (interface{}, error) {
+// GenerateFilterParameters returns a new instance of the struct used to filter a model.
+// The struct type is built once per engine.
+func (e *engine[T]) GenerateFilterParameters() (interface{}, error) {
+	plan, err := e.compile()
+	if err != nil {
+		return nil, err
+	}
+	return reflect.New(plan.filterType).Interface(), nil
+}
+
+// buildCreateParameters generates a new struct type for creating a model, excluding fields from base models.
+func (e *engine[T]) buildCreateParameters() (reflect.Type, error) {
 	var model T
 	// Get the reflection type of the model
 	modelType := reflect.TypeOf(model)
@@ -47,7 +169,7 @@ func (e engine[T]) GenerateCreateParameters() (interface{}, error) {
 			// Check if the field is an embedded struct (like orm.Model or orm.ContentModel)
 			if field.Anonymous && e.isBaseField(field) {
 				// Handle embedded structs (e.g., ContentModel)
-				embeddedFields, err := e.extractBaseEmbeddedFields(field.Type, addedFields)
+				embeddedFields, err := e.extractBaseEmbeddedFields(field.Type, addedFields, false)
 				if err != nil {
 					return nil, err
 				}
@@ -56,12 +178,24 @@ func (e engine[T]) GenerateCreateParameters() (interface{}, error) {
 			continue
 		}
 
+		// Skip fields that clients are not allowed to write (origin:"readonly" or origin:"hidden")
+		if !orm.ParseFieldOptions(field).IsWritable() {
+			continue
+		}
+
+		// Associations with other models (e.g., Author User or Tags []Tag) accept nested objects, existing records
+		// are referenced by objects holding their ID such as {"id": 1}, bare IDs are not accepted
+		if kind, associated := associationOf(field); kind != noAssociation {
+			fields = append(fields, associationField(field, kind, associated, false))
+			continue
+		}
+
 		// For slice fields (e.g., []BlocContent), we need to handle them specifically
 		if field.Type.Kind() == reflect.Slice {
 			// If the slice is of structs, we need to extract the relevant fields from the struct
 			if field.Type.Elem().Kind() == reflect.Struct {
-				// Extract fields from the slice's struct (e.g., BlocContent)
-				innerFields, err := e.generateInnerStruct(field.Type.Elem(), addedFields, true)
+				// Extract fields from the slice's struct (e.g., BlocContent), each collection has its own fields
+				innerFields, err := e.generateInnerStruct(field.Type.Elem(), make(map[string]bool), true)
 				if err != nil {
 					return nil, err
 				}
@@ -112,14 +246,11 @@ func (e engine[T]) GenerateCreateParameters() (interface{}, error) {
 	}
 
 	// Create a new struct type with the extracted fields
-	paramStruct := reflect.StructOf(fields)
-
-	// Return a new instance of the generated struct
-	return reflect.New(paramStruct).Interface(), nil
+	return reflect.StructOf(fields), nil
 }
 
-// GenerateUpdateParameters generates a new struct type for updating a model, excluding fields from base models.
-func (e engine[T]) GenerateUpdateParameters() (interface{}, error) {
+// buildUpdateParameters generates a new struct type for updating a model, excluding fields from base models.
+func (e *engine[T]) buildUpdateParameters() (reflect.Type, error) {
 	var model T
 	// Get the reflection type of the model
 	modelType := reflect.TypeOf(model)
@@ -143,7 +274,7 @@ func (e engine[T]) GenerateUpdateParameters() (interface{}, error) {
 			// Check if the field is an embedded struct (like orm.Model or orm.ContentModel)
 			if field.Anonymous && e.isBaseField(field) {
 				// Handle embedded structs (e.g., ContentModel)
-				embeddedFields, err := e.extractBaseEmbeddedFields(field.Type, addedFields)
+				embeddedFields, err := e.extractBaseEmbeddedFields(field.Type, addedFields, true)
 				if err != nil {
 					return nil, err
 				}
@@ -152,12 +283,24 @@ func (e engine[T]) GenerateUpdateParameters() (interface{}, error) {
 			continue
 		}
 
+		// Skip fields that clients are not allowed to write (origin:"readonly" or origin:"hidden")
+		if !orm.ParseFieldOptions(field).IsWritable() {
+			continue
+		}
+
+		// Associations with other models (e.g., Author User or Tags []Tag) accept nested objects, existing records
+		// are referenced by objects holding their ID such as {"id": 1}, bare IDs are not accepted
+		if kind, associated := associationOf(field); kind != noAssociation {
+			fields = append(fields, associationField(field, kind, associated, true))
+			continue
+		}
+
 		// For slice fields (e.g., []BlocContent), we need to handle them specifically
 		if field.Type.Kind() == reflect.Slice {
 			// If the slice is of structs, we need to extract the relevant fields from the struct
 			if field.Type.Elem().Kind() == reflect.Struct {
-				// Extract fields from the slice's struct (e.g., BlocContent)
-				innerFields, err := e.generateInnerStruct(field.Type.Elem(), addedFields, true)
+				// Extract fields from the slice's struct (e.g., BlocContent), each collection has its own fields
+				innerFields, err := e.generateInnerStruct(field.Type.Elem(), make(map[string]bool), true)
 				if err != nil {
 					return nil, err
 				}
@@ -208,17 +351,14 @@ func (e engine[T]) GenerateUpdateParameters() (interface{}, error) {
 	}
 
 	// Create a new struct type with the extracted fields
-	paramStruct := reflect.StructOf(fields)
-
-	// Return a new instance of the generated struct
-	return reflect.New(paramStruct).Interface(), nil
+	return reflect.StructOf(fields), nil
 }
 
-// GenerateFilterParameters generates a new struct type for filtering a model.
+// buildFilterParameters generates a new struct type for filtering a model.
 // It flattens the main model's fields and, for content model slices, extracts
 // the inner struct fields (e.g. "Content", "LanguageID") as top-level filter parameters.
 // All fields are pointers and use `url:"..."` tags.
-func (e engine[T]) GenerateFilterParameters() (interface{}, error) {
+func (e *engine[T]) buildFilterParameters() (reflect.Type, error) {
 	var model T
 	// Get the reflection type of the model.
 	modelType := reflect.TypeOf(model)
@@ -239,34 +379,50 @@ func (e engine[T]) GenerateFilterParameters() (interface{}, error) {
 			continue
 		}
 
-		// If the field is a slice and its element is a struct, check if it is a content model.
+		// Skip fields that are not readable by every caller, filtering on them would leak their values.
+		if !orm.ParseFieldOptions(field).IsPublic() {
+			continue
+		}
+
+		// Associations are filtered through their foreign key columns (e.g., author_id), not as a whole.
+		if kind, _ := associationOf(field); kind != noAssociation {
+			continue
+		}
+
+		// If the field is a slice and its element is a struct, check if it is a content collection.
 		if field.Type.Kind() == reflect.Slice && field.Type.Elem().Kind() == reflect.Struct {
-			// Check if the slice element implements the IContentModel interface.
-			if orm.IsContentModel(reflect.New(field.Type.Elem()).Elem().Interface()) {
+			// Content collections are detected by element type (IContentModel) or by the origin:"contents" tag.
+			// Fields shared by several collections are added once and match any of them.
+			if orm.IsContentField(field) {
 				innerType := field.Type.Elem()
 				// Iterate over the inner fields and flatten them.
 				for j := 0; j < innerType.NumField(); j++ {
 					innerField := innerType.Field(j)
-					if strings.HasSuffix(innerField.Name, "ID") {
+					if strings.HasSuffix(innerField.Name, "ID") && (innerField.Name != "LanguageID" || innerField.Anonymous) {
 						continue
 					}
 					// For embedded base fields (like orm.ContentModel), extract only LanguageID.
 					if innerField.Anonymous || e.isBaseField(innerField) {
-						if innerField.Name == "LanguageID" && !addedFields[innerField.Name] {
-							tag := fmt.Sprintf(`url:"%s"`, toSnakeCase(innerField.Name))
+						var languageField reflect.StructField
+						ok := false
+						if innerField.Anonymous && derefType(innerField.Type).Kind() == reflect.Struct {
+							languageField, ok = derefType(innerField.Type).FieldByName("LanguageID")
+						}
+						if ok && !addedFields[languageField.Name] {
+							tag := fmt.Sprintf(`url:"%s"`, orm.FilterName(languageField.Name))
 							fields = append(fields, reflect.StructField{
-								Name:      innerField.Name,
-								Type:      reflect.PtrTo(innerField.Type),
+								Name:      languageField.Name,
+								Type:      reflect.PtrTo(languageField.Type),
 								Tag:       reflect.StructTag(tag),
 								Anonymous: false,
 							})
-							addedFields[innerField.Name] = true
+							addedFields[languageField.Name] = true
 						}
 						continue
 					}
-					// For other inner fields, add them if not already added.
-					if !addedFields[innerField.Name] {
-						tag := fmt.Sprintf(`url:"%s"`, toSnakeCase(innerField.Name))
+					// For other inner fields, add them if not already added and readable by everyone.
+					if !addedFields[innerField.Name] && orm.ParseFieldOptions(innerField).IsPublic() {
+						tag := fmt.Sprintf(`url:"%s"`, orm.FilterName(innerField.Name))
 						fields = append(fields, reflect.StructField{
 							Name:      innerField.Name,
 							Type:      reflect.PtrTo(innerField.Type),
@@ -283,7 +439,7 @@ func (e engine[T]) GenerateFilterParameters() (interface{}, error) {
 
 		// For non-slice fields, add them as pointer types with a URL tag.
 		if !addedFields[field.Name] {
-			tag := fmt.Sprintf(`url:"%s"`, toSnakeCase(field.Name))
+			tag := fmt.Sprintf(`url:"%s"`, orm.FilterName(field.Name))
 			fields = append(fields, reflect.StructField{
 				Name:      field.Name,
 				Type:      reflect.PtrTo(field.Type),
@@ -295,13 +451,29 @@ func (e engine[T]) GenerateFilterParameters() (interface{}, error) {
 	}
 
 	// Create a new struct type with the collected fields.
-	paramStruct := reflect.StructOf(fields)
-	// Return a new instance of the generated struct.
-	return reflect.New(paramStruct).Interface(), nil
+	return reflect.StructOf(fields), nil
+}
+
+// CreateSchema returns the JSON Schema (draft 2020-12) of the struct built by GenerateCreateParameters.
+func (e *engine[T]) CreateSchema() (map[string]interface{}, error) {
+	params, err := e.GenerateCreateParameters()
+	if err != nil {
+		return nil, err
+	}
+	return documentSchema(params, structTypeName[T]()+"CreateParameters"), nil
+}
+
+// UpdateSchema returns the JSON Schema (draft 2020-12) of the struct built by GenerateUpdateParameters.
+func (e *engine[T]) UpdateSchema() (map[string]interface{}, error) {
+	params, err := e.GenerateUpdateParameters()
+	if err != nil {
+		return nil, err
+	}
+	return documentSchema(params, structTypeName[T]()+"UpdateParameters"), nil
 }
 
 // Helper function to generate inner structs (like BlocContent)
-func (e engine[T]) generateInnerStruct(innerType reflect.Type, addedFields map[string]bool, includeForeignKeys bool) (reflect.Type, error) {
+func (e *engine[T]) generateInnerStruct(innerType reflect.Type, addedFields map[string]bool, includeForeignKeys bool) (reflect.Type, error) {
 	var innerFields []reflect.StructField
 
 	// Iterate over the fields of the inner struct
@@ -312,7 +484,7 @@ func (e engine[T]) generateInnerStruct(innerType reflect.Type, addedFields map[s
 		if field.Anonymous && e.isBaseField(field) {
 			// Handle embedded structs (e.g., orm.ContentModel)
 			if field.Anonymous && e.isBaseField(field) {
-				embeddedFields, err := e.extractBaseEmbeddedFields(field.Type, addedFields)
+				embeddedFields, err := e.extractBaseEmbeddedFields(field.Type, addedFields, false)
 				if err != nil {
 					return nil, err
 				}
@@ -326,8 +498,13 @@ func (e engine[T]) generateInnerStruct(innerType reflect.Type, addedFields map[s
 			continue
 		}
 
-		// Skip foreign keys if includeForeignKeys is true
-		if includeForeignKeys && strings.HasSuffix(field.Name, "ID") {
+		// Skip fields that clients are not allowed to write
+		if !orm.ParseFieldOptions(field).IsWritable() {
+			continue
+		}
+
+		// Skip foreign keys if includeForeignKeys is true, the language of content declared without orm.ContentModel is kept
+		if includeForeignKeys && strings.HasSuffix(field.Name, "ID") && field.Name != "LanguageID" {
 			continue
 		}
 
@@ -361,7 +538,7 @@ func (e engine[T]) generateInnerStruct(innerType reflect.Type, addedFields map[s
 }
 
 // Check if the field belongs to a base model (like orm.Model or orm.ContentModel)
-func (e engine[T]) isBaseField(field reflect.StructField) bool {
+func (e *engine[T]) isBaseField(field reflect.StructField) bool {
 	// For simplicity, check by field name or type
 	// This can be extended to check by type name or a specific struct tag, etc.
 	baseTypes := []string{"Model", "ContentModel"}
@@ -374,8 +551,9 @@ func (e engine[T]) isBaseField(field reflect.StructField) bool {
 	return false
 }
 
-// Extract fields from embedded structs (like ContentModel) to ensure LanguageID is included
-func (e engine[T]) extractBaseEmbeddedFields(embeddedType reflect.Type, addedFields map[string]bool) ([]reflect.StructField, error) {
+// Extract fields from embedded structs (like ContentModel) to ensure LanguageID is included.
+// Optional fields, as in update parameters, are pointers so that a missing language keeps the current one.
+func (e *engine[T]) extractBaseEmbeddedFields(embeddedType reflect.Type, addedFields map[string]bool, optional bool) ([]reflect.StructField, error) {
 	var fields []reflect.StructField
 
 	// Iterate over the fields of the embedded struct
@@ -397,9 +575,14 @@ func (e engine[T]) extractBaseEmbeddedFields(embeddedType reflect.Type, addedFie
 				tag = fmt.Sprintf(`json:"%s"`, jsonTag)
 			}
 
+			fieldType := field.Type
+			if optional {
+				fieldType = reflect.PointerTo(fieldType)
+			}
+
 			fields = append(fields, reflect.StructField{
 				Name:      field.Name,
-				Type:      field.Type,
+				Type:      fieldType,
 				Tag:       reflect.StructTag(tag),
 				Anonymous: false,
 			})
@@ -412,7 +595,12 @@ func (e engine[T]) extractBaseEmbeddedFields(embeddedType reflect.Type, addedFie
 }
 
 // FillModelFromCreateParameters creates and populates a model instance from create parameters
-func (e engine[T]) FillModelFromCreateParameters(createParams interface{}) (*T, error) {
+func (e *engine[T]) FillModelFromCreateParameters(createParams interface{}) (*T, error) {
+	plan, err := e.compile()
+	if err != nil {
+		return nil, err
+	}
+
 	modelType := reflect.TypeOf((*T)(nil)).Elem()
 	modelVal := reflect.New(modelType) // *T
 	modelElem := modelVal.Elem()       // T
@@ -422,17 +610,20 @@ func (e engine[T]) FillModelFromCreateParameters(createParams interface{}) (*T,
 		cpVal = cpVal.Elem()
 	}
 
-	for i := 0; i < cpVal.NumField(); i++ {
-		cpField := cpVal.Type().Field(i)
-		cpFieldVal := cpVal.Field(i)
+	for _, mapping := range e.fieldMappings(cpVal.Type(), plan.createType, plan.createFields) {
+		cpFieldVal := cpVal.Field(mapping.paramIndex)
 
-		modelField := modelElem.FieldByName(cpField.Name)
-		if !modelField.IsValid() {
-			continue // Skip missing fields
+		modelField := modelElem.FieldByIndex(mapping.modelIndex)
+		if !modelField.CanSet() {
+			return nil, fmt.Errorf("model field %s cannot be set", mapping.name)
 		}
 
-		if !modelField.CanSet() {
-			return nil, fmt.Errorf("model field %s cannot be set", cpField.Name)
+		// Handle associations, given as nested objects or references by ID
+		if mapping.association != noAssociation {
+			if err := fillAssociation(modelField, cpFieldVal, mapping.association); err != nil {
+				return nil, fmt.Errorf("%s: %w", mapping.name, err)
+			}
+			continue
 		}
 
 		// Handle slice fields
@@ -452,16 +643,16 @@ func (e engine[T]) FillModelFromCreateParameters(createParams interface{}) (*T,
 
 				if srcElem.Kind() == reflect.Struct && dstElem.Kind() == reflect.Struct {
 					if err := copyStruct(dstElem, srcElem); err != nil {
-						return nil, fmt.Errorf("%s[%d]: %w", cpField.Name, j, err)
+						return nil, fmt.Errorf("%s[%d]: %w", mapping.name, j, err)
 					}
 				} else if err := copyField(dstElem, srcElem); err != nil {
-					return nil, fmt.Errorf("%s[%d]: %w", cpField.Name, j, err)
+					return nil, fmt.Errorf("%s[%d]: %w", mapping.name, j, err)
 				}
 			}
 			modelField.Set(newSlice)
 		} else {
 			if err := copyField(modelField, cpFieldVal); err != nil {
-				return nil, fmt.Errorf("%s: %w", cpField.Name, err)
+				return nil, fmt.Errorf("%s: %w", mapping.name, err)
 			}
 		}
 	}
@@ -471,7 +662,7 @@ func (e engine[T]) FillModelFromCreateParameters(createParams interface{}) (*T,
 
 //// FillModelFromCreateParameters1 fills the model instance with values from the createParams instance.
 //// model should be a pointer to the target struct, and createParams is a pointer to the create parameters struct.
-//func (e engine[T]) FillModelFromCreateParameters1(createParams interface{}) (*T, error) {
+//func (e *engine[T]) FillModelFromCreateParameters1(createParams interface{}) (*T, error) {
 //	var model *T
 //	// Get reflect.Value of model (dereferenced) and createParams (dereferenced)
 //	modelVal := reflect.ValueOf(model).Elem()
@@ -525,7 +716,12 @@ func (e engine[T]) FillModelFromCreateParameters(createParams interface{}) (*T,
 //}
 
 // UpdateModelFromUpdateParameters updates the model and returns the modified instance
-func (e engine[T]) UpdateModelFromUpdateParameters(model *T, updateParams interface{}) (*T, error) {
+func (e *engine[T]) UpdateModelFromUpdateParameters(model *T, updateParams interface{}) (*T, error) {
+	plan, err := e.compile()
+	if err != nil {
+		return model, err
+	}
+
 	modelVal := reflect.ValueOf(model).Elem()
 	paramsVal := reflect.ValueOf(updateParams)
 
@@ -533,21 +729,30 @@ func (e engine[T]) UpdateModelFromUpdateParameters(model *T, updateParams interf
 		paramsVal = paramsVal.Elem()
 	}
 
-	for i := 0; i < paramsVal.NumField(); i++ {
-		paramField := paramsVal.Type().Field(i)
-		paramValue := paramsVal.Field(i)
+	for _, mapping := range e.fieldMappings(paramsVal.Type(), plan.updateType, plan.updateFields) {
+		paramValue := paramsVal.Field(mapping.paramIndex)
 
 		// Skip nil pointers
 		if paramValue.Kind() == reflect.Ptr && paramValue.IsNil() {
 			continue
 		}
 
-		modelField := modelVal.FieldByName(paramField.Name)
-		if !modelField.IsValid() || !modelField.CanSet() {
-			continue // Skip non-existing fields
+		modelField := modelVal.FieldByIndex(mapping.modelIndex)
+		if !modelField.CanSet() {
+			continue // Skip non-settable fields
 		}
 
 		switch {
+		case mapping.association == manyAssociation:
+			if err := updateAssociation(modelField, paramValue.Elem()); err != nil {
+				return model, fmt.Errorf("field %s: %w", mapping.name, err)
+			}
+
+		case mapping.association == singleAssociation:
+			if err := fillAssociation(modelField, paramValue, singleAssociation); err != nil {
+				return model, fmt.Errorf("field %s: %w", mapping.name, err)
+			}
+
 		case modelField.Kind() == reflect.Slice:
 			var sliceValue reflect.Value
 			if paramValue.Kind() == reflect.Ptr {
@@ -556,22 +761,38 @@ func (e engine[T]) UpdateModelFromUpdateParameters(model *T, updateParams interf
 				sliceValue = paramValue
 			}
 
-			if paramField.Name == "Contents" {
+			if mapping.contents {
 				if err := handleContentUpdate(modelField, sliceValue); err != nil {
-					return model, fmt.Errorf("field %s: %w", paramField.Name, err)
+					return model, fmt.Errorf("field %s: %w", mapping.name, err)
 				}
+				break
 			}
 
+			// Other collections of plain values are replaced as a whole
+			newSlice := reflect.MakeSlice(modelField.Type(), sliceValue.Len(), sliceValue.Len())
+			for j := 0; j < sliceValue.Len(); j++ {
+				srcElem := sliceValue.Index(j)
+				dstElem := newSlice.Index(j)
+				if srcElem.Kind() == reflect.Struct && dstElem.Kind() == reflect.Struct {
+					if err := copyStruct(dstElem, srcElem); err != nil {
+						return model, fmt.Errorf("field %s[%d]: %w", mapping.name, j, err)
+					}
+				} else if err := copyField(dstElem, srcElem); err != nil {
+					return model, fmt.Errorf("field %s[%d]: %w", mapping.name, j, err)
+				}
+			}
+			modelField.Set(newSlice)
+
 		case paramValue.Kind() == reflect.Ptr:
 			// Handle pointer parameters
 			if err := copyField(modelField, paramValue.Elem()); err != nil {
-				return model, fmt.Errorf("field %s: %w", paramField.Name, err)
+				return model, fmt.Errorf("field %s: %w", mapping.name, err)
 			}
 
 		default:
 			// Handle direct value parameters
 			if err := copyField(modelField, paramValue); err != nil {
-				return model, fmt.Errorf("field %s: %w", paramField.Name, err)
+				return model, fmt.Errorf("field %s: %w", mapping.name, err)
 			}
 		}
 	}
@@ -589,7 +810,7 @@ func (e engine[T]) UpdateModelFromUpdateParameters(model *T, updateParams interf
 //	a) Extracts the new contents using ExtractContent,
 //	b) Merges the new values with the existing slice via GetAllContentsWithUpdated,
 //	c) Sets the merged slice on the model.
-func (e engine[T]) UpdateModelFromUpdateParameters1(updateParams interface{}) (*T, error) {
+func (e *engine[T]) UpdateModelFromUpdateParameters1(updateParams interface{}) (*T, error) {
 	var model *T
 	// Obtain the reflect.Value of the model (dereferenced) and the update parameters (also dereferenced)
 	modelVal := reflect.ValueOf(model).Elem()
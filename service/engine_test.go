@@ -0,0 +1,269 @@
+package service
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/MuhmdHsn313/origin/orm"
+)
+
+// benchmarkPost is a model with plain fields and a content collection, like the models of the applications.
+type benchmarkPost struct {
+	orm.Model
+
+	Contents    []benchmarkPostContent `json:"contents" gorm:"foreignKey:PostID"`
+	Slug        string                 `json:"slug"`
+	Views       int                    `json:"views"`
+	IsPublished bool                   `json:"is_published"`
+}
+
+type benchmarkPostContent struct {
+	orm.ContentModel
+
+	Title  string `json:"title"`
+	Body   string `json:"body"`
+	PostID uint   `json:"post_id"`
+}
+
+var (
+	benchmarkCreateBody = []byte(`{"slug":"hello","views":3,"is_published":true,` +
+		`"contents":[{"language_id":"en","title":"Hello","body":"World"},{"language_id":"ar","title":"Marhaba","body":"Alam"}]}`)
+	benchmarkUpdateBody = []byte(`{"views":4,"contents":[{"language_id":"en","title":"Hello again"}]}`)
+)
+
+// createWith does the work of the engine in a Create request: the parameters are generated,
+// decoded from the body and copied to a new model.
+func createWith(b *testing.B, eng Engine[benchmarkPost]) {
+	params, err := eng.GenerateCreateParameters()
+	if err != nil {
+		b.Fatal(err)
+	}
+	if err := json.Unmarshal(benchmarkCreateBody, params); err != nil {
+		b.Fatal(err)
+	}
+	if _, err := eng.FillModelFromCreateParameters(params); err != nil {
+		b.Fatal(err)
+	}
+}
+
+// updatePatchWith does the work of the engine in an UpdatePatch request on model.
+func updatePatchWith(b *testing.B, eng Engine[benchmarkPost], model *benchmarkPost) {
+	params, err := eng.GenerateUpdateParameters()
+	if err != nil {
+		b.Fatal(err)
+	}
+	if err := json.Unmarshal(benchmarkUpdateBody, params); err != nil {
+		b.Fatal(err)
+	}
+	if _, err := eng.UpdateModelFromUpdateParameters(model, params); err != nil {
+		b.Fatal(err)
+	}
+}
+
+// benchmarkModel returns the model updated by the UpdatePatch benchmarks.
+func benchmarkModel() *benchmarkPost {
+	model := &benchmarkPost{Slug: "hello", Views: 3}
+	model.ID = 1
+	model.Contents = []benchmarkPostContent{{Title: "Hello", Body: "World", PostID: 1}}
+	model.Contents[0].LanguageID = "en"
+	return model
+}
+
+// The cached benchmarks reuse the engine, and its plan, across requests like the services do. The uncached
+// ones build the plan for each request, which is what the engine did before the plan was cached.
+
+func BenchmarkCreate(b *testing.B) {
+	b.Run("cached", func(b *testing.B) {
+		eng := CreateEngine[benchmarkPost]()
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			createWith(b, eng)
+		}
+	})
+	b.Run("uncached", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			createWith(b, &engine[benchmarkPost]{})
+		}
+	})
+}
+
+func BenchmarkUpdatePatch(b *testing.B) {
+	b.Run("cached", func(b *testing.B) {
+		eng := CreateEngine[benchmarkPost]()
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			updatePatchWith(b, eng, benchmarkModel())
+		}
+	})
+	b.Run("uncached", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			updatePatchWith(b, &engine[benchmarkPost]{}, benchmarkModel())
+		}
+	})
+}
+
+func BenchmarkCreateParallel(b *testing.B) {
+	eng := CreateEngine[benchmarkPost]()
+	b.ReportAllocs()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			createWith(b, eng)
+		}
+	})
+}
+
+// TestEngineCachedPlan checks that the cached plan fills and updates the models like a plan built per request.
+func TestEngineCachedPlan(t *testing.T) {
+	cached := CreateEngine[benchmarkPost]()
+	for _, eng := range []Engine[benchmarkPost]{cached, cached, &engine[benchmarkPost]{}} {
+		params, err := eng.GenerateCreateParameters()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := json.Unmarshal(benchmarkCreateBody, params); err != nil {
+			t.Fatal(err)
+		}
+		model, err := eng.FillModelFromCreateParameters(params)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if model.Slug != "hello" || model.Views != 3 || !model.IsPublished || len(model.Contents) != 2 ||
+			model.Contents[1].LanguageID != "ar" || model.Contents[1].Title != "Marhaba" {
+			t.Fatalf("unexpected created model %+v", model)
+		}
+
+		model = benchmarkModel()
+		params, err = eng.GenerateUpdateParameters()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := json.Unmarshal(benchmarkUpdateBody, params); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := eng.UpdateModelFromUpdateParameters(model, params); err != nil {
+			t.Fatal(err)
+		}
+		if model.Slug != "hello" || model.Views != 4 || len(model.Contents) != 1 ||
+			model.Contents[0].Title != "Hello again" {
+			t.Fatalf("unexpected updated model %+v", model)
+		}
+	}
+}
+
+// accessProfile has fields of every access level, in the model, its contents and an association.
+type accessProfile struct {
+	orm.Model
+
+	Contents  []accessProfileContent `json:"contents" gorm:"foreignKey:ProfileID"`
+	Name      string                 `json:"name"`
+	Owner     string                 `json:"owner" origin:"readonly"`
+	Password  string                 `json:"password" origin:"writeonly"`
+	Notes     string                 `json:"notes" origin:"hidden,roles=admin"`
+	ManagerID *uint                  `json:"manager_id"`
+	Manager   *accessManager         `json:"manager"`
+}
+
+type accessProfileContent struct {
+	orm.ContentModel
+
+	Bio        string `json:"bio"`
+	Draft      string `json:"draft" origin:"writeonly,roles=editor"`
+	Moderation string `json:"moderation" origin:"readonly"`
+	ProfileID  uint   `json:"profile_id" gorm:"primaryKey"`
+}
+
+type accessManager struct {
+	orm.Model
+
+	Email string `json:"email"`
+	Token string `json:"token" origin:"hidden"`
+}
+
+// jsonFields returns the JSON names of the fields of the struct t points to or holds, with their types.
+func jsonFields(t reflect.Type) map[string]reflect.Type {
+	for t.Kind() == reflect.Ptr || t.Kind() == reflect.Slice {
+		t = t.Elem()
+	}
+	fields := make(map[string]reflect.Type)
+	for _, field := range reflect.VisibleFields(t) {
+		if name, _ := jsonFieldName(field); field.IsExported() && !field.Anonymous {
+			fields[name] = field.Type
+		}
+	}
+	return fields
+}
+
+// TestParametersFollowFieldOptions checks that the create and update parameters leave out the readonly and
+// hidden fields, of the model, its contents and its associations, and that filters only hold public fields.
+func TestParametersFollowFieldOptions(t *testing.T) {
+	eng := CreateEngine[accessProfile]()
+	tests := []struct {
+		name     string
+		generate func() (interface{}, error)
+		// fields lists the expected fields of the parameters, of their contents and of their manager.
+		fields, contentFields, managerFields map[string]bool
+	}{
+		{
+			name:          "create",
+			generate:      eng.GenerateCreateParameters,
+			fields:        map[string]bool{"name": true, "password": true, "owner": false, "notes": false},
+			contentFields: map[string]bool{"bio": true, "draft": true, "moderation": false},
+			managerFields: map[string]bool{"email": true, "token": false},
+		},
+		{
+			name:          "update",
+			generate:      eng.GenerateUpdateParameters,
+			fields:        map[string]bool{"name": true, "password": true, "owner": false, "notes": false},
+			contentFields: map[string]bool{"bio": true, "draft": true, "moderation": false},
+			managerFields: map[string]bool{"email": true, "token": false},
+		},
+		{
+			name:     "filter",
+			generate: eng.GenerateFilterParameters,
+			// Filters are flat, the fields of the contents are filters of the model.
+			fields: map[string]bool{
+				"name": true, "owner": true, "manager_id": true, "password": false, "notes": false,
+				"bio": true, "moderation": true, "draft": false,
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			params, err := test.generate()
+			if err != nil {
+				t.Fatal(err)
+			}
+			fields := jsonFields(reflect.TypeOf(params))
+			if test.name == "filter" {
+				fields = make(map[string]reflect.Type)
+				paramsType := reflect.TypeOf(params).Elem()
+				for i := 0; i < paramsType.NumField(); i++ {
+					fields[paramsType.Field(i).Tag.Get("url")] = paramsType.Field(i).Type
+				}
+			}
+
+			type fieldCheck struct {
+				of     string
+				fields map[string]reflect.Type
+				want   map[string]bool
+			}
+			checks := []fieldCheck{{"parameters", fields, test.fields}}
+			if test.contentFields != nil {
+				checks = append(checks, fieldCheck{"contents", jsonFields(fields["contents"]), test.contentFields})
+			}
+			if test.managerFields != nil {
+				checks = append(checks, fieldCheck{"manager", jsonFields(fields["manager"]), test.managerFields})
+			}
+			for _, check := range checks {
+				for name, want := range check.want {
+					if _, ok := check.fields[name]; ok != want {
+						t.Errorf("the %s have a field %q: %v, want %v", check.of, name, ok, want)
+					}
+				}
+			}
+		})
+	}
+}
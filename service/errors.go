@@ -0,0 +1,50 @@
+package service
+
+// Error codes returned in the "error_code" field of error responses emitted by the model service.
+const (
+	ErrorCodeCantReadID           = "CANT_READ_ID"
+	ErrorCodeFetchReadObject      = "FETCH_READ_OBJECT_ERROR"
+	ErrorCodeFetch                = "FETCH_ERROR"
+	ErrorCodeGenerateFilterParams = "GENERATE_FILTER_PARAMS_ERROR"
+	ErrorCodeParseFilterParams    = "PARSE_FILTER_PARAMS_ERROR"
+	ErrorCodeGenerateCreateParams = "GENERATE_CREATE_PARAMS_ERROR"
+	ErrorCodeParseCreateParams    = "PARSE_CREATE_PARAMS_ERROR"
+	ErrorCodeGenerateCreateModel  = "GENERATE_CREATE_MODEL_ERROR"
+	ErrorCodeCreate               = "CREATE_ERROR"
+	ErrorCodeReferenceNotFound    = "REFERENCE_NOT_FOUND"
+	ErrorCodeNotFound             = "NOT_FOUND"
+	ErrorCodeGenerateUpdateParams = "GENERATE_UPDATE_PARAMS_ERROR"
+	ErrorCodeParseUpdateParams    = "PARSE_UPDATE_PARAMS_ERROR"
+	ErrorCodeGenerateUpdateModel  = "GENERATE_UPDATE_MODEL_ERROR"
+	ErrorCodeUpdate               = "UPDATE_ERROR"
+	ErrorCodeDelete               = "DELETE_ERROR"
+	ErrorCodeEncodeResponse       = "ENCODE_RESPONSE_ERROR"
+	ErrorCodeGenerateSchema       = "GENERATE_SCHEMA_ERROR"
+	ErrorCodeInvalidInclude       = "INVALID_INCLUDE"
+	ErrorCodeParentNotFound       = "PARENT_NOT_FOUND"
+	ErrorCodeFetchParent          = "FETCH_PARENT_ERROR"
+	ErrorCodeFetchAudit           = "FETCH_AUDIT_ERROR"
+	ErrorCodeInvalidAsOf          = "INVALID_AS_OF"
+	ErrorCodeFetchVersions        = "FETCH_VERSIONS_ERROR"
+	ErrorCodeCantReadVersion      = "CANT_READ_VERSION"
+	ErrorCodeVersionNotFound      = "VERSION_NOT_FOUND"
+	ErrorCodeRevert               = "REVERT_ERROR"
+)
+
+// operationErrorCodes lists the error codes each service operation may emit.
+// It is used to document the error responses of every route.
+var operationErrorCodes = map[string][]string{
+	"GetByID":     {ErrorCodeCantReadID, ErrorCodeInvalidInclude, ErrorCodeFetchReadObject, ErrorCodeInvalidAsOf, ErrorCodeVersionNotFound, ErrorCodeEncodeResponse},
+	"GetAll":      {ErrorCodeGenerateFilterParams, ErrorCodeParseFilterParams, ErrorCodeInvalidInclude, ErrorCodeFetch, ErrorCodeEncodeResponse},
+	"Create":      {ErrorCodeGenerateCreateParams, ErrorCodeParseCreateParams, ErrorCodeGenerateCreateModel, ErrorCodeReferenceNotFound, ErrorCodeCreate, ErrorCodeEncodeResponse},
+	"UpdatePatch": {ErrorCodeCantReadID, ErrorCodeNotFound, ErrorCodeGenerateUpdateParams, ErrorCodeParseUpdateParams, ErrorCodeGenerateUpdateModel, ErrorCodeReferenceNotFound, ErrorCodeUpdate, ErrorCodeEncodeResponse},
+	"Delete":      {ErrorCodeCantReadID, ErrorCodeDelete},
+	"Schema":      {ErrorCodeGenerateSchema},
+	"Audit":       {ErrorCodeCantReadID, ErrorCodeFetchAudit},
+	"Versions":    {ErrorCodeCantReadID, ErrorCodeFetchVersions, ErrorCodeEncodeResponse},
+	"Revert":      {ErrorCodeCantReadID, ErrorCodeCantReadVersion, ErrorCodeVersionNotFound, ErrorCodeRevert, ErrorCodeEncodeResponse},
+}
+
+// childErrorCodes lists the error codes every route of a child model may emit on top of those of
+// its operation, when the parent ID of the path is invalid, does not exist or cannot be fetched.
+var childErrorCodes = []string{ErrorCodeCantReadID, ErrorCodeParentNotFound, ErrorCodeFetchParent}
@@ -1,16 +1,275 @@
 package service
 
 import (
+	"errors"
 	"fmt"
+	"reflect"
+
+	"github.com/MuhmdHsn313/origin/repository"
+	"github.com/iancoleman/strcase"
+	"github.com/kataras/iris/v12"
 	"github.com/kataras/iris/v12/core/router"
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
 )
 
-func RegisterHandler[T any](api router.Party, service Service[T]) {
+// engineProvider is implemented by services that expose the engine they use,
+// so that the routes of the model can be documented with the same parameter types.
+type engineProvider[T any] interface {
+	Engine() Engine[T]
+}
+
+// RegisterHandler registers the routes of model T under api and returns the party of the model,
+// under which child models can be registered with RegisterChildHandler.
+//
+// By default the routes are served at the snake_case name of the model (e.g. /api/blog) with an {id}
+// parameter, and every operation is exposed. An optional RegisterOptions changes the path, the
+// exposed operations, the middleware of each route and the name of the ID parameter:
+//
+//	service.RegisterHandler[Blog](api, blogService, service.RegisterOptions{
+//		Pluralize:  true, // /api/blogs
+//		Operations: service.ReadOnlyOperations,
+//	})
+//
+// The schema route is served by service when it implements SchemaService, and from the engine of the model
+// otherwise. The audit, versions and revert routes are only registered for the services implementing
+// AuditService and HistoryService, such as the services of NewModelService given an audit log or a history.
+//
+// The lines logged while serving the routes hold the name of the model as "model", see logging.AddFields.
+func RegisterHandler[T any](api router.Party, service Service[T], options ...RegisterOptions) router.Party {
+	var registerOptions RegisterOptions
+	if len(options) > 0 {
+		registerOptions = options[0]
+	}
+	registerOptions = registerOptions.withDefaults()
+	registerOptions.Operations = servedOperations(service, registerOptions.Operations)
+
+	var eng Engine[T]
+	if provider, ok := service.(engineProvider[T]); ok {
+		eng = provider.Engine()
+	} else {
+		eng = CreateEngine[T]()
+	}
+
+	handlers := map[Operation]iris.Handler{
+		OperationList:   service.GetAll,
+		OperationGet:    service.GetByID,
+		OperationCreate: service.Create,
+		OperationUpdate: service.UpdatePatch,
+		OperationDelete: service.Delete,
+	}
+	if schemaService, ok := service.(SchemaService); ok {
+		handlers[OperationSchema] = schemaService.Schema
+	} else {
+		handlers[OperationSchema] = newModelService[T](eng, nil).Schema
+	}
+	// The optional operations are only exposed by the services implementing them, see servedOperations.
+	if auditService, ok := service.(AuditService); ok {
+		handlers[OperationAudit] = auditService.Audit
+	}
+	if historyService, ok := service.(HistoryService); ok {
+		handlers[OperationVersions] = historyService.Versions
+		handlers[OperationRevert] = historyService.Revert
+	}
+
 	routerName := structNameToSnake(new(T))
-	serviceRouter := api.Party(fmt.Sprintf("/%s", routerName))
-	serviceRouter.Get("/", service.GetAll)
-	serviceRouter.Get("/{id}", service.GetByID)
-	serviceRouter.Post("/", service.Create)
-	serviceRouter.Delete("/{id}", service.Delete)
-	serviceRouter.Patch("/{id}", service.UpdatePatch)
+	serviceRouter := api.Party(fmt.Sprintf("/%s", registerOptions.routeName(structTypeName[T]())))
+	serviceRouter.Use(logModel[T]())
+	registerOptions.registerRoutes(serviceRouter, handlers)
+
+	description := describeModel[T](routerName, serviceRouter.GetRelPath(), "ID", eng)
+	description.IDParam = registerOptions.IDParam
+	description.Operations = registerOptions.Operations
+	defaultRegistry.add(description)
+	return serviceRouter
+}
+
+// ChildOptions configures the routes of a child model registered with RegisterChildHandler.
+// Every field is optional and derived from the parent model when left empty.
+type ChildOptions struct {
+	// RegisterOptions configures the routes of the children like those of RegisterHandler.
+	// Its Path defaults to the JSON name of the parent field holding the children (e.g. `Comments []Comment`),
+	// or to the name of the child model, following Pluralize and KebabCase, when the parent has no such field.
+	RegisterOptions
+	// ForeignKey is the field of the child holding the parent's ID, such as "BlogID".
+	// It defaults to the foreignKey of the parent field's gorm tag, or to the parent name followed by "ID".
+	ForeignKey string
+	// KeyField is the field of the child matched by the ID path parameter.
+	// It defaults to "ID", or to "LanguageID" for content models without an ID.
+	KeyField string
+}
+
+// withDefaults fills the empty options from the parent and child model types.
+func (options ChildOptions) withDefaults(parentType, childType reflect.Type) ChildOptions {
+	var parentField *reflect.StructField
+	for i := 0; i < parentType.NumField(); i++ {
+		field := parentType.Field(i)
+		if field.Type.Kind() == reflect.Slice && derefType(field.Type.Elem()) == childType {
+			parentField = &field
+			break
+		}
+	}
+
+	options.RegisterOptions = options.RegisterOptions.withDefaults()
+	if options.Path == "" {
+		options.Path = options.routeName(childType.Name())
+		if parentField != nil {
+			if name, tagged := jsonFieldName(*parentField); tagged {
+				options.Path = name
+			} else {
+				options.Path = toSnakeCase(parentField.Name)
+			}
+			if options.KebabCase {
+				options.Path = strcase.ToKebab(options.Path)
+			}
+		}
+	}
+
+	if options.ForeignKey == "" {
+		options.ForeignKey = parentType.Name() + "ID"
+		if parentField != nil {
+			if foreignKey := schema.ParseTagSetting(parentField.Tag.Get("gorm"), ";")["FOREIGNKEY"]; foreignKey != "" {
+				options.ForeignKey = foreignKey
+			}
+		}
+	}
+
+	if options.KeyField == "" {
+		options.KeyField = "ID"
+		if _, ok := childType.FieldByName("ID"); !ok && isContentType(childType) {
+			options.KeyField = "LanguageID"
+		}
+	}
+
+	return options
+}
+
+// RegisterChildHandler registers the routes of child model C under the item routes of parent model P,
+// as in GET/POST /api/blog/{blog_id}/comments and GET/PATCH/DELETE /api/blog/{blog_id}/comments/{id}.
+// The parent parameter is named after the parent model unless the parent has a custom IDParam, and the
+// options of the child routes are those of RegisterHandler, through the RegisterOptions embedded in ChildOptions.
+// The parent party is the one returned by RegisterHandler, or by RegisterChildHandler for deeper nesting.
+//
+// Every request first checks that the parent exists in parentRepo, answering 404 PARENT_NOT_FOUND otherwise.
+// When the parent is itself a child model, it is looked up among the children of its own parent, and so on up
+// to the outermost model: /api/blog/1/comments/5/replies is only served if comment 5 belongs to blog 1.
+// The child repository is then scoped to the parent through a repository.ScopedRepository: reads and deletes
+// only see the children of the parent, and created or updated children are attached to it.
+//
+// Parameters:
+//   - parent: The party of the parent model.
+//   - parentRepo: The repository of the parent, used to verify it exists.
+//   - eng: The engine of the child model.
+//   - repo: The repository of the child model, scoped to the parent on each request.
+//   - options: The routes, foreign key and key field of the children, see ChildOptions.
+//   - opts: The options of the child service, as given to NewModelService.
+//
+// Returns:
+//   - The party of the child model.
+func RegisterChildHandler[P any, C any](parent router.Party, parentRepo repository.Repository[P], eng Engine[C], repo repository.Repository[C], options ChildOptions, opts ...ServiceOption) router.Party {
+	parentType := derefType(reflect.TypeOf((*P)(nil)))
+	childType := derefType(reflect.TypeOf((*C)(nil)))
+	options = options.withDefaults(parentType, childType)
+
+	// The parent parameter is the custom ID parameter of the parent, or is named after the parent model.
+	parentDescription, _ := defaultRegistry.find(parent.GetRelPath())
+	parentParam := toSnakeCase(parentType.Name()) + "_id"
+	if parentDescription.IDParam != "" && parentDescription.IDParam != DefaultIDParam {
+		parentParam = parentDescription.IDParam
+	}
+	childRouter := parent.Party(fmt.Sprintf("/{%s}/%s", parentParam, options.Path))
+	childRouter.Use(logModel[C]())
+	serviceOptions := append(append([]ServiceOption(nil), opts...), withKeyField(options.KeyField))
+	options.Operations = servedOperations(newModelService[C](eng, repo, serviceOptions...), options.Operations)
+
+	// scopeToParent checks that the parent of the request exists, itself scoped to its own parents when it is
+	// a child model, and returns repo scoped to it. It answers the request and returns false otherwise.
+	scopeToParent := func(ctx iris.Context, repo repository.Repository[C]) (repository.Repository[C], bool) {
+		parents := parentRepo
+		if parentScope, ok := parentDescription.scopeToParent.(func(iris.Context, repository.Repository[P]) (repository.Repository[P], bool)); ok {
+			if parents, ok = parentScope(ctx, parentRepo); !ok {
+				return nil, false
+			}
+		}
+
+		rawParentID := ctx.Params().Get(parentParam)
+		parentID, err := repository.ParseKey[P]("ID", rawParentID)
+		if err != nil {
+			_ = ctx.StopWithJSON(
+				iris.StatusBadRequest,
+				iris.Map{
+					"error":      err.Error(),
+					"error_code": ErrorCodeCantReadID,
+				},
+			)
+			return nil, false
+		}
+
+		if _, err := repository.WithContext(parents, ctx.Request().Context()).GetByID(parentID); err != nil {
+			errorCode := ErrorCodeFetchParent
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				errorCode = ErrorCodeParentNotFound
+			}
+			_ = ctx.StopWithJSON(
+				errorCodeStatus(errorCode),
+				iris.Map{
+					"error":      err.Error(),
+					"error_code": errorCode,
+				},
+			)
+			return nil, false
+		}
+
+		foreignKey, err := repository.ParseKey[C](options.ForeignKey, rawParentID)
+		if err != nil {
+			_ = ctx.StopWithJSON(
+				iris.StatusBadRequest,
+				iris.Map{
+					"error":      err.Error(),
+					"error_code": ErrorCodeCantReadID,
+				},
+			)
+			return nil, false
+		}
+		return repository.NewScopedRepository[C](repo, options.ForeignKey, foreignKey, options.KeyField), true
+	}
+
+	// handle resolves the parent of the request and runs the operation on a service scoped to it.
+	handle := func(operation func(*modelService[C], iris.Context)) iris.Handler {
+		return func(ctx iris.Context) {
+			scoped, ok := scopeToParent(ctx, repo)
+			if !ok {
+				return
+			}
+			operation(newModelService[C](eng, scoped, serviceOptions...), ctx)
+		}
+	}
+
+	options.registerRoutes(childRouter, map[Operation]iris.Handler{
+		OperationList:     handle((*modelService[C]).GetAll),
+		OperationGet:      handle((*modelService[C]).GetByID),
+		OperationCreate:   handle((*modelService[C]).Create),
+		OperationUpdate:   handle((*modelService[C]).UpdatePatch),
+		OperationDelete:   handle((*modelService[C]).Delete),
+		OperationSchema:   handle((*modelService[C]).Schema),
+		OperationAudit:    handle((*modelService[C]).Audit),
+		OperationVersions: handle((*modelService[C]).Versions),
+		OperationRevert:   handle((*modelService[C]).Revert),
+	})
+
+	parentName := parentDescription.Name
+	if parentName == "" {
+		parentName = toSnakeCase(parentType.Name())
+	}
+
+	description := describeModel[C](parentName+"_"+toSnakeCase(options.Path), childRouter.GetRelPath(), options.KeyField, eng)
+	description.IDParam = options.IDParam
+	description.Operations = options.Operations
+	description.scopeToParent = scopeToParent
+	description.ParentParams = append(append([]PathParameter(nil), parentDescription.ParentParams...), PathParameter{
+		Name: parentParam,
+		Type: keyFieldType(parentType, "ID"),
+	})
+	defaultRegistry.add(description)
+	return childRouter
 }
@@ -0,0 +1,371 @@
+package service
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/MuhmdHsn313/origin/audit"
+	"github.com/MuhmdHsn313/origin/history"
+	"github.com/MuhmdHsn313/origin/internal/testdb"
+	"github.com/MuhmdHsn313/origin/logging"
+	"github.com/MuhmdHsn313/origin/orm"
+	"github.com/MuhmdHsn313/origin/repository"
+	"github.com/kataras/iris/v12"
+)
+
+type childPost struct {
+	orm.Model
+
+	Contents []childPostContent `json:"contents" gorm:"foreignKey:PostID"`
+	Comments []childComment     `json:"comments" gorm:"foreignKey:PostID"`
+	Slug     string             `json:"slug"`
+}
+
+type childPostContent struct {
+	orm.ContentModel
+
+	Title  string `json:"title"`
+	PostID uint   `json:"post_id" gorm:"primaryKey"`
+}
+
+type childComment struct {
+	orm.Model
+
+	Body   string `json:"body"`
+	PostID uint   `json:"post_id"`
+}
+
+// childReply is a child of childComment, itself a child of childPost.
+type childReply struct {
+	orm.Model
+
+	Body      string `json:"body"`
+	CommentID uint   `json:"comment_id"`
+}
+
+// serve sends a request to app and returns its status code and decoded JSON body, nil when empty and the
+// text of the body when it is not JSON.
+func serve(t *testing.T, app *iris.Application, method, path, body string) (int, interface{}) {
+	t.Helper()
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	app.ServeHTTP(rec, req)
+
+	var decoded interface{}
+	if rec.Body.Len() > 0 {
+		if err := json.Unmarshal(rec.Body.Bytes(), &decoded); err != nil {
+			decoded = rec.Body.String()
+		}
+	}
+	return rec.Code, decoded
+}
+
+// mustServe sends a request to app and returns its decoded JSON body, failing the test unless it answers with status.
+func mustServe(t *testing.T, app *iris.Application, method, path, body string, status int) interface{} {
+	t.Helper()
+	code, decoded := serve(t, app, method, path, body)
+	if code != status {
+		t.Fatalf("%s %s returned %d %v, want %d", method, path, code, decoded, status)
+	}
+	return decoded
+}
+
+// newChildApp serves childPost at /api/child_post, with its comments and contents as child routes.
+func newChildApp(t *testing.T) *iris.Application {
+	t.Helper()
+	useRegistry(t)
+	db := testdb.Open(t, &childPost{}, &childPostContent{}, &childComment{})
+
+	app := iris.New()
+	posts := repository.NewGenericRepository[childPost](db, logging.Nop())
+	party := RegisterHandler[childPost](app.Party("/api"), NewModelService[childPost](CreateEngine[childPost](), posts))
+	RegisterChildHandler[childPost, childComment](party, posts, CreateEngine[childComment](),
+		repository.NewGenericRepository[childComment](db, logging.Nop()), ChildOptions{})
+	RegisterChildHandler[childPost, childPostContent](party, posts, CreateEngine[childPostContent](),
+		repository.NewGenericRepository[childPostContent](db, logging.Nop()), ChildOptions{})
+	if err := app.Build(); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, slug := range []string{"first", "second"} {
+		mustServe(t, app, http.MethodPost, "/api/child_post",
+			`{"slug":"`+slug+`","contents":[{"language_id":"en","title":"`+slug+`"}]}`, http.StatusCreated)
+	}
+	return app
+}
+
+func TestChildRoutesAreScopedToTheParent(t *testing.T) {
+	app := newChildApp(t)
+
+	// The comment is attached to the parent of the path, whatever post_id the client sends.
+	created := mustServe(t, app, http.MethodPost, "/api/child_post/1/comments", `{"body":"hello","post_id":2}`, http.StatusCreated)
+	if postID := created.(map[string]interface{})["post_id"]; postID != float64(1) {
+		t.Errorf("the comment is attached to post %v, want 1", postID)
+	}
+	mustServe(t, app, http.MethodPost, "/api/child_post/2/comments", `{"body":"other"}`, http.StatusCreated)
+
+	// Each parent lists its own comments, filtered by their foreign key.
+	for _, test := range []struct {
+		path string
+		want []string
+	}{
+		{"/api/child_post/1/comments", []string{"hello"}},
+		{"/api/child_post/2/comments", []string{"other"}},
+		{"/api/child_post/2/comments?body=hello", nil},
+	} {
+		var bodies []string
+		for _, comment := range mustServe(t, app, http.MethodGet, test.path, "", http.StatusOK).([]interface{}) {
+			bodies = append(bodies, comment.(map[string]interface{})["body"].(string))
+		}
+		if strings.Join(bodies, ",") != strings.Join(test.want, ",") {
+			t.Errorf("%s listed %q, want %q", test.path, bodies, test.want)
+		}
+	}
+
+	// The comment of a parent is not reachable, nor modifiable, through another parent.
+	mustServe(t, app, http.MethodGet, "/api/child_post/1/comments/1", "", http.StatusOK)
+	for _, request := range []struct{ method, body string }{
+		{http.MethodGet, ""},
+		{http.MethodPatch, `{"body":"moved"}`},
+		{http.MethodDelete, ""},
+	} {
+		if code, _ := serve(t, app, request.method, "/api/child_post/2/comments/1", request.body); code < http.StatusBadRequest {
+			t.Errorf("%s of the comment of post 1 through post 2 returned %d", request.method, code)
+		}
+	}
+	comment := mustServe(t, app, http.MethodGet, "/api/child_post/1/comments/1", "", http.StatusOK)
+	if body := comment.(map[string]interface{})["body"]; body != "hello" {
+		t.Errorf("the comment of post 1 was changed to %v through post 2", body)
+	}
+}
+
+func TestChildContentsAreKeyedByLanguage(t *testing.T) {
+	app := newChildApp(t)
+
+	mustServe(t, app, http.MethodPatch, "/api/child_post/1/contents/en", `{"title":"updated"}`, http.StatusOK)
+
+	for path, want := range map[string]string{
+		"/api/child_post/1/contents/en": "updated",
+		"/api/child_post/2/contents/en": "second",
+	} {
+		content := mustServe(t, app, http.MethodGet, path, "", http.StatusOK)
+		if title := content.(map[string]interface{})["title"]; title != want {
+			t.Errorf("%s has title %v, want %q", path, title, want)
+		}
+	}
+}
+
+func TestChildRoutesOfMissingParent(t *testing.T) {
+	app := newChildApp(t)
+
+	for _, request := range []struct{ method, path, body string }{
+		{http.MethodGet, "/api/child_post/42/comments", ""},
+		{http.MethodPost, "/api/child_post/42/comments", `{"body":"orphan"}`},
+		{http.MethodGet, "/api/child_post/42/contents/en", ""},
+	} {
+		code, body := serve(t, app, request.method, request.path, request.body)
+		if code != http.StatusNotFound {
+			t.Errorf("%s %s returned %d, want %d", request.method, request.path, code, http.StatusNotFound)
+		}
+		if errorCode := body.(map[string]interface{})["error_code"]; errorCode != ErrorCodeParentNotFound {
+			t.Errorf("%s %s returned error code %v, want %s", request.method, request.path, errorCode, ErrorCodeParentNotFound)
+		}
+	}
+
+	// The orphan was not created.
+	if comments := mustServe(t, app, http.MethodGet, "/api/child_post/1/comments", "", http.StatusOK).([]interface{}); len(comments) != 0 {
+		t.Errorf("post 1 has comments %v", comments)
+	}
+
+	code, body := serve(t, app, http.MethodGet, "/api/child_post/first/comments", "")
+	if code != http.StatusBadRequest || body.(map[string]interface{})["error_code"] != ErrorCodeCantReadID {
+		t.Errorf("an invalid parent ID returned %d %v, want %d %s", code, body, http.StatusBadRequest, ErrorCodeCantReadID)
+	}
+}
+
+func TestNestedChildRoutesAreScopedToEveryAncestor(t *testing.T) {
+	useRegistry(t)
+	db := testdb.Open(t, &childPost{}, &childPostContent{}, &childComment{}, &childReply{})
+
+	app := iris.New()
+	posts := repository.NewGenericRepository[childPost](db, logging.Nop())
+	comments := repository.NewGenericRepository[childComment](db, logging.Nop())
+	postParty := RegisterHandler[childPost](app.Party("/api"), NewModelService[childPost](CreateEngine[childPost](), posts))
+	commentParty := RegisterChildHandler[childPost, childComment](postParty, posts, CreateEngine[childComment](), comments, ChildOptions{})
+	RegisterChildHandler[childComment, childReply](commentParty, comments, CreateEngine[childReply](),
+		repository.NewGenericRepository[childReply](db, logging.Nop()), ChildOptions{RegisterOptions: RegisterOptions{Path: "replies"}, ForeignKey: "CommentID"})
+	if err := app.Build(); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, slug := range []string{"first", "second"} {
+		mustServe(t, app, http.MethodPost, "/api/child_post", `{"slug":"`+slug+`"}`, http.StatusCreated)
+	}
+	// Comment 1 belongs to post 2.
+	mustServe(t, app, http.MethodPost, "/api/child_post/2/comments", `{"body":"comment"}`, http.StatusCreated)
+	mustServe(t, app, http.MethodPost, "/api/child_post/2/comments/1/replies", `{"body":"reply"}`, http.StatusCreated)
+
+	if replies := mustServe(t, app, http.MethodGet, "/api/child_post/2/comments/1/replies", "", http.StatusOK).([]interface{}); len(replies) != 1 {
+		t.Errorf("comment 1 of post 2 has the replies %v, want one", replies)
+	}
+
+	// The comment is not found under the posts it does not belong to, even though it exists.
+	for _, request := range []struct{ method, path, body string }{
+		{http.MethodGet, "/api/child_post/1/comments/1/replies", ""},
+		{http.MethodGet, "/api/child_post/1/comments/1/replies/1", ""},
+		{http.MethodPost, "/api/child_post/1/comments/1/replies", `{"body":"misplaced"}`},
+		{http.MethodDelete, "/api/child_post/1/comments/1/replies/1", ""},
+		{http.MethodGet, "/api/child_post/42/comments/1/replies", ""},
+	} {
+		code, body := serve(t, app, request.method, request.path, request.body)
+		if code != http.StatusNotFound || body.(map[string]interface{})["error_code"] != ErrorCodeParentNotFound {
+			t.Errorf("%s %s returned %d %v, want %d %s", request.method, request.path, code, body, http.StatusNotFound, ErrorCodeParentNotFound)
+		}
+	}
+	if replies := mustServe(t, app, http.MethodGet, "/api/child_post/2/comments/1/replies", "", http.StatusOK).([]interface{}); len(replies) != 1 {
+		t.Errorf("comment 1 of post 2 has the replies %v, want only the first one", replies)
+	}
+
+	// Failing to fetch a parent is not mistaken for a missing parent.
+	if err := db.Migrator().DropTable(&childComment{}); err != nil {
+		t.Fatal(err)
+	}
+	code, body := serve(t, app, http.MethodGet, "/api/child_post/2/comments/1/replies", "")
+	if code != http.StatusInternalServerError || body.(map[string]interface{})["error_code"] != ErrorCodeFetchParent {
+		t.Errorf("a failing parent lookup returned %d %v, want %d %s", code, body, http.StatusInternalServerError, ErrorCodeFetchParent)
+	}
+}
+
+func TestRoutesLogTheRequestAndTheModel(t *testing.T) {
+	useRegistry(t)
+	db := testdb.Open(t, &childPost{}, &childPostContent{}, &childComment{})
+	var buf bytes.Buffer
+	logger := logging.Slog(slog.New(slog.NewJSONHandler(&buf, nil)))
+
+	app := iris.New()
+	app.UseRouter(logging.Middleware())
+	posts := repository.NewGenericRepository[childPost](db, logger)
+	party := RegisterHandler[childPost](app.Party("/api"), NewModelService[childPost](CreateEngine[childPost](), posts))
+	RegisterChildHandler[childPost, childComment](party, posts, CreateEngine[childComment](),
+		repository.NewGenericRepository[childComment](db, logger), ChildOptions{})
+	if err := app.Build(); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, request := range []struct{ requestID, method, path, body, model string }{
+		{"create-post", http.MethodPost, "/api/child_post", `{"slug":"first"}`, "child_post"},
+		{"list-comments", http.MethodGet, "/api/child_post/1/comments", "", "child_comment"},
+	} {
+		buf.Reset()
+		req := httptest.NewRequest(request.method, request.path, strings.NewReader(request.body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set(logging.RequestIDHeader, request.requestID)
+		rec := httptest.NewRecorder()
+		app.ServeHTTP(rec, req)
+		if rec.Code >= http.StatusBadRequest {
+			t.Fatalf("%s %s returned %d %s", request.method, request.path, rec.Code, rec.Body)
+		}
+
+		// Every line of the repositories holds the request and the model of the route, even those of the
+		// parent fetched by the child route.
+		var lines int
+		for _, line := range bytes.Split(bytes.TrimSpace(buf.Bytes()), []byte("\n")) {
+			var fields map[string]interface{}
+			if err := json.Unmarshal(line, &fields); err != nil {
+				t.Fatalf("%q is not JSON: %v", line, err)
+			}
+			if fields[logging.RequestIDField] != request.requestID || fields["model"] != request.model {
+				t.Errorf("%s %s logged %v, want the request %q and the model %q", request.method, request.path, fields,
+					request.requestID, request.model)
+			}
+			lines++
+		}
+		if lines == 0 {
+			t.Errorf("%s %s logged no line", request.method, request.path)
+		}
+	}
+}
+
+// listService is a Service of BlogPost implementing none of the optional interfaces.
+type listService struct {
+	Service[BlogPost]
+}
+
+// auditedListService is a listService serving the audit entries of its records.
+type auditedListService struct {
+	listService
+}
+
+func (auditedListService) Audit(ctx iris.Context) {
+	_ = ctx.StopWithJSON(http.StatusOK, []audit.Entry{})
+}
+
+// versionedListService is a listService serving the versions of its records.
+type versionedListService struct {
+	listService
+}
+
+func (versionedListService) Versions(ctx iris.Context) {
+	_ = ctx.StopWithJSON(http.StatusOK, []history.Version{})
+}
+
+func (versionedListService) Revert(ctx iris.Context) {
+	ctx.StopWithStatus(http.StatusNotImplemented)
+}
+
+func TestRegisterHandlerOfCustomServices(t *testing.T) {
+	db := testdb.Open(t, &BlogPost{})
+	repo := repository.NewGenericRepository[BlogPost](db, logging.Nop())
+	// The wrapped service serves the audit entries, the wrappers do not unless they implement AuditService.
+	wrapped := NewModelService[BlogPost](CreateEngine[BlogPost](), repo, WithAuditLog(audit.New(db)))
+	crud := []string{
+		"DELETE /api/blog_post/{id}",
+		"GET /api/blog_post",
+		"GET /api/blog_post/_schema",
+		"GET /api/blog_post/{id}",
+		"PATCH /api/blog_post/{id}",
+		"POST /api/blog_post",
+	}
+
+	tests := []struct {
+		name    string
+		service Service[BlogPost]
+		want    []string
+	}{
+		{"service", listService{wrapped}, crud},
+		{"audit service", auditedListService{listService{wrapped}}, append([]string{"GET /api/blog_post/{id}/_audit"}, crud...)},
+		{"history service", versionedListService{listService{wrapped}},
+			append([]string{"GET /api/blog_post/{id}/_versions", "POST /api/blog_post/{id}/_revert/{version}"}, crud...)},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			useRegistry(t)
+			app := iris.New()
+			RegisterHandler[BlogPost](app.Party("/api"), test.service)
+			if err := app.Build(); err != nil {
+				t.Fatal(err)
+			}
+
+			routes := registeredRoutes(app)
+			sort.Strings(test.want)
+			if !reflect.DeepEqual(routes, test.want) {
+				t.Errorf("registered %q, want %q", routes, test.want)
+			}
+
+			// The schema of a service not implementing SchemaService is served from the engine of the model.
+			schema := mustServe(t, app, http.MethodGet, "/api/blog_post/_schema", "", http.StatusOK).(map[string]interface{})
+			if schema["create"] == nil || schema["update"] == nil {
+				t.Errorf("the schema is %v, want the create and update schemas", schema)
+			}
+			mustServe(t, app, http.MethodPost, "/api/blog_post", `{"title":"hello"}`, http.StatusCreated)
+		})
+	}
+}
@@ -1,9 +1,13 @@
 package service
 
 import (
+	"bytes"
+	"encoding/json"
 	"fmt"
+	"github.com/MuhmdHsn313/origin/orm"
 	"github.com/iancoleman/strcase"
 	"reflect"
+	"strings"
 	"unicode"
 )
 
@@ -98,12 +102,18 @@ func fillStruct(dst, src reflect.Value) error {
 //	return nil
 //}
 
-// copyField handles type conversions and pointer dereferencing
+// copyField handles type conversions and pointer dereferencing.
+// A nil source pointer, such as an optional field missing from the create parameters, zeroes the destination.
 func copyField(dst, src reflect.Value) error {
 	// Dereference pointers
 	for src.Kind() == reflect.Ptr && !src.IsNil() {
 		src = src.Elem()
 	}
+	// A nil pointer (e.g., an omitted optional field) clears the destination
+	if src.Kind() == reflect.Ptr {
+		dst.Set(reflect.Zero(dst.Type()))
+		return nil
+	}
 	for dst.Kind() == reflect.Ptr {
 		if dst.IsNil() {
 			dst.Set(reflect.New(dst.Type().Elem()))
@@ -248,14 +258,15 @@ func mergeContents(existing, newContents reflect.Value) reflect.Value {
 //		return nil
 //	}
 func handleContentUpdate(modelField, paramValue reflect.Value) error {
-	// Always create new map for merging
-	contentMap := make(map[string]reflect.Value)
+	// Items are merged by language, keeping the order of the existing content and appending new languages
+	newSlice := reflect.MakeSlice(modelField.Type(), 0, modelField.Len()+paramValue.Len())
+	positions := make(map[string]int)
 
 	// 1. Populate with existing content
 	for i := 0; i < modelField.Len(); i++ {
 		item := modelField.Index(i)
-		langID := getLanguageID(item)
-		contentMap[langID] = item
+		positions[getLanguageID(item)] = newSlice.Len()
+		newSlice = reflect.Append(newSlice, item)
 	}
 
 	// 2. Process updates
@@ -265,16 +276,18 @@ func handleContentUpdate(modelField, paramValue reflect.Value) error {
 
 		// Create new instance of the content type
 		newItem := reflect.New(modelField.Type().Elem()).Elem()
-		if err := copyStruct(newItem, updateItem); err == nil {
-			contentMap[langID] = newItem
+		if err := copyStruct(newItem, updateItem); err != nil {
+			continue
+		}
+		if position, ok := positions[langID]; ok {
+			newSlice.Index(position).Set(newItem)
+		} else {
+			positions[langID] = newSlice.Len()
+			newSlice = reflect.Append(newSlice, newItem)
 		}
 	}
 
-	// 3. Rebuild slice
-	newSlice := reflect.MakeSlice(modelField.Type(), 0, len(contentMap))
-	for _, v := range contentMap {
-		newSlice = reflect.Append(newSlice, v)
-	}
+	// 3. Replace the collection
 	modelField.Set(newSlice)
 
 	return nil
@@ -300,6 +313,11 @@ func getLanguageID(v reflect.Value) string {
 	return results[0].String()
 }
 
+// structTypeName returns the name of the struct type T (e.g. "Blog").
+func structTypeName[T any]() string {
+	return derefType(reflect.TypeOf((*T)(nil))).Name()
+}
+
 // structNameToSnake takes any struct instance and returns the snake_case version of its type name.
 // It uses reflection to handle both value and pointer types.
 func structNameToSnake(i interface{}) string {
@@ -317,3 +335,90 @@ func structNameToSnake(i interface{}) string {
 	// Return an empty string if the input is not a struct.
 	return ""
 }
+
+// VisibleFields converts a model (or slice of models) into its JSON representation and removes
+// every field that is not readable by the given role according to its `origin` tag.
+// The result is ready to be written as a response body, or in messages such as those of events.Outbox.
+// Its numbers are json.Number values, encoded as they were written, so that the integers above 2^53,
+// such as large IDs, are not rounded to float64.
+func VisibleFields(v interface{}, role string) (interface{}, error) {
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	var data interface{}
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.UseNumber()
+	if err := decoder.Decode(&data); err != nil {
+		return nil, err
+	}
+
+	stripHiddenFields(reflect.TypeOf(v), data, role)
+	return data, nil
+}
+
+// stripHiddenFields walks the decoded JSON data alongside the Go type it was encoded from
+// and deletes the keys of fields that the role is not allowed to read.
+func stripHiddenFields(t reflect.Type, data interface{}, role string) {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	switch t.Kind() {
+	case reflect.Slice, reflect.Array:
+		items, ok := data.([]interface{})
+		if !ok {
+			return
+		}
+		for _, item := range items {
+			stripHiddenFields(t.Elem(), item, role)
+		}
+
+	case reflect.Struct:
+		object, ok := data.(map[string]interface{})
+		if !ok {
+			return
+		}
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			if !field.IsExported() {
+				continue
+			}
+
+			name, tagged := jsonFieldName(field)
+			if name == "-" {
+				continue
+			}
+
+			// Embedded structs without a JSON name are flattened into the parent object.
+			if field.Anonymous && !tagged {
+				stripHiddenFields(field.Type, object, role)
+				continue
+			}
+
+			if !orm.ParseFieldOptions(field).IsReadableBy(role) {
+				delete(object, name)
+				continue
+			}
+
+			if value, ok := object[name]; ok {
+				stripHiddenFields(field.Type, value, role)
+			}
+		}
+	}
+}
+
+// jsonFieldName returns the JSON key of a struct field and whether it was set explicitly by a json tag.
+func jsonFieldName(field reflect.StructField) (string, bool) {
+	tag, ok := field.Tag.Lookup("json")
+	if !ok {
+		return field.Name, false
+	}
+
+	name := strings.Split(tag, ",")[0]
+	if name == "" {
+		return field.Name, false
+	}
+	return name, true
+}
@@ -0,0 +1,257 @@
+package service
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/MuhmdHsn313/origin/internal/testdb"
+	"github.com/MuhmdHsn313/origin/logging"
+	"github.com/MuhmdHsn313/origin/orm"
+	"github.com/MuhmdHsn313/origin/repository"
+	"github.com/kataras/iris/v12"
+)
+
+// TestToSnakeCase checks the names of the routes, parameters and models, every upper-case letter starting a word.
+func TestToSnakeCase(t *testing.T) {
+	tests := []struct {
+		name, want string
+	}{
+		{"Blog", "blog"},
+		{"BlogPost", "blog_post"},
+		{"HTMLPage", "h_t_m_l_page"},
+		{"AuthorID", "author_i_d"},
+		{"Page2Content", "page2_content"},
+	}
+	for _, test := range tests {
+		if got := toSnakeCase(test.name); got != test.want {
+			t.Errorf("toSnakeCase(%q) = %q, want %q", test.name, got, test.want)
+		}
+	}
+}
+
+type HTMLPage struct {
+	ID          uint   `json:"id"`
+	PageTitle   string `json:"page_title"`
+	SectionID   uint   `json:"section_id"`
+	IsPublished bool   `json:"is_published"`
+}
+
+// TestNamesOfModelsWithInitialisms checks that the route of a model splits its initialisms like toSnakeCase,
+// while its filters keep the ID suffix of its foreign keys, see orm.FilterName.
+func TestNamesOfModelsWithInitialisms(t *testing.T) {
+	if got := (RegisterOptions{}).routeName("HTMLPage"); got != "h_t_m_l_page" {
+		t.Errorf("routeName = %q, want %q", got, "h_t_m_l_page")
+	}
+
+	params, err := CreateEngine[HTMLPage]().GenerateFilterParameters()
+	if err != nil {
+		t.Fatal(err)
+	}
+	paramsType := reflect.TypeOf(params).Elem()
+	for field, want := range map[string]string{"PageTitle": "page_title", "SectionID": "section_id", "IsPublished": "is_published"} {
+		structField, ok := paramsType.FieldByName(field)
+		if !ok {
+			t.Errorf("no filter on %s", field)
+			continue
+		}
+		if got := structField.Tag.Get("url"); got != want {
+			t.Errorf("%s is filtered by %q, want %q", field, got, want)
+		}
+	}
+}
+
+func TestCopyField(t *testing.T) {
+	one, two := uint(1), uint(2)
+	onePointer := &one
+
+	tests := []struct {
+		name    string
+		dst     interface{}
+		src     interface{}
+		want    interface{}
+		wantErr bool
+	}{
+		{"value", new(uint), uint(1), uint(1), false},
+		{"conversion", new(int64), uint(1), int64(1), false},
+		{"pointer to value", new(uint), &one, uint(1), false},
+		{"pointer to pointer", func() interface{} { p := &two; return &p }(), &onePointer, &one, false},
+		{"value to pointer", func() interface{} { p := &two; return &p }(), uint(1), &one, false},
+		// A nil source, such as an optional foreign key set to null, clears the destination.
+		{"nil pointer to pointer", func() interface{} { p := &two; return &p }(), (*uint)(nil), (*uint)(nil), false},
+		{"nil pointer to value", func() interface{} { v := two; return &v }(), (*uint)(nil), uint(0), false},
+		{"nil pointer to pointer of pointer", func() interface{} { p := &two; return &p }(), func() interface{} { var p *uint; return &p }(), (*uint)(nil), false},
+		{"mismatch", new(uint), "1", nil, true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dst := reflect.ValueOf(test.dst).Elem()
+			err := copyField(dst, reflect.ValueOf(test.src))
+			if test.wantErr {
+				if err == nil {
+					t.Errorf("copied %v to %v, want an error", test.src, dst.Interface())
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(dst.Interface(), test.want) {
+				t.Errorf("copied %v, want %v", dst.Interface(), test.want)
+			}
+		})
+	}
+}
+
+type ratedNote struct {
+	orm.Model
+
+	Title  string   `json:"title"`
+	Rating *float64 `json:"rating"`
+}
+
+// TestCreateWithOptionalFields checks that the optional fields missing from the create parameters are left nil.
+func TestCreateWithOptionalFields(t *testing.T) {
+	eng := CreateEngine[ratedNote]()
+	tests := []struct {
+		body string
+		want *float64
+	}{
+		{`{"title":"unrated"}`, nil},
+		{`{"title":"rated","rating":4.5}`, func() *float64 { rating := 4.5; return &rating }()},
+	}
+	for _, test := range tests {
+		params, err := eng.GenerateCreateParameters()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := json.Unmarshal([]byte(test.body), params); err != nil {
+			t.Fatal(err)
+		}
+		model, err := eng.FillModelFromCreateParameters(params)
+		if err != nil {
+			t.Fatalf("create from %s: %v", test.body, err)
+		}
+		if !reflect.DeepEqual(model.Rating, test.want) {
+			t.Errorf("create from %s set the rating to %v, want %v", test.body, model.Rating, test.want)
+		}
+	}
+}
+
+func TestVisibleFields(t *testing.T) {
+	managerID := uint(2)
+	profile := accessProfile{
+		Name:      "alice",
+		Owner:     "system",
+		Password:  "secret",
+		Notes:     "internal",
+		ManagerID: &managerID,
+		Manager:   &accessManager{Email: "bob@example.com", Token: "token"},
+	}
+	profile.Contents = []accessProfileContent{{Bio: "hello", Draft: "draft", Moderation: "approved"}}
+	profile.Contents[0].LanguageID = "en"
+
+	tests := []struct {
+		role string
+		// fields, contentFields and managerFields list the expected keys of the profile, its content and its manager.
+		fields, contentFields, managerFields map[string]bool
+	}{
+		{
+			role:          "",
+			fields:        map[string]bool{"id": true, "name": true, "owner": true, "manager_id": true, "password": false, "notes": false},
+			contentFields: map[string]bool{"language_id": true, "bio": true, "moderation": true, "draft": false},
+			managerFields: map[string]bool{"email": true, "token": false},
+		},
+		{
+			role:          "admin",
+			fields:        map[string]bool{"name": true, "notes": true, "password": false},
+			contentFields: map[string]bool{"bio": true, "draft": false},
+			managerFields: map[string]bool{"email": true, "token": false},
+		},
+		{
+			role:          "editor",
+			fields:        map[string]bool{"name": true, "notes": false, "password": false},
+			contentFields: map[string]bool{"bio": true, "draft": true},
+			managerFields: map[string]bool{"email": true, "token": false},
+		},
+	}
+	for _, test := range tests {
+		// Single records and lists are stripped alike.
+		for _, v := range []interface{}{&profile, []accessProfile{profile}} {
+			body, err := VisibleFields(v, test.role)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if list, ok := body.([]interface{}); ok {
+				body = list[0]
+			}
+
+			object := body.(map[string]interface{})
+			content := object["contents"].([]interface{})[0].(map[string]interface{})
+			manager := object["manager"].(map[string]interface{})
+			for _, check := range []struct {
+				of     string
+				object map[string]interface{}
+				want   map[string]bool
+			}{
+				{"profile", object, test.fields},
+				{"content", content, test.contentFields},
+				{"manager", manager, test.managerFields},
+			} {
+				for name, want := range check.want {
+					if _, ok := check.object[name]; ok != want {
+						t.Errorf("role %q: the %s of %T has %q: %v, want %v", test.role, check.of, v, name, ok, want)
+					}
+				}
+			}
+		}
+	}
+}
+
+type bigCounter struct {
+	ID    uint64  `json:"id" gorm:"primaryKey;autoIncrement:false"`
+	Count int64   `json:"count"`
+	Ratio float64 `json:"ratio"`
+}
+
+// TestVisibleFieldsKeepsLargeIntegers checks that the integers above 2^53 are not rounded to float64.
+func TestVisibleFieldsKeepsLargeIntegers(t *testing.T) {
+	counter := bigCounter{ID: 9007199254740993, Count: -1234567890123456789, Ratio: 0.5}
+	body, err := VisibleFields([]bigCounter{counter}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	encoded, err := json.Marshal(body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `[{"count":-1234567890123456789,"id":9007199254740993,"ratio":0.5}]`; string(encoded) != want {
+		t.Errorf("VisibleFields encoded %s, want %s", encoded, want)
+	}
+
+	// The records are served as they are stored.
+	useRegistry(t)
+	db := testdb.Open(t, &bigCounter{})
+	repo := repository.NewGenericRepository[bigCounter](db, logging.Nop())
+	if err := repo.Create(&counter); err != nil {
+		t.Fatal(err)
+	}
+	app := iris.New()
+	RegisterHandler[bigCounter](app.Party("/api"), NewModelService[bigCounter](CreateEngine[bigCounter](), repo))
+	if err := app.Build(); err != nil {
+		t.Fatal(err)
+	}
+	for path, want := range map[string]string{
+		"/api/big_counter/9007199254740993": `{"count":-1234567890123456789,"id":9007199254740993,"ratio":0.5}`,
+		"/api/big_counter":                  `[{"count":-1234567890123456789,"id":9007199254740993,"ratio":0.5}]`,
+	} {
+		rec := httptest.NewRecorder()
+		app.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		if got := strings.TrimSpace(rec.Body.String()); rec.Code != http.StatusOK || got != want {
+			t.Errorf("%s returned %d %s, want %s", path, rec.Code, got, want)
+		}
+	}
+}
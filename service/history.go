@@ -0,0 +1,291 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/MuhmdHsn313/origin/history"
+	"github.com/MuhmdHsn313/origin/repository"
+	"github.com/kataras/iris/v12"
+)
+
+// History returns the versions recorded for a record, such as a history.Store.
+type History interface {
+	// Versions returns the versions of the record recordID of model, the most recent first,
+	// skipping offset versions and returning at most limit of them (every version when 0).
+	Versions(ctx context.Context, model, recordID string, limit, offset int) ([]history.Version, error)
+	// Version returns the version number of the record, or history.ErrVersionNotFound.
+	Version(ctx context.Context, model, recordID string, number int) (history.Version, error)
+	// AsOf returns the version of the record current at the given time, or history.ErrVersionNotFound
+	// if the record did not exist then.
+	AsOf(ctx context.Context, model, recordID string, at time.Time) (history.Version, error)
+}
+
+// WithHistory serves the versions of each record from h: they are listed at GET /{model}/{id}/_versions
+// (see OperationVersions), paginated like the list requests, a record is read as it was at a point in time
+// with GET /{model}/{id}?as_of=2024-01-02T15:04:05Z, and restored to a version with
+// POST /{model}/{id}/_revert/{version} (see OperationRevert). The routes are only registered for the
+// services given a history. The versions are recorded by the repository, see repository.WithAuditor.
+func WithHistory(h History) ServiceOption {
+	return func(options *serviceOptions) {
+		options.history = h
+	}
+}
+
+// serves reports whether the service serves an optional operation, which needs an audit log or a history.
+func (service modelService[T]) serves(operation Operation) bool {
+	switch operation {
+	case OperationAudit:
+		return service.options.auditLog != nil
+	case OperationVersions, OperationRevert:
+		return service.options.history != nil
+	default:
+		return true
+	}
+}
+
+// versionErrorCode returns the error code of an error returned by the history.
+func versionErrorCode(err error, fallback string) string {
+	if errors.Is(err, history.ErrVersionNotFound) {
+		return ErrorCodeVersionNotFound
+	}
+	return fallback
+}
+
+// getAsOf responds with the version of the record identified by id current at the time asOf,
+// an RFC 3339 timestamp. The record is returned as it was saved, with the associations it had then.
+func (service modelService[T]) getAsOf(ctx iris.Context, id interface{}, asOf string) {
+	at, err := time.Parse(time.RFC3339Nano, asOf)
+	if err != nil || service.options.history == nil {
+		message := "the history is not enabled"
+		if err != nil {
+			message = "as_of must be an RFC 3339 timestamp: " + err.Error()
+		}
+		_ = ctx.StopWithJSON(
+			iris.StatusBadRequest,
+			iris.Map{
+				"error":      message,
+				"error_code": ErrorCodeInvalidAsOf,
+			},
+		)
+		return
+	}
+
+	recordID, err := service.recordID(ctx, id)
+	if err != nil {
+		_ = ctx.StopWithJSON(
+			iris.StatusBadRequest,
+			iris.Map{
+				"error":      err.Error(),
+				"error_code": ErrorCodeCantReadID,
+			},
+		)
+		return
+	}
+
+	version, err := service.options.history.AsOf(ctx.Request().Context(), service.modelName(), recordID, at)
+	if err != nil {
+		_ = ctx.StopWithJSON(
+			iris.StatusBadRequest,
+			iris.Map{
+				"error":      err.Error(),
+				"error_code": versionErrorCode(err, ErrorCodeFetchReadObject),
+			},
+		)
+		return
+	}
+
+	object := new(T)
+	if err := version.Decode(object); err != nil {
+		_ = ctx.StopWithJSON(
+			iris.StatusBadRequest,
+			iris.Map{
+				"error":      err.Error(),
+				"error_code": ErrorCodeFetchReadObject,
+			},
+		)
+		return
+	}
+
+	service.respond(ctx, iris.StatusOK, object)
+}
+
+func (service modelService[T]) Versions(ctx iris.Context) {
+	span := service.startSpan(ctx, "Versions")
+	defer endSpan(ctx, span)
+
+	if service.options.history == nil {
+		_ = ctx.StopWithJSON(
+			iris.StatusBadRequest,
+			iris.Map{
+				"error":      "the history is not enabled",
+				"error_code": ErrorCodeFetchVersions,
+			},
+		)
+		return
+	}
+
+	id, err := service.parseID(ctx)
+	if err != nil {
+		_ = ctx.StopWithJSON(
+			iris.StatusBadRequest,
+			iris.Map{
+				"error":      err.Error(),
+				"error_code": ErrorCodeCantReadID,
+			},
+		)
+		return
+	}
+
+	recordID, err := service.recordID(ctx, id)
+	if err != nil {
+		_ = ctx.StopWithJSON(
+			iris.StatusBadRequest,
+			iris.Map{
+				"error":      err.Error(),
+				"error_code": ErrorCodeCantReadID,
+			},
+		)
+		return
+	}
+
+	versions, err := service.options.history.Versions(ctx.Request().Context(), service.modelName(), recordID,
+		service.limit(ctx), ctx.URLParamIntDefault("offset", 0))
+	if err != nil {
+		_ = ctx.StopWithJSON(
+			iris.StatusBadRequest,
+			iris.Map{
+				"error":      err.Error(),
+				"error_code": ErrorCodeFetchVersions,
+			},
+		)
+		return
+	}
+
+	// The snapshots hold every field of the records, they are stripped like the responses of GetByID.
+	role := service.options.roleResolver(ctx)
+	for i, version := range versions {
+		if version.Deleted {
+			continue
+		}
+
+		object := new(T)
+		err := version.Decode(object)
+		var body interface{}
+		if err == nil {
+			body, err = VisibleFields(object, role)
+		}
+		if err == nil {
+			versions[i].Snapshot, err = json.Marshal(body)
+		}
+		if err != nil {
+			_ = ctx.StopWithJSON(
+				iris.StatusInternalServerError,
+				iris.Map{
+					"error":      err.Error(),
+					"error_code": ErrorCodeEncodeResponse,
+				},
+			)
+			return
+		}
+	}
+
+	_ = ctx.StopWithJSON(iris.StatusOK, versions)
+}
+
+func (service modelService[T]) Revert(ctx iris.Context) {
+	span := service.startSpan(ctx, "Revert")
+	defer endSpan(ctx, span)
+
+	if service.options.history == nil {
+		_ = ctx.StopWithJSON(
+			iris.StatusBadRequest,
+			iris.Map{
+				"error":      "the history is not enabled",
+				"error_code": ErrorCodeRevert,
+			},
+		)
+		return
+	}
+
+	id, err := service.parseID(ctx)
+	if err != nil {
+		_ = ctx.StopWithJSON(
+			iris.StatusBadRequest,
+			iris.Map{
+				"error":      err.Error(),
+				"error_code": ErrorCodeCantReadID,
+			},
+		)
+		return
+	}
+
+	number, err := strconv.Atoi(ctx.Params().Get("version"))
+	if err != nil || number < 1 {
+		message := "version must be a positive integer"
+		if err != nil {
+			message += ": " + err.Error()
+		}
+		_ = ctx.StopWithJSON(
+			iris.StatusBadRequest,
+			iris.Map{
+				"error":      message,
+				"error_code": ErrorCodeCantReadVersion,
+			},
+		)
+		return
+	}
+
+	recordID, err := service.recordID(ctx, id)
+	if err != nil {
+		_ = ctx.StopWithJSON(
+			iris.StatusBadRequest,
+			iris.Map{
+				"error":      err.Error(),
+				"error_code": ErrorCodeCantReadID,
+			},
+		)
+		return
+	}
+
+	version, err := service.options.history.Version(ctx.Request().Context(), service.modelName(), recordID, number)
+	if err != nil {
+		_ = ctx.StopWithJSON(
+			iris.StatusBadRequest,
+			iris.Map{
+				"error":      err.Error(),
+				"error_code": versionErrorCode(err, ErrorCodeRevert),
+			},
+		)
+		return
+	}
+
+	// The version recording a deletion has no snapshot to restore.
+	model := new(T)
+	if err := version.Decode(model); err != nil {
+		_ = ctx.StopWithJSON(
+			iris.StatusBadRequest,
+			iris.Map{
+				"error":      err.Error(),
+				"error_code": ErrorCodeRevert,
+			},
+		)
+		return
+	}
+
+	if err := repository.Revert(service.repository(ctx), model); err != nil {
+		_ = ctx.StopWithJSON(
+			iris.StatusBadRequest,
+			iris.Map{
+				"error":      err.Error(),
+				"error_code": ErrorCodeRevert,
+			},
+		)
+		return
+	}
+
+	service.respond(ctx, iris.StatusOK, model)
+}
@@ -0,0 +1,105 @@
+package service
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/MuhmdHsn313/origin/history"
+	"github.com/MuhmdHsn313/origin/internal/testdb"
+	"github.com/MuhmdHsn313/origin/logging"
+	"github.com/MuhmdHsn313/origin/repository"
+	"github.com/kataras/iris/v12"
+)
+
+// newVersionedApp serves APIKey at /api/a_p_i_key, its versions recorded in a history.
+func newVersionedApp(t *testing.T) *iris.Application {
+	t.Helper()
+	useRegistry(t)
+	db := testdb.Open(t, &history.Version{}, &APIKey{}, &APIKeyContent{})
+	store := history.New(db)
+
+	app := iris.New()
+	repo := repository.NewGenericRepository[APIKey](db, logging.Nop(), repository.WithAuditor(store))
+	RegisterHandler[APIKey](app.Party("/api"), NewModelService[APIKey](CreateEngine[APIKey](), repo, WithHistory(store)))
+	if err := app.Build(); err != nil {
+		t.Fatal(err)
+	}
+
+	mustServe(t, app, http.MethodPost, "/api/a_p_i_key",
+		`{"name":"deploy","contents":[{"language_id":"en","description":"deploys"}]}`, http.StatusCreated)
+	mustServe(t, app, http.MethodPatch, "/api/a_p_i_key/1", `{"name":"release"}`, http.StatusOK)
+	return app
+}
+
+// asOf returns the as_of query parameter of the time at.
+func asOf(at time.Time) string {
+	return "as_of=" + url.QueryEscape(at.UTC().Format(time.RFC3339Nano))
+}
+
+func TestVersionsAreServedUnderTheNameOfTheModel(t *testing.T) {
+	app := newVersionedApp(t)
+
+	versions := mustServe(t, app, http.MethodGet, "/api/a_p_i_key/1/_versions", "", http.StatusOK).([]interface{})
+	if len(versions) != 2 {
+		t.Fatalf("the record has the versions %v, want its creation and update", versions)
+	}
+	for i, operation := range []string{"update", "create"} {
+		version := versions[i].(map[string]interface{})
+		if version["model"] != "api_key" || version["operation"] != operation || version["version"] != float64(2-i) {
+			t.Errorf("version %d is %v, want the %s of api_key", i, version, operation)
+		}
+	}
+
+	// The record is read as it was, with the associations saved with it.
+	current := mustServe(t, app, http.MethodGet, "/api/a_p_i_key/1?"+asOf(time.Now().Add(time.Hour)), "", http.StatusOK)
+	if name := current.(map[string]interface{})["name"]; name != "release" {
+		t.Errorf("the record is named %v now, want release", name)
+	}
+	if contents := current.(map[string]interface{})["contents"].([]interface{}); len(contents) != 1 {
+		t.Errorf("the record has the contents %v now, want its English content", contents)
+	}
+	code, body := serve(t, app, http.MethodGet, "/api/a_p_i_key/1?"+asOf(time.Now().Add(-time.Hour)), "")
+	if code != http.StatusBadRequest || body.(map[string]interface{})["error_code"] != ErrorCodeVersionNotFound {
+		t.Errorf("the record before its creation returned %d %v, want %s", code, body, ErrorCodeVersionNotFound)
+	}
+
+	reverted := mustServe(t, app, http.MethodPost, "/api/a_p_i_key/1/_revert/1", "", http.StatusOK)
+	if name := reverted.(map[string]interface{})["name"]; name != "deploy" {
+		t.Errorf("the record reverted to its first version is named %v, want deploy", name)
+	}
+	versions = mustServe(t, app, http.MethodGet, "/api/a_p_i_key/1/_versions?limit=1", "", http.StatusOK).([]interface{})
+	if version := versions[0].(map[string]interface{}); version["operation"] != "revert" || version["version"] != float64(3) {
+		t.Errorf("the last version is %v, want the revert", version)
+	}
+	code, body = serve(t, app, http.MethodPost, "/api/a_p_i_key/1/_revert/9", "")
+	if code != http.StatusBadRequest || body.(map[string]interface{})["error_code"] != ErrorCodeVersionNotFound {
+		t.Errorf("the revert to a missing version returned %d %v, want %s", code, body, ErrorCodeVersionNotFound)
+	}
+}
+
+func TestGetByIDRejectsIncludeWithAsOf(t *testing.T) {
+	app := newVersionedApp(t)
+	now := asOf(time.Now().Add(time.Hour))
+
+	tests := []struct {
+		query     string
+		errorCode string
+	}{
+		{now + "&include=contents", ErrorCodeInvalidAsOf},
+		// The include paths are checked first.
+		{now + "&include=owner", ErrorCodeInvalidInclude},
+		{"as_of=yesterday", ErrorCodeInvalidAsOf},
+	}
+	for _, test := range tests {
+		code, body := serve(t, app, http.MethodGet, "/api/a_p_i_key/1?"+test.query, "")
+		if code != http.StatusBadRequest || body.(map[string]interface{})["error_code"] != test.errorCode {
+			t.Errorf("?%s returned %d %v, want %d %s", test.query, code, body, http.StatusBadRequest, test.errorCode)
+		}
+	}
+
+	// An empty include holds no path.
+	mustServe(t, app, http.MethodGet, "/api/a_p_i_key/1?"+now+"&include=", "", http.StatusOK)
+	mustServe(t, app, http.MethodGet, "/api/a_p_i_key/1?include=contents", "", http.StatusOK)
+}
@@ -0,0 +1,507 @@
+package service
+
+import (
+	"fmt"
+	"html"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"github.com/MuhmdHsn313/origin/audit"
+	"github.com/MuhmdHsn313/origin/history"
+	"github.com/iancoleman/strcase"
+	"github.com/kataras/iris/v12"
+	"github.com/kataras/iris/v12/core/router"
+)
+
+// OpenAPIVersion is the version of the OpenAPI specification the generated documents conform to.
+const OpenAPIVersion = "3.1.0"
+
+// OpenAPI UI flavours that can be served next to the generated document.
+const (
+	OpenAPIUINone    = ""
+	OpenAPIUISwagger = "swagger"
+	OpenAPIUIRedoc   = "redoc"
+)
+
+// OpenAPIOptions configures the OpenAPI document and the routes serving it.
+type OpenAPIOptions struct {
+	// Title of the API, defaults to "Origin API".
+	Title string
+	// Version of the API, defaults to "1.0.0".
+	Version string
+	// Description of the API, optional.
+	Description string
+	// Path of the JSON document, defaults to "/openapi.json".
+	Path string
+	// UI selects an optional documentation UI (OpenAPIUISwagger or OpenAPIUIRedoc).
+	UI string
+	// UIPath is the path of the documentation UI, defaults to "/docs".
+	UIPath string
+}
+
+func (options OpenAPIOptions) withDefaults() OpenAPIOptions {
+	if options.Title == "" {
+		options.Title = "Origin API"
+	}
+	if options.Version == "" {
+		options.Version = "1.0.0"
+	}
+	if options.Path == "" {
+		options.Path = "/openapi.json"
+	}
+	if options.UIPath == "" {
+		options.UIPath = "/docs"
+	}
+	return options
+}
+
+// RegisterOpenAPI serves the OpenAPI document of every model registered through RegisterHandler
+// and, when requested, a documentation UI rendering it.
+// The document is built on each request, so models registered later are included as well.
+func RegisterOpenAPI(app router.Party, options OpenAPIOptions) {
+	options = options.withDefaults()
+
+	app.Get(options.Path, func(ctx iris.Context) {
+		_ = ctx.StopWithJSON(iris.StatusOK, BuildOpenAPI(options))
+	})
+
+	var page string
+	switch options.UI {
+	case OpenAPIUISwagger:
+		page = swaggerUIPage
+	case OpenAPIUIRedoc:
+		page = redocUIPage
+	default:
+		return
+	}
+
+	specURL := strings.TrimSuffix(app.GetRelPath(), "/") + options.Path
+	app.Get(options.UIPath, func(ctx iris.Context) {
+		_, _ = ctx.HTML(page, html.EscapeString(options.Title), html.EscapeString(specURL))
+	})
+}
+
+// BuildOpenAPI returns the OpenAPI 3.1 document describing the routes, parameters,
+// responses and error codes of every model registered through RegisterHandler.
+func BuildOpenAPI(options OpenAPIOptions) map[string]interface{} {
+	options = options.withDefaults()
+
+	responses := newSchemaBuilder("#/components/schemas/", true)
+	requests := newSchemaBuilder("#/components/schemas/", false)
+
+	info := map[string]interface{}{
+		"title":   options.Title,
+		"version": options.Version,
+	}
+	if options.Description != "" {
+		info["description"] = options.Description
+	}
+
+	paths := map[string]interface{}{}
+	var tags []interface{}
+	for _, model := range RegisteredModels() {
+		tags = append(tags, map[string]interface{}{"name": model.Name})
+
+		modelSchema := responses.schemaOf(model.ModelType)
+		idParameter := map[string]interface{}{
+			"name":     model.IDParam,
+			"in":       "path",
+			"required": true,
+			"schema":   requests.schemaOf(model.KeyType),
+		}
+
+		// Child models are nested under the item path of their parents, whose IDs are path parameters too.
+		var parentParameters []interface{}
+		var extraCodes []string
+		for _, parent := range model.ParentParams {
+			parentParameters = append(parentParameters, map[string]interface{}{
+				"name":     parent.Name,
+				"in":       "path",
+				"required": true,
+				"schema":   requests.schemaOf(parent.Type),
+			})
+		}
+		if len(model.ParentParams) > 0 {
+			extraCodes = childErrorCodes
+		}
+
+		collection := map[string]interface{}{
+			"get": map[string]interface{}{
+				"tags":        []string{model.Name},
+				"operationId": operationID("list", model.Name),
+				"parameters":  listParameters(requests, model.FilterParamsType),
+				"responses": operationResponses("GetAll", "200", "The matching records.", jsonSchema{
+					"type":  "array",
+					"items": modelSchema,
+				}, extraCodes...),
+			},
+			"post": map[string]interface{}{
+				"tags":        []string{model.Name},
+				"operationId": operationID("create", model.Name),
+				"requestBody": requestBody(requests, model.CreateParamsType),
+				"responses":   operationResponses("Create", "201", "The created record.", modelSchema, extraCodes...),
+			},
+		}
+		if len(parentParameters) > 0 {
+			collection["parameters"] = parentParameters
+		}
+
+		item := map[string]interface{}{
+			"parameters": append(append([]interface{}(nil), parentParameters...), idParameter),
+			"get": map[string]interface{}{
+				"tags":        []string{model.Name},
+				"operationId": operationID("get", model.Name),
+				"parameters":  getParameters(model),
+				"responses":   operationResponses("GetByID", "200", "The requested record.", modelSchema, extraCodes...),
+			},
+			"patch": map[string]interface{}{
+				"tags":        []string{model.Name},
+				"operationId": operationID("update", model.Name),
+				"requestBody": requestBody(requests, model.UpdateParamsType),
+				"responses":   operationResponses("UpdatePatch", "200", "The updated record.", modelSchema, extraCodes...),
+			},
+			"delete": map[string]interface{}{
+				"tags":        []string{model.Name},
+				"operationId": operationID("delete", model.Name),
+				"responses":   operationResponses("Delete", "204", "The record was deleted.", nil, extraCodes...),
+			},
+		}
+
+		schema := map[string]interface{}{
+			"get": map[string]interface{}{
+				"tags":        []string{model.Name},
+				"operationId": operationID("schema", model.Name),
+				"description": "JSON Schema (draft 2020-12) of the create and update parameters.",
+				"responses": operationResponses("Schema", "200", "The create and update parameter schemas.", jsonSchema{
+					"type": "object",
+					"properties": jsonSchema{
+						"create": jsonSchema{"$ref": JSONSchemaDialect},
+						"update": jsonSchema{"$ref": JSONSchemaDialect},
+					},
+				}, extraCodes...),
+			},
+		}
+
+		if len(parentParameters) > 0 {
+			schema["parameters"] = parentParameters
+		}
+
+		// Operations that are not exposed are left out, and so are the paths left without any operation.
+		for operation, method := range map[Operation]string{OperationList: "get", OperationCreate: "post"} {
+			if !model.Exposes(operation) {
+				delete(collection, method)
+			}
+		}
+		for operation, method := range map[Operation]string{OperationGet: "get", OperationUpdate: "patch", OperationDelete: "delete"} {
+			if !model.Exposes(operation) {
+				delete(item, method)
+			}
+		}
+
+		if model.Exposes(OperationList) || model.Exposes(OperationCreate) {
+			paths[model.Path] = collection
+		}
+		if model.Exposes(OperationGet) || model.Exposes(OperationUpdate) || model.Exposes(OperationDelete) {
+			paths[model.Path+"/{"+model.IDParam+"}"] = item
+		}
+		if model.Exposes(OperationSchema) {
+			paths[model.Path+"/_schema"] = schema
+		}
+		// The schemas of the audit entries and versions are only registered for the models serving them.
+		if model.Exposes(OperationAudit) {
+			auditPath := map[string]interface{}{
+				"parameters": append(append([]interface{}(nil), parentParameters...), idParameter),
+				"get": map[string]interface{}{
+					"tags":        []string{model.Name},
+					"operationId": operationID("audit", model.Name),
+					"description": "Audit entries of the record, the most recent first.",
+					"parameters":  paginationParameters,
+					"responses": operationResponses("Audit", "200", "The audit entries of the record.", jsonSchema{
+						"type":  "array",
+						"items": auditEntrySchema(responses),
+					}, extraCodes...),
+				},
+			}
+			paths[model.Path+"/{"+model.IDParam+"}/_audit"] = auditPath
+		}
+		if model.Exposes(OperationVersions) {
+			versionsPath := map[string]interface{}{
+				"parameters": append(append([]interface{}(nil), parentParameters...), idParameter),
+				"get": map[string]interface{}{
+					"tags":        []string{model.Name},
+					"operationId": operationID("versions", model.Name),
+					"description": "Versions of the record, the most recent first.",
+					"parameters":  paginationParameters,
+					"responses": operationResponses("Versions", "200", "The versions of the record.", jsonSchema{
+						"type":  "array",
+						"items": versionSchema(responses, modelSchema),
+					}, extraCodes...),
+				},
+			}
+			paths[model.Path+"/{"+model.IDParam+"}/_versions"] = versionsPath
+		}
+		if model.Exposes(OperationRevert) {
+			revertPath := map[string]interface{}{
+				"parameters": append(append([]interface{}(nil), parentParameters...), idParameter, versionParameter),
+				"post": map[string]interface{}{
+					"tags":        []string{model.Name},
+					"operationId": operationID("revert", model.Name),
+					"description": "Restores the record to one of its versions, recreating it if it was deleted.",
+					"responses":   operationResponses("Revert", "200", "The restored record.", modelSchema, extraCodes...),
+				},
+			}
+			paths[model.Path+"/{"+model.IDParam+"}/_revert/{version}"] = revertPath
+		}
+	}
+
+	// Request definitions never override the response ones, as both describe the same named types.
+	schemas := map[string]interface{}{}
+	for name, schema := range requests.definitions {
+		schemas[name] = schema
+	}
+	for name, schema := range responses.definitions {
+		schemas[name] = schema
+	}
+
+	document := map[string]interface{}{
+		"openapi": OpenAPIVersion,
+		"info":    info,
+		"paths":   paths,
+		"components": map[string]interface{}{
+			"schemas": schemas,
+		},
+	}
+	if len(tags) > 0 {
+		document["tags"] = tags
+	}
+	return document
+}
+
+// listParameters documents the filter and pagination query parameters accepted by GetAll.
+func listParameters(builder *schemaBuilder, filterType reflect.Type) []interface{} {
+	var parameters []interface{}
+
+	if filterType != nil {
+		for i := 0; i < filterType.NumField(); i++ {
+			field := filterType.Field(i)
+			name, ok := field.Tag.Lookup("url")
+			if !ok {
+				name = field.Name
+			}
+			parameters = append(parameters, map[string]interface{}{
+				"name":   strings.Split(name, ",")[0],
+				"in":     "query",
+				"schema": builder.schemaOf(field.Type),
+			})
+		}
+	}
+
+	parameters = append(parameters, paginationParameters...)
+	return append(parameters, includeParameter)
+}
+
+// paginationParameters documents the limit and offset query parameters accepted by GetAll, Audit and Versions.
+var paginationParameters = []interface{}{
+	map[string]interface{}{
+		"name":        "limit",
+		"in":          "query",
+		"description": "Maximum number of records to return, 0 returns every record.",
+		"schema":      jsonSchema{"type": "integer", "minimum": 0},
+	},
+	map[string]interface{}{
+		"name":        "offset",
+		"in":          "query",
+		"description": "Number of records to skip.",
+		"schema":      jsonSchema{"type": "integer", "minimum": 0},
+	},
+}
+
+// auditEntrySchema registers the schemas of audit.Entry and audit.Change as AuditEntry and AuditChange,
+// names that models are unlikely to take, and returns a reference to AuditEntry.
+func auditEntrySchema(builder *schemaBuilder) jsonSchema {
+	if _, ok := builder.definitions["AuditEntry"]; !ok {
+		// The changes are described by AuditChange, not by the Change definition built along with the entry.
+		_, hasChange := builder.definitions["Change"]
+		entry := builder.structSchema(reflect.TypeOf(audit.Entry{}))
+		if !hasChange {
+			delete(builder.definitions, "Change")
+		}
+		entry["properties"].(jsonSchema)["changes"] = jsonSchema{
+			"type":  "array",
+			"items": jsonSchema{"$ref": builder.refPrefix + "AuditChange"},
+		}
+		builder.definitions["AuditEntry"] = entry
+		builder.definitions["AuditChange"] = builder.structSchema(reflect.TypeOf(audit.Change{}))
+	}
+	return jsonSchema{"$ref": builder.refPrefix + "AuditEntry"}
+}
+
+// versionSchema registers the schema of history.Version as RecordVersion and returns the schema of
+// the versions of a model, whose snapshots are described by modelSchema.
+func versionSchema(builder *schemaBuilder, modelSchema jsonSchema) jsonSchema {
+	if _, ok := builder.definitions["RecordVersion"]; !ok {
+		version := builder.structSchema(reflect.TypeOf(history.Version{}))
+		version["properties"].(jsonSchema)["snapshot"] = jsonSchema{
+			"description": "The record as saved, null for the version recording its deletion.",
+		}
+		builder.definitions["RecordVersion"] = version
+	}
+	return jsonSchema{
+		"allOf": []interface{}{
+			jsonSchema{"$ref": builder.refPrefix + "RecordVersion"},
+			jsonSchema{"properties": jsonSchema{"snapshot": jsonSchema{"oneOf": []interface{}{modelSchema, jsonSchema{"type": "null"}}}}},
+		},
+	}
+}
+
+// getParameters documents the query parameters accepted by GetByID, as_of for the models keeping versions.
+func getParameters(model ModelDescription) []interface{} {
+	if model.Exposes(OperationVersions) {
+		return []interface{}{includeParameter, asOfParameter}
+	}
+	return []interface{}{includeParameter}
+}
+
+// asOfParameter documents the as_of query parameter accepted by GetByID when the model keeps versions.
+var asOfParameter = map[string]interface{}{
+	"name":        "as_of",
+	"in":          "query",
+	"description": "Returns the record as it was at this time, from its versions, with the associations saved with it. Cannot be combined with include.",
+	"schema":      jsonSchema{"type": "string", "format": "date-time"},
+}
+
+// versionParameter documents the version path parameter of the revert route.
+var versionParameter = map[string]interface{}{
+	"name":     "version",
+	"in":       "path",
+	"required": true,
+	"schema":   jsonSchema{"type": "integer", "minimum": 1},
+}
+
+// includeParameter documents the include query parameter accepted by GetAll and GetByID.
+var includeParameter = map[string]interface{}{
+	"name":        "include",
+	"in":          "query",
+	"description": "Comma-separated association paths to preload, such as author,comments.author.",
+	"schema":      jsonSchema{"type": "string"},
+}
+
+func requestBody(builder *schemaBuilder, paramsType reflect.Type) map[string]interface{} {
+	schema := jsonSchema{"type": "object"}
+	if paramsType != nil {
+		schema = builder.schemaOf(paramsType)
+	}
+
+	return map[string]interface{}{
+		"required": true,
+		"content": map[string]interface{}{
+			"application/json": map[string]interface{}{"schema": schema},
+		},
+	}
+}
+
+// operationResponses documents the success response of an operation and its error responses,
+// grouping the error codes the operation may emit, and the extra codes of its route, by HTTP status.
+func operationResponses(operation, successStatus, description string, schema jsonSchema, extraCodes ...string) map[string]interface{} {
+	success := map[string]interface{}{"description": description}
+	if schema != nil {
+		success["content"] = map[string]interface{}{
+			"application/json": map[string]interface{}{"schema": schema},
+		}
+	}
+	responses := map[string]interface{}{successStatus: success}
+
+	codesByStatus := map[int][]string{}
+	for _, code := range operationErrorCodes[operation] {
+		status := errorCodeStatus(code)
+		codesByStatus[status] = append(codesByStatus[status], code)
+	}
+	for _, code := range extraCodes {
+		status := errorCodeStatus(code)
+		if !containsString(codesByStatus[status], code) {
+			codesByStatus[status] = append(codesByStatus[status], code)
+		}
+	}
+	for status, codes := range codesByStatus {
+		responses[fmt.Sprint(status)] = map[string]interface{}{
+			"description": http.StatusText(status),
+			"content": map[string]interface{}{
+				"application/json": map[string]interface{}{"schema": errorSchema(codes)},
+			},
+		}
+	}
+
+	return responses
+}
+
+func errorSchema(codes []string) jsonSchema {
+	return jsonSchema{
+		"type": "object",
+		"properties": jsonSchema{
+			"error":      jsonSchema{"type": "string"},
+			"error_code": jsonSchema{"type": "string", "enum": codes},
+		},
+		"required": []string{"error", "error_code"},
+	}
+}
+
+// errorCodeStatus returns the HTTP status the service responds with for an error code.
+func errorCodeStatus(code string) int {
+	switch code {
+	case ErrorCodeEncodeResponse, ErrorCodeFetchParent:
+		return iris.StatusInternalServerError
+	case ErrorCodeParentNotFound:
+		return iris.StatusNotFound
+	default:
+		return iris.StatusBadRequest
+	}
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func operationID(action, modelName string) string {
+	return strcase.ToLowerCamel(action + "_" + modelName)
+}
+
+// The documentation UIs are loaded from unpkg at exact versions, a floating version ("@5" or "latest")
+// would serve whatever is published next to every client of the API.
+const (
+	swaggerUIVersion = "5.17.14"
+	redocVersion     = "2.1.5"
+)
+
+const swaggerUIPage = `<!DOCTYPE html>
+<html lang="en">
+<head>
+  <meta charset="utf-8">
+  <title>%[1]s</title>
+  <link rel="stylesheet" href="https://unpkg.com/swagger-ui-dist@` + swaggerUIVersion + `/swagger-ui.css" crossorigin="anonymous">
+</head>
+<body>
+  <div id="swagger-ui"></div>
+  <script src="https://unpkg.com/swagger-ui-dist@` + swaggerUIVersion + `/swagger-ui-bundle.js" crossorigin="anonymous"></script>
+  <script>window.ui = SwaggerUIBundle({ url: "%[2]s", dom_id: "#swagger-ui" });</script>
+</body>
+</html>
+`
+
+const redocUIPage = `<!DOCTYPE html>
+<html lang="en">
+<head>
+  <meta charset="utf-8">
+  <title>%[1]s</title>
+</head>
+<body>
+  <redoc spec-url="%[2]s"></redoc>
+  <script src="https://unpkg.com/redoc@` + redocVersion + `/bundles/redoc.standalone.js" crossorigin="anonymous"></script>
+</body>
+</html>
+`
@@ -0,0 +1,178 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/MuhmdHsn313/origin/audit"
+	"github.com/MuhmdHsn313/origin/history"
+	"github.com/MuhmdHsn313/origin/orm"
+	"github.com/kataras/iris/v12"
+)
+
+// update rewrites the golden files of the generators with their current output: go test ./service -update
+var update = flag.Bool("update", false, "update the golden files of the generators")
+
+// GoldenArticle has a content collection, fields of every visibility and validation rules, to cover the
+// generators. It is exported so that the generated TypeScript names are those of an application model.
+type GoldenArticle struct {
+	orm.Model
+
+	Contents []GoldenArticleContent `json:"contents" gorm:"foreignKey:ArticleID"`
+	Slug     string                 `json:"slug" validate:"required,min=3,max=64"`
+	Status   string                 `json:"status" validate:"oneof=draft published"`
+	Rating   int                    `json:"rating" validate:"gte=1,lte=5"`
+	Views    int                    `json:"views" origin:"readonly"`
+	Password string                 `json:"password" origin:"writeonly"`
+	Notes    string                 `json:"notes" origin:"hidden"`
+}
+
+type GoldenArticleContent struct {
+	orm.ContentModel
+
+	Title     string `json:"title" validate:"required,max=120"`
+	ArticleID uint   `json:"article_id" gorm:"primaryKey"`
+}
+
+// useRegistry replaces the registry of the models with an empty one for the duration of the test,
+// so that the documents generated by the test only describe the models it registers.
+func useRegistry(t *testing.T) {
+	t.Helper()
+	registry := defaultRegistry
+	defaultRegistry = &modelRegistry{}
+	t.Cleanup(func() { defaultRegistry = registry })
+}
+
+// registerGoldenArticle registers the routes of GoldenArticle under /api in a new registry.
+func registerGoldenArticle(t *testing.T) {
+	t.Helper()
+	useRegistry(t)
+	RegisterHandler[GoldenArticle](iris.New().Party("/api"), NewModelService[GoldenArticle](CreateEngine[GoldenArticle](), nil))
+}
+
+// assertGolden compares got with the golden file testdata/name, rewriting it instead when -update is set.
+func assertGolden(t *testing.T, name string, got []byte) {
+	t.Helper()
+	path := filepath.Join("testdata", name)
+	if *update {
+		if err := os.MkdirAll("testdata", 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, got, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("%s is out of date, run go test ./service -update and review the diff:\n%s", path, got)
+	}
+}
+
+// marshalGolden encodes v as indented JSON, with the keys of its maps sorted, for a golden file.
+func marshalGolden(t *testing.T, v interface{}) []byte {
+	t.Helper()
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return append(data, '\n')
+}
+
+func TestBuildOpenAPI(t *testing.T) {
+	registerGoldenArticle(t)
+	assertGolden(t, "openapi.golden.json", marshalGolden(t, BuildOpenAPI(OpenAPIOptions{Title: "Golden API"})))
+}
+
+// goldenHistory serves neither audit entries nor versions, it only enables the routes of WithAuditLog and WithHistory.
+type goldenHistory struct{}
+
+func (goldenHistory) Entries(context.Context, string, string, int, int) ([]audit.Entry, error) {
+	return nil, nil
+}
+
+func (goldenHistory) Versions(context.Context, string, string, int, int) ([]history.Version, error) {
+	return nil, nil
+}
+
+func (goldenHistory) Version(context.Context, string, string, int) (history.Version, error) {
+	return history.Version{}, history.ErrVersionNotFound
+}
+
+func (goldenHistory) AsOf(context.Context, string, string, time.Time) (history.Version, error) {
+	return history.Version{}, history.ErrVersionNotFound
+}
+
+// TestBuildOpenAPIOptionalOperations checks that the audit and version routes, and their schemas, are only
+// documented for the models serving them.
+func TestBuildOpenAPIOptionalOperations(t *testing.T) {
+	useRegistry(t)
+	api := iris.New().Party("/api")
+	RegisterHandler[GoldenArticle](api, NewModelService[GoldenArticle](CreateEngine[GoldenArticle](), nil,
+		WithAuditLog(goldenHistory{}), WithHistory(goldenHistory{})))
+	RegisterHandler[includeAuthor](api, NewModelService[includeAuthor](CreateEngine[includeAuthor](), nil))
+
+	document := BuildOpenAPI(OpenAPIOptions{})
+	paths := document["paths"].(map[string]interface{})
+	for _, path := range []string{"/api/golden_article/{id}/_audit", "/api/golden_article/{id}/_versions", "/api/golden_article/{id}/_revert/{version}"} {
+		if _, ok := paths[path]; !ok {
+			t.Errorf("%s is not documented", path)
+		}
+	}
+	for _, path := range []string{"/api/include_author/{id}/_audit", "/api/include_author/{id}/_versions", "/api/include_author/{id}/_revert/{version}"} {
+		if _, ok := paths[path]; ok {
+			t.Errorf("%s is documented, but not served", path)
+		}
+	}
+
+	schemas := document["components"].(map[string]interface{})["schemas"].(map[string]interface{})
+	for _, name := range []string{"AuditEntry", "AuditChange", "RecordVersion"} {
+		if _, ok := schemas[name]; !ok {
+			t.Errorf("schema %s is missing", name)
+		}
+	}
+	if _, ok := schemas["Change"]; ok {
+		t.Error("the changes of the audit entries are described by Change as well as AuditChange")
+	}
+}
+
+func TestRegisterOpenAPIServesPinnedUIs(t *testing.T) {
+	tests := []struct {
+		ui, asset string
+	}{
+		{OpenAPIUISwagger, "swagger-ui-dist@" + swaggerUIVersion + "/swagger-ui-bundle.js"},
+		{OpenAPIUIRedoc, "redoc@" + redocVersion + "/bundles/redoc.standalone.js"},
+	}
+	for _, test := range tests {
+		t.Run(test.ui, func(t *testing.T) {
+			useRegistry(t)
+			app := iris.New()
+			RegisterOpenAPI(app.Party("/api"), OpenAPIOptions{Title: "Blog <API>", UI: test.ui})
+			if err := app.Build(); err != nil {
+				t.Fatal(err)
+			}
+
+			page := mustServe(t, app, http.MethodGet, "/api/docs", "", http.StatusOK).(string)
+			for _, want := range []string{test.asset, "/api/openapi.json", "Blog &lt;API&gt;"} {
+				if !strings.Contains(page, want) {
+					t.Errorf("the page does not hold %q:\n%s", want, page)
+				}
+			}
+			if strings.Contains(page, "latest") || strings.Contains(page, "@5/") {
+				t.Errorf("the page loads a floating version of its assets:\n%s", page)
+			}
+		})
+	}
+}
@@ -0,0 +1,118 @@
+package service
+
+import (
+	"github.com/MuhmdHsn313/origin/repository"
+	"github.com/kataras/iris/v12"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// RoleResolver returns the role of the caller of the current request.
+// The role decides which `origin:"writeonly"` and `origin:"hidden"` fields are visible in responses.
+type RoleResolver func(ctx iris.Context) string
+
+// ServiceOption configures optional behaviour of a model service created by NewModelService.
+type ServiceOption func(options *serviceOptions)
+
+type serviceOptions struct {
+	roleResolver RoleResolver
+	// includes whitelists the paths accepted by ?include=, see isIncludeAllowed. Other paths are denied,
+	// except the content collections, which are always allowed.
+	includes []string
+	// maxIncludeDepth limits the number of nested associations in a single include path.
+	maxIncludeDepth int
+	// keyField is the field matched by the "id" path parameter, "ID" unless set by RegisterChildHandler.
+	keyField string
+	// defaultLimit is the page size of list requests without a limit, 0 returning every record.
+	defaultLimit int
+	// maxLimit caps the limit of list requests, 0 leaving it unbounded.
+	maxLimit int
+	// tracerProvider creates the spans of the operations, the global provider when nil.
+	tracerProvider trace.TracerProvider
+	// auditLog serves the audit entries of the records, the audit route is not registered when nil.
+	auditLog AuditLog
+	// history serves the versions of the records, the versions and revert routes are not registered when nil.
+	history History
+	// events are notified of the mutations made by the service once committed, none when nil.
+	events repository.CommitObserver
+}
+
+// DefaultMaxIncludeDepth is the deepest include path accepted by default, as in "comments.author".
+const DefaultMaxIncludeDepth = 2
+
+func defaultServiceOptions() serviceOptions {
+	return serviceOptions{
+		roleResolver:    func(ctx iris.Context) string { return "" },
+		maxIncludeDepth: DefaultMaxIncludeDepth,
+		keyField:        "ID",
+	}
+}
+
+// WithRoleResolver sets the function used to determine the caller's role.
+// Without it every caller is anonymous and only public fields are returned.
+func WithRoleResolver(resolver RoleResolver) ServiceOption {
+	return func(options *serviceOptions) {
+		if resolver != nil {
+			options.roleResolver = resolver
+		}
+	}
+}
+
+// WithIncludes whitelists the association paths clients may preload with the include query
+// parameter, such as "author" or "comments.author". The parent segments of a listed path
+// (e.g. "comments") are allowed too. Without it, only the content collections may be included.
+func WithIncludes(paths ...string) ServiceOption {
+	return func(options *serviceOptions) {
+		options.includes = append(options.includes, paths...)
+	}
+}
+
+// WithMaxIncludeDepth sets the number of nested associations allowed in a single include path,
+// DefaultMaxIncludeDepth by default.
+func WithMaxIncludeDepth(depth int) ServiceOption {
+	return func(options *serviceOptions) {
+		if depth > 0 {
+			options.maxIncludeDepth = depth
+		}
+	}
+}
+
+// WithPagination bounds the pages returned by list requests. Requests without a limit query
+// parameter get defaultLimit records, and larger limits than maxLimit are lowered to it.
+// A limit of 0 (the default of both) returns every record.
+func WithPagination(defaultLimit, maxLimit int) ServiceOption {
+	return func(options *serviceOptions) {
+		if defaultLimit >= 0 {
+			options.defaultLimit = defaultLimit
+		}
+		if maxLimit >= 0 {
+			options.maxLimit = maxLimit
+		}
+	}
+}
+
+// WithTracerProvider creates the spans of the operations of the service with provider,
+// instead of the global provider of otel.GetTracerProvider.
+func WithTracerProvider(provider trace.TracerProvider) ServiceOption {
+	return func(options *serviceOptions) {
+		options.tracerProvider = provider
+	}
+}
+
+// WithEvents emits the events of the creations, updates, reverts and deletions made by the service to bus,
+// such as an events.Bus, once they are committed. The mutations rolled back emit nothing, and those made
+// through the repository outside of the service are not emitted.
+func WithEvents(bus repository.CommitObserver) ServiceOption {
+	return func(options *serviceOptions) {
+		options.events = bus
+	}
+}
+
+// withKeyField makes the service look records up by another field than their ID, such as
+// the LanguageID of the contents of a parent registered through RegisterChildHandler.
+func withKeyField(field string) ServiceOption {
+	return func(options *serviceOptions) {
+		if field != "" {
+			options.keyField = field
+		}
+	}
+}
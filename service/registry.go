@@ -0,0 +1,128 @@
+package service
+
+import (
+	"reflect"
+	"sync"
+)
+
+// ModelDescription describes a model registered through RegisterHandler.
+// It carries everything needed to document the routes of the model, such as
+// the generated parameter types of its engine.
+type ModelDescription struct {
+	// Name is the snake_case name of the model, also used as its route segment (e.g. "blog").
+	Name string
+	// Path is the full relative path of the model routes (e.g. "/api/blog").
+	Path string
+	// ModelType is the struct type of the model.
+	ModelType reflect.Type
+	// CreateParamsType is the struct type generated by Engine.GenerateCreateParameters.
+	CreateParamsType reflect.Type
+	// UpdateParamsType is the struct type generated by Engine.GenerateUpdateParameters.
+	UpdateParamsType reflect.Type
+	// FilterParamsType is the struct type generated by Engine.GenerateFilterParameters.
+	FilterParamsType reflect.Type
+	// KeyType is the type of the "id" path parameter: the ID of the model, or the key field
+	// of a child model registered through RegisterChildHandler (e.g. the LanguageID of contents).
+	KeyType reflect.Type
+	// IDParam is the name of the path parameter identifying a record, such as "id".
+	IDParam string
+	// Operations lists the operations exposed for the model.
+	Operations []Operation
+	// ParentParams are the path parameters identifying the parents of a child model, outermost first
+	// (e.g. "blog_id" in "/api/blog/{blog_id}/comments"). It is empty for models registered through RegisterHandler.
+	ParentParams []PathParameter
+
+	// scopeToParent scopes the repository of a child model to the parent of a request, see RegisterChildHandler.
+	// It is nil for models registered through RegisterHandler.
+	scopeToParent interface{}
+}
+
+// Exposes reports whether the operation is served for the model.
+func (model ModelDescription) Exposes(operation Operation) bool {
+	for _, exposed := range model.Operations {
+		if exposed == operation {
+			return true
+		}
+	}
+	return false
+}
+
+// PathParameter describes a path parameter of the routes of a model.
+type PathParameter struct {
+	// Name of the parameter in the route, such as "blog_id".
+	Name string
+	// Type of the key held by the parameter.
+	Type reflect.Type
+}
+
+// modelRegistry keeps the descriptions of all registered models in registration order.
+type modelRegistry struct {
+	mu     sync.RWMutex
+	models []ModelDescription
+}
+
+var defaultRegistry = &modelRegistry{}
+
+func (registry *modelRegistry) add(description ModelDescription) {
+	registry.mu.Lock()
+	defer registry.mu.Unlock()
+	registry.models = append(registry.models, description)
+}
+
+// find returns the description of the model registered under path.
+func (registry *modelRegistry) find(path string) (ModelDescription, bool) {
+	registry.mu.RLock()
+	defer registry.mu.RUnlock()
+	for _, model := range registry.models {
+		if model.Path == path {
+			return model, true
+		}
+	}
+	return ModelDescription{}, false
+}
+
+func (registry *modelRegistry) list() []ModelDescription {
+	registry.mu.RLock()
+	defer registry.mu.RUnlock()
+	return append([]ModelDescription(nil), registry.models...)
+}
+
+// RegisteredModels returns the descriptions of every model registered through RegisterHandler,
+// in registration order.
+func RegisteredModels() []ModelDescription {
+	return defaultRegistry.list()
+}
+
+// describeModel builds the description of model T, looked up by keyField, from the engine used to serve it.
+// Parameter types that cannot be generated are left nil.
+func describeModel[T any](name, path, keyField string, eng Engine[T]) ModelDescription {
+	modelType := derefType(reflect.TypeOf((*T)(nil)))
+	description := ModelDescription{
+		Name:       name,
+		Path:       path,
+		ModelType:  modelType,
+		KeyType:    keyFieldType(modelType, keyField),
+		IDParam:    DefaultIDParam,
+		Operations: AllOperations,
+	}
+
+	if params, err := eng.GenerateCreateParameters(); err == nil {
+		description.CreateParamsType = derefType(reflect.TypeOf(params))
+	}
+	if params, err := eng.GenerateUpdateParameters(); err == nil {
+		description.UpdateParamsType = derefType(reflect.TypeOf(params))
+	}
+	if params, err := eng.GenerateFilterParameters(); err == nil {
+		description.FilterParamsType = derefType(reflect.TypeOf(params))
+	}
+
+	return description
+}
+
+// keyFieldType returns the type of the key field of a model, falling back to uint.
+func keyFieldType(modelType reflect.Type, keyField string) reflect.Type {
+	if field, ok := modelType.FieldByName(keyField); ok {
+		return field.Type
+	}
+	return reflect.TypeOf(uint(0))
+}
@@ -0,0 +1,273 @@
+package service
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/iancoleman/strcase"
+	"github.com/jinzhu/inflection"
+	"github.com/kataras/iris/v12"
+	"github.com/kataras/iris/v12/core/router"
+)
+
+// Operation identifies one of the routes registered for a model.
+type Operation string
+
+// Operations of a model service, each served by its own route.
+const (
+	// OperationList is GET /{model}, served by Service.GetAll.
+	OperationList Operation = "list"
+	// OperationGet is GET /{model}/{id}, served by Service.GetByID.
+	OperationGet Operation = "get"
+	// OperationCreate is POST /{model}, served by Service.Create.
+	OperationCreate Operation = "create"
+	// OperationUpdate is PATCH /{model}/{id}, served by Service.UpdatePatch.
+	OperationUpdate Operation = "update"
+	// OperationDelete is DELETE /{model}/{id}, served by Service.Delete.
+	OperationDelete Operation = "delete"
+	// OperationSchema is GET /{model}/_schema, served by SchemaService.Schema or from the engine of the model.
+	OperationSchema Operation = "schema"
+	// OperationAudit is GET /{model}/{id}/_audit, served by AuditService.Audit.
+	// It is only exposed by the services given an audit log, see WithAuditLog.
+	OperationAudit Operation = "audit"
+	// OperationVersions is GET /{model}/{id}/_versions, served by HistoryService.Versions.
+	// It is only exposed by the services given a history, see WithHistory.
+	OperationVersions Operation = "versions"
+	// OperationRevert is POST /{model}/{id}/_revert/{version}, served by HistoryService.Revert.
+	// It is only exposed by the services given a history, see WithHistory.
+	OperationRevert Operation = "revert"
+)
+
+// AllOperations lists every operation, it is the default of RegisterOptions.Operations.
+var AllOperations = []Operation{OperationList, OperationGet, OperationCreate, OperationUpdate, OperationDelete, OperationSchema, OperationAudit, OperationVersions, OperationRevert}
+
+// ReadOnlyOperations lists the operations that do not modify records.
+var ReadOnlyOperations = []Operation{OperationList, OperationGet, OperationSchema, OperationAudit, OperationVersions}
+
+// optionalOperations lists the operations only exposed by the services configured to serve them.
+var optionalOperations = []Operation{OperationAudit, OperationVersions, OperationRevert}
+
+// operationServer is implemented by services that may not serve every optional operation,
+// so that the routes of the operations they do not serve are not registered.
+type operationServer interface {
+	serves(operation Operation) bool
+}
+
+// servedOperations returns a copy of operations without the optional operations that service does not serve.
+func servedOperations(service interface{}, operations []Operation) []Operation {
+	kept := make([]Operation, 0, len(operations))
+	for _, operation := range operations {
+		if isOptionalOperation(operation) && !servesOptionalOperation(service, operation) {
+			continue
+		}
+		kept = append(kept, operation)
+	}
+	return kept
+}
+
+// servesOptionalOperation reports whether service serves an optional operation: it implements the interface
+// of the operation (AuditService or HistoryService) and, when it is an operationServer, serves it.
+func servesOptionalOperation(service interface{}, operation Operation) bool {
+	switch operation {
+	case OperationAudit:
+		if _, ok := service.(AuditService); !ok {
+			return false
+		}
+	case OperationVersions, OperationRevert:
+		if _, ok := service.(HistoryService); !ok {
+			return false
+		}
+	}
+	server, ok := service.(operationServer)
+	return !ok || server.serves(operation)
+}
+
+// isOptionalOperation reports whether operation is one of optionalOperations.
+func isOptionalOperation(operation Operation) bool {
+	for _, optional := range optionalOperations {
+		if optional == operation {
+			return true
+		}
+	}
+	return false
+}
+
+// DefaultIDParam is the name of the path parameter identifying a record, as in /api/blog/{id}.
+const DefaultIDParam = "id"
+
+// idParamContextKey is the context value holding the ID parameter name of the current route,
+// set when RegisterOptions.IDParam is not DefaultIDParam.
+const idParamContextKey = "origin.id_param"
+
+// RegisterOptions configures the routes registered by RegisterHandler and RegisterChildHandler.
+// The zero value keeps the defaults: the snake_case model name as path, every operation and an {id} parameter.
+type RegisterOptions struct {
+	// Path is the route segment of the model, such as "articles" or "v1/articles".
+	// When set, it is used as is and Pluralize and KebabCase are ignored.
+	Path string
+	// Pluralize uses the plural of the model name as path, /blogs instead of /blog.
+	Pluralize bool
+	// KebabCase writes the derived path in kebab-case, /blog-post instead of /blog_post.
+	KebabCase bool
+	// Operations lists the operations exposed for the model, AllOperations when empty.
+	// Use ReadOnlyOperations to serve a model without letting clients modify it.
+	Operations []Operation
+	// Middleware holds extra handlers run before the handler of each operation, such as authorization checks.
+	Middleware map[Operation][]iris.Handler
+	// IDParam is the name of the path parameter identifying a record, DefaultIDParam when empty.
+	IDParam string
+}
+
+// withDefaults fills the empty options.
+func (options RegisterOptions) withDefaults() RegisterOptions {
+	if len(options.Operations) == 0 {
+		options.Operations = AllOperations
+	}
+	if options.IDParam == "" {
+		options.IDParam = DefaultIDParam
+	}
+	return options
+}
+
+// routeName returns the path of a model from its Go type name, following Pluralize and KebabCase.
+func (options RegisterOptions) routeName(typeName string) string {
+	if options.Path != "" {
+		return strings.Trim(options.Path, "/")
+	}
+
+	if options.Pluralize {
+		typeName = inflection.Plural(typeName)
+	}
+	if options.KebabCase {
+		return strcase.ToKebab(typeName)
+	}
+	return toSnakeCase(typeName)
+}
+
+// exposes reports whether the operation is served.
+func (options RegisterOptions) exposes(operation Operation) bool {
+	for _, exposed := range options.Operations {
+		if exposed == operation {
+			return true
+		}
+	}
+	return false
+}
+
+// registerRoutes registers the handlers of the exposed operations on party, each after its middleware.
+func (options RegisterOptions) registerRoutes(party router.Party, handlers map[Operation]iris.Handler) {
+	itemPath := "/{" + options.IDParam + "}"
+	routes := []struct {
+		operation Operation
+		method    string
+		path      string
+	}{
+		{OperationList, iris.MethodGet, "/"},
+		{OperationGet, iris.MethodGet, itemPath},
+		{OperationCreate, iris.MethodPost, "/"},
+		{OperationDelete, iris.MethodDelete, itemPath},
+		{OperationUpdate, iris.MethodPatch, itemPath},
+		{OperationSchema, iris.MethodGet, "/_schema"},
+		{OperationAudit, iris.MethodGet, itemPath + "/_audit"},
+		{OperationVersions, iris.MethodGet, itemPath + "/_versions"},
+		{OperationRevert, iris.MethodPost, itemPath + "/_revert/{version}"},
+	}
+
+	for _, route := range routes {
+		if !options.exposes(route.operation) {
+			continue
+		}
+
+		var chain []iris.Handler
+		if options.IDParam != DefaultIDParam {
+			idParam := options.IDParam
+			chain = append(chain, func(ctx iris.Context) {
+				ctx.Values().Set(idParamContextKey, idParam)
+				ctx.Next()
+			})
+		}
+		chain = append(chain, options.Middleware[route.operation]...)
+		chain = append(chain, handlers[route.operation])
+		party.Handle(route.method, route.path, chain...)
+	}
+}
+
+// idParam returns the name of the path parameter identifying the record of the current route.
+func idParam(ctx iris.Context) string {
+	return ctx.Values().GetStringDefault(idParamContextKey, DefaultIDParam)
+}
+
+// RouteLister is implemented by iris applications, which list the routes registered on them.
+type RouteLister interface {
+	GetRoutes() []*router.Route
+}
+
+// LookupRoute returns the model and the operation served by a route registered through RegisterHandler
+// or RegisterChildHandler, from its method and path template (such as "/api/blog/{id}").
+//
+// Returns:
+//   - The name of the model, the operation and true, or false for a route that serves no model.
+func LookupRoute(method, path string) (string, Operation, bool) {
+	for _, model := range RegisteredModels() {
+		itemPath := model.Path + "/{" + model.IDParam + "}"
+		var operation Operation
+		switch {
+		case path == model.Path && method == iris.MethodGet:
+			operation = OperationList
+		case path == model.Path && method == iris.MethodPost:
+			operation = OperationCreate
+		case path == itemPath && method == iris.MethodGet:
+			operation = OperationGet
+		case path == itemPath && method == iris.MethodPatch:
+			operation = OperationUpdate
+		case path == itemPath && method == iris.MethodDelete:
+			operation = OperationDelete
+		case path == model.Path+"/_schema" && method == iris.MethodGet:
+			operation = OperationSchema
+		case path == itemPath+"/_audit" && method == iris.MethodGet:
+			operation = OperationAudit
+		case path == itemPath+"/_versions" && method == iris.MethodGet:
+			operation = OperationVersions
+		case path == itemPath+"/_revert/{version}" && method == iris.MethodPost:
+			operation = OperationRevert
+		default:
+			continue
+		}
+
+		if model.Exposes(operation) {
+			return model.Name, operation, true
+		}
+	}
+	return "", "", false
+}
+
+// WriteRoutes writes a table of the routes of app, in registration order. Routes registered through
+// RegisterHandler and RegisterChildHandler are listed with the name of their model and their operation,
+// other routes with the name of their handler:
+//
+//	METHOD  PATH                                MODEL          OPERATION
+//	GET     /api/blog                           blog           list
+//	GET     /api/blog/{blog_id}/comments/{id}   blog_comments  get
+//	GET     /openapi.json                       -              github.com/MuhmdHsn313/origin/service.RegisterOpenAPI.func1
+//
+// The routes are known once registered, app does not need to be built or running.
+func WriteRoutes(w io.Writer, app RouteLister) error {
+	table := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(table, "METHOD\tPATH\tMODEL\tOPERATION")
+	for _, route := range app.GetRoutes() {
+		// Error handlers are registered as routes with a status code, they are not served by path.
+		if route.StatusCode != 0 {
+			continue
+		}
+
+		path := route.Tmpl().Src
+		if model, operation, ok := LookupRoute(route.Method, path); ok {
+			fmt.Fprintf(table, "%s\t%s\t%s\t%s\n", route.Method, path, model, operation)
+		} else {
+			fmt.Fprintf(table, "%s\t%s\t-\t%s\n", route.Method, path, route.MainHandlerName)
+		}
+	}
+	return table.Flush()
+}
@@ -0,0 +1,166 @@
+package service
+
+import (
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/MuhmdHsn313/origin/internal/testdb"
+	"github.com/MuhmdHsn313/origin/logging"
+	"github.com/MuhmdHsn313/origin/orm"
+	"github.com/MuhmdHsn313/origin/repository"
+	"github.com/kataras/iris/v12"
+)
+
+type BlogPost struct {
+	orm.Model
+
+	Title string `json:"title"`
+}
+
+// registeredRoutes returns the routes of app as "METHOD path", sorted.
+func registeredRoutes(app *iris.Application) []string {
+	var routes []string
+	for _, route := range app.GetRoutes() {
+		if route.StatusCode == 0 {
+			routes = append(routes, route.Method+" "+route.Tmpl().Src)
+		}
+	}
+	sort.Strings(routes)
+	return routes
+}
+
+func TestRegisterOptionsRoutes(t *testing.T) {
+	tests := []struct {
+		name    string
+		options []RegisterOptions
+		want    []string
+	}{
+		{
+			name: "defaults",
+			want: []string{
+				"DELETE /api/blog_post/{id}",
+				"GET /api/blog_post",
+				"GET /api/blog_post/_schema",
+				"GET /api/blog_post/{id}",
+				"PATCH /api/blog_post/{id}",
+				"POST /api/blog_post",
+			},
+		},
+		{
+			name:    "pluralize",
+			options: []RegisterOptions{{Pluralize: true, Operations: []Operation{OperationList}}},
+			want:    []string{"GET /api/blog_posts"},
+		},
+		{
+			name:    "kebab case",
+			options: []RegisterOptions{{KebabCase: true, Operations: []Operation{OperationList}}},
+			want:    []string{"GET /api/blog-post"},
+		},
+		{
+			name:    "plural kebab case",
+			options: []RegisterOptions{{Pluralize: true, KebabCase: true, Operations: []Operation{OperationList}}},
+			want:    []string{"GET /api/blog-posts"},
+		},
+		{
+			// The path is used as is, Pluralize and KebabCase are ignored.
+			name:    "path",
+			options: []RegisterOptions{{Path: "/v1/articles/", Pluralize: true, KebabCase: true, Operations: []Operation{OperationList}}},
+			want:    []string{"GET /api/v1/articles"},
+		},
+		{
+			name:    "read-only operations",
+			options: []RegisterOptions{{Operations: ReadOnlyOperations}},
+			want: []string{
+				"GET /api/blog_post",
+				"GET /api/blog_post/_schema",
+				"GET /api/blog_post/{id}",
+			},
+		},
+		{
+			// The optional operations are not registered for services that do not serve them.
+			name:    "unserved optional operations",
+			options: []RegisterOptions{{Operations: []Operation{OperationGet, OperationAudit, OperationVersions, OperationRevert}}},
+			want:    []string{"GET /api/blog_post/{id}"},
+		},
+		{
+			name:    "ID parameter",
+			options: []RegisterOptions{{IDParam: "post_id", Operations: []Operation{OperationGet, OperationUpdate, OperationDelete}}},
+			want: []string{
+				"DELETE /api/blog_post/{post_id}",
+				"GET /api/blog_post/{post_id}",
+				"PATCH /api/blog_post/{post_id}",
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			useRegistry(t)
+			app := iris.New()
+			RegisterHandler[BlogPost](app.Party("/api"), NewModelService[BlogPost](CreateEngine[BlogPost](), nil), test.options...)
+
+			if got := registeredRoutes(app); !reflect.DeepEqual(got, test.want) {
+				t.Errorf("registered %q, want %q", got, test.want)
+			}
+
+			// The registry describes the same routes, for the documents generated from it.
+			models := RegisteredModels()
+			if len(models) != 1 {
+				t.Fatalf("%d models are registered", len(models))
+			}
+			for _, route := range test.want {
+				method, path, _ := strings.Cut(route, " ")
+				if name, _, ok := LookupRoute(method, path); !ok || name != models[0].Name {
+					t.Errorf("the registry does not describe %s", route)
+				}
+			}
+		})
+	}
+}
+
+func TestRegisterOptionsServeRequests(t *testing.T) {
+	useRegistry(t)
+	db := testdb.Open(t, &BlogPost{})
+
+	// The middleware of the delete operation denies every request, the other operations have none.
+	var ran []Operation
+	deny := func(ctx iris.Context) {
+		ran = append(ran, OperationDelete)
+		ctx.StopWithStatus(http.StatusForbidden)
+	}
+	app := iris.New()
+	repo := repository.NewGenericRepository[BlogPost](db, logging.Nop())
+	RegisterHandler[BlogPost](app.Party("/api"), NewModelService[BlogPost](CreateEngine[BlogPost](), repo), RegisterOptions{
+		Pluralize:  true,
+		KebabCase:  true,
+		IDParam:    "post_id",
+		Middleware: map[Operation][]iris.Handler{OperationDelete: {deny}},
+	})
+	if err := app.Build(); err != nil {
+		t.Fatal(err)
+	}
+
+	mustServe(t, app, http.MethodPost, "/api/blog-posts", `{"title":"hello"}`, http.StatusCreated)
+
+	// The record is read through the custom ID parameter.
+	post := mustServe(t, app, http.MethodGet, "/api/blog-posts/1", "", http.StatusOK)
+	if title := post.(map[string]interface{})["title"]; title != "hello" {
+		t.Errorf("read title %v, want hello", title)
+	}
+	mustServe(t, app, http.MethodPatch, "/api/blog-posts/1", `{"title":"updated"}`, http.StatusOK)
+
+	mustServe(t, app, http.MethodDelete, "/api/blog-posts/1", "", http.StatusForbidden)
+	if !reflect.DeepEqual(ran, []Operation{OperationDelete}) {
+		t.Errorf("the middleware ran for %q, want only the delete", ran)
+	}
+	post = mustServe(t, app, http.MethodGet, "/api/blog-posts/1", "", http.StatusOK)
+	if title := post.(map[string]interface{})["title"]; title != "updated" {
+		t.Errorf("read title %v after the denied delete, want updated", title)
+	}
+
+	if code, _ := serve(t, app, http.MethodGet, "/api/blog_post/1", ""); code != http.StatusNotFound {
+		t.Errorf("the default path answered %d, want %d", code, http.StatusNotFound)
+	}
+}
@@ -0,0 +1,358 @@
+package service
+
+import (
+	"encoding"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/MuhmdHsn313/origin/orm"
+	"github.com/google/uuid"
+)
+
+// jsonSchema is a JSON Schema (draft 2020-12, as used by OpenAPI 3.1) object.
+type jsonSchema = map[string]interface{}
+
+// schemaBuilder converts Go types, including the parameter structs generated by the engine,
+// into JSON Schema objects. Named struct types are collected into definitions and referenced
+// through refPrefix, anonymous structs (like the generated parameters) are inlined.
+type schemaBuilder struct {
+	// definitions holds the schemas of named struct types, keyed by type name.
+	definitions map[string]jsonSchema
+	// refPrefix is prepended to type names when referencing definitions (e.g. "#/components/schemas/").
+	refPrefix string
+	// responseMode omits fields that are not readable by every caller and marks readonly fields.
+	responseMode bool
+}
+
+func newSchemaBuilder(refPrefix string, responseMode bool) *schemaBuilder {
+	return &schemaBuilder{
+		definitions:  make(map[string]jsonSchema),
+		refPrefix:    refPrefix,
+		responseMode: responseMode,
+	}
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+var uuidType = reflect.TypeOf(uuid.UUID{})
+
+var textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+
+// isTextType reports whether values of t are encoded as JSON strings through encoding.TextMarshaler,
+// as uuid.UUID keys are, whatever their underlying kind.
+func isTextType(t reflect.Type) bool {
+	return t.Implements(textMarshalerType) || reflect.PointerTo(t).Implements(textMarshalerType)
+}
+
+// schemaOf returns the JSON Schema describing values of type t.
+func (b *schemaBuilder) schemaOf(t reflect.Type) jsonSchema {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	if t == timeType {
+		return jsonSchema{"type": "string", "format": "date-time"}
+	}
+	if t == uuidType {
+		return jsonSchema{"type": "string", "format": "uuid"}
+	}
+	if isTextType(t) {
+		return jsonSchema{"type": "string"}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return jsonSchema{"type": "boolean"}
+	case reflect.Int, reflect.Int64:
+		return jsonSchema{"type": "integer", "format": "int64"}
+	case reflect.Int8, reflect.Int16, reflect.Int32:
+		return jsonSchema{"type": "integer", "format": "int32"}
+	case reflect.Uint, reflect.Uint64, reflect.Uintptr:
+		return jsonSchema{"type": "integer", "format": "int64", "minimum": 0}
+	case reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return jsonSchema{"type": "integer", "format": "int32", "minimum": 0}
+	case reflect.Float32:
+		return jsonSchema{"type": "number", "format": "float"}
+	case reflect.Float64:
+		return jsonSchema{"type": "number", "format": "double"}
+	case reflect.String:
+		return jsonSchema{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		// []byte is encoded as a base64 string by encoding/json.
+		if t.Elem().Kind() == reflect.Uint8 {
+			return jsonSchema{"type": "string", "contentEncoding": "base64"}
+		}
+		return jsonSchema{"type": "array", "items": b.schemaOf(t.Elem())}
+	case reflect.Map:
+		return jsonSchema{"type": "object", "additionalProperties": b.schemaOf(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return b.structSchema(t)
+		}
+		if _, ok := b.definitions[t.Name()]; !ok {
+			// Register a placeholder first so that recursive types terminate.
+			b.definitions[t.Name()] = jsonSchema{}
+			b.definitions[t.Name()] = b.structSchema(t)
+		}
+		return jsonSchema{"$ref": b.refPrefix + t.Name()}
+	default:
+		return jsonSchema{}
+	}
+}
+
+// structSchema builds an object schema from the exported fields of a struct,
+// flattening embedded structs the same way encoding/json does.
+func (b *schemaBuilder) structSchema(t reflect.Type) jsonSchema {
+	properties := jsonSchema{}
+	var required []string
+	b.collectProperties(t, properties, &required)
+
+	schema := jsonSchema{"type": "object", "properties": properties}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+	return schema
+}
+
+func (b *schemaBuilder) collectProperties(t reflect.Type, properties jsonSchema, required *[]string) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		name, tagged := jsonFieldName(field)
+		if name == "-" {
+			continue
+		}
+
+		// Query parameters use `url` tags instead of `json` tags.
+		if urlName, ok := field.Tag.Lookup("url"); ok && !tagged {
+			name = strings.Split(urlName, ",")[0]
+		}
+
+		fieldType := field.Type
+		for fieldType.Kind() == reflect.Ptr {
+			fieldType = fieldType.Elem()
+		}
+		if field.Anonymous && !tagged && fieldType.Kind() == reflect.Struct {
+			b.collectProperties(fieldType, properties, required)
+			continue
+		}
+
+		options := orm.ParseFieldOptions(field)
+		if b.responseMode && !options.IsPublic() {
+			continue
+		}
+
+		schema := b.schemaOf(field.Type)
+		if validateTag, ok := field.Tag.Lookup("validate"); ok {
+			schema = applyValidateRules(schema, fieldType, validateTag)
+			if hasValidateRule(validateTag, "required") {
+				*required = append(*required, name)
+			}
+		}
+		if b.responseMode && options.ReadOnly {
+			schema = withKeyword(schema, "readOnly", true)
+		}
+		if options.WriteOnly {
+			schema = withKeyword(schema, "writeOnly", true)
+		}
+		if description, ok := field.Tag.Lookup("description"); ok {
+			schema = withKeyword(schema, "description", description)
+		}
+
+		properties[name] = schema
+	}
+}
+
+// withKeyword adds a keyword to a schema. References are wrapped in allOf so that the
+// referenced definition itself is left untouched.
+func withKeyword(schema jsonSchema, keyword string, value interface{}) jsonSchema {
+	if _, isRef := schema["$ref"]; isRef {
+		schema = jsonSchema{"allOf": []interface{}{schema}}
+	}
+	schema[keyword] = value
+	return schema
+}
+
+// hasValidateRule reports whether a `validate` tag contains the given rule before any "dive".
+func hasValidateRule(tag, rule string) bool {
+	for _, part := range strings.Split(tag, ",") {
+		if part == "dive" {
+			return false
+		}
+		if part == rule {
+			return true
+		}
+	}
+	return false
+}
+
+// applyValidateRules maps go-playground/validator rules of a `validate` tag onto JSON Schema keywords.
+// Rules following "dive" apply to the items of a slice. Unknown rules are ignored.
+func applyValidateRules(schema jsonSchema, t reflect.Type, tag string) jsonSchema {
+	rules := strings.Split(tag, ",")
+	for i, rule := range rules {
+		if rule == "dive" {
+			if items, ok := schema["items"].(jsonSchema); ok && (t.Kind() == reflect.Slice || t.Kind() == reflect.Array) {
+				schema["items"] = applyValidateRules(items, derefType(t.Elem()), strings.Join(rules[i+1:], ","))
+			}
+			return schema
+		}
+
+		name, param, _ := strings.Cut(rule, "=")
+		switch name {
+		case "min", "max", "len", "gt", "gte", "lt", "lte":
+			applyBoundRule(schema, t, name, param)
+		case "oneof":
+			var enum []interface{}
+			for _, value := range strings.Fields(param) {
+				enum = append(enum, schemaValue(t, value))
+			}
+			schema = withKeyword(schema, "enum", enum)
+		case "eq":
+			schema = withKeyword(schema, "const", schemaValue(t, param))
+		case "email":
+			schema["format"] = "email"
+		case "url", "uri", "http_url":
+			schema["format"] = "uri"
+		case "uuid", "uuid3", "uuid4", "uuid5":
+			schema["format"] = "uuid"
+		case "hostname", "hostname_rfc1123":
+			schema["format"] = "hostname"
+		case "ipv4":
+			schema["format"] = "ipv4"
+		case "ipv6":
+			schema["format"] = "ipv6"
+		case "alpha":
+			schema["pattern"] = "^[a-zA-Z]+$"
+		case "alphanum":
+			schema["pattern"] = "^[a-zA-Z0-9]+$"
+		case "numeric":
+			schema["pattern"] = "^[-+]?[0-9]+(?:\\.[0-9]+)?$"
+		case "lowercase":
+			schema["pattern"] = "^[^A-Z]*$"
+		case "uppercase":
+			schema["pattern"] = "^[^a-z]*$"
+		}
+	}
+	return schema
+}
+
+// applyBoundRule translates the size rules of the validator into the matching JSON Schema keyword,
+// depending on whether the field is a string, a collection or a number.
+func applyBoundRule(schema jsonSchema, t reflect.Type, rule, param string) {
+	bound, err := strconv.ParseFloat(param, 64)
+	if err != nil {
+		return
+	}
+
+	var minKey, maxKey string
+	switch t.Kind() {
+	case reflect.String:
+		minKey, maxKey = "minLength", "maxLength"
+	case reflect.Slice, reflect.Array:
+		minKey, maxKey = "minItems", "maxItems"
+	case reflect.Map:
+		minKey, maxKey = "minProperties", "maxProperties"
+	default:
+		switch rule {
+		case "min", "gte":
+			schema["minimum"] = numberValue(bound)
+		case "max", "lte":
+			schema["maximum"] = numberValue(bound)
+		case "len":
+			schema["minimum"] = numberValue(bound)
+			schema["maximum"] = numberValue(bound)
+		case "gt":
+			schema["exclusiveMinimum"] = numberValue(bound)
+		case "lt":
+			schema["exclusiveMaximum"] = numberValue(bound)
+		}
+		return
+	}
+
+	// Lengths are integers, so the exclusive bounds are shifted by one.
+	switch rule {
+	case "min", "gte":
+		schema[minKey] = int(bound)
+	case "max", "lte":
+		schema[maxKey] = int(bound)
+	case "len":
+		schema[minKey] = int(bound)
+		schema[maxKey] = int(bound)
+	case "gt":
+		schema[minKey] = int(bound) + 1
+	case "lt":
+		schema[maxKey] = int(bound) - 1
+	}
+}
+
+// schemaValue converts a literal from a `validate` tag into a value of the field's JSON type.
+func schemaValue(t reflect.Type, literal string) interface{} {
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		if number, err := strconv.ParseFloat(literal, 64); err == nil {
+			return numberValue(number)
+		}
+	case reflect.Bool:
+		if value, err := strconv.ParseBool(literal); err == nil {
+			return value
+		}
+	}
+	return literal
+}
+
+// numberValue returns integral numbers as int64 so that they are encoded without a fraction.
+func numberValue(number float64) interface{} {
+	if number == float64(int64(number)) {
+		return int64(number)
+	}
+	return number
+}
+
+func derefType(t reflect.Type) reflect.Type {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t
+}
+
+// JSONSchemaDialect is the JSON Schema draft emitted by Engine.CreateSchema and Engine.UpdateSchema.
+const JSONSchemaDialect = "https://json-schema.org/draft/2020-12/schema"
+
+// ParametersSchema returns the JSON Schema (draft 2020-12) document of a parameters struct instance.
+// It lets engines other than the reflective one, such as generated engines, implement
+// Engine.CreateSchema and Engine.UpdateSchema.
+func ParametersSchema(params interface{}, title string) map[string]interface{} {
+	return documentSchema(params, title)
+}
+
+// documentSchema builds a standalone JSON Schema document for the given parameters instance,
+// with the named types it references collected under "$defs".
+func documentSchema(params interface{}, title string) map[string]interface{} {
+	builder := newSchemaBuilder("#/$defs/", false)
+
+	// The root is always inlined, even when the parameters are a named type (as with generated engines).
+	var schema jsonSchema
+	if rootType := derefType(reflect.TypeOf(params)); rootType.Kind() == reflect.Struct {
+		schema = builder.structSchema(rootType)
+	} else {
+		schema = builder.schemaOf(rootType)
+	}
+	schema["$schema"] = JSONSchemaDialect
+	schema["title"] = title
+	if len(builder.definitions) > 0 {
+		defs := map[string]interface{}{}
+		for name, definition := range builder.definitions {
+			defs[name] = definition
+		}
+		schema["$defs"] = defs
+	}
+	return schema
+}
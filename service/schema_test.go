@@ -0,0 +1,51 @@
+package service
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParametersSchema(t *testing.T) {
+	eng := CreateEngine[GoldenArticle]()
+
+	createSchema, err := eng.CreateSchema()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertGolden(t, "create_schema.golden.json", marshalGolden(t, createSchema))
+
+	updateSchema, err := eng.UpdateSchema()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertGolden(t, "update_schema.golden.json", marshalGolden(t, updateSchema))
+}
+
+// TestAssociationSchemasDocumentReferencesByID checks that the schemas describe how the items of associations
+// reference existing records, as objects holding their ID.
+func TestAssociationSchemasDocumentReferencesByID(t *testing.T) {
+	eng := CreateEngine[includePost]()
+	createSchema, err := eng.CreateSchema()
+	if err != nil {
+		t.Fatal(err)
+	}
+	updateSchema, err := eng.UpdateSchema()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	createProperties := createSchema["properties"].(jsonSchema)
+	updateProperties := updateSchema["properties"].(jsonSchema)
+	items := map[string]jsonSchema{
+		"created author":    createProperties["author"].(jsonSchema),
+		"created comments":  createProperties["comments"].(jsonSchema)["items"].(jsonSchema),
+		"updated author":    updateProperties["author"].(jsonSchema),
+		"appended comments": updateProperties["comments"].(jsonSchema)["properties"].(jsonSchema)["append"].(jsonSchema)["items"].(jsonSchema),
+	}
+	for name, item := range items {
+		id, _ := item["properties"].(jsonSchema)["id"].(jsonSchema)
+		if description, _ := id["description"].(string); !strings.Contains(description, `{"id": 1}`) {
+			t.Errorf("the id of the %s is %v, want it described as referenced by an object", name, id)
+		}
+	}
+}
@@ -1,6 +1,13 @@
 package service
 
 import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/MuhmdHsn313/origin/logging"
+	"github.com/MuhmdHsn313/origin/orm"
 	"github.com/MuhmdHsn313/origin/repository"
 	"github.com/kataras/iris/v12"
 )
@@ -20,83 +27,352 @@ type Service[T any] interface {
 	Delete(ctx iris.Context)
 }
 
+// SchemaService is implemented by the services serving the JSON Schema of their parameters themselves,
+// at GET /{model}/_schema (see OperationSchema). The schema of the other services is served from their
+// engine, see RegisterHandler.
+type SchemaService interface {
+	// Schema returns the JSON Schema of the create and update parameters.
+	Schema(ctx iris.Context)
+}
+
+// AuditService is implemented by the services serving the audit entries of their records, at
+// GET /{model}/{id}/_audit (see OperationAudit). The route is not registered for the other services.
+type AuditService interface {
+	// Audit returns the audit entries of a model instance identified by id, the most recent first.
+	Audit(ctx iris.Context)
+}
+
+// HistoryService is implemented by the services serving the versions of their records, at
+// GET /{model}/{id}/_versions and POST /{model}/{id}/_revert/{version} (see OperationVersions and
+// OperationRevert). The routes are not registered for the other services.
+type HistoryService interface {
+	// Versions returns the versions of a model instance identified by id, the most recent first.
+	Versions(ctx iris.Context)
+	// Revert restores a model instance identified by id to one of its versions.
+	Revert(ctx iris.Context)
+}
+
 type modelService[T any] struct {
-	eng  Engine[T]
-	repo repository.Repository[T]
+	eng     Engine[T]
+	repo    repository.Repository[T]
+	options serviceOptions
 }
 
-func NewModelService[T any](eng Engine[T], repo repository.Repository[T]) Service[T] {
+func NewModelService[T any](eng Engine[T], repo repository.Repository[T], opts ...ServiceOption) Service[T] {
+	return newModelService[T](eng, repo, opts...)
+}
+
+// newModelService returns the service created by NewModelService, which also implements SchemaService,
+// AuditService and HistoryService.
+func newModelService[T any](eng Engine[T], repo repository.Repository[T], opts ...ServiceOption) *modelService[T] {
+	options := defaultServiceOptions()
+	for _, opt := range opts {
+		opt(&options)
+	}
+
 	return &modelService[T]{
-		eng:  eng,
-		repo: repo,
+		eng:     eng,
+		repo:    repo,
+		options: options,
+	}
+}
+
+// Engine returns the engine used by the service to generate and apply parameters.
+func (service modelService[T]) Engine() Engine[T] {
+	return service.eng
+}
+
+// repository returns the repository of the service running its queries within the context of the request,
+// which carries its trace and deadline, see repository.ContextRepository. The mutations it commits are
+// emitted to the events of the service, see WithEvents.
+func (service modelService[T]) repository(ctx iris.Context) repository.Repository[T] {
+	repo := repository.WithContext(service.repo, ctx.Request().Context())
+	if service.options.events != nil {
+		repo = repository.WithCommitObserver(repo, service.options.events)
+	}
+	return repo
+}
+
+// modelName returns the snake_case name of model T, as in "blog_post", the name of the model in its
+// repositories, see repository.ModelName.
+func modelName[T any]() string {
+	return repository.ModelName[T]()
+}
+
+// modelName returns the snake_case name of the model of the service, see modelName.
+func (service modelService[T]) modelName() string {
+	return modelName[T]()
+}
+
+// logModel returns the middleware adding the name of model T to the fields of the requests of its routes
+// (see logging.AddFields), so that the lines logged while serving them, such as those of the repositories
+// and of the middleware of the routes, hold it as "model". RegisterHandler and RegisterChildHandler use it
+// on the party of the model, whatever the implementation of its service.
+func logModel[T any]() iris.Handler {
+	fields := logging.Fields{"model": modelName[T]()}
+	return func(ctx iris.Context) {
+		logging.AddFields(ctx, fields)
+		ctx.Next()
+	}
+}
+
+// respond writes the given model(s) as JSON, stripping fields the caller's role is not allowed to read.
+func (service modelService[T]) respond(ctx iris.Context, statusCode int, v interface{}) {
+	body, err := VisibleFields(v, service.options.roleResolver(ctx))
+	if err != nil {
+		_ = ctx.StopWithJSON(
+			iris.StatusInternalServerError,
+			iris.Map{
+				"error":      err.Error(),
+				"error_code": ErrorCodeEncodeResponse,
+			},
+		)
+		return
+	}
+
+	_ = ctx.StopWithJSON(statusCode, body)
+}
+
+// limit returns the page size of a list request, the limit query parameter bounded by the pagination of the service.
+func (service modelService[T]) limit(ctx iris.Context) int {
+	limit := ctx.URLParamIntDefault("limit", service.options.defaultLimit)
+	if service.options.maxLimit > 0 && (limit <= 0 || limit > service.options.maxLimit) {
+		limit = service.options.maxLimit
 	}
+	return limit
+}
+
+// includeScope reads the comma-separated association paths of the include query parameter,
+// checks them against the whitelist and depth limit of the service, and returns the scope preloading them.
+func (service modelService[T]) includeScope(ctx iris.Context) (repository.ScopeWithLog, error) {
+	paths, err := service.parseIncludes(ctx.URLParam("include"))
+	if err != nil {
+		return nil, err
+	}
+	return repository.IncludeScope[T](paths...), nil
+}
+
+// parseIncludes splits the comma-separated association paths of an include query parameter, ignoring empty ones,
+// and checks them against the whitelist and depth limit of the service.
+func (service modelService[T]) parseIncludes(include string) ([]string, error) {
+	var paths []string
+	for _, path := range strings.Split(include, ",") {
+		path = strings.TrimSpace(path)
+		if path == "" {
+			continue
+		}
+
+		if depth := strings.Count(path, ".") + 1; depth > service.options.maxIncludeDepth {
+			return nil, fmt.Errorf("include %q is nested %d levels deep, at most %d are allowed", path, depth, service.options.maxIncludeDepth)
+		}
+		if !service.isIncludeAllowed(path) {
+			return nil, fmt.Errorf("include %q is not allowed", path)
+		}
+		paths = append(paths, path)
+	}
+	return paths, nil
+}
+
+// isIncludeAllowed reports whether an include path is whitelisted, directly or as the parent of a whitelisted path.
+// Paths are denied unless whitelisted with WithIncludes, except the content collections, which are preloaded with
+// their records anyway: "contents" is always allowed, and "comments.contents" when "comments" is.
+func (service modelService[T]) isIncludeAllowed(path string) bool {
+	parent := ""
+	if i := strings.LastIndex(path, "."); i >= 0 {
+		parent = path[:i]
+	}
+	if isContentPath(derefType(reflect.TypeOf((*T)(nil))), path) && (parent == "" || service.isIncludeAllowed(parent)) {
+		return true
+	}
+
+	for _, allowed := range service.options.includes {
+		if allowed == path || strings.HasPrefix(allowed, path+".") {
+			return true
+		}
+	}
+	return false
+}
+
+// isContentPath reports whether an include path of modelType, made of JSON or Go field names, leads to a
+// content collection (see orm.IsContentField).
+func isContentPath(modelType reflect.Type, path string) bool {
+	var field reflect.StructField
+	for _, segment := range strings.Split(path, ".") {
+		if modelType.Kind() != reflect.Struct {
+			return false
+		}
+		found := false
+		for _, candidate := range reflect.VisibleFields(modelType) {
+			name, _ := jsonFieldName(candidate)
+			if candidate.IsExported() && !candidate.Anonymous && (candidate.Name == segment || name == segment) {
+				field, found = candidate, true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+		modelType = derefType(field.Type)
+		if modelType.Kind() == reflect.Slice {
+			modelType = derefType(modelType.Elem())
+		}
+	}
+	return orm.IsContentField(field)
+}
+
+// parseID reads the ID path parameter of the route ("id" unless RegisterOptions.IDParam is set)
+// as a value of the service's key field, "ID" by default.
+func (service modelService[T]) parseID(ctx iris.Context) (interface{}, error) {
+	return repository.ParseKey[T](service.options.keyField, ctx.Params().Get(idParam(ctx)))
+}
+
+// recordID returns the ID under which the mutations of the record identified by id are recorded by the
+// auditors of the repository, which build it from the key field and, for the contents served by
+// RegisterChildHandler, from the parent.
+func (service modelService[T]) recordID(ctx iris.Context, id interface{}) (string, error) {
+	model := new(T)
+	reflect.ValueOf(model).Elem().FieldByName(service.options.keyField).Set(reflect.ValueOf(id))
+	return repository.RecordID(service.repository(ctx), model)
 }
 
 func (service modelService[T]) GetByID(ctx iris.Context) {
-	id, err := ctx.Params().GetUint("id")
+	span := service.startSpan(ctx, "GetByID")
+	defer endSpan(ctx, span)
+
+	id, err := service.parseID(ctx)
+	if err != nil {
+		_ = ctx.StopWithJSON(
+			iris.StatusBadRequest,
+			iris.Map{
+				"error":      err.Error(),
+				"error_code": ErrorCodeCantReadID,
+			},
+		)
+		return
+	}
+
+	include, err := service.includeScope(ctx)
 	if err != nil {
 		_ = ctx.StopWithJSON(
 			iris.StatusBadRequest,
 			iris.Map{
 				"error":      err.Error(),
-				"error_code": "CANT_READ_ID",
+				"error_code": ErrorCodeInvalidInclude,
 			},
 		)
 		return
 	}
 
-	object, err := service.repo.GetByID(id)
+	if asOf := ctx.URLParam("as_of"); asOf != "" {
+		// A version holds the associations saved with the record, which the include paths cannot change.
+		if paths, _ := service.parseIncludes(ctx.URLParam("include")); len(paths) > 0 {
+			_ = ctx.StopWithJSON(
+				iris.StatusBadRequest,
+				iris.Map{
+					"error":      "include cannot be combined with as_of, a version holds the associations saved with the record",
+					"error_code": ErrorCodeInvalidAsOf,
+				},
+			)
+			return
+		}
+		service.getAsOf(ctx, id, asOf)
+		return
+	}
+
+	object, err := service.repository(ctx).GetByID(id, include)
 	if err != nil {
+		errorCode := ErrorCodeFetchReadObject
+		if errors.Is(err, repository.ErrInvalidInclude) {
+			errorCode = ErrorCodeInvalidInclude
+		}
 		_ = ctx.StopWithJSON(
 			iris.StatusBadRequest,
 			iris.Map{
 				"error":      err.Error(),
-				"error_code": "FETCH_READ_OBJECT_ERROR",
+				"error_code": errorCode,
 			},
 		)
 		return
 	}
 
-	_ = ctx.StopWithJSON(iris.StatusOK, object)
+	service.respond(ctx, iris.StatusOK, object)
 }
 
 func (service modelService[T]) GetAll(ctx iris.Context) {
-	// Generate filter parameters
-	//filter, err := service.eng.GenerateFilterParameters()
-	//if err != nil {
-	//	_ = ctx.StopWithJSON(
-	//		iris.StatusBadRequest,
-	//		iris.Map{
-	//			"error":      err.Error(),
-	//			"error_code": "CANT_GEN_FILTER",
-	//		},
-	//	)
-	//}
+	span := service.startSpan(ctx, "GetAll")
+	defer endSpan(ctx, span)
 
-	//
-	objects, err := service.repo.GetAll()
+	// Generate filter parameters and bind them from the query string
+	filter, err := service.eng.GenerateFilterParameters()
 	if err != nil {
 		_ = ctx.StopWithJSON(
 			iris.StatusBadRequest,
 			iris.Map{
 				"error":      err.Error(),
-				"error_code": "FETCH_ERROR",
+				"error_code": ErrorCodeGenerateFilterParams,
 			},
 		)
 		return
 	}
 
-	_ = ctx.StopWithJSON(iris.StatusOK, objects)
+	err = ctx.ReadQuery(filter)
+	if err != nil {
+		_ = ctx.StopWithJSON(
+			iris.StatusBadRequest,
+			iris.Map{
+				"error":      err.Error(),
+				"error_code": ErrorCodeParseFilterParams,
+			},
+		)
+		return
+	}
+
+	include, err := service.includeScope(ctx)
+	if err != nil {
+		_ = ctx.StopWithJSON(
+			iris.StatusBadRequest,
+			iris.Map{
+				"error":      err.Error(),
+				"error_code": ErrorCodeInvalidInclude,
+			},
+		)
+		return
+	}
+
+	objects, err := service.repository(ctx).GetAll(
+		repository.FilterScope[T](filter),
+		repository.PaginateScope(service.limit(ctx), ctx.URLParamIntDefault("offset", 0)),
+		include,
+	)
+	if err != nil {
+		errorCode := ErrorCodeFetch
+		if errors.Is(err, repository.ErrInvalidInclude) {
+			errorCode = ErrorCodeInvalidInclude
+		}
+		_ = ctx.StopWithJSON(
+			iris.StatusBadRequest,
+			iris.Map{
+				"error":      err.Error(),
+				"error_code": errorCode,
+			},
+		)
+		return
+	}
+
+	service.respond(ctx, iris.StatusOK, objects)
 }
 
 func (service modelService[T]) Create(ctx iris.Context) {
+	span := service.startSpan(ctx, "Create")
+	defer endSpan(ctx, span)
+
 	createParams, err := service.eng.GenerateCreateParameters()
 	if err != nil {
 		_ = ctx.StopWithJSON(
 			iris.StatusBadRequest,
 			iris.Map{
 				"error":      err.Error(),
-				"error_code": "GENERATE_CREATE_PARAMS_ERROR",
+				"error_code": ErrorCodeGenerateCreateParams,
 			},
 		)
 		return
@@ -108,7 +384,7 @@ func (service modelService[T]) Create(ctx iris.Context) {
 			iris.StatusBadRequest,
 			iris.Map{
 				"error":      err.Error(),
-				"error_code": "PARSE_CREATE_PARAMS_ERROR",
+				"error_code": ErrorCodeParseCreateParams,
 			},
 		)
 		return
@@ -120,37 +396,55 @@ func (service modelService[T]) Create(ctx iris.Context) {
 			iris.StatusBadRequest,
 			iris.Map{
 				"error":      err.Error(),
-				"error_code": "GENERATE_CREATE_MODEL_ERROR",
+				"error_code": ErrorCodeGenerateCreateModel,
 			},
 		)
 		return
 	}
 
-	err = service.repo.Create(model)
+	err = service.repository(ctx).Create(model)
 	if err != nil {
+		errorCode := ErrorCodeCreate
+		if errors.Is(err, repository.ErrReferenceNotFound) {
+			errorCode = ErrorCodeReferenceNotFound
+		}
 		_ = ctx.StopWithJSON(
 			iris.StatusBadRequest,
 			iris.Map{
 				"error":      err.Error(),
-				"error_code": "CREATE_ERROR",
+				"error_code": errorCode,
 			},
 		)
 		return
 	}
 
-	_ = ctx.StopWithJSON(iris.StatusCreated, model)
+	service.respond(ctx, iris.StatusCreated, model)
 }
 
 func (service modelService[T]) UpdatePatch(ctx iris.Context) {
-	objId := ctx.Params().Get("id")
+	span := service.startSpan(ctx, "UpdatePatch")
+	defer endSpan(ctx, span)
+
+	objId, err := service.parseID(ctx)
+	if err != nil {
+		_ = ctx.StopWithJSON(
+			iris.StatusBadRequest,
+			iris.Map{
+				"error":      err.Error(),
+				"error_code": ErrorCodeCantReadID,
+			},
+		)
+		return
+	}
 
-	objModel, err := service.repo.GetByID(objId)
+	// The collections are merged with their current items, every association is read.
+	objModel, err := service.repository(ctx).GetByID(objId, repository.AssociationsScope())
 	if err != nil {
 		_ = ctx.StopWithJSON(
 			iris.StatusBadRequest,
 			iris.Map{
 				"error":      err.Error(),
-				"error_code": "NOT_FOUND",
+				"error_code": ErrorCodeNotFound,
 			},
 		)
 		return
@@ -162,7 +456,7 @@ func (service modelService[T]) UpdatePatch(ctx iris.Context) {
 			iris.StatusBadRequest,
 			iris.Map{
 				"error":      err.Error(),
-				"error_code": "GENERATE_UPDATE_PARAMS_ERROR",
+				"error_code": ErrorCodeGenerateUpdateParams,
 			},
 		)
 		return
@@ -174,7 +468,7 @@ func (service modelService[T]) UpdatePatch(ctx iris.Context) {
 			iris.StatusBadRequest,
 			iris.Map{
 				"error":      err.Error(),
-				"error_code": "PARSE_UPDATE_PARAMS_ERROR",
+				"error_code": ErrorCodeParseUpdateParams,
 			},
 		)
 		return
@@ -186,37 +480,54 @@ func (service modelService[T]) UpdatePatch(ctx iris.Context) {
 			iris.StatusBadRequest,
 			iris.Map{
 				"error":      err.Error(),
-				"error_code": "GENERATE_UPDATE_MODEL_ERROR",
+				"error_code": ErrorCodeGenerateUpdateModel,
 			},
 		)
 		return
 	}
 
-	err = service.repo.Update(model)
+	err = service.repository(ctx).Update(model)
 	if err != nil {
+		errorCode := ErrorCodeUpdate
+		if errors.Is(err, repository.ErrReferenceNotFound) {
+			errorCode = ErrorCodeReferenceNotFound
+		}
 		_ = ctx.StopWithJSON(
 			iris.StatusBadRequest,
 			iris.Map{
 				"error":      err.Error(),
-				"error_code": "UPDATE_ERROR",
+				"error_code": errorCode,
 			},
 		)
 		return
 	}
 
-	_ = ctx.StopWithJSON(iris.StatusOK, model)
+	service.respond(ctx, iris.StatusOK, model)
 }
 
 func (service modelService[T]) Delete(ctx iris.Context) {
-	objId := ctx.Params().Get("id")
+	span := service.startSpan(ctx, "Delete")
+	defer endSpan(ctx, span)
+
+	objId, err := service.parseID(ctx)
+	if err != nil {
+		_ = ctx.StopWithJSON(
+			iris.StatusBadRequest,
+			iris.Map{
+				"error":      err.Error(),
+				"error_code": ErrorCodeCantReadID,
+			},
+		)
+		return
+	}
 
-	err := service.repo.Delete(objId)
+	err = service.repository(ctx).Delete(objId)
 	if err != nil {
 		_ = ctx.StopWithJSON(
 			iris.StatusBadRequest,
 			iris.Map{
 				"error":      err.Error(),
-				"error_code": "DELETE_ERROR",
+				"error_code": ErrorCodeDelete,
 			},
 		)
 		return
@@ -224,3 +535,34 @@ func (service modelService[T]) Delete(ctx iris.Context) {
 
 	ctx.StopWithStatus(iris.StatusNoContent)
 }
+
+func (service modelService[T]) Schema(ctx iris.Context) {
+	createSchema, err := service.eng.CreateSchema()
+	if err != nil {
+		_ = ctx.StopWithJSON(
+			iris.StatusBadRequest,
+			iris.Map{
+				"error":      err.Error(),
+				"error_code": ErrorCodeGenerateSchema,
+			},
+		)
+		return
+	}
+
+	updateSchema, err := service.eng.UpdateSchema()
+	if err != nil {
+		_ = ctx.StopWithJSON(
+			iris.StatusBadRequest,
+			iris.Map{
+				"error":      err.Error(),
+				"error_code": ErrorCodeGenerateSchema,
+			},
+		)
+		return
+	}
+
+	_ = ctx.StopWithJSON(iris.StatusOK, iris.Map{
+		"create": createSchema,
+		"update": updateSchema,
+	})
+}
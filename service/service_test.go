@@ -0,0 +1,172 @@
+package service
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/MuhmdHsn313/origin/internal/testdb"
+	"github.com/MuhmdHsn313/origin/logging"
+	"github.com/MuhmdHsn313/origin/orm"
+	"github.com/MuhmdHsn313/origin/repository"
+	"github.com/kataras/iris/v12"
+)
+
+type includeAuthor struct {
+	orm.Model
+
+	Name string `json:"name"`
+}
+
+type includeComment struct {
+	orm.Model
+
+	Contents []includeCommentContent `json:"contents" gorm:"foreignKey:CommentID"`
+	AuthorID uint                    `json:"author_id"`
+	Author   *includeAuthor          `json:"author"`
+	PostID   uint                    `json:"post_id"`
+}
+
+type includeCommentContent struct {
+	orm.ContentModel
+
+	Body      string `json:"body"`
+	CommentID uint   `json:"comment_id" gorm:"primaryKey"`
+}
+
+type includePost struct {
+	orm.Model
+
+	Translations []includePostContent `json:"translations" gorm:"foreignKey:PostID"`
+	AuthorID     uint                 `json:"author_id"`
+	Author       *includeAuthor       `json:"author"`
+	Comments     []includeComment     `json:"comments" gorm:"foreignKey:PostID"`
+}
+
+type includePostContent struct {
+	orm.ContentModel
+
+	Title  string `json:"title"`
+	PostID uint   `json:"post_id" gorm:"primaryKey"`
+}
+
+func TestParseIncludes(t *testing.T) {
+	tests := []struct {
+		name    string
+		opts    []ServiceOption
+		include string
+		want    []string
+		wantErr bool
+	}{
+		{name: "empty", include: "", want: nil},
+		{name: "blank paths", include: " , ,", want: nil},
+		// Without a whitelist, only the content collections may be included.
+		{name: "content by default", include: "translations", want: []string{"translations"}},
+		{name: "content by Go name", include: "Translations", want: []string{"Translations"}},
+		{name: "association denied by default", include: "author", wantErr: true},
+		{name: "nested association denied by default", include: "comments.author", wantErr: true},
+		{name: "nested content of a denied association", include: "comments.contents", wantErr: true},
+		{
+			name:    "whitelisted",
+			opts:    []ServiceOption{WithIncludes("author", "comments.author")},
+			include: "author, comments.author",
+			want:    []string{"author", "comments.author"},
+		},
+		{
+			name:    "parent of a whitelisted path",
+			opts:    []ServiceOption{WithIncludes("comments.author")},
+			include: "comments",
+			want:    []string{"comments"},
+		},
+		{
+			name:    "nested content of an allowed association",
+			opts:    []ServiceOption{WithIncludes("comments")},
+			include: "comments.contents",
+			want:    []string{"comments.contents"},
+		},
+		{name: "not whitelisted", opts: []ServiceOption{WithIncludes("comments")}, include: "author", wantErr: true},
+		{name: "child of a whitelisted path", opts: []ServiceOption{WithIncludes("comments")}, include: "comments.author", wantErr: true},
+		{
+			name:    "deeper than the limit",
+			opts:    []ServiceOption{WithIncludes("comments.author.posts")},
+			include: "comments.author.posts",
+			wantErr: true,
+		},
+		{
+			name:    "raised limit",
+			opts:    []ServiceOption{WithIncludes("comments.author.posts"), WithMaxIncludeDepth(3)},
+			include: "comments.author.posts",
+			want:    []string{"comments.author.posts"},
+		},
+		{
+			name:    "lowered limit",
+			opts:    []ServiceOption{WithIncludes("comments.author"), WithMaxIncludeDepth(1)},
+			include: "comments,comments.author",
+			wantErr: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			service := NewModelService[includePost](CreateEngine[includePost](), nil, test.opts...).(*modelService[includePost])
+			got, err := service.parseIncludes(test.include)
+			if test.wantErr {
+				if err == nil {
+					t.Errorf("parsed %q as %q, want an error", test.include, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("parsed %q as %q, want %q", test.include, got, test.want)
+			}
+		})
+	}
+}
+
+func TestWritesRejectMissingReferences(t *testing.T) {
+	useRegistry(t)
+	db := testdb.Open(t, &includeAuthor{}, &includeComment{}, &includeCommentContent{}, &includePost{}, &includePostContent{})
+	app := iris.New()
+	RegisterHandler[includePost](app.Party("/api"), NewModelService[includePost](CreateEngine[includePost](),
+		repository.NewGenericRepository[includePost](db, logging.Nop())))
+	if err := app.Build(); err != nil {
+		t.Fatal(err)
+	}
+	mustServe(t, app, http.MethodPost, "/api/include_post", `{"author":{"name":"alice"}}`, http.StatusCreated)
+
+	tests := []struct {
+		method, path, body string
+	}{
+		{http.MethodPost, "/api/include_post", `{"author":{"id":42}}`},
+		{http.MethodPost, "/api/include_post", `{"author":{"id":1},"comments":[{"id":99}]}`},
+		{http.MethodPatch, "/api/include_post/1", `{"author":{"id":42}}`},
+	}
+	for _, test := range tests {
+		code, body := serve(t, app, test.method, test.path, test.body)
+		if code != http.StatusBadRequest || body.(map[string]interface{})["error_code"] != ErrorCodeReferenceNotFound {
+			t.Errorf("%s %s %s returned %d %v, want %d %s", test.method, test.path, test.body, code, body,
+				http.StatusBadRequest, ErrorCodeReferenceNotFound)
+		}
+	}
+
+	// The missing records were not inserted empty.
+	counts := []struct {
+		table interface{}
+		want  int64
+	}{
+		{&includePost{}, 1},
+		{&includeAuthor{}, 1},
+		{&includeComment{}, 0},
+	}
+	for _, count := range counts {
+		var got int64
+		if err := db.Model(count.table).Count(&got).Error; err != nil {
+			t.Fatal(err)
+		}
+		if got != count.want {
+			t.Errorf("%T has %d records, want %d", count.table, got, count.want)
+		}
+	}
+}
@@ -0,0 +1,149 @@
+// Code generated by Origin. DO NOT EDIT.
+
+export type ErrorCode =
+  | "CANT_READ_ID"
+  | "CANT_READ_VERSION"
+  | "CREATE_ERROR"
+  | "DELETE_ERROR"
+  | "ENCODE_RESPONSE_ERROR"
+  | "FETCH_AUDIT_ERROR"
+  | "FETCH_ERROR"
+  | "FETCH_PARENT_ERROR"
+  | "FETCH_READ_OBJECT_ERROR"
+  | "FETCH_VERSIONS_ERROR"
+  | "GENERATE_CREATE_MODEL_ERROR"
+  | "GENERATE_CREATE_PARAMS_ERROR"
+  | "GENERATE_FILTER_PARAMS_ERROR"
+  | "GENERATE_SCHEMA_ERROR"
+  | "GENERATE_UPDATE_MODEL_ERROR"
+  | "GENERATE_UPDATE_PARAMS_ERROR"
+  | "INVALID_AS_OF"
+  | "INVALID_INCLUDE"
+  | "NOT_FOUND"
+  | "PARENT_NOT_FOUND"
+  | "PARSE_CREATE_PARAMS_ERROR"
+  | "PARSE_FILTER_PARAMS_ERROR"
+  | "PARSE_UPDATE_PARAMS_ERROR"
+  | "REFERENCE_NOT_FOUND"
+  | "REVERT_ERROR"
+  | "UPDATE_ERROR"
+  | "VERSION_NOT_FOUND";
+
+export interface ApiError {
+  error: string;
+  error_code: ErrorCode;
+}
+
+export interface PaginationParams {
+  limit?: number;
+  offset?: number;
+}
+
+export interface IncludeParams {
+  /** Comma-separated association paths to preload, such as "author,comments.author". */
+  include?: string;
+}
+
+export interface GoldenArticleContent {
+  language_id: string;
+  created_at: string;
+  updated_at: string;
+  title: string;
+  article_id: number;
+}
+
+export interface GoldenArticle {
+  id: number;
+  created_at: string;
+  updated_at: string;
+  contents: GoldenArticleContent[];
+  slug: string;
+  status: "draft" | "published";
+  rating: number;
+  readonly views: number;
+}
+
+export interface GoldenArticleCreateParams {
+  contents?: ({
+    language_id?: string;
+    title: string;
+  })[];
+  slug: string;
+  status?: "draft" | "published";
+  rating?: number;
+  password?: string;
+}
+
+export interface GoldenArticleUpdateParams {
+  contents?: ({
+    language_id?: string;
+    title: string;
+  })[];
+  slug?: string;
+  status?: "draft" | "published";
+  rating?: number;
+  password?: string;
+}
+
+export interface GoldenArticleFilterParams {
+  language_id?: string;
+  title?: string;
+  slug?: string;
+  status?: string;
+  rating?: number;
+  views?: number;
+}
+
+export class OriginError extends Error {
+  constructor(
+    public readonly status: number,
+    public readonly body: ApiError,
+  ) {
+    super(body.error);
+  }
+}
+
+export interface ClientOptions {
+  baseUrl?: string;
+  headers?: Record<string, string>;
+  fetch?: typeof fetch;
+}
+
+export function createClient(options: ClientOptions = {}) {
+  const baseUrl = options.baseUrl ?? "";
+  const fetchImpl = options.fetch ?? fetch;
+
+  async function request<R>(method: string, path: string, body?: unknown, query?: object): Promise<R> {
+    const search = new URLSearchParams();
+    for (const [key, value] of Object.entries(query ?? {})) {
+      if (value !== undefined && value !== null) {
+        search.append(key, String(value));
+      }
+    }
+    const url = baseUrl + path + (search.toString() ? "?" + search.toString() : "");
+
+    const response = await fetchImpl(url, {
+      method,
+      headers: { "Content-Type": "application/json", ...options.headers },
+      body: body === undefined ? undefined : JSON.stringify(body),
+    });
+    if (!response.ok) {
+      throw new OriginError(response.status, (await response.json()) as ApiError);
+    }
+    if (response.status === 204) {
+      return undefined as R;
+    }
+    return (await response.json()) as R;
+  }
+
+  return {
+    goldenArticle: {
+      list: (filter?: GoldenArticleFilterParams & PaginationParams & IncludeParams) => request<GoldenArticle[]>("GET", "/api/golden_article", undefined, filter),
+      get: (id: number, query?: IncludeParams) => request<GoldenArticle>("GET", `/api/golden_article/${id}`, undefined, query),
+      create: (params: GoldenArticleCreateParams) => request<GoldenArticle>("POST", "/api/golden_article", params),
+      update: (id: number, params: GoldenArticleUpdateParams) => request<GoldenArticle>("PATCH", `/api/golden_article/${id}`, params),
+      delete: (id: number) => request<void>("DELETE", `/api/golden_article/${id}`),
+      schema: () => request<{ create: unknown; update: unknown }>("GET", "/api/golden_article/_schema"),
+    },
+  };
+}
@@ -0,0 +1,45 @@
+package service
+
+import (
+	"github.com/kataras/iris/v12"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// TracerName is the instrumentation scope of the spans of the service operations.
+const TracerName = "github.com/MuhmdHsn313/origin/service"
+
+// tracer returns the tracer of the service operations.
+func (options serviceOptions) tracer() trace.Tracer {
+	if options.tracerProvider == nil {
+		return otel.GetTracerProvider().Tracer(TracerName)
+	}
+	return options.tracerProvider.Tracer(TracerName)
+}
+
+// startSpan starts the span of an operation, such as "modelService.Create", as a child of the span of the request.
+// The request is bound to the context of the new span so that the repository operations are nested in it.
+func (service modelService[T]) startSpan(ctx iris.Context, operation string) trace.Span {
+	spanCtx, span := service.options.tracer().Start(ctx.Request().Context(), "modelService."+operation,
+		trace.WithAttributes(
+			attribute.String("origin.model", service.modelName()),
+			attribute.String("origin.operation", operation),
+		),
+	)
+	ctx.ResetRequest(ctx.Request().WithContext(spanCtx))
+	return span
+}
+
+// endSpan ends the span of an operation with the status code of its response, failed operations
+// (4xx and 5xx responses) setting the status of the span to an error.
+func endSpan(ctx iris.Context, span trace.Span) {
+	status := ctx.GetStatusCode()
+	span.SetAttributes(semconv.HTTPResponseStatusCode(status))
+	if status >= iris.StatusBadRequest {
+		span.SetStatus(codes.Error, iris.StatusText(status))
+	}
+	span.End()
+}
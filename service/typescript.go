@@ -0,0 +1,468 @@
+package service
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/MuhmdHsn313/origin/orm"
+	"github.com/iancoleman/strcase"
+)
+
+// TypeScriptOptions configures the TypeScript client generated by WriteTypeScript.
+type TypeScriptOptions struct {
+	// Header is written at the top of the file, defaults to a "DO NOT EDIT" notice.
+	Header string
+}
+
+// WriteTypeScript writes TypeScript interfaces for every model registered through RegisterHandler
+// (the model itself and its create, update and filter parameters), the error codes of the service
+// as a union type, and a small fetch-based client for the registered routes.
+//
+// It is meant to run from a `go generate` target of the application, once the models are registered:
+//
+//	//go:generate go run . -ts ./web/src/api.ts
+func WriteTypeScript(w io.Writer, options TypeScriptOptions) error {
+	if options.Header == "" {
+		options.Header = "// Code generated by Origin. DO NOT EDIT."
+	}
+
+	builder := &tsBuilder{declared: map[string]bool{}}
+	models := RegisteredModels()
+
+	var out bytes.Buffer
+	out.WriteString(options.Header + "\n\n")
+
+	out.WriteString("export type ErrorCode =\n")
+	codes := allErrorCodes()
+	for i, code := range codes {
+		separator := ""
+		if i == len(codes)-1 {
+			separator = ";"
+		}
+		fmt.Fprintf(&out, "  | %q%s\n", code, separator)
+	}
+	out.WriteString(tsRuntimeTypes)
+	for _, model := range models {
+		if model.Exposes(OperationAudit) {
+			out.WriteString(tsAuditTypes)
+			break
+		}
+	}
+	for _, model := range models {
+		if model.Exposes(OperationVersions) {
+			out.WriteString(tsVersionTypes)
+			break
+		}
+	}
+
+	for _, model := range models {
+		builder.declareNamed(model.ModelType)
+
+		prefix := model.ModelType.Name()
+		if model.CreateParamsType != nil {
+			builder.declareParams(prefix+"CreateParams", model.CreateParamsType, false)
+		}
+		if model.UpdateParamsType != nil {
+			builder.declareParams(prefix+"UpdateParams", model.UpdateParamsType, true)
+		}
+		if model.FilterParamsType != nil {
+			builder.declareParams(prefix+"FilterParams", model.FilterParamsType, true)
+		}
+	}
+	out.WriteString(builder.declarations.String())
+
+	out.WriteString(tsClientPrelude)
+	for _, model := range models {
+		writeTypeScriptRoutes(&out, model)
+	}
+	out.WriteString("  };\n}\n")
+
+	_, err := w.Write(out.Bytes())
+	return err
+}
+
+// WriteTypeScriptFile writes the TypeScript client generated by WriteTypeScript to path.
+func WriteTypeScriptFile(path string, options TypeScriptOptions) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+
+	if err := WriteTypeScript(file, options); err != nil {
+		_ = file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// writeTypeScriptRoutes writes the client methods of a model, matching the routes of RegisterHandler.
+func writeTypeScriptRoutes(out *bytes.Buffer, model ModelDescription) {
+	name := model.ModelType.Name()
+	idType := tsPrimitive(model.KeyType)
+
+	filterType := "PaginationParams & IncludeParams"
+	if model.FilterParamsType != nil {
+		filterType = name + "FilterParams & PaginationParams & IncludeParams"
+	}
+	createType, updateType := "Record<string, unknown>", "Record<string, unknown>"
+	if model.CreateParamsType != nil {
+		createType = name + "CreateParams"
+	}
+	if model.UpdateParamsType != nil {
+		updateType = name + "UpdateParams"
+	}
+
+	// The routes of child models take the IDs of their parents first, interpolated into the path.
+	path := model.Path
+	var parentArgs string
+	for _, parent := range model.ParentParams {
+		arg := strcase.ToLowerCamel(parent.Name)
+		parentArgs += fmt.Sprintf("%s: %s, ", arg, tsPrimitive(parent.Type))
+		path = strings.ReplaceAll(path, "{"+parent.Name+"}", "${"+arg+"}")
+	}
+	collection := fmt.Sprintf("%q", path)
+	if len(model.ParentParams) > 0 {
+		collection = "`" + path + "`"
+	}
+	item := "`" + path + "/${id}`"
+	schema := fmt.Sprintf("%q", path+"/_schema")
+	if len(model.ParentParams) > 0 {
+		schema = "`" + path + "/_schema`"
+	}
+
+	fmt.Fprintf(out, "    %s: {\n", strcase.ToLowerCamel(model.Name))
+	if model.Exposes(OperationList) {
+		fmt.Fprintf(out, "      list: (%sfilter?: %s) => request<%s[]>(\"GET\", %s, undefined, filter),\n", parentArgs, filterType, name, collection)
+	}
+	if model.Exposes(OperationGet) {
+		getQuery := "IncludeParams"
+		if model.Exposes(OperationVersions) {
+			getQuery = "IncludeParams & AsOfParams"
+		}
+		fmt.Fprintf(out, "      get: (%sid: %s, query?: %s) => request<%s>(\"GET\", %s, undefined, query),\n", parentArgs, idType, getQuery, name, item)
+	}
+	if model.Exposes(OperationCreate) {
+		fmt.Fprintf(out, "      create: (%sparams: %s) => request<%s>(\"POST\", %s, params),\n", parentArgs, createType, name, collection)
+	}
+	if model.Exposes(OperationUpdate) {
+		fmt.Fprintf(out, "      update: (%sid: %s, params: %s) => request<%s>(\"PATCH\", %s, params),\n", parentArgs, idType, updateType, name, item)
+	}
+	if model.Exposes(OperationDelete) {
+		fmt.Fprintf(out, "      delete: (%sid: %s) => request<void>(\"DELETE\", %s),\n", parentArgs, idType, item)
+	}
+	if model.Exposes(OperationSchema) {
+		fmt.Fprintf(out, "      schema: (%s) => request<{ create: unknown; update: unknown }>(\"GET\", %s),\n", strings.TrimSuffix(parentArgs, ", "), schema)
+	}
+	if model.Exposes(OperationAudit) {
+		fmt.Fprintf(out, "      audit: (%sid: %s, query?: PaginationParams) => request<AuditEntry[]>(\"GET\", %s, undefined, query),\n", parentArgs, idType, strings.TrimSuffix(item, "`")+"/_audit`")
+	}
+	if model.Exposes(OperationVersions) {
+		fmt.Fprintf(out, "      versions: (%sid: %s, query?: PaginationParams) => request<RecordVersion<%s>[]>(\"GET\", %s, undefined, query),\n", parentArgs, idType, name, strings.TrimSuffix(item, "`")+"/_versions`")
+	}
+	if model.Exposes(OperationRevert) {
+		fmt.Fprintf(out, "      revert: (%sid: %s, version: number) => request<%s>(\"POST\", %s),\n", parentArgs, idType, name, strings.TrimSuffix(item, "`")+"/_revert/${version}`")
+	}
+	out.WriteString("    },\n")
+}
+
+// allErrorCodes returns every error code the service may emit, sorted alphabetically.
+func allErrorCodes() []string {
+	seen := map[string]bool{}
+	var codes []string
+	groups := [][]string{childErrorCodes}
+	for _, operationCodes := range operationErrorCodes {
+		groups = append(groups, operationCodes)
+	}
+	for _, operationCodes := range groups {
+		for _, code := range operationCodes {
+			if !seen[code] {
+				seen[code] = true
+				codes = append(codes, code)
+			}
+		}
+	}
+	sort.Strings(codes)
+	return codes
+}
+
+// tsBuilder converts Go types into TypeScript type expressions and collects
+// the interfaces of named struct types.
+type tsBuilder struct {
+	declarations bytes.Buffer
+	declared     map[string]bool
+}
+
+// declareNamed declares an interface for a named struct type (a model), describing its JSON response.
+func (b *tsBuilder) declareNamed(t reflect.Type) string {
+	t = derefType(t)
+	if b.declared[t.Name()] {
+		return t.Name()
+	}
+	b.declared[t.Name()] = true
+
+	// Build the body first so that the interfaces of nested types are declared before this one.
+	body := b.structBody(t, false, true, "")
+	fmt.Fprintf(&b.declarations, "\nexport interface %s %s\n", t.Name(), body)
+	return t.Name()
+}
+
+// declareParams declares an interface for a generated parameters struct.
+// When optional is true every property is optional, as in update and filter parameters.
+func (b *tsBuilder) declareParams(name string, t reflect.Type, optional bool) {
+	body := b.structBody(derefType(t), optional, false, "")
+	fmt.Fprintf(&b.declarations, "\nexport interface %s %s\n", name, body)
+}
+
+// typeOf returns the TypeScript type expression of t.
+func (b *tsBuilder) typeOf(t reflect.Type, response bool, indent string) string {
+	t = derefType(t)
+	if t == timeType || isTextType(t) {
+		return "string"
+	}
+
+	switch t.Kind() {
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return "string"
+		}
+		element := b.typeOf(t.Elem(), response, indent)
+		if strings.ContainsAny(element, " |") {
+			element = "(" + element + ")"
+		}
+		return element + "[]"
+	case reflect.Map:
+		return "Record<string, " + b.typeOf(t.Elem(), response, indent) + ">"
+	case reflect.Struct:
+		if t.Name() == "" {
+			return b.structBody(t, false, response, indent)
+		}
+		return b.declareNamed(t)
+	default:
+		return tsPrimitive(t)
+	}
+}
+
+// structBody renders the properties of a struct as a TypeScript object type.
+func (b *tsBuilder) structBody(t reflect.Type, optional, response bool, indent string) string {
+	var lines []string
+	b.collectTSProperties(t, optional, response, indent+"  ", &lines)
+	if len(lines) == 0 {
+		return "{}"
+	}
+	return "{\n" + strings.Join(lines, "\n") + "\n" + indent + "}"
+}
+
+func (b *tsBuilder) collectTSProperties(t reflect.Type, optional, response bool, indent string, lines *[]string) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		name, tagged := jsonFieldName(field)
+		if name == "-" {
+			continue
+		}
+		if urlName, ok := field.Tag.Lookup("url"); ok && !tagged {
+			name = strings.Split(urlName, ",")[0]
+		}
+
+		if field.Anonymous && !tagged && derefType(field.Type).Kind() == reflect.Struct {
+			b.collectTSProperties(derefType(field.Type), optional, response, indent, lines)
+			continue
+		}
+
+		options := orm.ParseFieldOptions(field)
+		if response && !options.IsPublic() {
+			continue
+		}
+
+		modifier := ""
+		if response && options.ReadOnly {
+			modifier = "readonly "
+		}
+
+		// Request properties are optional unless validated as required, since omitted
+		// JSON fields simply keep their zero value.
+		question := ""
+		if optional || (!response && !hasValidateRule(field.Tag.Get("validate"), "required")) {
+			question = "?"
+		}
+
+		typeExpression := b.typeOf(field.Type, response, indent)
+		if enum := tsEnum(derefType(field.Type), field.Tag.Get("validate")); enum != "" {
+			typeExpression = enum
+		}
+		if response && field.Type.Kind() == reflect.Ptr {
+			typeExpression += " | null"
+		}
+
+		*lines = append(*lines, fmt.Sprintf("%s%s%s%s: %s;", indent, modifier, tsPropertyName(name), question, typeExpression))
+	}
+}
+
+// tsEnum returns the union of the values allowed by the oneof rule of a validate tag, such as
+// "draft" | "published", or an empty string when the field of type t has no such rule.
+func tsEnum(t reflect.Type, tag string) string {
+	for _, rule := range strings.Split(tag, ",") {
+		if rule == "dive" {
+			return ""
+		}
+		name, param, _ := strings.Cut(rule, "=")
+		if name != "oneof" {
+			continue
+		}
+
+		var values []string
+		for _, value := range strings.Fields(param) {
+			literal, err := json.Marshal(schemaValue(t, value))
+			if err != nil {
+				return ""
+			}
+			values = append(values, string(literal))
+		}
+		return strings.Join(values, " | ")
+	}
+	return ""
+}
+
+var tsIdentifier = regexp.MustCompile(`^[A-Za-z_$][A-Za-z0-9_$]*$`)
+
+// tsPropertyName quotes property names that are not valid TypeScript identifiers.
+func tsPropertyName(name string) string {
+	if tsIdentifier.MatchString(name) {
+		return name
+	}
+	return fmt.Sprintf("%q", name)
+}
+
+func tsPrimitive(t reflect.Type) string {
+	if isTextType(derefType(t)) {
+		return "string"
+	}
+	switch derefType(t).Kind() {
+	case reflect.Bool:
+		return "boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64:
+		return "number"
+	case reflect.String:
+		return "string"
+	default:
+		return "unknown"
+	}
+}
+
+const tsRuntimeTypes = `
+export interface ApiError {
+  error: string;
+  error_code: ErrorCode;
+}
+
+export interface PaginationParams {
+  limit?: number;
+  offset?: number;
+}
+
+export interface IncludeParams {
+  /** Comma-separated association paths to preload, such as "author,comments.author". */
+  include?: string;
+}
+`
+
+// tsAuditTypes declares the audit entries returned by the audit routes, see audit.Entry.
+const tsAuditTypes = `
+export interface AuditChange {
+  field: string;
+  language?: string;
+  before?: unknown;
+  after?: unknown;
+  redacted?: boolean;
+}
+
+export interface AuditEntry {
+  id: number;
+  model: string;
+  record_id: string;
+  operation: "create" | "update" | "delete" | "revert";
+  principal: string;
+  request_id: string;
+  timestamp: string;
+  changes: AuditChange[];
+}
+`
+
+// tsVersionTypes declares the versions returned by the versions routes, see history.Version.
+const tsVersionTypes = `
+export interface AsOfParams {
+  /** Returns the record as it was at this RFC 3339 time, such as "2024-01-02T15:04:05Z". Cannot be combined with include. */
+  as_of?: string;
+}
+
+export interface RecordVersion<T> {
+  id: number;
+  model: string;
+  record_id: string;
+  version: number;
+  operation: "create" | "update" | "delete" | "revert";
+  principal: string;
+  request_id: string;
+  timestamp: string;
+  deleted: boolean;
+  snapshot: T | null;
+}
+`
+
+const tsClientPrelude = `
+export class OriginError extends Error {
+  constructor(
+    public readonly status: number,
+    public readonly body: ApiError,
+  ) {
+    super(body.error);
+  }
+}
+
+export interface ClientOptions {
+  baseUrl?: string;
+  headers?: Record<string, string>;
+  fetch?: typeof fetch;
+}
+
+export function createClient(options: ClientOptions = {}) {
+  const baseUrl = options.baseUrl ?? "";
+  const fetchImpl = options.fetch ?? fetch;
+
+  async function request<R>(method: string, path: string, body?: unknown, query?: object): Promise<R> {
+    const search = new URLSearchParams();
+    for (const [key, value] of Object.entries(query ?? {})) {
+      if (value !== undefined && value !== null) {
+        search.append(key, String(value));
+      }
+    }
+    const url = baseUrl + path + (search.toString() ? "?" + search.toString() : "");
+
+    const response = await fetchImpl(url, {
+      method,
+      headers: { "Content-Type": "application/json", ...options.headers },
+      body: body === undefined ? undefined : JSON.stringify(body),
+    });
+    if (!response.ok) {
+      throw new OriginError(response.status, (await response.json()) as ApiError);
+    }
+    if (response.status === 204) {
+      return undefined as R;
+    }
+    return (await response.json()) as R;
+  }
+
+  return {
+`
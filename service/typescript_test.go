@@ -0,0 +1,50 @@
+package service
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestWriteTypeScript(t *testing.T) {
+	registerGoldenArticle(t)
+
+	var out bytes.Buffer
+	if err := WriteTypeScript(&out, TypeScriptOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	assertGolden(t, "client.golden.ts", out.Bytes())
+
+	// The golden file is reviewed as a whole, the visibility of the fields is checked here as well.
+	model := declaration(t, out.String(), "GoldenArticle")
+	createParams := declaration(t, out.String(), "GoldenArticleCreateParams")
+	for _, test := range []struct {
+		body, property string
+		declared       bool
+	}{
+		{model, "readonly views: number;", true},
+		{model, `status: "draft" | "published";`, true},
+		{model, "password", false},
+		{model, "notes", false},
+		{createParams, "views", false},
+		{createParams, "password?: string;", true},
+		{createParams, "notes", false},
+		{createParams, "slug: string;", true},
+		{createParams, `status?: "draft" | "published";`, true},
+	} {
+		if strings.Contains(test.body, test.property) != test.declared {
+			t.Errorf("declaring %q is %t, want %t in:\n%s", test.property, !test.declared, test.declared, test.body)
+		}
+	}
+}
+
+// declaration returns the body of the interface name declared in source.
+func declaration(t *testing.T, source, name string) string {
+	t.Helper()
+	start := strings.Index(source, "export interface "+name+" {")
+	if start < 0 {
+		t.Fatalf("interface %s is not declared", name)
+	}
+	end := strings.Index(source[start:], "\n}\n")
+	return source[start : start+end+2]
+}
@@ -0,0 +1,111 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+	"gorm.io/gorm"
+)
+
+// statementSpanKey returns the setting holding the span of a statement while it runs. The settings are
+// copied to the statements of the associations saved within it, the key is therefore unique per statement.
+func statementSpanKey(statement *gorm.Statement) string {
+	return fmt.Sprintf("origin:tracing:span:%p", statement)
+}
+
+// statementSpan is the span of a statement, with the context it replaced in the statement.
+type statementSpan struct {
+	span   trace.Span
+	parent context.Context
+}
+
+// gormPlugin registers the callbacks tracing the statements of a database.
+type gormPlugin struct {
+	tracer trace.Tracer
+}
+
+// NewGormPlugin returns the GORM plugin adding a client span per SQL statement, named after its
+// operation (e.g. "gorm.query"), with the statement as db.query.text and the number of affected or
+// returned rows as db.rows_affected. Install it with db.Use. Only the statements whose context belongs
+// to a trace are traced, such as those of the repositories serving a traced request, see repository.ContextRepository.
+func NewGormPlugin(opts ...Option) gorm.Plugin {
+	options := defaultTracingOptions()
+	for _, opt := range opts {
+		opt(&options)
+	}
+	return gormPlugin{tracer: options.tracer()}
+}
+
+// Name identifies the plugin, see gorm.Plugin.
+func (plugin gormPlugin) Name() string {
+	return "origin:tracing"
+}
+
+// Initialize registers the callbacks around the create, query, update, delete, row and raw statements.
+func (plugin gormPlugin) Initialize(db *gorm.DB) error {
+	callbacks := db.Callback()
+	return errors.Join(
+		callbacks.Create().Before("gorm:create").Register("origin:tracing:before_create", plugin.before("create")),
+		callbacks.Create().After("gorm:create").Register("origin:tracing:after_create", plugin.after),
+		callbacks.Query().Before("gorm:query").Register("origin:tracing:before_query", plugin.before("query")),
+		callbacks.Query().After("gorm:query").Register("origin:tracing:after_query", plugin.after),
+		callbacks.Update().Before("gorm:update").Register("origin:tracing:before_update", plugin.before("update")),
+		callbacks.Update().After("gorm:update").Register("origin:tracing:after_update", plugin.after),
+		callbacks.Delete().Before("gorm:delete").Register("origin:tracing:before_delete", plugin.before("delete")),
+		callbacks.Delete().After("gorm:delete").Register("origin:tracing:after_delete", plugin.after),
+		callbacks.Row().Before("gorm:row").Register("origin:tracing:before_row", plugin.before("row")),
+		callbacks.Row().After("gorm:row").Register("origin:tracing:after_row", plugin.after),
+		callbacks.Raw().Before("gorm:raw").Register("origin:tracing:before_raw", plugin.before("raw")),
+		callbacks.Raw().After("gorm:raw").Register("origin:tracing:after_raw", plugin.after),
+	)
+}
+
+// before returns the callback starting the span of a statement of the given operation.
+func (plugin gormPlugin) before(operation string) func(db *gorm.DB) {
+	return func(db *gorm.DB) {
+		parent := db.Statement.Context
+		if parent == nil || !spanContextValid(parent) {
+			return
+		}
+
+		ctx, span := plugin.tracer.Start(parent, "gorm."+operation,
+			trace.WithSpanKind(trace.SpanKindClient),
+			trace.WithAttributes(
+				semconv.DBSystemKey.String(db.Dialector.Name()),
+				semconv.DBOperationName(operation),
+			),
+		)
+		db.Statement.Context = ctx
+		db.Statement.Settings.Store(statementSpanKey(db.Statement), statementSpan{span: span, parent: parent})
+	}
+}
+
+// after ends the span of a statement, recording the statement, its row count and its error.
+// Missing records are expected by the callers and are not reported as span errors.
+func (plugin gormPlugin) after(db *gorm.DB) {
+	value, ok := db.Statement.Settings.LoadAndDelete(statementSpanKey(db.Statement))
+	if !ok {
+		return
+	}
+	statement := value.(statementSpan)
+	db.Statement.Context = statement.parent
+
+	span := statement.span
+	span.SetAttributes(
+		semconv.DBQueryText(db.Statement.SQL.String()),
+		attribute.Int64("db.rows_affected", db.Statement.RowsAffected),
+	)
+	if db.Statement.Table != "" {
+		span.SetAttributes(semconv.DBCollectionName(db.Statement.Table))
+	}
+	if db.Error != nil && !errors.Is(db.Error, gorm.ErrRecordNotFound) {
+		span.RecordError(db.Error)
+		span.SetStatus(codes.Error, db.Error.Error())
+	}
+	span.End()
+}
@@ -0,0 +1,196 @@
+// Package tracing traces the requests of an Origin application with OpenTelemetry. The Middleware
+// starts a span per request, continuing the W3C trace context of the incoming headers, the services
+// and repositories nest the spans of their operations in it, and the GORM plugin adds a span per SQL
+// statement with its text and row count:
+//
+//	provider := tracing.NewTracerProvider(exporter, tracing.WithServiceName("blog"))
+//	app.UseRouter(tracing.Middleware(tracing.WithTracerProvider(provider)))
+//	err := db.Use(tracing.NewGormPlugin(tracing.WithTracerProvider(provider)))
+//	service.NewModelService[Blog](eng, repo, service.WithTracerProvider(provider))
+//
+// The exporter is any sdktrace.SpanExporter, such as an OTLP exporter, or in tests the in-memory
+// exporter of go.opentelemetry.io/otel/sdk/trace/tracetest. server.New sets tracing up when enabled
+// in the configuration.
+package tracing
+
+import (
+	"context"
+
+	"github.com/MuhmdHsn313/origin/service"
+	"github.com/kataras/iris/v12"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// TracerName is the instrumentation scope of the spans of the requests and of the SQL statements.
+const TracerName = "github.com/MuhmdHsn313/origin/tracing"
+
+// Option configures the Middleware and the GORM plugin.
+type Option func(options *tracingOptions)
+
+type tracingOptions struct {
+	// tracerProvider creates the spans, the global provider when nil.
+	tracerProvider trace.TracerProvider
+	// propagator extracts the trace context of the incoming requests.
+	propagator propagation.TextMapPropagator
+}
+
+func defaultTracingOptions() tracingOptions {
+	return tracingOptions{
+		propagator: propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}),
+	}
+}
+
+// tracer returns the tracer of the spans.
+func (options tracingOptions) tracer() trace.Tracer {
+	if options.tracerProvider == nil {
+		return otel.GetTracerProvider().Tracer(TracerName)
+	}
+	return options.tracerProvider.Tracer(TracerName)
+}
+
+// WithTracerProvider creates the spans with provider, instead of the global provider of otel.GetTracerProvider.
+func WithTracerProvider(provider trace.TracerProvider) Option {
+	return func(options *tracingOptions) {
+		options.tracerProvider = provider
+	}
+}
+
+// WithPropagator extracts the trace context of the incoming requests with propagator,
+// the W3C trace context and baggage by default.
+func WithPropagator(propagator propagation.TextMapPropagator) Option {
+	return func(options *tracingOptions) {
+		if propagator != nil {
+			options.propagator = propagator
+		}
+	}
+}
+
+// Middleware starts a server span per request, named after its method and route template
+// (e.g. "GET /api/blog/{id}"), as a child of the trace context of the request headers (traceparent).
+// The context of the request carries the span, for the services and repositories to nest theirs in it.
+// It must be registered with UseRouter, the routes registered by service.RegisterHandler being
+// annotated with their model and operation. Server errors (5xx responses) set the status of the span to an error.
+func Middleware(opts ...Option) iris.Handler {
+	options := defaultTracingOptions()
+	for _, opt := range opts {
+		opt(&options)
+	}
+	tracer := options.tracer()
+
+	return func(ctx iris.Context) {
+		request := ctx.Request()
+		parent := options.propagator.Extract(request.Context(), propagation.HeaderCarrier(request.Header))
+		spanCtx, span := tracer.Start(parent, request.Method,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				semconv.HTTPRequestMethodKey.String(request.Method),
+				semconv.URLPath(request.URL.Path),
+			),
+		)
+		defer span.End()
+
+		ctx.ResetRequest(request.WithContext(spanCtx))
+		ctx.Next()
+
+		if route := ctx.GetCurrentRoute(); route != nil {
+			span.SetName(request.Method + " " + route.Path())
+			span.SetAttributes(semconv.HTTPRoute(route.Path()))
+			if model, operation, ok := service.LookupRoute(route.Method(), route.Path()); ok {
+				span.SetAttributes(
+					attribute.String("origin.model", model),
+					attribute.String("origin.operation", string(operation)),
+				)
+			}
+		}
+
+		status := ctx.GetStatusCode()
+		span.SetAttributes(semconv.HTTPResponseStatusCode(status))
+		if status >= iris.StatusInternalServerError {
+			span.SetStatus(codes.Error, iris.StatusText(status))
+		}
+	}
+}
+
+// ProviderOption configures a tracer provider created by NewTracerProvider.
+type ProviderOption func(options *providerOptions)
+
+type providerOptions struct {
+	// serviceName is the service.name of the resource of the spans.
+	serviceName string
+	// sampleRatio is the fraction of the traces started by the application that are sampled.
+	sampleRatio float64
+	// syncExport exports each span as it ends instead of in batches.
+	syncExport bool
+}
+
+// DefaultServiceName is the service.name of the spans of a tracer provider without WithServiceName.
+const DefaultServiceName = "origin"
+
+// WithServiceName sets the service.name of the spans, DefaultServiceName by default.
+func WithServiceName(name string) ProviderOption {
+	return func(options *providerOptions) {
+		if name != "" {
+			options.serviceName = name
+		}
+	}
+}
+
+// WithSampleRatio samples a fraction of the traces started by the application, between 0 and 1 (the default).
+// The traces continued from an incoming request follow the sampling decision of the caller.
+func WithSampleRatio(ratio float64) ProviderOption {
+	return func(options *providerOptions) {
+		options.sampleRatio = ratio
+	}
+}
+
+// WithSyncExport exports each span as it ends instead of in batches, as done in tests with the in-memory exporter.
+func WithSyncExport() ProviderOption {
+	return func(options *providerOptions) {
+		options.syncExport = true
+	}
+}
+
+// NewTracerProvider creates a tracer provider exporting the spans with exporter.
+// It must be shut down to flush the remaining spans, as done by server.App.Close.
+//
+// Parameters:
+//   - exporter: The exporter of the spans, such as an OTLP exporter or tracetest.NewInMemoryExporter.
+//   - opts: Options such as WithServiceName and WithSampleRatio.
+//
+// Returns:
+//   - The tracer provider.
+func NewTracerProvider(exporter sdktrace.SpanExporter, opts ...ProviderOption) *sdktrace.TracerProvider {
+	options := providerOptions{serviceName: DefaultServiceName, sampleRatio: 1}
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	export := sdktrace.WithBatcher(exporter)
+	if options.syncExport {
+		export = sdktrace.WithSyncer(exporter)
+	}
+
+	serviceResource, err := resource.Merge(resource.Default(), resource.NewWithAttributes(semconv.SchemaURL, semconv.ServiceName(options.serviceName)))
+	if err != nil {
+		// The schemas of the SDK and of the attributes differ, the attributes alone are kept.
+		serviceResource = resource.NewSchemaless(semconv.ServiceName(options.serviceName))
+	}
+
+	return sdktrace.NewTracerProvider(
+		export,
+		sdktrace.WithResource(serviceResource),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(options.sampleRatio))),
+	)
+}
+
+// spanContextValid reports whether ctx carries a span, that is whether it belongs to a trace.
+func spanContextValid(ctx context.Context) bool {
+	return trace.SpanContextFromContext(ctx).IsValid()
+}
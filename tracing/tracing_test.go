@@ -0,0 +1,244 @@
+package tracing
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/MuhmdHsn313/origin/internal/testdb"
+	"github.com/MuhmdHsn313/origin/logging"
+	"github.com/MuhmdHsn313/origin/orm"
+	"github.com/MuhmdHsn313/origin/repository"
+	"github.com/MuhmdHsn313/origin/service"
+	"github.com/kataras/iris/v12"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type tracedNote struct {
+	orm.Model
+
+	Title string `json:"title"`
+}
+
+// newTestApp returns an iris application serving the routes of tracedNote, traced from the request to the
+// SQL statements with a provider exporting to the returned in-memory exporter.
+func newTestApp(t *testing.T) (*iris.Application, *tracetest.InMemoryExporter) {
+	t.Helper()
+
+	exporter := tracetest.NewInMemoryExporter()
+	provider := NewTracerProvider(exporter, WithSyncExport())
+	t.Cleanup(func() { _ = provider.Shutdown(context.Background()) })
+
+	db := testdb.Open(t, &tracedNote{})
+	if err := db.Use(NewGormPlugin(WithTracerProvider(provider))); err != nil {
+		t.Fatal(err)
+	}
+
+	app := iris.New()
+	app.UseRouter(Middleware(WithTracerProvider(provider)))
+	repo := repository.NewGenericRepository[tracedNote](db, logging.Nop(), repository.WithTracerProvider(provider))
+	svc := service.NewModelService[tracedNote](service.CreateEngine[tracedNote](), repo, service.WithTracerProvider(provider))
+	service.RegisterHandler[tracedNote](app.Party("/api"), svc)
+	if err := app.Build(); err != nil {
+		t.Fatal(err)
+	}
+	return app, exporter
+}
+
+// serve sends a request to app, with the given headers, and returns its status code.
+func serve(app *iris.Application, method, path, body string, header http.Header) int {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	for key, values := range header {
+		req.Header[key] = values
+	}
+	rec := httptest.NewRecorder()
+	app.ServeHTTP(rec, req)
+	return rec.Code
+}
+
+// spanNamed returns the first exported span named name.
+func spanNamed(t *testing.T, spans tracetest.SpanStubs, name string) tracetest.SpanStub {
+	t.Helper()
+
+	for _, span := range spans {
+		if span.Name == name {
+			return span
+		}
+	}
+	t.Fatalf("no span named %q in %v", name, spanNames(spans))
+	return tracetest.SpanStub{}
+}
+
+func spanNames(spans tracetest.SpanStubs) []string {
+	names := make([]string, 0, len(spans))
+	for _, span := range spans {
+		names = append(names, span.Name)
+	}
+	return names
+}
+
+// childrenOf returns the spans whose parent is span.
+func childrenOf(spans tracetest.SpanStubs, span tracetest.SpanStub) tracetest.SpanStubs {
+	var children tracetest.SpanStubs
+	for _, child := range spans {
+		if child.Parent.SpanID() == span.SpanContext.SpanID() {
+			children = append(children, child)
+		}
+	}
+	return children
+}
+
+func TestSpanHierarchy(t *testing.T) {
+	app, exporter := newTestApp(t)
+
+	if code := serve(app, http.MethodPost, "/api/traced_note", `{"title":"hello"}`, nil); code != http.StatusCreated {
+		t.Fatalf("create returned %d", code)
+	}
+
+	spans := exporter.GetSpans()
+	request := spanNamed(t, spans, "POST /api/traced_note")
+	serviceSpan := spanNamed(t, spans, "modelService.Create")
+	repositorySpan := spanNamed(t, spans, "GenericRepository.Create")
+	statement := spanNamed(t, spans, "gorm.create")
+
+	if request.SpanKind != trace.SpanKindServer || request.Parent.IsValid() {
+		t.Errorf("request span is a %v child of %v, want a root server span", request.SpanKind, request.Parent)
+	}
+	for _, link := range []struct {
+		child, parent tracetest.SpanStub
+	}{
+		{serviceSpan, request},
+		{repositorySpan, serviceSpan},
+		{statement, repositorySpan},
+	} {
+		if link.child.Parent.SpanID() != link.parent.SpanContext.SpanID() {
+			t.Errorf("%s is a child of %v, want %s", link.child.Name, link.child.Parent.SpanID(), link.parent.Name)
+		}
+		if link.child.SpanContext.TraceID() != request.SpanContext.TraceID() {
+			t.Errorf("%s is not in the trace of the request", link.child.Name)
+		}
+	}
+	if statement.SpanKind != trace.SpanKindClient {
+		t.Errorf("statement span is a %v span, want a client span", statement.SpanKind)
+	}
+
+	attributes := make(map[string]string)
+	for _, attribute := range request.Attributes {
+		attributes[string(attribute.Key)] = attribute.Value.Emit()
+	}
+	if attributes["origin.model"] != "traced_note" || attributes["origin.operation"] != "create" ||
+		attributes["http.route"] != "/api/traced_note" || attributes["http.response.status_code"] != "201" {
+		t.Errorf("unexpected request attributes %v", attributes)
+	}
+
+	attributes = make(map[string]string)
+	for _, attribute := range statement.Attributes {
+		attributes[string(attribute.Key)] = attribute.Value.Emit()
+	}
+	if !strings.HasPrefix(attributes["db.query.text"], "INSERT INTO `traced_notes`") ||
+		attributes["db.rows_affected"] != "1" || attributes["db.collection.name"] != "traced_notes" {
+		t.Errorf("unexpected statement attributes %v", attributes)
+	}
+}
+
+func TestMissingRecordSpans(t *testing.T) {
+	app, exporter := newTestApp(t)
+
+	if code := serve(app, http.MethodGet, "/api/traced_note/42", "", nil); code != http.StatusBadRequest {
+		t.Fatalf("get returned %d", code)
+	}
+
+	spans := exporter.GetSpans()
+	// The missing record is expected by the repository and its statement, the service fails the request.
+	for _, name := range []string{"GenericRepository.GetByID", "gorm.query"} {
+		if span := spanNamed(t, spans, name); span.Status.Code == codes.Error {
+			t.Errorf("%s reports the missing record as an error", name)
+		}
+	}
+	if span := spanNamed(t, spans, "modelService.GetByID"); span.Status.Code != codes.Error {
+		t.Errorf("service span has status %v, want an error", span.Status)
+	}
+	// The service answers missing records with a client error, which is not a server error.
+	if span := spanNamed(t, spans, "GET /api/traced_note/{id}"); span.Status.Code == codes.Error {
+		t.Error("request span reports a client error as an error")
+	}
+}
+
+func TestMiddlewareContinuesIncomingTrace(t *testing.T) {
+	app, exporter := newTestApp(t)
+
+	const (
+		traceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+		spanID  = "00f067aa0ba902b7"
+	)
+	header := http.Header{"Traceparent": {"00-" + traceID + "-" + spanID + "-01"}}
+	if code := serve(app, http.MethodGet, "/api/traced_note", "", header); code != http.StatusOK {
+		t.Fatalf("list returned %d", code)
+	}
+
+	spans := exporter.GetSpans()
+	request := spanNamed(t, spans, "GET /api/traced_note")
+	if request.SpanContext.TraceID().String() != traceID {
+		t.Errorf("request span is in trace %s, want %s", request.SpanContext.TraceID(), traceID)
+	}
+	if !request.Parent.IsRemote() || request.Parent.SpanID().String() != spanID {
+		t.Errorf("request span is a child of %v, want the remote span %s", request.Parent, spanID)
+	}
+	for _, span := range spans {
+		if span.SpanContext.TraceID().String() != traceID {
+			t.Errorf("%s is in trace %s, want %s", span.Name, span.SpanContext.TraceID(), traceID)
+		}
+	}
+	if len(childrenOf(spans, request)) != 1 {
+		t.Errorf("request span has children %v, want the span of the service", spanNames(childrenOf(spans, request)))
+	}
+}
+
+func TestMiddlewareFollowsIncomingSampling(t *testing.T) {
+	app, exporter := newTestApp(t)
+
+	// The caller did not sample the trace, its spans are not recorded.
+	header := http.Header{"Traceparent": {"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00"}}
+	serve(app, http.MethodGet, "/api/traced_note", "", header)
+	if spans := exporter.GetSpans(); len(spans) != 0 {
+		t.Errorf("exported spans %v of an unsampled trace", spanNames(spans))
+	}
+}
+
+func TestGormPluginIgnoresUntracedStatements(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	t.Cleanup(func() { _ = provider.Shutdown(context.Background()) })
+
+	db := testdb.Open(t)
+	if err := db.Use(NewGormPlugin(WithTracerProvider(provider))); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := db.AutoMigrate(&tracedNote{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Create(&tracedNote{Title: "hello"}).Error; err != nil {
+		t.Fatal(err)
+	}
+	if spans := exporter.GetSpans(); len(spans) != 0 {
+		t.Errorf("traced statements %v outside of a trace", spanNames(spans))
+	}
+
+	ctx, span := provider.Tracer("test").Start(context.Background(), "parent")
+	var notes []tracedNote
+	if err := db.WithContext(ctx).Find(&notes).Error; err != nil {
+		t.Fatal(err)
+	}
+	span.End()
+	statement := spanNamed(t, exporter.GetSpans(), "gorm.query")
+	if statement.Parent.SpanID() != span.SpanContext().SpanID() {
+		t.Error("statement span is not a child of the span of its context")
+	}
+}